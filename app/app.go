@@ -47,9 +47,15 @@ var logoStr = []string{
 
 const usageStr = `
 Usage: cubit [options]
+       cubit import [options] <file>
+       cubit export [options]
 
 Server Options:
 	-c, --config <file>   Configuration file path
+Import/Export Options:
+	-f, --format <format> Site configuration format, yaml or csv (inferred from file extension)
+	--dry-run             Show import changes without applying them
+	-o, --output <file>   Export output file path (standard output by default)
 Common Options:
 	-h, --help            Show this message
 	-v, --version         Show version
@@ -82,6 +88,9 @@ func New(output io.Writer, args []string) *Application {
 
 // Run runs Jabber/XMPP application until either a stop signal is received or an error occurs.
 func (a *Application) Run() error {
+	if len(a.args) > 1 && (a.args[1] == importCmd || a.args[1] == exportCmd) {
+		return a.runProvision(a.args[1], a.args[2:])
+	}
 	var configFile string
 	var showVersion, showUsage bool
 
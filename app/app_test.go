@@ -3,6 +3,7 @@ package app
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"sync"
 	"syscall"
@@ -86,3 +87,22 @@ func expectedUsageString() string {
 	r += fmt.Sprintf("%s\n", usageStr)
 	return r
 }
+
+func TestApp_Provision(t *testing.T) {
+	w := newWriterBuffer()
+	err := New(w, []string{"./cubit", "export", "--config=../data/basic.yml", "--format=csv"}).Run()
+	require.Nil(t, err)
+	require.Equal(t, "kind,room,name,type,in,broadcast,route,protocol,codec,resolution,framerate,bitrate,latency,serial,username\n", w.String())
+
+	siteFile := "ut.site.yml"
+	_ = ioutil.WriteFile(siteFile, []byte("rooms:\n  - username: room01\n    name: Room 01\n    type: normal\n"), 0644)
+	defer os.Remove(siteFile)
+
+	w = newWriterBuffer()
+	err = New(w, []string{"./cubit", "import", "--config=../data/basic.yml", "--dry-run", siteFile}).Run()
+	require.Nil(t, err)
+	require.Equal(t, "+ room room01\n1 change(s) to be applied\n", w.String())
+
+	err = New(newWriterBuffer(), []string{"./cubit", "import", "--config=../data/basic.yml"}).Run()
+	require.NotNil(t, err)
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dantin/cubit/provision"
+	"github.com/dantin/cubit/storage"
+)
+
+const (
+	importCmd = "import"
+	exportCmd = "export"
+)
+
+// runProvision runs a site configuration import or export subcommand.
+func (a *Application) runProvision(cmd string, args []string) error {
+	var configFile, formatStr, outFile string
+	var dryRun bool
+
+	fs := flag.NewFlagSet("cubit "+cmd, flag.ContinueOnError)
+	fs.SetOutput(a.output)
+
+	fs.StringVar(&configFile, "config", "/etc/cubit/cubit.yml", "Configuration file path.")
+	fs.StringVar(&configFile, "c", "/etc/cubit/cubit.yml", "Configuration file path.")
+	fs.StringVar(&formatStr, "format", "", "Site configuration format (yaml or csv).")
+	fs.StringVar(&formatStr, "f", "", "Site configuration format (yaml or csv).")
+	if cmd == importCmd {
+		fs.BoolVar(&dryRun, "dry-run", false, "Show changes without applying them.")
+	} else {
+		fs.StringVar(&outFile, "output", "", "Output file path.")
+		fs.StringVar(&outFile, "o", "", "Output file path.")
+	}
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(a.output, "%s\n", usageStr)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var cfg Config
+	if err := cfg.FromFile(configFile); err != nil {
+		return err
+	}
+	repContainer, err := storage.New(&cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = repContainer.Close(context.Background()) }()
+
+	p := provision.New(repContainer.Room(), repContainer.User(), repContainer.Device())
+
+	if cmd == exportCmd {
+		return a.exportSite(p, outFile, formatStr)
+	}
+	if fs.NArg() != 1 {
+		return errors.New("import: site configuration file is required")
+	}
+	return a.importSite(p, fs.Arg(0), formatStr, dryRun)
+}
+
+func (a *Application) importSite(p *provision.Provisioner, inFile, formatStr string, dryRun bool) error {
+	format, err := siteFormat(formatStr, inFile)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	site, err := provision.Read(f, format)
+	if err != nil {
+		return err
+	}
+	changes, err := p.Import(context.Background(), site, dryRun)
+	for _, change := range changes {
+		_, _ = fmt.Fprintln(a.output, change.String())
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(changes) == 0:
+		_, _ = fmt.Fprintln(a.output, "no changes")
+	case dryRun:
+		_, _ = fmt.Fprintf(a.output, "%d change(s) to be applied\n", len(changes))
+	default:
+		_, _ = fmt.Fprintf(a.output, "%d change(s) applied\n", len(changes))
+	}
+	return nil
+}
+
+func (a *Application) exportSite(p *provision.Provisioner, outFile, formatStr string) error {
+	format, err := siteFormat(formatStr, outFile)
+	if err != nil {
+		return err
+	}
+	site, err := p.Export(context.Background())
+	if err != nil {
+		return err
+	}
+	var w io.Writer = a.output
+	if len(outFile) > 0 {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		w = f
+	}
+	return provision.Write(w, site, format)
+}
+
+// siteFormat returns the explicitly requested format, or infers it from filename. YAML is used by default.
+func siteFormat(formatStr, filename string) (provision.Format, error) {
+	if len(formatStr) > 0 {
+		format := provision.ParseFormatString(formatStr)
+		if format == 0 {
+			return 0, fmt.Errorf("unsupported site configuration format: %s", formatStr)
+		}
+		return format, nil
+	}
+	if format := provision.FormatOf(filename); format != 0 {
+		return format, nil
+	}
+	return provision.YAML, nil
+}
@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/dantin/cubit/log"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	"github.com/dantin/cubit/storage/repository"
+)
+
+// Emit appends an event to rep audit log, stamping it with current time if not set.
+// A nil repository disables emission, and emission failures are logged, so that
+// audited operations are never interrupted by them.
+func Emit(ctx context.Context, rep repository.Audit, event *auditmodel.Event) {
+	if rep == nil {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if err := rep.AppendAuditEvent(ctx, event); err != nil {
+		log.Errorf("audit: failed to record %s event by %s: %v", event.Action, event.Actor, err)
+	}
+}
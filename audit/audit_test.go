@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	auditmodel "github.com/dantin/cubit/model/audit"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit_Emit(t *testing.T) {
+	rep := memorystorage.NewAudit()
+
+	// disabled
+	Emit(context.Background(), nil, &auditmodel.Event{Actor: "admin", Action: auditmodel.RoomCreate})
+
+	Emit(context.Background(), rep, &auditmodel.Event{Actor: "admin", Action: auditmodel.RoomCreate, RoomID: 1})
+
+	memorystorage.EnableMockedError()
+	Emit(context.Background(), rep, &auditmodel.Event{Actor: "admin", Action: auditmodel.RoomDelete, RoomID: 1})
+	memorystorage.DisableMockedError()
+
+	events, err := rep.FetchAuditEvents(context.Background(), &auditmodel.Filter{})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, int64(1), events[0].Seq)
+	require.Equal(t, auditmodel.RoomCreate, events[0].Action)
+	require.False(t, events[0].Timestamp.IsZero())
+}
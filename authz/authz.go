@@ -0,0 +1,100 @@
+package authz
+
+import (
+	"context"
+	"sort"
+
+	"github.com/dantin/cubit/log"
+	"github.com/dantin/cubit/model"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	"github.com/dantin/cubit/storage/repository"
+)
+
+// Authorizer resolves user permissions out of the role assigned to them.
+type Authorizer struct {
+	userRep repository.User
+	roleRep repository.Role
+}
+
+// New returns an authorizer instance.
+func New(userRep repository.User, roleRep repository.Role) *Authorizer {
+	return &Authorizer{userRep: userRep, roleRep: roleRep}
+}
+
+// Role returns a role definition, that is, the stored one or its built-in default otherwise.
+// Root role always grants every permission regardless of its stored definition.
+// A nil role is returned if its name is malformed, or it's neither stored nor built-in.
+func (a *Authorizer) Role(ctx context.Context, name string) (*rolesmodel.Role, error) {
+	if name == model.Root.String() {
+		return rolesmodel.BuiltIn(name), nil
+	}
+	if !model.IsValidRoleName(name) {
+		return nil, nil
+	}
+	role, err := a.roleRep.FetchRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if role != nil {
+		return role, nil
+	}
+	return rolesmodel.BuiltIn(name), nil
+}
+
+// Roles returns every stored role definition, along with built-in ones not being overridden, sorted by name.
+func (a *Authorizer) Roles(ctx context.Context) ([]rolesmodel.Role, error) {
+	stored, err := a.roleRep.FetchRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []rolesmodel.Role
+	names := make(map[string]struct{})
+	for _, role := range stored {
+		if role.Name == model.Root.String() {
+			continue
+		}
+		names[role.Name] = struct{}{}
+		res = append(res, role)
+	}
+	for _, name := range []model.Role{model.Root, model.Admin, model.Usr} {
+		if _, ok := names[name.String()]; !ok {
+			res = append(res, *rolesmodel.BuiltIn(name.String()))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// Permissions returns the permission set granted to a user.
+func (a *Authorizer) Permissions(ctx context.Context, username string) ([]rolesmodel.Permission, error) {
+	user, err := a.userRep.FetchUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Role == model.Unknown {
+		return nil, nil
+	}
+	role, err := a.Role(ctx, user.Role.String())
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		log.Warnf("authz: user %s refers to undefined role %s", username, user.Role.String())
+		return nil, nil
+	}
+	return role.Permissions, nil
+}
+
+// IsAllowed tells whether or not a user has been granted a given permission.
+func (a *Authorizer) IsAllowed(ctx context.Context, username string, perm rolesmodel.Permission) (bool, error) {
+	perms, err := a.Permissions(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range perms {
+		if p == perm {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,79 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizer_IsAllowed(t *testing.T) {
+	userRep := memorystorage.NewUser()
+	roleRep := memorystorage.NewRole()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "root", Role: model.Root})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "bob", Role: "operator"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "carol"})
+
+	a := New(userRep, roleRep)
+
+	var requireAllowed = func(username string, perm rolesmodel.Permission, allowed bool) {
+		ok, err := a.IsAllowed(context.Background(), username, perm)
+		require.Nil(t, err)
+		require.Equal(t, allowed, ok, "%s: %s", username, perm)
+	}
+	// built-in roles
+	requireAllowed("root", rolesmodel.RoomsList, true)
+	requireAllowed("root", rolesmodel.RolesEdit, true)
+	requireAllowed("admin", rolesmodel.RoomsEdit, true)
+	requireAllowed("alice", rolesmodel.QCReview, true)
+	requireAllowed("alice", rolesmodel.RoomsList, false)
+
+	// undefined role, no role and unknown user
+	requireAllowed("bob", rolesmodel.RoomsList, false)
+	requireAllowed("carol", rolesmodel.QCReview, false)
+	requireAllowed("dave", rolesmodel.QCReview, false)
+
+	// stored roles
+	_ = roleRep.UpsertRole(context.Background(), &rolesmodel.Role{Name: "operator", Permissions: []rolesmodel.Permission{rolesmodel.RoomsList}})
+	_ = roleRep.UpsertRole(context.Background(), &rolesmodel.Role{Name: "user", Permissions: []rolesmodel.Permission{rolesmodel.RoomsList}})
+	_ = roleRep.UpsertRole(context.Background(), &rolesmodel.Role{Name: "root"})
+
+	requireAllowed("bob", rolesmodel.RoomsList, true)
+	requireAllowed("alice", rolesmodel.RoomsList, true)
+	requireAllowed("alice", rolesmodel.QCReview, false)
+	requireAllowed("root", rolesmodel.RoomsList, true)
+
+	memorystorage.EnableMockedError()
+	_, err := a.IsAllowed(context.Background(), "alice", rolesmodel.RoomsList)
+	require.Equal(t, memorystorage.ErrMocked, err)
+	memorystorage.DisableMockedError()
+}
+
+func TestAuthorizer_Roles(t *testing.T) {
+	roleRep := memorystorage.NewRole()
+	_ = roleRep.UpsertRole(context.Background(), &rolesmodel.Role{Name: "operator", Permissions: []rolesmodel.Permission{rolesmodel.RoomsList}})
+	_ = roleRep.UpsertRole(context.Background(), &rolesmodel.Role{Name: "user", Permissions: []rolesmodel.Permission{rolesmodel.RoomsList}})
+	_ = roleRep.UpsertRole(context.Background(), &rolesmodel.Role{Name: "root"})
+
+	a := New(memorystorage.NewUser(), roleRep)
+
+	roles, err := a.Roles(context.Background())
+	require.Nil(t, err)
+	require.Len(t, roles, 4)
+	require.Equal(t, "admin", roles[0].Name)
+	require.Equal(t, "operator", roles[1].Name)
+	require.Equal(t, "root", roles[2].Name)
+	require.Equal(t, rolesmodel.Permissions, roles[2].Permissions)
+	require.Equal(t, "user", roles[3].Name)
+	require.Equal(t, []rolesmodel.Permission{rolesmodel.RoomsList}, roles[3].Permissions)
+
+	role, err := a.Role(context.Background(), "none")
+	require.Nil(t, err)
+	require.Nil(t, role)
+}
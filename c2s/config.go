@@ -2,12 +2,14 @@ package c2s
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
 	"github.com/dantin/cubit/stream"
 	"github.com/dantin/cubit/transport"
 	"github.com/dantin/cubit/transport/compress"
+	"github.com/dantin/cubit/transport/proxyproto"
 )
 
 const (
@@ -17,6 +19,12 @@ const (
 	defaultTransportPort      = 5222
 	defaultTransportKeepAlive = time.Duration(120) * time.Second
 	defaultTransportURLPath   = "/xmpp/ws"
+	defaultBOSHURLPath        = "/http-bind"
+	defaultBOSHWait           = time.Duration(60) * time.Second
+	defaultBOSHHold           = 1
+	defaultBOSHInactivity     = time.Duration(60) * time.Second
+	defaultBOSHMaxPause       = time.Duration(120) * time.Second
+	defaultBOSHPolling        = time.Duration(2) * time.Second
 )
 
 // ResourceConflictPolicy represents a resource conflict policy.
@@ -69,14 +77,33 @@ type TransportConfig struct {
 	BindAddress string
 	Port        int
 	URLPath     string
+
+	// KeepAlive defines websocket ping interval, half stream keep alive if not set.
+	KeepAlive time.Duration
+
+	// TLS defines whether or not websocket and bosh connections are secured using host certificates.
+	TLS bool
+
+	// DirectTLS defines whether or not socket connections start with a TLS handshake (XEP-0368).
+	DirectTLS bool
+
+	// ProxyProtocol defines whether or not connections from trusted proxies start with a PROXY protocol header.
+	ProxyProtocol bool
+
+	// TrustedProxies defines the networks allowed to send a PROXY protocol header.
+	TrustedProxies []*net.IPNet
 }
 
 type transportProxyType struct {
-	Type        string `yaml:"type"`
-	BindAddress string `yaml:"bind_addr"`
-	Port        int    `yaml:"port"`
-	KeepAlive   int    `yaml:"keep_alive"`
-	URLPath     string `yaml:"url_path"`
+	Type           string   `yaml:"type"`
+	BindAddress    string   `yaml:"bind_addr"`
+	Port           int      `yaml:"port"`
+	KeepAlive      int      `yaml:"keep_alive"`
+	URLPath        string   `yaml:"url_path"`
+	TLS            bool     `yaml:"tls"`
+	DirectTLS      bool     `yaml:"direct_tls"`
+	ProxyProtocol  bool     `yaml:"proxy_protocol"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -89,14 +116,45 @@ func (t *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	switch p.Type {
 	case "", "socket":
 		t.Type = transport.Socket
+	case "websocket":
+		t.Type = transport.WebSocket
+	case "bosh":
+		t.Type = transport.BOSH
 	default:
 		return fmt.Errorf("c2s.TransportConfig: unrecognized transport type: %s", p.Type)
 	}
+	if p.KeepAlive < 0 {
+		return fmt.Errorf("c2s.TransportConfig: keep alive must be 0 or higher")
+	}
+	if p.TLS && t.Type == transport.Socket {
+		return fmt.Errorf("c2s.TransportConfig: tls option is only available for websocket and bosh transports")
+	}
+	if p.DirectTLS && t.Type != transport.Socket {
+		return fmt.Errorf("c2s.TransportConfig: direct_tls option is only available for socket transport")
+	}
+	if len(p.URLPath) > 0 && !strings.HasPrefix(p.URLPath, "/") {
+		return fmt.Errorf("c2s.TransportConfig: invalid url path: %s", p.URLPath)
+	}
+	if p.ProxyProtocol && len(p.TrustedProxies) == 0 {
+		return fmt.Errorf("c2s.TransportConfig: proxy_protocol option requires at least one trusted proxy")
+	}
+	trustedProxies, err := proxyproto.ParseTrustedProxies(p.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("c2s.TransportConfig: %v", err)
+	}
 	t.BindAddress = p.BindAddress
 	t.Port = p.Port
 	t.URLPath = p.URLPath
+	t.KeepAlive = time.Duration(p.KeepAlive) * time.Second
+	t.TLS = p.TLS
+	t.DirectTLS = p.DirectTLS
+	t.ProxyProtocol = p.ProxyProtocol
+	t.TrustedProxies = trustedProxies
 	if len(t.URLPath) == 0 {
 		t.URLPath = defaultTransportURLPath
+		if t.Type == transport.BOSH {
+			t.URLPath = defaultBOSHURLPath
+		}
 	}
 
 	// assign transport's defaults
@@ -107,6 +165,40 @@ func (t *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	return nil
 }
 
+// BOSHConfig represents a BOSH connection manager configuration.
+type BOSHConfig struct {
+	Wait       time.Duration
+	Hold       int
+	Inactivity time.Duration
+	MaxPause   time.Duration
+	Polling    time.Duration
+}
+
+type boshProxyType struct {
+	Wait       int `yaml:"max_wait"`
+	Hold       int `yaml:"max_hold"`
+	Inactivity int `yaml:"inactivity"`
+	MaxPause   int `yaml:"max_pause"`
+	Polling    int `yaml:"polling"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (b *BOSHConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := boshProxyType{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if p.Wait < 0 || p.Hold < 0 || p.Inactivity < 0 || p.MaxPause < 0 || p.Polling < 0 {
+		return fmt.Errorf("c2s.BOSHConfig: values must be 0 or higher")
+	}
+	b.Wait = time.Duration(p.Wait) * time.Second
+	b.Hold = p.Hold
+	b.Inactivity = time.Duration(p.Inactivity) * time.Second
+	b.MaxPause = time.Duration(p.MaxPause) * time.Second
+	b.Polling = time.Duration(p.Polling) * time.Second
+	return nil
+}
+
 // TLSConfig represents a server TLS configuration.
 type TLSConfig struct {
 	CertFile    string `yaml:"cert_path"`
@@ -122,6 +214,7 @@ type Config struct {
 	MaxStanzaSize    int
 	ResourceConflict ResourceConflictPolicy
 	Transport        TransportConfig
+	BOSH             BOSHConfig
 	SASL             []string
 	Compression      CompressConfig
 }
@@ -136,6 +229,7 @@ type configProxy struct {
 	MaxStanzaSize    int             `yaml:"max_stanza_size"`
 	ResourceConflict string          `yaml:"resource_conflict"`
 	Transport        TransportConfig `yaml:"transport"`
+	BOSH             BOSHConfig      `yaml:"bosh"`
 	SASL             []string        `yaml:"sasl"`
 	Compression      CompressConfig  `yaml:"compression"`
 }
@@ -186,6 +280,22 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		}
 	}
 	cfg.Transport = p.Transport
+	cfg.BOSH = p.BOSH
+	if cfg.BOSH.Wait == 0 {
+		cfg.BOSH.Wait = defaultBOSHWait
+	}
+	if cfg.BOSH.Hold == 0 {
+		cfg.BOSH.Hold = defaultBOSHHold
+	}
+	if cfg.BOSH.Inactivity == 0 {
+		cfg.BOSH.Inactivity = defaultBOSHInactivity
+	}
+	if cfg.BOSH.MaxPause == 0 {
+		cfg.BOSH.MaxPause = defaultBOSHMaxPause
+	}
+	if cfg.BOSH.Polling == 0 {
+		cfg.BOSH.Polling = defaultBOSHPolling
+	}
 	cfg.SASL = p.SASL
 	cfg.Compression = p.Compression
 
@@ -198,6 +308,9 @@ type streamConfig struct {
 	keepAlive        time.Duration
 	maxStanzaSize    int
 	resourceConflict ResourceConflictPolicy
+	tls              bool
+	directTLS        bool
+	remoteAddr       net.Addr
 	sasl             []string
 	compression      CompressConfig
 	onDisconnect     func(s stream.C2S)
@@ -3,6 +3,7 @@ package c2s
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/dantin/cubit/transport"
 	"github.com/dantin/cubit/transport/compress"
@@ -39,6 +40,75 @@ func TestTransportConfig(t *testing.T) {
 	require.Equal(t, transport.Socket, s.Type)
 	require.Equal(t, "0.0.0.0", s.BindAddress)
 	require.Equal(t, 5222, s.Port)
+
+	s = TransportConfig{}
+	err = yaml.Unmarshal([]byte("{type: websocket, port: 5280, keep_alive: 30, tls: true}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, transport.WebSocket, s.Type)
+	require.Equal(t, defaultTransportURLPath, s.URLPath)
+	require.Equal(t, time.Second*30, s.KeepAlive)
+	require.True(t, s.TLS)
+
+	err = yaml.Unmarshal([]byte("{type: websocket, url_path: xmpp}"), &s)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{type: socket, tls: true}"), &s)
+	require.NotNil(t, err)
+
+	s = TransportConfig{}
+	err = yaml.Unmarshal([]byte("{type: bosh, port: 5280, tls: true}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, transport.BOSH, s.Type)
+	require.Equal(t, defaultBOSHURLPath, s.URLPath)
+	require.True(t, s.TLS)
+
+	s = TransportConfig{}
+	err = yaml.Unmarshal([]byte("{type: socket, port: 5223, direct_tls: true}"), &s)
+	require.Nil(t, err)
+	require.True(t, s.DirectTLS)
+
+	err = yaml.Unmarshal([]byte("{type: websocket, direct_tls: true}"), &s)
+	require.NotNil(t, err)
+
+	s = TransportConfig{}
+	err = yaml.Unmarshal([]byte("{type: socket, proxy_protocol: true, trusted_proxies: [10.0.0.0/8, 192.168.1.10]}"), &s)
+	require.Nil(t, err)
+	require.True(t, s.ProxyProtocol)
+	require.Len(t, s.TrustedProxies, 2)
+	require.Equal(t, "192.168.1.10/32", s.TrustedProxies[1].String())
+
+	err = yaml.Unmarshal([]byte("{type: socket, proxy_protocol: true}"), &s)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{type: socket, proxy_protocol: true, trusted_proxies: [10.0.0.0/99]}"), &s)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{type: unknown}"), &s)
+	require.NotNil(t, err)
+}
+
+func TestBOSHConfig(t *testing.T) {
+	b := BOSHConfig{}
+	err := yaml.Unmarshal([]byte("{max_wait: 30, max_hold: 2, inactivity: 45, max_pause: 90, polling: 5}"), &b)
+	require.Nil(t, err)
+	require.Equal(t, time.Second*30, b.Wait)
+	require.Equal(t, 2, b.Hold)
+	require.Equal(t, time.Second*45, b.Inactivity)
+	require.Equal(t, time.Second*90, b.MaxPause)
+	require.Equal(t, time.Second*5, b.Polling)
+
+	err = yaml.Unmarshal([]byte("{max_wait: -1}"), &b)
+	require.NotNil(t, err)
+
+	// defaults
+	s := Config{}
+	err = yaml.Unmarshal([]byte("{connect_timeout: 5}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, defaultBOSHWait, s.BOSH.Wait)
+	require.Equal(t, defaultBOSHHold, s.BOSH.Hold)
+	require.Equal(t, defaultBOSHInactivity, s.BOSH.Inactivity)
+	require.Equal(t, defaultBOSHMaxPause, s.BOSH.MaxPause)
+	require.Equal(t, defaultBOSHPolling, s.BOSH.Polling)
 }
 
 func TestConfig(t *testing.T) {
@@ -3,6 +3,7 @@ package c2s
 import (
 	"context"
 	"crypto/tls"
+	"net"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -74,7 +75,7 @@ func newStream(id string, config *streamConfig, tr transport.Transport, mods *mo
 	}
 
 	// initialize stream context
-	secured := !(tr.Type() == transport.Socket)
+	secured := (tr.Type() != transport.Socket && config.tls) || config.directTLS
 	s.setSecured(secured)
 	s.setJID(&jid.JID{})
 
@@ -149,6 +150,11 @@ func (s *inStream) IsAuthenticated() bool {
 	return s.authenticated
 }
 
+// RemoteAddr returns the network address of the connected client.
+func (s *inStream) RemoteAddr() net.Addr {
+	return s.cfg.remoteAddr
+}
+
 // IsSecured returns whether or not the XMPP stream has been secured using SSL/TLS.
 func (s *inStream) IsSecured() bool {
 	s.mu.RLock()
@@ -427,7 +433,8 @@ func (s *inStream) handleBound(ctx context.Context, elem xmpp.XElement) {
 }
 
 func (s *inStream) proceedStartTLS(ctx context.Context, elem xmpp.XElement) {
-	if s.IsSecured() {
+	// only socket transports can be upgraded to TLS
+	if s.IsSecured() || s.tr.Type() != transport.Socket {
 		s.disconnectWithStreamError(ctx, streamerror.ErrNotAuthorized)
 		return
 	}
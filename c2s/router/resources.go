@@ -65,6 +65,9 @@ func (r *resources) unbind(res string) {
 }
 
 func (r *resources) route(ctx context.Context, stanza xmpp.Stanza) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	toJID := stanza.ToJID()
 	if toJID.IsFullWithUser() {
 		for _, stm := range r.streams {
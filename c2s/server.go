@@ -2,8 +2,10 @@ package c2s
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"net/http"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -16,6 +18,7 @@ import (
 	"github.com/dantin/cubit/storage/repository"
 	"github.com/dantin/cubit/stream"
 	"github.com/dantin/cubit/transport"
+	"github.com/dantin/cubit/transport/proxyproto"
 )
 
 var listenerProvider = net.Listen
@@ -29,6 +32,8 @@ type server struct {
 	inConnectionsMu sync.Mutex
 	inConnections   map[string]stream.C2S
 	ln              net.Listener
+	httpSrv         *http.Server
+	boshHandler     *transport.BOSHHandler
 	stmSeq          uint64
 	listening       uint32
 }
@@ -55,6 +60,10 @@ func (s *server) start() {
 	switch s.cfg.Transport.Type {
 	case transport.Socket:
 		err = s.listenSocketConn(address)
+	case transport.WebSocket:
+		err = s.listenWebSocketConn(address)
+	case transport.BOSH:
+		err = s.listenBOSHConn(address)
 	}
 	if err != nil {
 		log.Fatalf("%v", err)
@@ -62,23 +71,120 @@ func (s *server) start() {
 }
 
 func (s *server) listenSocketConn(address string) error {
-	ln, err := listenerProvider("tcp", address)
+	ln, err := s.listen(address)
 	if err != nil {
 		return err
 	}
+	if s.cfg.Transport.DirectTLS {
+		ln = tls.NewListener(ln, &tls.Config{
+			GetCertificate: s.router.Hosts().GetCertificate,
+			NextProtos:     []string{"xmpp-client"},
+		})
+	}
 	s.ln = ln
 
 	atomic.StoreUint32(&s.listening, 1)
 	for atomic.LoadUint32(&s.listening) == 1 {
 		conn, err := ln.Accept()
 		if err == nil {
-			go s.startStream(transport.NewSocketTransport(conn), s.cfg.KeepAlive)
+			go func(conn net.Conn) {
+				// reading remote address might block until PROXY protocol header arrives
+				s.startStream(transport.NewSocketTransport(conn), conn.RemoteAddr(), s.cfg.KeepAlive)
+			}(conn)
 			continue
 		}
 	}
 	return nil
 }
 
+func (s *server) listenWebSocketConn(address string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.cfg.Transport.URLPath, s.handleWebSocket)
+	return s.listenHTTP(address, mux)
+}
+
+func (s *server) listenBOSHConn(address string) error {
+	s.boshHandler = transport.NewBOSHHandler(&transport.BOSHConfig{
+		Wait:           s.cfg.BOSH.Wait,
+		Hold:           s.cfg.BOSH.Hold,
+		Inactivity:     s.cfg.BOSH.Inactivity,
+		MaxPause:       s.cfg.BOSH.MaxPause,
+		Polling:        s.cfg.BOSH.Polling,
+		MaxRequestSize: s.cfg.MaxStanzaSize,
+	}, func(tr transport.Transport, r *http.Request) {
+		s.startStream(tr, httpRemoteAddr(r), s.boshKeepAlive())
+	})
+	mux := http.NewServeMux()
+	mux.Handle(s.cfg.Transport.URLPath, s.boshHandler)
+	return s.listenHTTP(address, mux)
+}
+
+// boshKeepAlive returns the longest time a BOSH stream may go without reading,
+// that is a fully held request followed by the longest allowed client silence.
+// Inactive sessions are otherwise terminated by the connection manager.
+func (s *server) boshKeepAlive() time.Duration {
+	silence := s.cfg.BOSH.Inactivity
+	if s.cfg.BOSH.MaxPause > silence {
+		silence = s.cfg.BOSH.MaxPause
+	}
+	keepAlive := s.cfg.BOSH.Wait + silence
+	if s.cfg.KeepAlive > keepAlive {
+		keepAlive = s.cfg.KeepAlive
+	}
+	return keepAlive
+}
+
+func (s *server) listenHTTP(address string, handler http.Handler) error {
+	ln, err := s.listen(address)
+	if err != nil {
+		return err
+	}
+	if s.cfg.Transport.TLS {
+		ln = tls.NewListener(ln, &tls.Config{
+			GetCertificate: s.router.Hosts().GetCertificate,
+			NextProtos:     []string{"http/1.1"},
+		})
+	}
+	s.httpSrv = &http.Server{Handler: handler}
+
+	atomic.StoreUint32(&s.listening, 1)
+	if err := s.httpSrv.Serve(ln); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *server) listen(address string) (net.Listener, error) {
+	ln, err := listenerProvider("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.Transport.ProxyProtocol {
+		ln = proxyproto.NewListener(ln, &proxyproto.Config{
+			TrustedProxies: s.cfg.Transport.TrustedProxies,
+			HeaderTimeout:  s.cfg.ConnectTimeout,
+		})
+	}
+	return ln, nil
+}
+
+func (s *server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	keepAlive := s.cfg.Transport.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = s.cfg.KeepAlive / 2
+	}
+	tr, err := transport.NewWebSocketTransport(w, r, &transport.WebSocketConfig{
+		KeepAlive:        keepAlive,
+		MaxMessageSize:   s.cfg.MaxStanzaSize,
+		CompressionLevel: s.cfg.Compression.Level,
+	})
+	if err != nil {
+		log.Warnf("%s: failed to upgrade websocket connection from %s: %v", s.cfg.ID, r.RemoteAddr, err)
+		return
+	}
+	s.startStream(tr, httpRemoteAddr(r), s.cfg.KeepAlive)
+}
+
 func (s *server) shutdown(ctx context.Context) error {
 	if atomic.CompareAndSwapUint32(&s.listening, 1, 0) {
 		// stop listening
@@ -87,6 +193,16 @@ func (s *server) shutdown(ctx context.Context) error {
 			if err := s.ln.Close(); err != nil {
 				return err
 			}
+		case transport.WebSocket:
+			if err := s.httpSrv.Shutdown(ctx); err != nil {
+				return err
+			}
+		case transport.BOSH:
+			// release held requests before waiting for them to complete
+			s.boshHandler.Close()
+			if err := s.httpSrv.Shutdown(ctx); err != nil {
+				return err
+			}
 		}
 		// close all connections
 		c, err := s.closeConnections(ctx)
@@ -98,11 +214,14 @@ func (s *server) shutdown(ctx context.Context) error {
 	return nil
 }
 
-func (s *server) startStream(tr transport.Transport, keepAlive time.Duration) {
+func (s *server) startStream(tr transport.Transport, remoteAddr net.Addr, keepAlive time.Duration) {
 	cfg := &streamConfig{
 		resourceConflict: s.cfg.ResourceConflict,
+		tls:              s.cfg.Transport.TLS,
+		directTLS:        s.cfg.Transport.DirectTLS,
+		remoteAddr:       remoteAddr,
 		connectTimeout:   s.cfg.ConnectTimeout,
-		keepAlive:        s.cfg.KeepAlive,
+		keepAlive:        keepAlive,
 		timeout:          s.cfg.Timeout,
 		maxStanzaSize:    s.cfg.MaxStanzaSize,
 		sasl:             s.cfg.SASL,
@@ -118,7 +237,7 @@ func (s *server) registerStream(stm stream.C2S) {
 	s.inConnections[stm.ID()] = stm
 	s.inConnectionsMu.Unlock()
 
-	log.Infof("registered c2s stream... (id: %s)", stm.ID())
+	log.Infof("registered c2s stream... (id: %s, addr: %v)", stm.ID(), stm.RemoteAddr())
 }
 
 func (s *server) unregisterStream(stm stream.C2S) {
@@ -134,8 +253,15 @@ func (s *server) nextID() string {
 }
 
 func (s *server) closeConnections(ctx context.Context) (count int, err error) {
+	// streams unregister themselves on disconnection
 	s.inConnectionsMu.Lock()
+	stms := make([]stream.C2S, 0, len(s.inConnections))
 	for _, stm := range s.inConnections {
+		stms = append(stms, stm)
+	}
+	s.inConnectionsMu.Unlock()
+
+	for _, stm := range stms {
 		select {
 		case <-closeConn(ctx, stm):
 			count++
@@ -143,7 +269,6 @@ func (s *server) closeConnections(ctx context.Context) (count int, err error) {
 			return 0, ctx.Err()
 		}
 	}
-	s.inConnectionsMu.Unlock()
 	return count, nil
 }
 
@@ -155,3 +280,13 @@ func closeConn(ctx context.Context, stm stream.InStream) <-chan bool {
 	}()
 	return c
 }
+
+// httpRemoteAddr returns the address of the client that sent the request,
+// as seen through PROXY protocol if enabled.
+func httpRemoteAddr(r *http.Request) net.Addr {
+	addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return addr
+}
@@ -1,14 +1,29 @@
 package c2s
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
 	"net"
+	"net/http"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	c2srouter "github.com/dantin/cubit/c2s/router"
 	"github.com/dantin/cubit/module"
+	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/router/host"
+	memorystorage "github.com/dantin/cubit/storage/memory"
 	"github.com/dantin/cubit/stream"
 	"github.com/dantin/cubit/transport"
+	"github.com/dantin/cubit/transport/proxyproto"
+	utiltls "github.com/dantin/cubit/util/tls"
+	"github.com/dantin/cubit/xmpp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -62,3 +77,289 @@ func TestC2SSocketServer(t *testing.T) {
 	err := <-errCh
 	require.Nil(t, err)
 }
+
+func TestC2SDirectTLSServer(t *testing.T) {
+	defer func() { _ = os.RemoveAll("./.cert") }()
+
+	cer, err := utiltls.LoadCertificate("", "", "localhost")
+	require.Nil(t, err)
+	hosts, _ := host.New([]host.Config{{Name: "localhost", Certificate: cer}})
+	r, _ := router.New(hosts, c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()), nil)
+
+	cfg := Config{
+		ID:               "srv-123456",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		SASL:             []string{"plain"},
+		Transport: TransportConfig{
+			Type:      transport.Socket,
+			Port:      9996,
+			DirectTLS: true,
+		},
+	}
+	srv := server{
+		cfg:           &cfg,
+		router:        r,
+		mods:          &module.Modules{},
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+	time.Sleep(time.Millisecond * 150)
+
+	// unsupported application protocol
+	_, err = tls.Dial("tcp", "127.0.0.1:9996", &tls.Config{ServerName: "localhost", InsecureSkipVerify: true, NextProtos: []string{"http/1.1"}})
+	require.NotNil(t, err)
+
+	conn, err := tls.Dial("tcp", "127.0.0.1:9996", &tls.Config{ServerName: "localhost", InsecureSkipVerify: true, NextProtos: []string{"xmpp-client"}})
+	require.Nil(t, err)
+	defer func() { _ = conn.Close() }()
+
+	st := conn.ConnectionState()
+	require.Equal(t, "xmpp-client", st.NegotiatedProtocol)
+	require.Equal(t, cer.Certificate[0], st.PeerCertificates[0].Raw)
+
+	_, err = conn.Write([]byte(`<stream:stream xmlns="jabber:client" xmlns:stream="http://etherx.jabber.org/streams" to="localhost" version="1.0">`))
+	require.Nil(t, err)
+
+	// stream is already secured
+	pr := xmpp.NewParser(conn, xmpp.SocketStream, 0)
+	elem, err := pr.ParseElement()
+	for err == nil && elem == nil { // skip xml declaration
+		elem, err = pr.ParseElement()
+	}
+	require.Nil(t, err)
+	require.Equal(t, "stream:stream", elem.Name())
+
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "stream:features", elem.Name())
+	require.Nil(t, elem.Elements().Child("starttls"))
+	require.NotNil(t, elem.Elements().Child("mechanisms"))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+	defer cancel()
+	require.Nil(t, srv.shutdown(ctx))
+}
+
+func TestC2SProxyProtocolServer(t *testing.T) {
+	r, _, _ := setupTest("localhost")
+
+	trusted, _ := proxyproto.ParseTrustedProxies([]string{"127.0.0.1"})
+	cfg := Config{
+		ID:               "srv-1234567",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type:           transport.Socket,
+			Port:           9995,
+			ProxyProtocol:  true,
+			TrustedProxies: trusted,
+		},
+	}
+	srv := server{
+		cfg:           &cfg,
+		router:        r,
+		mods:          &module.Modules{},
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+	time.Sleep(time.Millisecond * 150)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:9995")
+	require.Nil(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 5222\r\n"))
+	require.Nil(t, err)
+	_, err = conn.Write([]byte(`<stream:stream xmlns="jabber:client" xmlns:stream="http://etherx.jabber.org/streams" to="localhost" version="1.0">`))
+	require.Nil(t, err)
+
+	pr := xmpp.NewParser(conn, xmpp.SocketStream, 0)
+	var elem xmpp.XElement
+	for elem == nil || elem.Name() != "stream:features" {
+		elem, err = pr.ParseElement()
+		require.Nil(t, err)
+	}
+	// stream exposes client address
+	srv.inConnectionsMu.Lock()
+	require.Len(t, srv.inConnections, 1)
+	for _, stm := range srv.inConnections {
+		require.Equal(t, "192.168.0.1:56324", stm.RemoteAddr().String())
+	}
+	srv.inConnectionsMu.Unlock()
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+	defer cancel()
+	require.Nil(t, srv.shutdown(ctx))
+}
+
+func TestC2SWebSocketServer(t *testing.T) {
+	r, _, _ := setupTest("localhost")
+
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type:    transport.WebSocket,
+			Port:    9999,
+			URLPath: "/xmpp/ws",
+		},
+	}
+	srv := server{
+		cfg:           &cfg,
+		router:        r,
+		mods:          &module.Modules{},
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+	time.Sleep(time.Millisecond * 150)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:9999")
+	require.Nil(t, err)
+	defer func() { _ = conn.Close() }()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:9999/xmpp/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Protocol", "xmpp")
+	require.Nil(t, req.Write(conn))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// open framed stream
+	open := []byte(`<open xmlns="urn:ietf:params:xml:ns:xmpp-framing" to="localhost" version="1.0"/>`)
+	frame := []byte{0x81, 0x80 | byte(len(open)), 0, 0, 0, 0} // zero mask
+	_, err = conn.Write(append(frame, open...))
+	require.Nil(t, err)
+
+	elem := readWebSocketElement(t, br)
+	require.Equal(t, "open", elem.Name())
+	require.Equal(t, "urn:ietf:params:xml:ns:xmpp-framing", elem.Namespace())
+
+	elem = readWebSocketElement(t, br)
+	require.Equal(t, "stream:features", elem.Name())
+	require.Nil(t, elem.Elements().Child("starttls"))
+
+	// plain websocket streams are not secured
+	srv.inConnectionsMu.Lock()
+	for _, stm := range srv.inConnections {
+		require.False(t, stm.IsSecured())
+	}
+	srv.inConnectionsMu.Unlock()
+
+	// unsolicited starttls is rejected
+	startTLS := []byte(`<starttls xmlns="urn:ietf:params:xml:ns:xmpp-tls"/>`)
+	frame = []byte{0x81, 0x80 | byte(len(startTLS)), 0, 0, 0, 0}
+	_, err = conn.Write(append(frame, startTLS...))
+	require.Nil(t, err)
+
+	elem = readWebSocketElement(t, br)
+	require.Equal(t, "stream:error", elem.Name())
+	require.NotNil(t, elem.Elements().Child("not-authorized"))
+
+	srv.inConnectionsMu.Lock()
+	for _, stm := range srv.inConnections {
+		require.False(t, stm.IsSecured())
+	}
+	srv.inConnectionsMu.Unlock()
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+	defer cancel()
+	require.Nil(t, srv.shutdown(ctx))
+}
+
+func TestC2SBOSHServer(t *testing.T) {
+	r, _, _ := setupTest("localhost")
+
+	cfg := Config{
+		ID:               "srv-12345",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		KeepAlive:        time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type:    transport.BOSH,
+			Port:    9997,
+			URLPath: "/http-bind",
+		},
+		BOSH: BOSHConfig{
+			Wait:       time.Second * time.Duration(30),
+			Hold:       1,
+			Inactivity: time.Second * time.Duration(30),
+			MaxPause:   time.Second * time.Duration(60),
+		},
+	}
+	srv := server{
+		cfg:           &cfg,
+		router:        r,
+		mods:          &module.Modules{},
+		inConnections: make(map[string]stream.C2S),
+	}
+	go srv.start()
+	time.Sleep(time.Millisecond * 150)
+
+	body := postBOSHBody(t, `<body xmlns="http://jabber.org/protocol/httpbind" to="localhost" rid="1" xmpp:version="1.0" xmlns:xmpp="urn:xmpp:xbosh"/>`)
+	sid := body.Attributes().Get("sid")
+	require.NotEqual(t, "", sid)
+
+	features := body.Elements().Child("stream:features")
+	require.NotNil(t, features)
+	require.Nil(t, features.Elements().Child("starttls"))
+
+	// held request is released on shutdown
+	bodyCh := make(chan xmpp.XElement, 1)
+	go func() {
+		bodyCh <- postBOSHBody(t, `<body xmlns="http://jabber.org/protocol/httpbind" sid="`+sid+`" rid="2"/>`)
+	}()
+	time.Sleep(time.Millisecond * 150)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+	defer cancel()
+	require.Nil(t, srv.shutdown(ctx))
+
+	body = <-bodyCh
+	require.Equal(t, "terminate", body.Type())
+}
+
+func postBOSHBody(t *testing.T, body string) xmpp.XElement {
+	resp, err := http.Post("http://127.0.0.1:9997/http-bind", "text/xml; charset=utf-8", strings.NewReader(body))
+	require.Nil(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	elem, err := xmpp.NewParser(resp.Body, xmpp.DefaultMode, 0).ParseElement()
+	require.Nil(t, err)
+	return elem
+}
+
+func readWebSocketElement(t *testing.T, br *bufio.Reader) xmpp.XElement {
+	var hdr [2]byte
+	_, err := io.ReadFull(br, hdr[:])
+	require.Nil(t, err)
+	require.Equal(t, byte(0x81), hdr[0]) // final text frame
+
+	length := int(hdr[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(br, payload)
+	require.Nil(t, err)
+
+	elem, err := xmpp.NewParser(bytes.NewReader(payload), xmpp.DefaultMode, 0).ParseElement()
+	require.Nil(t, err)
+	return elem
+}
@@ -18,8 +18,11 @@ CREATE TABLE IF NOT EXISTS users (
 -- roles
 
 CREATE TABLE IF NOT EXISTS roles (
-    id    BIGINT AUTO_INCREMENT PRIMARY KEY,
-    name  VARCHAR(256) NOT NULL
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name         VARCHAR(256) NOT NULL,
+    permissions  TEXT NOT NULL,
+
+    UNIQUE INDEX i_roles_name(name)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
 -- user_role
@@ -251,11 +254,135 @@ CREATE TABLE IF NOT EXISTS rooms (
     updated_at  DATETIME NOT NULL
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
+-- user_rooms
+
+CREATE TABLE IF NOT EXISTS user_rooms (
+    username   VARCHAR(256) NOT NULL,
+    room_id    BIGINT NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, room_id),
+
+    INDEX i_user_rooms_room_id (room_id)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- room_video_streams
+
 CREATE TABLE IF NOT EXISTS room_video_streams (
     id          BIGINT AUTO_INCREMENT PRIMARY KEY,
     input       VARCHAR(256) NOT NULL,  -- video input stream
     broadcast   VARCHAR(256) NOT NULL,  -- video broadcast stream
     routes      TEXT NOT NULL,          -- video stream redirect map in json
-    `type`      VARCHAR(32) NOT NULL,   -- [camera, device]
-    room_id     BIGINT NOT NULL
+    `type`      VARCHAR(32) NOT NULL,   -- [box, camera, device]
+    room_id     BIGINT NOT NULL,
+    protocol    VARCHAR(16) NOT NULL DEFAULT '',        -- [rtmp, hls, webrtc, rtsp, srt]
+    codec       VARCHAR(32) NOT NULL DEFAULT '',
+    width       INT NOT NULL DEFAULT 0,
+    height      INT NOT NULL DEFAULT 0,
+    framerate   DECIMAL(6,2) NOT NULL DEFAULT 0,
+    bitrate     INT NOT NULL DEFAULT 0,                 -- kbps
+    latency     VARCHAR(16) NOT NULL DEFAULT '',        -- [ultra_low, low, normal]
+
+    UNIQUE INDEX i_room_video_streams_room_id_type (room_id, `type`)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- devices
+
+CREATE TABLE IF NOT EXISTS devices (
+    serial      VARCHAR(64) PRIMARY KEY,
+    `type`      VARCHAR(32) NOT NULL,           -- [box, camera, device]
+    room_id     BIGINT NOT NULL DEFAULT 0,      -- bound room, 0 if unbound
+    username    VARCHAR(256) NOT NULL DEFAULT '', -- issued XMPP account
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL,
+
+    INDEX i_devices_room_id (room_id),
+    INDEX i_devices_username (username)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- qc_sessions
+
+CREATE TABLE IF NOT EXISTS qc_sessions (
+    id          VARCHAR(36) PRIMARY KEY,
+    room_id     BIGINT NOT NULL,
+    stream_type VARCHAR(32) NOT NULL,           -- [box, camera, device]
+    reviewer    VARCHAR(256) NOT NULL,
+    verdict     VARCHAR(32) NOT NULL DEFAULT '', -- [pass, fail], empty if released without verdict
+    notes       TEXT NOT NULL,
+    claim       VARCHAR(64) NULL,               -- stream claim lock, NULL once session ended
+    started_at  DATETIME NOT NULL,
+    ended_at    DATETIME NULL,
+
+    UNIQUE INDEX i_qc_sessions_claim (claim),
+    INDEX i_qc_sessions_room_id_started_at (room_id, started_at)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- reservations
+
+CREATE TABLE IF NOT EXISTS reservations (
+    id          VARCHAR(36) PRIMARY KEY,
+    room_id     BIGINT NOT NULL,
+    username    VARCHAR(256) NOT NULL,          -- reservation holder
+    purpose     TEXT NOT NULL,
+    start_at    DATETIME NOT NULL,
+    end_at      DATETIME NOT NULL,
+    created_at  DATETIME NOT NULL,
+
+    INDEX i_reservations_room_id_start_at (room_id, start_at),
+    INDEX i_reservations_username_start_at (username, start_at)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- muc_rooms
+
+CREATE TABLE IF NOT EXISTS muc_rooms (
+    name          VARCHAR(256) PRIMARY KEY,     -- room JID node
+    title         VARCHAR(256) NOT NULL,
+    subject       TEXT NOT NULL,
+    members_only  BOOL NOT NULL,
+    room_id       BIGINT NOT NULL DEFAULT 0,    -- linked ultrasound room, 0 if none
+    updated_at    DATETIME NOT NULL,
+    created_at    DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- muc_affiliations
+
+CREATE TABLE IF NOT EXISTS muc_affiliations (
+    room_name     VARCHAR(256) NOT NULL,
+    jid           VARCHAR(512) NOT NULL,        -- bare user JID
+    affiliation   VARCHAR(16) NOT NULL,
+    updated_at    DATETIME NOT NULL,
+    created_at    DATETIME NOT NULL,
+
+    PRIMARY KEY (room_name, jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- muc_messages
+
+CREATE TABLE IF NOT EXISTS muc_messages (
+    id            BIGINT AUTO_INCREMENT PRIMARY KEY,
+    room_name     VARCHAR(256) NOT NULL,
+    nick          VARCHAR(256) NOT NULL,
+    jid           VARCHAR(512) NOT NULL,        -- sender bare JID
+    body          TEXT NOT NULL,
+    created_at    DATETIME NOT NULL,
+
+    INDEX i_muc_messages_room_name_id (room_name, id)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- audit_events
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    seq         BIGINT PRIMARY KEY,             -- chain position, starting at 1
+    actor       VARCHAR(256) NOT NULL,
+    action      VARCHAR(64) NOT NULL,
+    room_id     BIGINT NOT NULL DEFAULT 0,      -- affected room, 0 if none
+    target      VARCHAR(512) NOT NULL DEFAULT '',
+    details     TEXT NOT NULL,
+    created_at  DATETIME NOT NULL,
+    prev_hash   CHAR(64) NOT NULL,              -- previous event hash, empty for the first one
+    hash        CHAR(64) NOT NULL,
+
+    INDEX i_audit_events_actor (actor),
+    INDEX i_audit_events_room_id (room_id),
+    INDEX i_audit_events_created_at (created_at)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -1,9 +1,10 @@
 USE cubit_db;
 
 
-INSERT INTO roles (id, name) VALUES 
-(1, 'admin'),
-(2, 'user');
+INSERT INTO roles (id, name, permissions) VALUES
+(1, 'admin', 'rooms.list,rooms.edit,qc.review,device.control,device.edit,roles.edit,audit.view'),
+(2, 'user',  'qc.review,device.control'),
+(3, 'root',  '');
 
 INSERT INTO users (username, password, last_presence, last_presence_at, updated_at, created_at) VALUES
 ('admin',  'password', '', '2020-09-30 08:00:00+08:00', '2020-09-30 08:00:00+08:00', '2020-09-30 08:00:00+08:00'),
@@ -29,6 +30,18 @@ INSERT INTO rooms (id, username, name, `type`, created_at, updated_at) VALUES
 (5, 'room04', 'Room 04', 'normal', '2020-09-30 09:00:00+08:00', '2020-09-30 09:00:00+08:00'),
 (6, 'room05', 'Room 05', 'normal', '2020-09-30 09:00:00+08:00', '2020-09-30 09:00:00+08:00');
 
+INSERT INTO user_rooms (username, room_id, created_at) VALUES
+('room01', 1, '2020-09-30 09:00:00+08:00'),
+('room02', 1, '2020-09-30 09:00:00+08:00'),
+('room03', 1, '2020-09-30 09:00:00+08:00'),
+('room04', 1, '2020-09-30 09:00:00+08:00'),
+('room05', 1, '2020-09-30 09:00:00+08:00'),
+('room01', 2, '2020-09-30 09:00:00+08:00'),
+('room02', 3, '2020-09-30 09:00:00+08:00'),
+('room03', 4, '2020-09-30 09:00:00+08:00'),
+('room04', 5, '2020-09-30 09:00:00+08:00'),
+('room05', 6, '2020-09-30 09:00:00+08:00');
+
 INSERT INTO room_video_streams (id, input, broadcast, routes, `type`, room_id) VALUES
 (1,  '', '', '{"room01": "srt://10.189.153.255:39991", "room02": "srt://10.189.153.255:39992", "room03": "srt://10.189.153.255:39993", "room04": "srt://10.189.153.255:39994", "room05": "srt://10.189.153.255:39995"}', 'camera', 1),
 (2,  '', '', '{"user": "srt://10.189.153.255:65101", "admin": "srt://10.189.153.255:65102"}', 'device', 2),
@@ -0,0 +1,11 @@
+-- Migrates a database created from an earlier schema, so that every room holds at most one stream of each type.
+-- Run it once against existing deployments, fresh databases get it from mysql-entrypoint/1-schema.sql.
+
+USE cubit_db;
+
+-- keep latest stream definition of each room and type
+DELETE s1 FROM room_video_streams s1
+    JOIN room_video_streams s2 ON s1.room_id = s2.room_id AND s1.`type` = s2.`type` AND s1.id < s2.id;
+
+ALTER TABLE room_video_streams
+    ADD UNIQUE INDEX i_room_video_streams_room_id_type (room_id, `type`);
@@ -0,0 +1,14 @@
+-- Migrates a database created from an earlier schema to permission-based roles.
+-- Run it once against existing deployments, fresh databases get it from mysql-entrypoint/1-schema.sql.
+
+USE cubit_db;
+
+ALTER TABLE roles
+    ADD COLUMN permissions TEXT NOT NULL,
+    ADD UNIQUE INDEX i_roles_name(name);
+
+-- stored roles take precedence over built-in ones, so former roles must keep their permissions
+UPDATE roles SET permissions = 'rooms.list,rooms.edit,qc.review,device.control,device.edit,roles.edit,audit.view' WHERE name = 'admin';
+UPDATE roles SET permissions = 'qc.review,device.control' WHERE name = 'user';
+
+INSERT IGNORE INTO roles (name, permissions) VALUES ('root', '');
@@ -0,0 +1,13 @@
+-- Migrates a database created from an earlier schema to carry video stream metadata.
+-- Run it once against existing deployments, fresh databases get it from mysql-entrypoint/1-schema.sql.
+
+USE cubit_db;
+
+ALTER TABLE room_video_streams
+    ADD COLUMN protocol    VARCHAR(16) NOT NULL DEFAULT '',
+    ADD COLUMN codec       VARCHAR(32) NOT NULL DEFAULT '',
+    ADD COLUMN width       INT NOT NULL DEFAULT 0,
+    ADD COLUMN height      INT NOT NULL DEFAULT 0,
+    ADD COLUMN framerate   DECIMAL(6,2) NOT NULL DEFAULT 0,
+    ADD COLUMN bitrate     INT NOT NULL DEFAULT 0,
+    ADD COLUMN latency     VARCHAR(16) NOT NULL DEFAULT '';
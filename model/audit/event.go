@@ -0,0 +1,236 @@
+package auditmodel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"hash"
+	"strconv"
+	"time"
+
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Action represents an audited action.
+type Action string
+
+const (
+	// StreamView represents a room video stream being handed out to a user.
+	StreamView Action = "stream.view"
+	// RoomCreate represents a room creation.
+	RoomCreate Action = "room.create"
+	// RoomUpdate represents a room update.
+	RoomUpdate Action = "room.update"
+	// RoomDelete represents a room deletion.
+	RoomDelete Action = "room.delete"
+	// RoomBind represents a room being bound to a user.
+	RoomBind Action = "room.bind"
+	// RoomUnbind represents a room being unbound from a user.
+	RoomUnbind Action = "room.unbind"
+	// StreamUpdate represents a room video stream creation or update.
+	StreamUpdate Action = "stream.update"
+	// StreamDelete represents a room video stream deletion.
+	StreamDelete Action = "stream.delete"
+	// StreamRoute represents a room video stream route change.
+	StreamRoute Action = "stream.route"
+	// DeviceUpdate represents a device registration, binding, provisioning or revocation.
+	DeviceUpdate Action = "device.update"
+	// DeviceCommand represents a control command relayed to room devices.
+	DeviceCommand Action = "device.command"
+	// QCSessionStart represents a QC review session start.
+	QCSessionStart Action = "qc.start"
+	// QCSessionStop represents a QC review session stop.
+	QCSessionStop Action = "qc.stop"
+	// ReservationBook represents a room reservation being booked.
+	ReservationBook Action = "reservation.book"
+	// ReservationCancel represents a room reservation being cancelled.
+	ReservationCancel Action = "reservation.cancel"
+	// RoleUpdate represents a role definition or update.
+	RoleUpdate Action = "role.update"
+	// RoleDelete represents a role deletion.
+	RoleDelete Action = "role.delete"
+	// RoleAssign represents a role being assigned to a user.
+	RoleAssign Action = "role.assign"
+	// AccountRegister represents an account registration.
+	AccountRegister Action = "account.register"
+	// AccountDelete represents an account cancellation.
+	AccountDelete Action = "account.delete"
+	// AccountPassword represents an account password change.
+	AccountPassword Action = "account.password"
+	// BlockListBlock represents JIDs being added to a user block list.
+	BlockListBlock Action = "blocklist.block"
+	// BlockListUnblock represents JIDs being removed from a user block list.
+	BlockListUnblock Action = "blocklist.unblock"
+)
+
+// Event represents an audit log entry.
+// Each entry is chained to its predecessor by including its hash, so that
+// altering or removing any of them breaks every hash from that point on.
+type Event struct {
+	Seq       int64
+	Actor     string
+	Action    Action
+	RoomID    int
+	Target    string
+	Details   string
+	Timestamp time.Time
+	PrevHash  string
+	Hash      string
+}
+
+// Chain links event to its predecessor, assigning its sequence number and hash.
+// A nil predecessor means event is the first one in the log.
+func (e *Event) Chain(prev *Event) {
+	e.Seq = 1
+	e.PrevHash = ""
+	if prev != nil {
+		e.Seq = prev.Seq + 1
+		e.PrevHash = prev.Hash
+	}
+	// storage keeps second precision timestamps
+	e.Timestamp = e.Timestamp.UTC().Truncate(time.Second)
+	e.Hash = e.ComputeHash()
+}
+
+// ComputeHash returns event hash, computed out of its contents and predecessor hash.
+func (e *Event) ComputeHash() string {
+	h := sha256.New()
+	writeHashField(h, strconv.FormatInt(e.Seq, 10))
+	writeHashField(h, e.PrevHash)
+	writeHashField(h, e.Actor)
+	writeHashField(h, string(e.Action))
+	writeHashField(h, strconv.Itoa(e.RoomID))
+	writeHashField(h, e.Target)
+	writeHashField(h, e.Details)
+	writeHashField(h, strconv.FormatInt(e.Timestamp.Unix(), 10))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// eventFormatVersion is the current Event binary format version.
+const eventFormatVersion = 1
+
+// FromBytes deserializes an Event entity from its binary representation.
+func (e *Event) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, eventFormatVersion); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&e.Seq); err != nil {
+		return err
+	}
+	if err := dec.Decode(&e.Actor); err != nil {
+		return err
+	}
+	if err := dec.Decode(&e.Action); err != nil {
+		return err
+	}
+	if err := dec.Decode(&e.RoomID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&e.Target); err != nil {
+		return err
+	}
+	if err := dec.Decode(&e.Details); err != nil {
+		return err
+	}
+	if err := dec.Decode(&e.Timestamp); err != nil {
+		return err
+	}
+	if err := dec.Decode(&e.PrevHash); err != nil {
+		return err
+	}
+	return dec.Decode(&e.Hash)
+}
+
+// ToBytes converts an Event entity to its binary representation.
+func (e *Event) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, eventFormatVersion)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&e.Seq); err != nil {
+		return err
+	}
+	if err := enc.Encode(&e.Actor); err != nil {
+		return err
+	}
+	if err := enc.Encode(&e.Action); err != nil {
+		return err
+	}
+	if err := enc.Encode(&e.RoomID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&e.Target); err != nil {
+		return err
+	}
+	if err := enc.Encode(&e.Details); err != nil {
+		return err
+	}
+	if err := enc.Encode(&e.Timestamp); err != nil {
+		return err
+	}
+	if err := enc.Encode(&e.PrevHash); err != nil {
+		return err
+	}
+	return enc.Encode(&e.Hash)
+}
+
+// VerifyChain checks that a whole log, sorted by sequence number, has not been tampered with.
+// Sequence number of the first offending event is returned otherwise.
+func VerifyChain(events []Event) (int64, bool) {
+	var prev *Event
+	for i := range events {
+		e := &events[i]
+		switch {
+		case prev == nil && (e.Seq != 1 || len(e.PrevHash) > 0):
+			return e.Seq, false
+		case prev != nil && (e.Seq != prev.Seq+1 || e.PrevHash != prev.Hash):
+			return e.Seq, false
+		case e.Hash != e.ComputeHash():
+			return e.Seq, false
+		}
+		prev = e
+	}
+	return 0, true
+}
+
+// Filter represents an audit log query.
+type Filter struct {
+	// Actor restricts events to those performed by a given user, if not empty.
+	Actor string
+
+	// RoomID restricts events to those concerning a given room, if not zero.
+	RoomID int
+
+	// From restricts events to those recorded at or after a given time, if not zero.
+	From time.Time
+
+	// To restricts events to those recorded before a given time, if not zero.
+	To time.Time
+
+	// Max is the maximum number of events to return, unbounded if zero.
+	Max int
+}
+
+// Matches tells whether or not an event satisfies filter conditions.
+func (f *Filter) Matches(e *Event) bool {
+	if len(f.Actor) > 0 && e.Actor != f.Actor {
+		return false
+	}
+	if f.RoomID != 0 && e.RoomID != f.RoomID {
+		return false
+	}
+	if !f.From.IsZero() && e.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.Timestamp.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// writeHashField writes a length prefixed field, so that no two different events share a hash input.
+func writeHashField(h hash.Hash, s string) {
+	h.Write([]byte(strconv.Itoa(len(s))))
+	h.Write([]byte(":"))
+	h.Write([]byte(s))
+}
@@ -0,0 +1,78 @@
+package auditmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvent_Serialization(t *testing.T) {
+	e := Event{Actor: "admin", Action: RoomCreate, RoomID: 2, Target: "Room 02", Timestamp: time.Now()}
+	e.Chain(nil)
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, e.ToBytes(buf))
+
+	e2 := Event{}
+	_ = e2.FromBytes(buf)
+
+	require.True(t, reflect.DeepEqual(&e, &e2))
+}
+
+func TestEvent_Chain(t *testing.T) {
+	now := time.Date(2020, 10, 1, 8, 0, 0, 500, time.Local)
+
+	events := make([]Event, 3)
+	events[0] = Event{Actor: "admin", Action: RoomCreate, RoomID: 2, Timestamp: now}
+	events[0].Chain(nil)
+	events[1] = Event{Actor: "room01", Action: StreamView, RoomID: 2, Target: "camera", Timestamp: now}
+	events[1].Chain(&events[0])
+	events[2] = Event{Actor: "admin", Action: RoomDelete, RoomID: 2, Timestamp: now}
+	events[2].Chain(&events[1])
+
+	require.Equal(t, int64(3), events[2].Seq)
+	require.Equal(t, events[1].Hash, events[2].PrevHash)
+	require.Equal(t, time.UTC, events[0].Timestamp.Location())
+	require.Equal(t, 0, events[0].Timestamp.Nanosecond())
+
+	_, ok := VerifyChain(events)
+	require.True(t, ok)
+
+	// altered event
+	tampered := append([]Event{}, events...)
+	tampered[1].Actor = "room02"
+	seq, ok := VerifyChain(tampered)
+	require.False(t, ok)
+	require.Equal(t, int64(2), seq)
+
+	// altered and rehashed event
+	tampered[1].Hash = tampered[1].ComputeHash()
+	seq, ok = VerifyChain(tampered)
+	require.False(t, ok)
+	require.Equal(t, int64(3), seq)
+
+	// removed event
+	seq, ok = VerifyChain([]Event{events[0], events[2]})
+	require.False(t, ok)
+	require.Equal(t, int64(3), seq)
+
+	// truncated log head
+	seq, ok = VerifyChain(events[1:])
+	require.False(t, ok)
+	require.Equal(t, int64(2), seq)
+}
+
+func TestFilter_Matches(t *testing.T) {
+	now := time.Now()
+	e := Event{Actor: "admin", Action: RoomUpdate, RoomID: 2, Timestamp: now}
+
+	require.True(t, (&Filter{}).Matches(&e))
+	require.True(t, (&Filter{Actor: "admin", RoomID: 2, From: now, To: now.Add(time.Second)}).Matches(&e))
+	require.False(t, (&Filter{Actor: "room01"}).Matches(&e))
+	require.False(t, (&Filter{RoomID: 3}).Matches(&e))
+	require.False(t, (&Filter{From: now.Add(time.Second)}).Matches(&e))
+	require.False(t, (&Filter{To: now}).Matches(&e))
+}
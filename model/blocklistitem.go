@@ -3,6 +3,8 @@ package model
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // BlockListItem represents block list item storage entity.
@@ -11,8 +13,14 @@ type BlockListItem struct {
 	JID      string
 }
 
+// blockListItemFormatVersion is the current BlockListItem binary format version.
+const blockListItemFormatVersion = 1
+
 // FromBytes deserialize a BlockListItem entiry from its binary representation.
 func (bli *BlockListItem) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, blockListItemFormatVersion); err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&bli.Username); err != nil {
 		return err
@@ -22,6 +30,7 @@ func (bli *BlockListItem) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a BlockListItem entity to its binary representation.
 func (bli *BlockListItem) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, blockListItemFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(&bli.Username); err != nil {
 		return err
@@ -3,6 +3,8 @@ package capsmodel
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // Capabilities represents presence capabilities info.
@@ -22,8 +24,14 @@ func (c *Capabilities) HasFeature(feature string) bool {
 	return false
 }
 
+// capabilitiesFormatVersion is the current Capabilities binary format version.
+const capabilitiesFormatVersion = 1
+
 // FromBytes deserializes a Capabilities entiry from its binary representation.
 func (c *Capabilities) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, capabilitiesFormatVersion); err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&c.Node); err != nil {
 		return err
@@ -36,6 +44,7 @@ func (c *Capabilities) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a Capabilities entiry to its binary representation.
 func (c *Capabilities) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, capabilitiesFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(&c.Node); err != nil {
 		return err
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/gob"
 
+	"github.com/dantin/cubit/model/serializer"
 	"github.com/dantin/cubit/xmpp"
 )
 
@@ -13,8 +14,14 @@ type PresenceCaps struct {
 	Caps     *Capabilities
 }
 
+// presenceCapsFormatVersion is the current PresenceCaps binary format version.
+const presenceCapsFormatVersion = 1
+
 // FromBytes deserializes a Capabilities entity from its binary representation.
 func (p *PresenceCaps) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, presenceCapsFormatVersion); err != nil {
+		return err
+	}
 	presence, err := xmpp.NewPresenceFromBytes(buf)
 	if err != nil {
 		return err
@@ -34,6 +41,7 @@ func (p *PresenceCaps) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a Capabilities entity to its binary representation.
 func (p *PresenceCaps) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, presenceCapsFormatVersion)
 	if err := p.Presence.ToBytes(buf); err != nil {
 		return err
 	}
@@ -0,0 +1,114 @@
+package devicesmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"regexp"
+	"strings"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/model/serializer"
+)
+
+const accountPrefix = "device-"
+
+// Health represents a room aggregated device health.
+type Health int
+
+const (
+	// OK represents a room whose devices are all alive.
+	OK Health = iota
+	// Degraded represents a room with some of its devices gone stale.
+	Degraded
+	// Down represents a room whose devices are all gone stale.
+	Down
+)
+
+func (h Health) String() string {
+	switch h {
+	case OK:
+		return "ok"
+	case Degraded:
+		return "degraded"
+	case Down:
+		return "down"
+	}
+	return ""
+}
+
+// ParseHealthString converts a string to Health, reporting whether or not it was recognized.
+func ParseHealthString(h string) (Health, bool) {
+	switch h {
+	case "ok":
+		return OK, true
+	case "degraded":
+		return Degraded, true
+	case "down":
+		return Down, true
+	default:
+		return OK, false
+	}
+}
+
+var serialRegExp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Device represents a registered streaming device (box, camera or encoder).
+type Device struct {
+	Serial   string
+	Type     roomsmodel.VideoType
+	RoomID   int    // bound room identifier, zero if unbound
+	Username string // issued XMPP account, empty if not provisioned
+}
+
+// IsValidSerial tells whether or not a device serial number is well formed.
+func IsValidSerial(serial string) bool {
+	return serialRegExp.MatchString(serial)
+}
+
+// AccountName returns the XMPP account username to be issued to a device.
+func AccountName(serial string) string {
+	return fmt.Sprintf("%s%s", accountPrefix, strings.ToLower(serial))
+}
+
+// IsAccountName tells whether or not username belongs to a device issued account.
+func IsAccountName(username string) bool {
+	return strings.HasPrefix(username, accountPrefix)
+}
+
+// deviceFormatVersion is the current Device binary format version.
+const deviceFormatVersion = 1
+
+// FromBytes deserializes a Device entity from its binary representation.
+func (d *Device) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, deviceFormatVersion); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&d.Serial); err != nil {
+		return err
+	}
+	if err := dec.Decode(&d.Type); err != nil {
+		return err
+	}
+	if err := dec.Decode(&d.RoomID); err != nil {
+		return err
+	}
+	return dec.Decode(&d.Username)
+}
+
+// ToBytes converts a Device entity to its binary representation.
+func (d *Device) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, deviceFormatVersion)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&d.Serial); err != nil {
+		return err
+	}
+	if err := enc.Encode(&d.Type); err != nil {
+		return err
+	}
+	if err := enc.Encode(&d.RoomID); err != nil {
+		return err
+	}
+	return enc.Encode(&d.Username)
+}
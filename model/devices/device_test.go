@@ -0,0 +1,47 @@
+package devicesmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevice_Serialization(t *testing.T) {
+	d := Device{Serial: "SN-0001", Type: roomsmodel.Camera, RoomID: 2, Username: "device-sn-0001"}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, d.ToBytes(buf))
+
+	d2 := Device{}
+	_ = d2.FromBytes(buf)
+
+	require.True(t, reflect.DeepEqual(&d, &d2))
+}
+
+func TestDevice_Serial(t *testing.T) {
+	require.True(t, IsValidSerial("SN-0001"))
+	require.True(t, IsValidSerial("cam.01_a"))
+	require.False(t, IsValidSerial(""))
+	require.False(t, IsValidSerial("-SN"))
+	require.False(t, IsValidSerial("SN 0001"))
+	require.False(t, IsValidSerial("SN@0001"))
+
+	require.Equal(t, "device-sn-0001", AccountName("SN-0001"))
+	require.True(t, IsAccountName(AccountName("SN-0001")))
+	require.False(t, IsAccountName("alice"))
+}
+
+func TestDevice_Health(t *testing.T) {
+	require.Equal(t, "ok", OK.String())
+	require.Equal(t, "degraded", Degraded.String())
+	require.Equal(t, "down", Down.String())
+
+	h, ok := ParseHealthString("degraded")
+	require.True(t, ok)
+	require.Equal(t, Degraded, h)
+	_, ok = ParseHealthString("unknown")
+	require.False(t, ok)
+}
@@ -0,0 +1,94 @@
+package mucmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// AffiliationType represents a long-lived association between a user and a chat room.
+type AffiliationType int
+
+const (
+	// None represents no affiliation at all.
+	None AffiliationType = iota
+	// Outcast represents a user banned from a room.
+	Outcast
+	// Member represents a user allowed to enter a members-only room.
+	Member
+	// Admin represents a room administrator.
+	Admin
+	// Owner represents a room owner.
+	Owner
+)
+
+func (a AffiliationType) String() string {
+	switch a {
+	case Outcast:
+		return "outcast"
+	case Member:
+		return "member"
+	case Admin:
+		return "admin"
+	case Owner:
+		return "owner"
+	}
+	return "none"
+}
+
+// ParseAffiliationString converts a string to AffiliationType.
+// The second returned value tells whether or not conversion succeeded.
+func ParseAffiliationString(s string) (AffiliationType, bool) {
+	switch s {
+	case "none":
+		return None, true
+	case "outcast":
+		return Outcast, true
+	case "member":
+		return Member, true
+	case "admin":
+		return Admin, true
+	case "owner":
+		return Owner, true
+	}
+	return None, false
+}
+
+// Affiliation represents a user affiliation within a chat room.
+type Affiliation struct {
+	RoomName    string
+	JID         string // bare user JID
+	Affiliation AffiliationType
+}
+
+// affiliationFormatVersion is the current Affiliation binary format version.
+const affiliationFormatVersion = 1
+
+// FromBytes deserializes an Affiliation entity from its binary representation.
+func (a *Affiliation) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, affiliationFormatVersion); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&a.RoomName); err != nil {
+		return err
+	}
+	if err := dec.Decode(&a.JID); err != nil {
+		return err
+	}
+	return dec.Decode(&a.Affiliation)
+}
+
+// ToBytes converts an Affiliation entity to its binary representation.
+func (a *Affiliation) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, affiliationFormatVersion)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&a.RoomName); err != nil {
+		return err
+	}
+	if err := enc.Encode(&a.JID); err != nil {
+		return err
+	}
+	return enc.Encode(&a.Affiliation)
+}
@@ -0,0 +1,61 @@
+package mucmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Message represents a chat room history message.
+type Message struct {
+	RoomName string
+	Nick     string // sender room nickname
+	JID      string // sender bare JID
+	Body     string
+	Stamp    time.Time
+}
+
+// messageFormatVersion is the current Message binary format version.
+const messageFormatVersion = 1
+
+// FromBytes deserializes a Message entity from its binary representation.
+func (m *Message) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, messageFormatVersion); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&m.RoomName); err != nil {
+		return err
+	}
+	if err := dec.Decode(&m.Nick); err != nil {
+		return err
+	}
+	if err := dec.Decode(&m.JID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&m.Body); err != nil {
+		return err
+	}
+	return dec.Decode(&m.Stamp)
+}
+
+// ToBytes converts a Message entity to its binary representation.
+func (m *Message) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, messageFormatVersion)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&m.RoomName); err != nil {
+		return err
+	}
+	if err := enc.Encode(&m.Nick); err != nil {
+		return err
+	}
+	if err := enc.Encode(&m.JID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&m.Body); err != nil {
+		return err
+	}
+	return enc.Encode(&m.Stamp)
+}
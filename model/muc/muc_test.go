@@ -0,0 +1,54 @@
+package mucmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoom_Serialization(t *testing.T) {
+	r := Room{Name: "room01", Title: "Room 01", Subject: "exam", MembersOnly: true, RoomID: 1}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, r.ToBytes(buf))
+
+	r2 := Room{}
+	require.Nil(t, r2.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&r, &r2))
+	require.True(t, r2.IsLinked())
+}
+
+func TestAffiliation_Serialization(t *testing.T) {
+	a := Affiliation{RoomName: "room01", JID: "alice@jackal.im", Affiliation: Admin}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, a.ToBytes(buf))
+
+	a2 := Affiliation{}
+	require.Nil(t, a2.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&a, &a2))
+}
+
+func TestAffiliation_ParseString(t *testing.T) {
+	for _, tp := range []AffiliationType{None, Outcast, Member, Admin, Owner} {
+		parsed, ok := ParseAffiliationString(tp.String())
+		require.True(t, ok)
+		require.Equal(t, tp, parsed)
+	}
+	_, ok := ParseAffiliationString("moderator")
+	require.False(t, ok)
+}
+
+func TestMessage_Serialization(t *testing.T) {
+	m := Message{RoomName: "room01", Nick: "alice", JID: "alice@jackal.im", Body: "hi", Stamp: time.Date(2020, 10, 1, 8, 0, 0, 0, time.UTC)}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, m.ToBytes(buf))
+
+	m2 := Message{}
+	require.Nil(t, m2.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&m, &m2))
+}
@@ -0,0 +1,65 @@
+package mucmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Room represents a persistent multi-user chat room.
+type Room struct {
+	Name        string // room JID node
+	Title       string
+	Subject     string
+	MembersOnly bool
+	RoomID      int // linked ultrasound room, 0 if none
+}
+
+// IsLinked tells whether or not chat room follows an ultrasound room.
+func (r *Room) IsLinked() bool {
+	return r.RoomID > 0
+}
+
+// roomFormatVersion is the current Room binary format version.
+const roomFormatVersion = 1
+
+// FromBytes deserializes a Room entity from its binary representation.
+func (r *Room) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, roomFormatVersion); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&r.Name); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Title); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Subject); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.MembersOnly); err != nil {
+		return err
+	}
+	return dec.Decode(&r.RoomID)
+}
+
+// ToBytes converts a Room entity to its binary representation.
+func (r *Room) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, roomFormatVersion)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&r.Name); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.Title); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.Subject); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.MembersOnly); err != nil {
+		return err
+	}
+	return enc.Encode(&r.RoomID)
+}
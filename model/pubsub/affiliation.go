@@ -3,6 +3,8 @@ package pubsubmodel
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // affiliation definitions.
@@ -25,8 +27,14 @@ type Affiliation struct {
 	Affiliation string
 }
 
+// affiliationFormatVersion is the current Affiliation binary format version.
+const affiliationFormatVersion = 1
+
 // FromBytes deserializes a Affiliation entity from its binary representation.
 func (a *Affiliation) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, affiliationFormatVersion); err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&a.JID); err != nil {
 		return err
@@ -36,6 +44,7 @@ func (a *Affiliation) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a Affiliation entity to its binary representation.
 func (a *Affiliation) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, affiliationFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(a.JID); err != nil {
 		return err
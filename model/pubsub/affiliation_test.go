@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"reflect"
 	"testing"
+	"testing/quick"
 
 	"github.com/stretchr/testify/require"
 )
@@ -20,3 +21,18 @@ func TestAffiliation_Serialize(t *testing.T) {
 	require.Nil(t, a2.FromBytes(b))
 	require.True(t, reflect.DeepEqual(a, a2))
 }
+
+func TestAffiliation_SerializationProperty(t *testing.T) {
+	f := func(a Affiliation) bool {
+		b := bytes.NewBuffer(nil)
+		if err := a.ToBytes(b); err != nil {
+			return false
+		}
+		var a2 Affiliation
+		if err := a2.FromBytes(b); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(a, a2)
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
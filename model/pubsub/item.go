@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/gob"
 
+	"github.com/dantin/cubit/model/serializer"
 	"github.com/dantin/cubit/xmpp"
 )
 
@@ -14,8 +15,14 @@ type Item struct {
 	Payload   xmpp.XElement
 }
 
+// itemFormatVersion is the current Item binary format version.
+const itemFormatVersion = 1
+
 // FromBytes deserializes a Item entity from its binary representation.
 func (i *Item) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, itemFormatVersion); err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&i.ID); err != nil {
 		return err
@@ -39,6 +46,7 @@ func (i *Item) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a Item entity to its binary representation.
 func (i *Item) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, itemFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(i.ID); err != nil {
 		return err
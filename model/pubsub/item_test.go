@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"reflect"
 	"testing"
+	"testing/quick"
 
 	"github.com/dantin/cubit/xmpp"
 	"github.com/stretchr/testify/require"
@@ -32,3 +33,24 @@ func TestItem_Serialization(t *testing.T) {
 	_ = it3.FromBytes(buf2)
 	require.True(t, reflect.DeepEqual(&it, &it3))
 }
+
+func TestItem_SerializationProperty(t *testing.T) {
+	f := func(id, publisher, text string, withPayload bool) bool {
+		it := Item{ID: id, Publisher: publisher}
+		if withPayload {
+			payload := xmpp.NewElementName("el")
+			payload.SetText(text)
+			it.Payload = payload
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := it.ToBytes(buf); err != nil {
+			return false
+		}
+		var it2 Item
+		if err := it2.FromBytes(buf); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(&it, &it2)
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
@@ -3,6 +3,8 @@ package pubsubmodel
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // Node represents a pubsub node.
@@ -12,8 +14,14 @@ type Node struct {
 	Options Options
 }
 
+// nodeFormatVersion is the current Node binary format version.
+const nodeFormatVersion = 1
+
 // FromBytes deserializes a Node entity from its binary representation.
 func (n *Node) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, nodeFormatVersion); err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&n.Host); err != nil {
 		return err
@@ -26,6 +34,7 @@ func (n *Node) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a Node entity to its binary representation.
 func (n *Node) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, nodeFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(n.Host); err != nil {
 		return err
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"reflect"
 	"testing"
+	"testing/quick"
 
 	"github.com/stretchr/testify/require"
 )
@@ -22,3 +23,21 @@ func TestNode_Serialization(t *testing.T) {
 	_ = n2.FromBytes(buf)
 	require.True(t, reflect.DeepEqual(&n, &n2))
 }
+
+func TestNode_SerializationProperty(t *testing.T) {
+	f := func(n Node) bool {
+		if len(n.Options.RosterGroupsAllowed) == 0 {
+			n.Options.RosterGroupsAllowed = nil // gob omits empty slices
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := n.ToBytes(buf); err != nil {
+			return false
+		}
+		var n2 Node
+		if err := n2.FromBytes(buf); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(&n, &n2)
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
@@ -3,6 +3,8 @@ package pubsubmodel
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // Subscription represents a pubsub node subscription.
@@ -12,8 +14,14 @@ type Subscription struct {
 	Subscription string
 }
 
+// subscriptionFormatVersion is the current Subscription binary format version.
+const subscriptionFormatVersion = 1
+
 // FromBytes deserializes a Subscription entity from its binary representation.
 func (s *Subscription) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, subscriptionFormatVersion); err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&s.SubID); err != nil {
 		return err
@@ -26,6 +34,7 @@ func (s *Subscription) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a Subscription entity to its binary representation.
 func (s *Subscription) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, subscriptionFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(s.SubID); err != nil {
 		return err
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"reflect"
 	"testing"
+	"testing/quick"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -22,3 +23,18 @@ func TestSubscription_Serialization(t *testing.T) {
 	require.Nil(t, s2.FromBytes(b))
 	require.True(t, reflect.DeepEqual(s, s2))
 }
+
+func TestSubscription_SerializationProperty(t *testing.T) {
+	f := func(s Subscription) bool {
+		b := bytes.NewBuffer(nil)
+		if err := s.ToBytes(b); err != nil {
+			return false
+		}
+		var s2 Subscription
+		if err := s2.FromBytes(b); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(s, s2)
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
@@ -0,0 +1,122 @@
+package qcmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Verdict represents a QC review outcome.
+type Verdict int
+
+const (
+	// None represents a session released without a verdict.
+	None Verdict = iota
+	// Pass represents a stream that passed QC review.
+	Pass
+	// Fail represents a stream that failed QC review.
+	Fail
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Pass:
+		return "pass"
+	case Fail:
+		return "fail"
+	}
+	return ""
+}
+
+// ParseVerdictString converts a string to Verdict.
+func ParseVerdictString(v string) Verdict {
+	switch v {
+	case "pass":
+		return Pass
+	case "fail":
+		return Fail
+	default:
+		return None
+	}
+}
+
+// Session represents a QC review session over a room video stream.
+type Session struct {
+	ID         string
+	RoomID     int
+	StreamType roomsmodel.VideoType
+	Reviewer   string
+	Verdict    Verdict
+	Notes      string
+	StartedAt  time.Time
+	EndedAt    time.Time // zero while session is in progress
+}
+
+// IsOpen tells whether or not session is still in progress, thus holding its stream claim.
+func (s *Session) IsOpen() bool {
+	return s.EndedAt.IsZero()
+}
+
+// sessionFormatVersion is the current Session binary format version.
+const sessionFormatVersion = 1
+
+// FromBytes deserializes a Session entity from its binary representation.
+func (s *Session) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, sessionFormatVersion); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&s.ID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&s.RoomID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&s.StreamType); err != nil {
+		return err
+	}
+	if err := dec.Decode(&s.Reviewer); err != nil {
+		return err
+	}
+	if err := dec.Decode(&s.Verdict); err != nil {
+		return err
+	}
+	if err := dec.Decode(&s.Notes); err != nil {
+		return err
+	}
+	if err := dec.Decode(&s.StartedAt); err != nil {
+		return err
+	}
+	return dec.Decode(&s.EndedAt)
+}
+
+// ToBytes converts a Session entity to its binary representation.
+func (s *Session) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, sessionFormatVersion)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&s.ID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&s.RoomID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&s.StreamType); err != nil {
+		return err
+	}
+	if err := enc.Encode(&s.Reviewer); err != nil {
+		return err
+	}
+	if err := enc.Encode(&s.Verdict); err != nil {
+		return err
+	}
+	if err := enc.Encode(&s.Notes); err != nil {
+		return err
+	}
+	if err := enc.Encode(&s.StartedAt); err != nil {
+		return err
+	}
+	return enc.Encode(&s.EndedAt)
+}
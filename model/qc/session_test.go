@@ -0,0 +1,46 @@
+package qcmodel
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_Serialization(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	s := Session{
+		ID:         "6b1d5e7e-7d0f-4bb1-9b0a-3c1f2f6c8a11",
+		RoomID:     1,
+		StreamType: roomsmodel.Camera,
+		Reviewer:   "qc01",
+		Verdict:    Fail,
+		Notes:      "probe out of focus",
+		StartedAt:  now,
+		EndedAt:    now.Add(time.Minute),
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, s.ToBytes(buf))
+
+	s2 := Session{}
+	require.Nil(t, s2.FromBytes(buf))
+	require.Equal(t, s.ID, s2.ID)
+	require.Equal(t, s.StreamType, s2.StreamType)
+	require.Equal(t, s.Notes, s2.Notes)
+	require.True(t, s.StartedAt.Equal(s2.StartedAt))
+	require.True(t, s.EndedAt.Equal(s2.EndedAt))
+	require.False(t, s2.IsOpen())
+}
+
+func TestSession_Verdict(t *testing.T) {
+	require.Equal(t, "pass", Pass.String())
+	require.Equal(t, "fail", Fail.String())
+	require.Equal(t, "", None.String())
+
+	require.Equal(t, Pass, ParseVerdictString("pass"))
+	require.Equal(t, Fail, ParseVerdictString("fail"))
+	require.Equal(t, None, ParseVerdictString("maybe"))
+}
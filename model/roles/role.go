@@ -0,0 +1,112 @@
+package rolesmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sort"
+
+	"github.com/dantin/cubit/model"
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Permission represents a named operation users can be granted.
+type Permission string
+
+const (
+	// RoomsList allows listing every room.
+	RoomsList Permission = "rooms.list"
+	// RoomsEdit allows creating, updating, deleting and binding rooms, as well as operating any of them.
+	RoomsEdit Permission = "rooms.edit"
+	// QCReview allows reviewing QC streams.
+	QCReview Permission = "qc.review"
+	// DeviceControl allows sending control commands to room devices.
+	DeviceControl Permission = "device.control"
+	// DeviceEdit allows registering and provisioning devices.
+	DeviceEdit Permission = "device.edit"
+	// RolesEdit allows managing roles and assigning them to users.
+	RolesEdit Permission = "roles.edit"
+	// AuditView allows querying and verifying the audit log.
+	AuditView Permission = "audit.view"
+)
+
+// Permissions contains every known permission.
+var Permissions = []Permission{RoomsList, RoomsEdit, QCReview, DeviceControl, DeviceEdit, RolesEdit, AuditView}
+
+// IsValidPermission tells whether or not p is a known permission.
+func IsValidPermission(p Permission) bool {
+	for _, perm := range Permissions {
+		if perm == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Role represents a named permission set.
+type Role struct {
+	Name        string
+	Permissions []Permission
+}
+
+// HasPermission tells whether or not role grants a given permission.
+func (r *Role) HasPermission(p Permission) bool {
+	for _, perm := range r.Permissions {
+		if perm == p {
+			return true
+		}
+	}
+	return false
+}
+
+// SortPermissions sorts role permissions by name.
+func (r *Role) SortPermissions() {
+	sort.Slice(r.Permissions, func(i, j int) bool { return r.Permissions[i] < r.Permissions[j] })
+}
+
+// roleFormatVersion is the current Role binary format version.
+const roleFormatVersion = 1
+
+// FromBytes deserializes a Role entity from its binary representation.
+func (r *Role) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, roleFormatVersion); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&r.Name); err != nil {
+		return err
+	}
+	return dec.Decode(&r.Permissions)
+}
+
+// ToBytes converts a Role entity to its binary representation.
+func (r *Role) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, roleFormatVersion)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&r.Name); err != nil {
+		return err
+	}
+	return enc.Encode(&r.Permissions)
+}
+
+var builtIn = map[model.Role][]Permission{
+	model.Root:  Permissions,
+	model.Admin: {RoomsList, RoomsEdit, QCReview, DeviceControl, DeviceEdit, RolesEdit, AuditView},
+	model.Usr:   {QCReview, DeviceControl},
+}
+
+// IsBuiltIn tells whether or not name refers to a built-in role.
+// Built-in roles can be redefined but not deleted, while root cannot be modified at all
+// and always grants every permission.
+func IsBuiltIn(name string) bool {
+	_, ok := builtIn[model.Role(name)]
+	return ok
+}
+
+// BuiltIn returns the default definition of a built-in role, used whenever it's not stored.
+func BuiltIn(name string) *Role {
+	perms, ok := builtIn[model.Role(name)]
+	if !ok {
+		return nil
+	}
+	return &Role{Name: name, Permissions: append([]Permission{}, perms...)}
+}
@@ -0,0 +1,50 @@
+package rolesmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRole_Serialization(t *testing.T) {
+	r := Role{Name: "operator", Permissions: []Permission{RoomsList, DeviceControl}}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, r.ToBytes(buf))
+
+	r2 := Role{}
+	_ = r2.FromBytes(buf)
+
+	require.True(t, reflect.DeepEqual(&r, &r2))
+}
+
+func TestRole_Permissions(t *testing.T) {
+	require.True(t, IsValidPermission(QCReview))
+	require.False(t, IsValidPermission("rooms.delete"))
+
+	r := Role{Name: "operator", Permissions: []Permission{RoomsList, DeviceControl}}
+	require.True(t, r.HasPermission(RoomsList))
+	require.False(t, r.HasPermission(RoomsEdit))
+
+	r.SortPermissions()
+	require.Equal(t, []Permission{DeviceControl, RoomsList}, r.Permissions)
+}
+
+func TestRole_BuiltIn(t *testing.T) {
+	require.True(t, IsBuiltIn("root"))
+	require.True(t, IsBuiltIn("admin"))
+	require.True(t, IsBuiltIn("user"))
+	require.False(t, IsBuiltIn("operator"))
+
+	require.Nil(t, BuiltIn("operator"))
+	require.Equal(t, Permissions, BuiltIn("root").Permissions)
+	require.True(t, BuiltIn("admin").HasPermission(RolesEdit))
+	require.True(t, BuiltIn("admin").HasPermission(AuditView))
+	require.False(t, BuiltIn("user").HasPermission(RoomsList))
+
+	// built-in definitions cannot be altered by callers
+	BuiltIn("user").Permissions[0] = RolesEdit
+	require.False(t, BuiltIn("user").HasPermission(RolesEdit))
+}
@@ -0,0 +1,39 @@
+package roomsmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Binding represents a room to user binding.
+type Binding struct {
+	RoomID   int
+	Username string
+}
+
+// bindingFormatVersion is the current Binding binary format version.
+const bindingFormatVersion = 1
+
+// FromBytes deserializes a Binding entity from its binary representation.
+func (b *Binding) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, bindingFormatVersion); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&b.RoomID); err != nil {
+		return err
+	}
+	return dec.Decode(&b.Username)
+}
+
+// ToBytes converts a Binding entity to its binary representation.
+func (b *Binding) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, bindingFormatVersion)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&b.RoomID); err != nil {
+		return err
+	}
+	return enc.Encode(&b.Username)
+}
@@ -0,0 +1,21 @@
+package roomsmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinding_Serialization(t *testing.T) {
+	b := Binding{RoomID: 2, Username: "alice"}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, b.ToBytes(buf))
+
+	b2 := Binding{}
+	_ = b2.FromBytes(buf)
+
+	require.True(t, reflect.DeepEqual(&b, &b2))
+}
@@ -0,0 +1,130 @@
+package roomsmodel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Protocol represents a video stream delivery protocol.
+type Protocol int
+
+const (
+	// UnknownProtocol represents an unspecified delivery protocol.
+	UnknownProtocol Protocol = iota
+	// RTMP represents Real-Time Messaging Protocol.
+	RTMP
+	// HLS represents HTTP Live Streaming.
+	HLS
+	// WebRTC represents Web Real-Time Communication.
+	WebRTC
+	// RTSP represents Real Time Streaming Protocol.
+	RTSP
+	// SRT represents Secure Reliable Transport.
+	SRT
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case RTMP:
+		return "rtmp"
+	case HLS:
+		return "hls"
+	case WebRTC:
+		return "webrtc"
+	case RTSP:
+		return "rtsp"
+	case SRT:
+		return "srt"
+	default:
+		return ""
+	}
+}
+
+// ParseProtocolString convert a string to Protocol.
+func ParseProtocolString(p string) Protocol {
+	switch p {
+	case "rtmp":
+		return RTMP
+	case "hls":
+		return HLS
+	case "webrtc":
+		return WebRTC
+	case "rtsp":
+		return RTSP
+	case "srt":
+		return SRT
+	default:
+		return UnknownProtocol
+	}
+}
+
+// LatencyClass represents the expected glass-to-glass latency of a video stream.
+type LatencyClass int
+
+const (
+	// UnknownLatency represents an unspecified latency class.
+	UnknownLatency LatencyClass = iota
+	// UltraLowLatency represents sub-second latency streams.
+	UltraLowLatency
+	// LowLatency represents a few seconds latency streams.
+	LowLatency
+	// NormalLatency represents segmented delivery latency streams.
+	NormalLatency
+)
+
+func (l LatencyClass) String() string {
+	switch l {
+	case UltraLowLatency:
+		return "ultra_low"
+	case LowLatency:
+		return "low"
+	case NormalLatency:
+		return "normal"
+	default:
+		return ""
+	}
+}
+
+// ParseLatencyClassString convert a string to LatencyClass.
+func ParseLatencyClassString(l string) LatencyClass {
+	switch l {
+	case "ultra_low":
+		return UltraLowLatency
+	case "low":
+		return LowLatency
+	case "normal":
+		return NormalLatency
+	default:
+		return UnknownLatency
+	}
+}
+
+// Metadata represents video stream technical description, letting clients choose
+// the right player and quality. Zero values stand for unspecified properties.
+type Metadata struct {
+	Protocol  Protocol
+	Codec     string
+	Width     int
+	Height    int
+	Framerate float64
+	Bitrate   int // kbps
+	Latency   LatencyClass
+}
+
+// ParseResolution parses a 'WIDTHxHEIGHT' resolution string.
+func ParseResolution(s string) (width, height int, err error) {
+	parts := strings.Split(s, "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("roomsmodel: invalid resolution: %s", s)
+	}
+	width, err = strconv.Atoi(parts[0])
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("roomsmodel: invalid resolution: %s", s)
+	}
+	height, err = strconv.Atoi(parts[1])
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("roomsmodel: invalid resolution: %s", s)
+	}
+	return width, height, nil
+}
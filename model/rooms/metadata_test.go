@@ -0,0 +1,35 @@
+package roomsmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata_Protocol(t *testing.T) {
+	for _, p := range []Protocol{RTMP, HLS, WebRTC, RTSP, SRT} {
+		require.Equal(t, p, ParseProtocolString(p.String()))
+	}
+	require.Equal(t, UnknownProtocol, ParseProtocolString("dash"))
+	require.Equal(t, "", UnknownProtocol.String())
+}
+
+func TestMetadata_LatencyClass(t *testing.T) {
+	for _, l := range []LatencyClass{UltraLowLatency, LowLatency, NormalLatency} {
+		require.Equal(t, l, ParseLatencyClassString(l.String()))
+	}
+	require.Equal(t, UnknownLatency, ParseLatencyClassString("high"))
+	require.Equal(t, "", UnknownLatency.String())
+}
+
+func TestMetadata_ParseResolution(t *testing.T) {
+	width, height, err := ParseResolution("1280x720")
+	require.Nil(t, err)
+	require.Equal(t, 1280, width)
+	require.Equal(t, 720, height)
+
+	for _, s := range []string{"", "720p", "1280x", "x720", "0x720", "1280x-1", "1x2x3"} {
+		_, _, err := ParseResolution(s)
+		require.NotNil(t, err, s)
+	}
+}
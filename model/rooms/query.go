@@ -0,0 +1,59 @@
+package roomsmodel
+
+import "strings"
+
+// Filter represents a room listing filter.
+type Filter struct {
+	// Name matches rooms whose name contains it, case insensitively.
+	Name string
+
+	// Type matches rooms of a given type, any type if zero.
+	Type RoomType
+
+	// IDs restricts matching rooms to a given identifier set, if not nil.
+	IDs []int
+
+	// ExcludedIDs excludes a given identifier set from matching rooms.
+	ExcludedIDs []int
+}
+
+// Matches tells whether or not a room satisfies filter.
+func (f *Filter) Matches(room *Room) bool {
+	if len(f.Name) > 0 && !strings.Contains(strings.ToLower(room.Name), strings.ToLower(f.Name)) {
+		return false
+	}
+	if f.Type != 0 && room.Type != f.Type {
+		return false
+	}
+	if f.IDs != nil && !containsID(f.IDs, room.ID) {
+		return false
+	}
+	return !containsID(f.ExcludedIDs, room.ID)
+}
+
+// Page represents a room listing page, keyed by room identifier.
+type Page struct {
+	// Max is the maximum number of rooms to be returned.
+	Max int
+
+	// After returns rooms following a given room identifier.
+	After int
+
+	// Before returns rooms preceding a given room identifier.
+	Before int
+
+	// Last returns last rooms page.
+	Last bool
+
+	// Index returns rooms starting from a given position, when no After or Before identifier is set.
+	Index int
+}
+
+func containsID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
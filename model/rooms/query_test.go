@@ -0,0 +1,20 @@
+package roomsmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_Matches(t *testing.T) {
+	room := Room{ID: 2, Name: "Operating Room 01", Type: Normal}
+
+	require.True(t, (&Filter{}).Matches(&room))
+	require.True(t, (&Filter{Name: "room 0"}).Matches(&room))
+	require.False(t, (&Filter{Name: "room 02"}).Matches(&room))
+	require.True(t, (&Filter{Type: Normal}).Matches(&room))
+	require.False(t, (&Filter{Type: QC}).Matches(&room))
+	require.True(t, (&Filter{IDs: []int{1, 2}}).Matches(&room))
+	require.False(t, (&Filter{IDs: []int{}}).Matches(&room))
+	require.False(t, (&Filter{ExcludedIDs: []int{2}}).Matches(&room))
+}
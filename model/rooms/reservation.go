@@ -0,0 +1,78 @@
+package roomsmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Reservation represents a room booking over a time range.
+type Reservation struct {
+	ID       string
+	RoomID   int
+	Username string // reservation holder
+	Purpose  string
+	Start    time.Time
+	End      time.Time
+}
+
+// Overlaps tells whether or not reservation time range intersects [start, end).
+func (r *Reservation) Overlaps(start, end time.Time) bool {
+	return r.Start.Before(end) && start.Before(r.End)
+}
+
+// IsActive tells whether or not reservation holds its room at a given time.
+func (r *Reservation) IsActive(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// reservationFormatVersion is the current Reservation binary format version.
+const reservationFormatVersion = 1
+
+// FromBytes deserializes a Reservation entity from its binary representation.
+func (r *Reservation) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, reservationFormatVersion); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&r.ID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.RoomID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Username); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Purpose); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Start); err != nil {
+		return err
+	}
+	return dec.Decode(&r.End)
+}
+
+// ToBytes converts a Reservation entity to its binary representation.
+func (r *Reservation) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, reservationFormatVersion)
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&r.ID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.RoomID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.Username); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.Purpose); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.Start); err != nil {
+		return err
+	}
+	return enc.Encode(&r.End)
+}
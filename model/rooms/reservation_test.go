@@ -0,0 +1,37 @@
+package roomsmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservation_Overlaps(t *testing.T) {
+	now := time.Date(2020, 10, 1, 8, 0, 0, 0, time.UTC)
+	rsv := Reservation{Start: now, End: now.Add(time.Hour)}
+
+	require.True(t, rsv.Overlaps(now.Add(-time.Minute), now.Add(time.Minute)))
+	require.True(t, rsv.Overlaps(now.Add(time.Minute), now.Add(time.Minute*2)))
+	require.False(t, rsv.Overlaps(now.Add(-time.Hour), now))
+	require.False(t, rsv.Overlaps(now.Add(time.Hour), now.Add(time.Hour*2)))
+
+	require.True(t, rsv.IsActive(now))
+	require.True(t, rsv.IsActive(now.Add(time.Minute)))
+	require.False(t, rsv.IsActive(now.Add(time.Hour)))
+	require.False(t, rsv.IsActive(now.Add(-time.Minute)))
+}
+
+func TestReservation_Serialization(t *testing.T) {
+	now := time.Date(2020, 10, 1, 8, 0, 0, 0, time.UTC)
+	rsv := Reservation{ID: "r1", RoomID: 1, Username: "alice", Purpose: "exam", Start: now, End: now.Add(time.Hour)}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, rsv.ToBytes(buf))
+
+	rsv2 := Reservation{}
+	require.Nil(t, rsv2.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&rsv, &rsv2))
+}
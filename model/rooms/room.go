@@ -3,6 +3,8 @@ package roomsmodel
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // RoomType represents a room type.
@@ -25,16 +27,55 @@ func (tt RoomType) String() string {
 	return ""
 }
 
+// ParseRoomTypeString converts a string to RoomType.
+func ParseRoomTypeString(t string) RoomType {
+	switch t {
+	case "normal":
+		return Normal
+	case "qc":
+		return QC
+	default:
+		return 0
+	}
+}
+
 // Room represents presence room info.
 type Room struct {
 	ID       int
 	Name     string
 	Username string
+	Type     RoomType
 	Streams  []VideoStream
 }
 
+// RoutedTo returns a copy of room holding only the video streams routed to target,
+// each one of them pointing to its target broadcast URL. Route maps are left out, so that
+// other targets are never disclosed.
+func (r *Room) RoutedTo(target string) (*Room, error) {
+	res := *r
+	res.Streams = nil
+	for _, vs := range r.Streams {
+		routes, err := vs.Routes()
+		if err != nil {
+			return nil, err
+		}
+		if streamURI, ok := routes.Lookup(target); ok {
+			res.Streams = append(res.Streams, VideoStream{Type: vs.Type, Stream: streamURI, RoomID: vs.RoomID, Metadata: vs.Metadata})
+		}
+	}
+	return &res, nil
+}
+
+// roomFormatVersion is the current Room binary format version.
+// Legacy payloads carried no room type.
+const roomFormatVersion = 1
+
 // FromBytes deserializes a Room entiry from its binary representation.
 func (r *Room) FromBytes(buf *bytes.Buffer) error {
+	version, err := serializer.ReadVersion(buf, roomFormatVersion)
+	if err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&r.ID); err != nil {
 		return err
@@ -45,11 +86,17 @@ func (r *Room) FromBytes(buf *bytes.Buffer) error {
 	if err := dec.Decode(&r.Username); err != nil {
 		return err
 	}
+	if version != serializer.LegacyVersion {
+		if err := dec.Decode(&r.Type); err != nil {
+			return err
+		}
+	}
 	return dec.Decode(&r.Streams)
 }
 
 // ToBytes converts a Room entiry to its binary representation.
 func (r *Room) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, roomFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(&r.ID); err != nil {
 		return err
@@ -60,5 +107,8 @@ func (r *Room) ToBytes(buf *bytes.Buffer) error {
 	if err := enc.Encode(&r.Username); err != nil {
 		return err
 	}
+	if err := enc.Encode(&r.Type); err != nil {
+		return err
+	}
 	return enc.Encode(&r.Streams)
 }
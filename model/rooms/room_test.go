@@ -2,8 +2,10 @@ package roomsmodel
 
 import (
 	"bytes"
+	"encoding/gob"
 	"reflect"
 	"testing"
+	"testing/quick"
 
 	"github.com/stretchr/testify/require"
 )
@@ -13,6 +15,7 @@ func TestRoom_Serialization(t *testing.T) {
 	room.ID = 1
 	room.Name = "room"
 	room.Username = "alice"
+	room.Type = QC
 	room.Streams = []VideoStream{
 		VideoStream{In: "camera_in", Broadcast: "camera_broadcast", Route: "camera_route_JSON"},
 		VideoStream{In: "device_in", Broadcast: "device_broadcast", Route: "device_route_JSON"},
@@ -26,3 +29,71 @@ func TestRoom_Serialization(t *testing.T) {
 
 	require.True(t, reflect.DeepEqual(&room, &room2))
 }
+
+func TestRoom_SerializationProperty(t *testing.T) {
+	f := func(room Room) bool {
+		if len(room.Streams) == 0 {
+			room.Streams = nil // gob decodes empty slices as nil
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := room.ToBytes(buf); err != nil {
+			return false
+		}
+		var room2 Room
+		if err := room2.FromBytes(buf); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(&room, &room2)
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
+
+func TestRoom_LegacySerialization(t *testing.T) {
+	type legacyVideoStream struct {
+		In        string
+		Broadcast string
+		Stream    string
+		Route     string
+		Type      VideoType
+		RoomID    int
+	}
+	buf := bytes.NewBuffer(nil)
+	enc := gob.NewEncoder(buf)
+	require.Nil(t, enc.Encode(1))
+	require.Nil(t, enc.Encode("room"))
+	require.Nil(t, enc.Encode("alice"))
+	require.Nil(t, enc.Encode([]legacyVideoStream{{In: "camera_in", Broadcast: "camera_broadcast", Route: "camera_route_JSON", Type: Camera, RoomID: 1}}))
+
+	room := Room{}
+	require.Nil(t, room.FromBytes(buf))
+	require.Equal(t, Room{
+		ID:       1,
+		Name:     "room",
+		Username: "alice",
+		Streams:  []VideoStream{{In: "camera_in", Broadcast: "camera_broadcast", Route: "camera_route_JSON", Type: Camera, RoomID: 1}},
+	}, room)
+}
+
+func TestRoom_RoutedTo(t *testing.T) {
+	room := Room{ID: 1, Name: "room", Username: "alice", Type: Normal}
+	room.Streams = []VideoStream{
+		{In: "camera_in", Route: `{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`, Type: Camera, RoomID: 1},
+		{In: "device_in", Route: `{"admin": "srt://127.0.0.1:9004"}`, Type: Device, RoomID: 1},
+	}
+
+	routed, err := room.RoutedTo("user")
+	require.Nil(t, err)
+	require.Equal(t, "room", routed.Name)
+	require.Equal(t, []VideoStream{
+		{Stream: "srt://127.0.0.1:9001", Type: Camera, RoomID: 1},
+	}, routed.Streams)
+	require.Len(t, room.Streams, 2)
+
+	routed, err = room.RoutedTo("admin")
+	require.Nil(t, err)
+	require.Len(t, routed.Streams, 2)
+
+	room.Streams[1].Route = "stream map in JSON"
+	_, err = room.RoutedTo("admin")
+	require.NotNil(t, err)
+}
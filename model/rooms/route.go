@@ -0,0 +1,94 @@
+package roomsmodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Route represents a video stream redirection towards a broadcast target.
+type Route struct {
+	// Target identifies the audience the stream is redirected to (a role name or a username).
+	Target string
+
+	// URL is the broadcast endpoint serving the stream to target.
+	URL string
+}
+
+// Validate checks whether or not route is well formed.
+func (r *Route) Validate() error {
+	if len(r.Target) == 0 {
+		return fmt.Errorf("roomsmodel: empty route target")
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return fmt.Errorf("roomsmodel: invalid route %s url: %v", r.Target, err)
+	}
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("roomsmodel: invalid route %s url: %s", r.Target, r.URL)
+	}
+	return nil
+}
+
+// Routes represents the whole set of video stream redirections, sorted by target.
+type Routes []Route
+
+// ParseRoutes parses and validates a JSON encoded redirect map.
+func ParseRoutes(s string) (Routes, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	var m map[string]string
+	if err := json.NewDecoder(strings.NewReader(s)).Decode(&m); err != nil {
+		return nil, err
+	}
+	routes := make(Routes, 0, len(m))
+	for target, u := range m {
+		rt := Route{Target: target, URL: u}
+		if err := rt.Validate(); err != nil {
+			return nil, err
+		}
+		routes = append(routes, rt)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Target < routes[j].Target })
+	return routes, nil
+}
+
+// Lookup returns the broadcast URL associated to target.
+func (rs Routes) Lookup(target string) (string, bool) {
+	for _, rt := range rs {
+		if rt.Target == target {
+			return rt.URL, true
+		}
+	}
+	return "", false
+}
+
+// Set redirects target to a new broadcast URL, returning the updated routes.
+func (rs Routes) Set(route Route) Routes {
+	for i, rt := range rs {
+		if rt.Target == route.Target {
+			res := append(Routes(nil), rs...)
+			res[i] = route
+			return res
+		}
+	}
+	res := append(append(Routes(nil), rs...), route)
+	sort.Slice(res, func(i, j int) bool { return res[i].Target < res[j].Target })
+	return res
+}
+
+// String returns routes JSON encoded redirect map.
+func (rs Routes) String() string {
+	if len(rs) == 0 {
+		return ""
+	}
+	m := make(map[string]string, len(rs))
+	for _, rt := range rs {
+		m[rt.Target] = rt.URL
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
@@ -0,0 +1,60 @@
+package roomsmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoute_Validate(t *testing.T) {
+	require.Nil(t, (&Route{Target: "user", URL: "srt://127.0.0.1:9001"}).Validate())
+	require.NotNil(t, (&Route{URL: "srt://127.0.0.1:9001"}).Validate())
+	require.NotNil(t, (&Route{Target: "user"}).Validate())
+	require.NotNil(t, (&Route{Target: "user", URL: "127.0.0.1:9001"}).Validate())
+	require.NotNil(t, (&Route{Target: "user", URL: "srt://"}).Validate())
+}
+
+func TestRoutes_Parse(t *testing.T) {
+	routes, err := ParseRoutes("")
+	require.Nil(t, err)
+	require.Nil(t, routes)
+
+	_, err = ParseRoutes("stream map in JSON")
+	require.NotNil(t, err)
+
+	_, err = ParseRoutes(`{"user": "not an url"}`)
+	require.NotNil(t, err)
+
+	routes, err = ParseRoutes(`{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`)
+	require.Nil(t, err)
+	require.Equal(t, Routes{
+		{Target: "admin", URL: "srt://127.0.0.1:9002"},
+		{Target: "user", URL: "srt://127.0.0.1:9001"},
+	}, routes)
+
+	u, ok := routes.Lookup("user")
+	require.True(t, ok)
+	require.Equal(t, "srt://127.0.0.1:9001", u)
+	_, ok = routes.Lookup("room01")
+	require.False(t, ok)
+
+	// round trip
+	routes2, err := ParseRoutes(routes.String())
+	require.Nil(t, err)
+	require.Equal(t, routes, routes2)
+}
+
+func TestRoutes_Set(t *testing.T) {
+	routes := Routes{{Target: "user", URL: "srt://127.0.0.1:9001"}}
+
+	updated := routes.Set(Route{Target: "user", URL: "srt://127.0.0.1:9003"})
+	require.Equal(t, Routes{{Target: "user", URL: "srt://127.0.0.1:9003"}}, updated)
+	require.Equal(t, "srt://127.0.0.1:9001", routes[0].URL)
+
+	updated = updated.Set(Route{Target: "admin", URL: "srt://127.0.0.1:9002"})
+	require.Len(t, updated, 2)
+	require.Equal(t, "admin", updated[0].Target)
+	require.Equal(t, `{"admin":"srt://127.0.0.1:9002","user":"srt://127.0.0.1:9003"}`, updated.String())
+
+	require.Equal(t, "", Routes(nil).String())
+}
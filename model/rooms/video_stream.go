@@ -3,6 +3,8 @@ package roomsmodel
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // VideoType represents a video stream type.
@@ -55,10 +57,25 @@ type VideoStream struct {
 	Route  string
 	Type   VideoType
 	RoomID int
+
+	Metadata Metadata
+}
+
+// Routes parses video stream redirect map.
+func (v *VideoStream) Routes() (Routes, error) {
+	return ParseRoutes(v.Route)
 }
 
+// videoStreamFormatVersion is the current VideoStream binary format version.
+// Legacy payloads only carried input, broadcast and route definitions.
+const videoStreamFormatVersion = 1
+
 // FromBytes deserializes a VideoStream entiry from its binary representation.
 func (v *VideoStream) FromBytes(buf *bytes.Buffer) error {
+	version, err := serializer.ReadVersion(buf, videoStreamFormatVersion)
+	if err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&v.In); err != nil {
 		return err
@@ -66,11 +83,27 @@ func (v *VideoStream) FromBytes(buf *bytes.Buffer) error {
 	if err := dec.Decode(&v.Broadcast); err != nil {
 		return err
 	}
-	return dec.Decode(&v.Route)
+	if version == serializer.LegacyVersion {
+		return dec.Decode(&v.Route)
+	}
+	if err := dec.Decode(&v.Stream); err != nil {
+		return err
+	}
+	if err := dec.Decode(&v.Route); err != nil {
+		return err
+	}
+	if err := dec.Decode(&v.Type); err != nil {
+		return err
+	}
+	if err := dec.Decode(&v.RoomID); err != nil {
+		return err
+	}
+	return dec.Decode(&v.Metadata)
 }
 
 // ToBytes converts a VideoStream entiry to its binary representation.
 func (v *VideoStream) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, videoStreamFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(&v.In); err != nil {
 		return err
@@ -78,5 +111,17 @@ func (v *VideoStream) ToBytes(buf *bytes.Buffer) error {
 	if err := enc.Encode(&v.Broadcast); err != nil {
 		return err
 	}
-	return enc.Encode(&v.Route)
+	if err := enc.Encode(&v.Stream); err != nil {
+		return err
+	}
+	if err := enc.Encode(&v.Route); err != nil {
+		return err
+	}
+	if err := enc.Encode(&v.Type); err != nil {
+		return err
+	}
+	if err := enc.Encode(&v.RoomID); err != nil {
+		return err
+	}
+	return enc.Encode(&v.Metadata)
 }
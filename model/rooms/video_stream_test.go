@@ -2,8 +2,10 @@ package roomsmodel
 
 import (
 	"bytes"
+	"encoding/gob"
 	"reflect"
 	"testing"
+	"testing/quick"
 
 	"github.com/stretchr/testify/require"
 )
@@ -13,6 +15,7 @@ func TestVideoStream_Serialization(t *testing.T) {
 	vs.In = "room"
 	vs.Broadcast = "broadcast"
 	vs.Route = "stream map in JSON"
+	vs.Metadata = Metadata{Protocol: HLS, Codec: "h264", Width: 1920, Height: 1080, Framerate: 29.97, Bitrate: 4000, Latency: NormalLatency}
 
 	buf := bytes.NewBuffer(nil)
 	require.Nil(t, vs.ToBytes(buf))
@@ -22,3 +25,30 @@ func TestVideoStream_Serialization(t *testing.T) {
 
 	require.True(t, reflect.DeepEqual(&vs, &vs2))
 }
+
+func TestVideoStream_SerializationProperty(t *testing.T) {
+	f := func(vs VideoStream) bool {
+		buf := bytes.NewBuffer(nil)
+		if err := vs.ToBytes(buf); err != nil {
+			return false
+		}
+		var vs2 VideoStream
+		if err := vs2.FromBytes(buf); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(&vs, &vs2)
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
+
+func TestVideoStream_LegacySerialization(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := gob.NewEncoder(buf)
+	require.Nil(t, enc.Encode("room"))
+	require.Nil(t, enc.Encode("broadcast"))
+	require.Nil(t, enc.Encode("stream map in JSON"))
+
+	vs := VideoStream{}
+	require.Nil(t, vs.FromBytes(buf))
+	require.Equal(t, VideoStream{In: "room", Broadcast: "broadcast", Route: "stream map in JSON"}, vs)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/dantin/cubit/model/serializer"
 	"github.com/dantin/cubit/xmpp"
 	"github.com/dantin/cubit/xmpp/jid"
 )
@@ -104,8 +105,14 @@ func (ri *Item) ContactJID() *jid.JID {
 	return j
 }
 
+// itemFormatVersion is the current Item binary format version.
+const itemFormatVersion = 1
+
 // FromBytes deserializes a RosterItem entity from its binary representation.
 func (ri *Item) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, itemFormatVersion); err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&ri.Username); err != nil {
 		return err
@@ -130,6 +137,7 @@ func (ri *Item) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a RosterItem entity to its binary representation.
 func (ri *Item) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, itemFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(&ri.Username); err != nil {
 		return err
@@ -2,7 +2,9 @@ package rostermodel
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
+	"testing/quick"
 
 	"github.com/dantin/cubit/xmpp"
 	"github.com/stretchr/testify/require"
@@ -80,3 +82,21 @@ func TestItem_Serialize(t *testing.T) {
 	require.Nil(t, ri2.FromBytes(buf))
 	require.Equal(t, ri1, *ri2)
 }
+
+func TestItem_SerializationProperty(t *testing.T) {
+	f := func(ri1 Item) bool {
+		if len(ri1.Groups) == 0 {
+			ri1.Groups = nil // gob decodes empty slices as nil
+		}
+		buf := new(bytes.Buffer)
+		if err := ri1.ToBytes(buf); err != nil {
+			return false
+		}
+		var ri2 Item
+		if err := ri2.FromBytes(buf); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(&ri1, &ri2)
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/gob"
 
+	"github.com/dantin/cubit/model/serializer"
 	"github.com/dantin/cubit/xmpp"
 )
 
@@ -14,8 +15,14 @@ type Notification struct {
 	Presence *xmpp.Presence
 }
 
+// notificationFormatVersion is the current Notification binary format version.
+const notificationFormatVersion = 1
+
 // FromBytes deserializes a Notification entity from its binary representation.
 func (rn *Notification) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, notificationFormatVersion); err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&rn.Contact); err != nil {
 		return err
@@ -33,6 +40,7 @@ func (rn *Notification) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a Notification entity to its binary representation.
 func (rn *Notification) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, notificationFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(&rn.Contact); err != nil {
 		return err
@@ -3,6 +3,7 @@ package rostermodel
 import (
 	"bytes"
 	"testing"
+	"testing/quick"
 
 	"github.com/dantin/cubit/xmpp"
 	"github.com/dantin/cubit/xmpp/jid"
@@ -29,3 +30,28 @@ func TestModelRosterNotification(t *testing.T) {
 	require.NotNil(t, rn2.Presence)
 	require.Equal(t, rn1.Presence.String(), rn2.Presence.String())
 }
+
+func TestModelRosterNotification_SerializationProperty(t *testing.T) {
+	j, _ := jid.NewWithString("alex@example.org/desktop", true)
+
+	f := func(contact, jidStr, status string) bool {
+		presence := xmpp.NewPresence(j, j, xmpp.AvailableType)
+		statusEl := xmpp.NewElementName("status")
+		statusEl.SetText(status)
+		presence.AppendElement(statusEl)
+
+		rn1 := Notification{Contact: contact, JID: jidStr, Presence: presence}
+		buf := new(bytes.Buffer)
+		if err := rn1.ToBytes(buf); err != nil {
+			return false
+		}
+		var rn2 Notification
+		if err := rn2.FromBytes(buf); err != nil {
+			return false
+		}
+		return rn1.Contact == rn2.Contact &&
+			rn1.JID == rn2.JID &&
+			rn2.Presence != nil && rn1.Presence.String() == rn2.Presence.String()
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
@@ -3,6 +3,8 @@ package rostermodel
 import (
 	"bytes"
 	"encoding/gob"
+
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // Version represents a roster version info.
@@ -11,8 +13,14 @@ type Version struct {
 	DeletionVer int
 }
 
+// versionFormatVersion is the current Version binary format version.
+const versionFormatVersion = 1
+
 // FromBytes deserializes a Version from its binary representation.
 func (rv *Version) FromBytes(buf *bytes.Buffer) error {
+	if _, err := serializer.ReadVersion(buf, versionFormatVersion); err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&rv.Ver); err != nil {
 		return err
@@ -22,6 +30,7 @@ func (rv *Version) FromBytes(buf *bytes.Buffer) error {
 
 // ToBytes converts a Version entity to its binary representation.
 func (rv *Version) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, versionFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(&rv.Ver); err != nil {
 		return err
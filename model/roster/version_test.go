@@ -2,7 +2,9 @@ package rostermodel
 
 import (
 	"bytes"
+	"encoding/gob"
 	"testing"
+	"testing/quick"
 
 	"github.com/stretchr/testify/require"
 )
@@ -15,3 +17,29 @@ func TestModelRosterVersion(t *testing.T) {
 	require.Nil(t, rv2.FromBytes(buf))
 	require.Equal(t, rv1, rv2)
 }
+
+func TestModelRosterVersion_SerializationProperty(t *testing.T) {
+	f := func(rv1 Version) bool {
+		buf := new(bytes.Buffer)
+		if err := rv1.ToBytes(buf); err != nil {
+			return false
+		}
+		var rv2 Version
+		if err := rv2.FromBytes(buf); err != nil {
+			return false
+		}
+		return rv1 == rv2
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
+
+func TestModelRosterVersion_LegacySerialization(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := gob.NewEncoder(buf)
+	require.Nil(t, enc.Encode(2))
+	require.Nil(t, enc.Encode(1))
+
+	var rv Version
+	require.Nil(t, rv.FromBytes(buf))
+	require.Equal(t, Version{Ver: 2, DeletionVer: 1}, rv)
+}
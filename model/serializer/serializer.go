@@ -3,12 +3,24 @@ package serializer
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
 
 	"github.com/dantin/cubit/util/pool"
 )
 
+// LegacyVersion is the format version of payloads written before entities carried a version header.
+const LegacyVersion uint8 = 0
+
+// versionMarker starts every version header. Gob streams always begin with a non-zero message length,
+// so that a leading zero byte tells versioned payloads apart from legacy ones.
+const versionMarker byte = 0
+
+// ErrUnsupportedVersion will be returned by ReadVersion when payload was written using a newer format.
+var ErrUnsupportedVersion = errors.New("serializer: unsupported format version")
+
 var bufPool = pool.NewBufferPool()
 
 // Serializer represents a gob serializable entity.
@@ -21,6 +33,34 @@ type Deserializer interface {
 	FromBytes(buf *bytes.Buffer) error
 }
 
+// WriteVersion writes an entity format version header.
+func WriteVersion(buf *bytes.Buffer, version uint8) {
+	buf.WriteByte(versionMarker)
+	buf.WriteByte(version)
+}
+
+// ReadVersion reads an entity format version header, returning LegacyVersion if payload carries none.
+// Versions newer than latest are rejected.
+func ReadVersion(buf *bytes.Buffer, latest uint8) (uint8, error) {
+	b := buf.Bytes()
+	if len(b) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if b[0] != versionMarker {
+		return LegacyVersion, nil
+	}
+	if len(b) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	buf.Next(2)
+
+	version := b[1]
+	if version > latest {
+		return 0, ErrUnsupportedVersion
+	}
+	return version, nil
+}
+
 // Serialize converts a serializable entity into its bytes representation.
 func Serialize(serializer Serializer) ([]byte, error) {
 	buf := bufPool.Get()
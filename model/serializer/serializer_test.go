@@ -3,6 +3,7 @@ package serializer
 import (
 	"bytes"
 	"encoding/gob"
+	"io"
 	"reflect"
 	"testing"
 
@@ -51,3 +52,37 @@ func TestSerializeSlice(t *testing.T) {
 	require.Nil(t, err)
 	require.True(t, reflect.DeepEqual(&v1, &v2))
 }
+
+func TestVersion(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	WriteVersion(buf, 2)
+	buf.WriteString("payload")
+
+	version, err := ReadVersion(buf, 2)
+	require.Nil(t, err)
+	require.Equal(t, uint8(2), version)
+	require.Equal(t, "payload", buf.String())
+
+	// legacy payload
+	v1 := mockType{someField: "foo value"}
+	buf = bytes.NewBuffer(nil)
+	require.Nil(t, v1.ToBytes(buf))
+	ln := buf.Len()
+
+	version, err = ReadVersion(buf, 2)
+	require.Nil(t, err)
+	require.Equal(t, LegacyVersion, version)
+	require.Equal(t, ln, buf.Len())
+
+	// newer format
+	buf = bytes.NewBuffer(nil)
+	WriteVersion(buf, 3)
+	_, err = ReadVersion(buf, 2)
+	require.Equal(t, ErrUnsupportedVersion, err)
+
+	// truncated
+	_, err = ReadVersion(bytes.NewBuffer(nil), 2)
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = ReadVersion(bytes.NewBuffer([]byte{0}), 2)
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -5,46 +5,60 @@ import (
 	"encoding/gob"
 	"time"
 
+	"github.com/dantin/cubit/model/serializer"
 	"github.com/dantin/cubit/xmpp"
 )
 
-// Role represents a user role type.
-type Role int
+// Role represents a user role name.
+type Role string
 
 const (
 	// Unknown represents a unknown role.
-	Unknown Role = iota
+	Unknown Role = ""
 	// Root represents a root role.
-	Root
+	Root Role = "root"
 	// Admin represents a admin role.
-	Admin
+	Admin Role = "admin"
 	// Usr represents a user role.
-	Usr
+	Usr Role = "user"
 )
 
 func (r Role) String() string {
-	switch r {
-	case Root:
-		return "root"
-	case Admin:
-		return "admin"
-	case Usr:
-		return "user"
-	}
-	return "unknown"
+	if r == Unknown {
+		return "unknown"
+	}
+	return string(r)
 }
 
-// ParseRoleString parses string to Role.
+// legacyRoles maps roles to their former integer representation.
+var legacyRoles = map[int]Role{1: Root, 2: Admin, 3: Usr}
+
+// maxRoleNameLength is the longest role name allowed.
+const maxRoleNameLength = 64
+
+// IsValidRoleName tells whether or not s is a well-formed role name, that is,
+// a lowercase identifier made of letters, digits, '.', '_' or '-' other than 'unknown'.
+func IsValidRoleName(s string) bool {
+	if len(s) == 0 || len(s) > maxRoleNameLength || s == Unknown.String() {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseRoleString parses string to Role. Unknown is returned for malformed role names.
 func ParseRoleString(s string) Role {
-	switch s {
-	case "root":
-		return Root
-	case "admin":
-		return Admin
-	case "user":
-		return Usr
-	}
-	return Unknown
+	if !IsValidRoleName(s) {
+		return Unknown
+	}
+	return Role(s)
 }
 
 // User represents a user storage entity.
@@ -56,8 +70,16 @@ type User struct {
 	LastPresenceAt time.Time
 }
 
+// userFormatVersion is the current User binary format version.
+// Legacy payloads stored role as an integer, and only carried last presence time along with last presence.
+const userFormatVersion = 1
+
 // FromBytes deserializes a User entity from it's gob binary representation.
 func (u *User) FromBytes(buf *bytes.Buffer) error {
+	version, err := serializer.ReadVersion(buf, userFormatVersion)
+	if err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(buf)
 	if err := dec.Decode(&u.Username); err != nil {
 		return err
@@ -65,7 +87,13 @@ func (u *User) FromBytes(buf *bytes.Buffer) error {
 	if err := dec.Decode(&u.Password); err != nil {
 		return err
 	}
-	if err := dec.Decode(&u.Role); err != nil {
+	if version == serializer.LegacyVersion {
+		var role int
+		if err := dec.Decode(&role); err != nil {
+			return err
+		}
+		u.Role = legacyRoles[role]
+	} else if err := dec.Decode(&u.Role); err != nil {
 		return err
 	}
 	var hasPresence bool
@@ -78,15 +106,16 @@ func (u *User) FromBytes(buf *bytes.Buffer) error {
 			return err
 		}
 		u.LastPresence = p
-		if err := dec.Decode(&u.LastPresenceAt); err != nil {
-			return err
-		}
+	}
+	if hasPresence || version != serializer.LegacyVersion {
+		return dec.Decode(&u.LastPresenceAt)
 	}
 	return nil
 }
 
 // ToBytes converts a User entity to it's gob binary representation.
 func (u *User) ToBytes(buf *bytes.Buffer) error {
+	serializer.WriteVersion(buf, userFormatVersion)
 	enc := gob.NewEncoder(buf)
 	if err := enc.Encode(&u.Username); err != nil {
 		return err
@@ -105,8 +134,6 @@ func (u *User) ToBytes(buf *bytes.Buffer) error {
 		if err := u.LastPresence.ToBytes(buf); err != nil {
 			return err
 		}
-		u.LastPresenceAt = time.Now()
-		return enc.Encode(&u.LastPresenceAt)
 	}
-	return nil
+	return enc.Encode(&u.LastPresenceAt)
 }
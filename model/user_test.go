@@ -2,7 +2,9 @@ package model
 
 import (
 	"bytes"
+	"encoding/gob"
 	"testing"
+	"testing/quick"
 	"time"
 
 	"github.com/dantin/cubit/xmpp"
@@ -20,6 +22,7 @@ func TestModelUser(t *testing.T) {
 	user1.Password = "passwd"
 	user1.Role = Usr
 	user1.LastPresence = xmpp.NewPresence(j1, j2, xmpp.AvailableType)
+	user1.LastPresenceAt = time.Date(2020, 9, 30, 8, 0, 0, 0, time.UTC)
 
 	buf := new(bytes.Buffer)
 	require.Nil(t, user1.ToBytes(buf))
@@ -29,5 +32,87 @@ func TestModelUser(t *testing.T) {
 	require.Equal(t, user1.Password, user2.Password)
 	require.Equal(t, user1.Role, user2.Role)
 	require.Equal(t, user1.LastPresence.String(), user2.LastPresence.String())
-	require.NotEqual(t, time.Time{}, user2.LastPresenceAt)
+	require.Equal(t, user1.LastPresenceAt, user2.LastPresenceAt)
+}
+
+func TestModelUser_SerializationProperty(t *testing.T) {
+	j, _ := jid.NewWithString("username@example.org/desktop", true)
+
+	f := func(username, password string, role Role, withPresence bool, sec int64) bool {
+		user1 := User{Username: username, Password: password, Role: role}
+		if withPresence {
+			user1.LastPresence = xmpp.NewPresence(j, j, xmpp.AvailableType)
+		}
+		user1.LastPresenceAt = time.Unix(sec%(1<<32), 0).UTC()
+
+		buf := new(bytes.Buffer)
+		if err := user1.ToBytes(buf); err != nil {
+			return false
+		}
+		var user2 User
+		if err := user2.FromBytes(buf); err != nil {
+			return false
+		}
+		if withPresence != (user2.LastPresence != nil) {
+			return false
+		}
+		if withPresence && user1.LastPresence.String() != user2.LastPresence.String() {
+			return false
+		}
+		return user1.Username == user2.Username &&
+			user1.Password == user2.Password &&
+			user1.Role == user2.Role &&
+			user1.LastPresenceAt.Equal(user2.LastPresenceAt)
+	}
+	require.Nil(t, quick.Check(f, nil))
+}
+
+func TestModelUser_LegacySerialization(t *testing.T) {
+	type legacyRole int // integer role enumeration: unknown, root, admin, user
+
+	buf := new(bytes.Buffer)
+	enc := gob.NewEncoder(buf)
+	require.Nil(t, enc.Encode("username"))
+	require.Nil(t, enc.Encode("passwd"))
+	require.Nil(t, enc.Encode(legacyRole(3)))
+	require.Nil(t, enc.Encode(false))
+
+	var user User
+	require.Nil(t, user.FromBytes(buf))
+	require.Equal(t, User{Username: "username", Password: "passwd", Role: Usr}, user)
+
+	// last presence time follows last presence
+	j, _ := jid.NewWithString("username@example.org/res", true)
+	p := xmpp.NewPresence(j, j, xmpp.AvailableType)
+	lastPresenceAt := time.Now().UTC().Truncate(time.Second)
+
+	buf = new(bytes.Buffer)
+	enc = gob.NewEncoder(buf)
+	require.Nil(t, enc.Encode("username"))
+	require.Nil(t, enc.Encode("passwd"))
+	require.Nil(t, enc.Encode(legacyRole(2)))
+	require.Nil(t, enc.Encode(true))
+	require.Nil(t, p.ToBytes(buf))
+	require.Nil(t, enc.Encode(lastPresenceAt))
+
+	user = User{}
+	require.Nil(t, user.FromBytes(buf))
+	require.Equal(t, Admin, user.Role)
+	require.Equal(t, p.String(), user.LastPresence.String())
+	require.True(t, lastPresenceAt.Equal(user.LastPresenceAt))
+}
+
+func TestModelUser_Role(t *testing.T) {
+	require.Equal(t, "admin", Admin.String())
+	require.Equal(t, "unknown", Unknown.String())
+
+	require.Equal(t, Root, ParseRoleString("root"))
+	require.Equal(t, Role("operator"), ParseRoleString("operator"))
+	require.Equal(t, Unknown, ParseRoleString("unknown"))
+	require.Equal(t, Unknown, ParseRoleString(""))
+	require.Equal(t, Unknown, ParseRoleString("Operator "))
+	require.Equal(t, Unknown, ParseRoleString("1st-line"))
+
+	require.True(t, IsValidRoleName("qc.reviewer_2"))
+	require.False(t, IsValidRoleName("qc reviewer"))
 }
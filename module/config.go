@@ -6,9 +6,11 @@ import (
 	"github.com/dantin/cubit/module/offline"
 	"github.com/dantin/cubit/module/roster"
 	"github.com/dantin/cubit/module/ultrasound"
+	"github.com/dantin/cubit/module/xep0045"
 	"github.com/dantin/cubit/module/xep0077"
 	"github.com/dantin/cubit/module/xep0092"
 	"github.com/dantin/cubit/module/xep0199"
+	"github.com/dantin/cubit/module/xep0215"
 )
 
 // Config represents C2S modules configuration.
@@ -17,9 +19,11 @@ type Config struct {
 	Roster       roster.Config
 	Offline      offline.Config
 	Ultrasound   ultrasound.Config
+	MUC          xep0045.Config
 	Registration xep0077.Config
 	Version      xep0092.Config
 	Ping         xep0199.Config
+	ExtDisco     xep0215.Config
 }
 
 type configProxy struct {
@@ -27,9 +31,11 @@ type configProxy struct {
 	Roster       roster.Config     `yaml:"mod_roster"`
 	Offline      offline.Config    `yaml:"mod_offline"`
 	Ultrasound   ultrasound.Config `yaml:"mod_ultrasound"`
+	MUC          xep0045.Config    `yaml:"mod_muc"`
 	Registration xep0077.Config    `yaml:"mod_registration"`
 	Version      xep0092.Config    `yaml:"mod_version"`
 	Ping         xep0199.Config    `yaml:"mod_ping"`
+	ExtDisco     xep0215.Config    `yaml:"mod_external_services"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -42,8 +48,8 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	enabled := make(map[string]struct{}, len(p.Enabled))
 	for _, mod := range p.Enabled {
 		switch mod {
-		case "roster", "last_activity", "private", "vcard", "registration", "pep", "version", "blocking_command",
-			"ping", "offline", "ultrasound":
+		case "roster", "last_activity", "private", "adhoc_commands", "vcard", "registration", "pep", "version",
+			"blocking_command", "ping", "offline", "ultrasound", "muc", "external_services", "jingle":
 			break
 		default:
 			return fmt.Errorf("module.Config: unrecognized module: %s", mod)
@@ -54,8 +60,10 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	cfg.Roster = p.Roster
 	cfg.Offline = p.Offline
 	cfg.Ultrasound = p.Ultrasound
+	cfg.MUC = p.MUC
 	cfg.Registration = p.Registration
 	cfg.Version = p.Version
 	cfg.Ping = p.Ping
+	cfg.ExtDisco = p.ExtDisco
 	return nil
 }
@@ -9,14 +9,18 @@ import (
 	"github.com/dantin/cubit/module/ultrasound"
 	"github.com/dantin/cubit/module/xep0012"
 	"github.com/dantin/cubit/module/xep0030"
+	"github.com/dantin/cubit/module/xep0045"
 	"github.com/dantin/cubit/module/xep0049"
+	"github.com/dantin/cubit/module/xep0050"
 	"github.com/dantin/cubit/module/xep0054"
 	"github.com/dantin/cubit/module/xep0077"
 	"github.com/dantin/cubit/module/xep0092"
 	"github.com/dantin/cubit/module/xep0115"
 	"github.com/dantin/cubit/module/xep0163"
+	"github.com/dantin/cubit/module/xep0166"
 	"github.com/dantin/cubit/module/xep0191"
 	"github.com/dantin/cubit/module/xep0199"
+	"github.com/dantin/cubit/module/xep0215"
 	"github.com/dantin/cubit/router"
 	"github.com/dantin/cubit/storage/repository"
 	"github.com/dantin/cubit/xmpp"
@@ -46,13 +50,17 @@ type Modules struct {
 	Ultrasound   *ultrasound.Ultrasound
 	LastActivity *xep0012.LastActivity
 	Private      *xep0049.Private
+	AdHoc        *xep0050.AdHoc
 	DiscoInfo    *xep0030.DiscoInfo
+	MUC          *xep0045.MUC
 	VCard        *xep0054.VCard
 	Register     *xep0077.Register
 	Version      *xep0092.Version
 	Pep          *xep0163.Pep
+	Jingle       *xep0166.Jingle
 	BlockingCmd  *xep0191.BlockingCommand
 	Ping         *xep0199.Ping
+	ExtDisco     *xep0215.ExternalServices
 
 	router     router.Router
 	iqHandlers []IQHandler
@@ -84,6 +92,13 @@ func New(config *Config, router router.Router, reps repository.Container, alloca
 		m.all = append(m.all, m.Private)
 	}
 
+	// XEP-0050: Ad-Hoc Commands (https://xmpp.org/extensions/xep-0050.html)
+	if _, ok := config.Enabled["adhoc_commands"]; ok {
+		m.AdHoc = xep0050.New(m.DiscoInfo, router)
+		m.iqHandlers = append(m.iqHandlers, m.AdHoc)
+		m.all = append(m.all, m.AdHoc)
+	}
+
 	// XEP-0054: vcard-temp (https://xmpp.org/extensions/xep-0054.html)
 	if _, ok := config.Enabled["vcard"]; ok {
 		m.VCard = xep0054.New(m.DiscoInfo, router, reps.VCard())
@@ -93,7 +108,7 @@ func New(config *Config, router router.Router, reps repository.Container, alloca
 
 	// XEP-0077: In-band registration (https://xmpp.org/extensions/xep-0077.html)
 	if _, ok := config.Enabled["registration"]; ok {
-		m.Register = xep0077.New(&config.Registration, m.DiscoInfo, router, reps.User())
+		m.Register = xep0077.New(&config.Registration, m.DiscoInfo, router, reps.User(), reps.Audit())
 		m.iqHandlers = append(m.iqHandlers, m.Register)
 		m.all = append(m.all, m.Register)
 	}
@@ -107,9 +122,33 @@ func New(config *Config, router router.Router, reps repository.Container, alloca
 
 	// XEP-ultrasound: customized protocol
 	if _, ok := config.Enabled["ultrasound"]; ok {
-		m.Ultrasound = ultrasound.New(&config.Ultrasound, m.DiscoInfo, router, reps.User(), reps.Room())
+		m.Ultrasound = ultrasound.New(&config.Ultrasound, m.DiscoInfo, router, reps.User(), reps.Room(), reps.Device(), reps.QC(), reps.Reservation(), reps.Role(), reps.Audit())
 		m.iqHandlers = append(m.iqHandlers, m.Ultrasound)
 		m.all = append(m.all, m.Ultrasound)
+
+		// expire room viewers on disconnection
+		router.RegisterUnbindHandler(m.Ultrasound)
+
+		// remote device control commands
+		if m.AdHoc != nil {
+			for _, cmd := range m.Ultrasound.Commands() {
+				m.AdHoc.RegisterCommand(cmd)
+			}
+		}
+	}
+
+	// XEP-0045: Multi-User Chat (https://xmpp.org/extensions/xep-0045.html)
+	if _, ok := config.Enabled["muc"]; ok {
+		m.MUC = xep0045.New(&config.MUC, m.DiscoInfo, router, reps.User(), reps.Room(), reps.MUC(), reps.Role())
+		m.all = append(m.all, m.MUC)
+
+		// leave occupied rooms on disconnection
+		router.RegisterUnbindHandler(m.MUC)
+
+		// keep chat rooms linked to ultrasound rooms
+		if m.Ultrasound != nil {
+			m.Ultrasound.RegisterRoomHandler(m.MUC)
+		}
 	}
 
 	// XEP-0160: Offline message storage (https://xmpp.org/extensions/xep-0160.html)
@@ -128,7 +167,7 @@ func New(config *Config, router router.Router, reps repository.Container, alloca
 
 	// XEP-0191: Blocking Command (https://xmpp.org/extensions/xep-0191.html)
 	if _, ok := config.Enabled["blocking_command"]; ok {
-		m.BlockingCmd = xep0191.New(m.DiscoInfo, presenceHub, router, reps.Roster(), reps.BlockList())
+		m.BlockingCmd = xep0191.New(m.DiscoInfo, presenceHub, router, reps.Roster(), reps.BlockList(), reps.Audit())
 		m.iqHandlers = append(m.iqHandlers, m.BlockingCmd)
 		m.all = append(m.all, m.BlockingCmd)
 	}
@@ -138,6 +177,28 @@ func New(config *Config, router router.Router, reps repository.Container, alloca
 		m.Ping = xep0199.New(&config.Ping, m.DiscoInfo, router)
 		m.iqHandlers = append(m.iqHandlers, m.Ping)
 		m.all = append(m.all, m.Ping)
+
+		// track ultrasound device heartbeats
+		if m.Ultrasound != nil {
+			m.Ping.RegisterHeartbeatHandler(m.Ultrasound)
+		}
+	}
+
+	// XEP-0166: Jingle (https://xmpp.org/extensions/xep-0166.html)
+	if _, ok := config.Enabled["jingle"]; ok {
+		m.Jingle = xep0166.New(router, reps.Room(), reps.Device())
+		m.all = append(m.all, m.Jingle)
+
+		// track session signaling and tear down sessions on disconnection
+		router.RegisterInterceptor(m.Jingle)
+		router.RegisterUnbindHandler(m.Jingle)
+	}
+
+	// XEP-0215: External Service Discovery (https://xmpp.org/extensions/xep-0215.html)
+	if _, ok := config.Enabled["external_services"]; ok {
+		m.ExtDisco = xep0215.New(&config.ExtDisco, m.DiscoInfo, router, reps.User(), reps.Room(), reps.Role())
+		m.iqHandlers = append(m.iqHandlers, m.ExtDisco)
+		m.all = append(m.all, m.ExtDisco)
 	}
 
 	// Roster (https://xmpp.org/rfcs/rfc3921.html#roster)
@@ -147,6 +208,11 @@ func New(config *Config, router router.Router, reps repository.Container, alloca
 		m.Roster = roster.New(&config.Roster, presenceHub, m.Pep, router, reps.User(), reps.Roster())
 		m.iqHandlers = append(m.iqHandlers, m.Roster)
 		m.all = append(m.all, m.Roster)
+
+		// track room viewers joining or leaving through directed presences
+		if m.Ultrasound != nil {
+			m.Roster.RegisterPresenceHandler(m.Ultrasound)
+		}
 	}
 
 	return m
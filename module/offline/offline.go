@@ -16,6 +16,8 @@ const offlineNamespace = "msgoffline"
 
 const hintsNamespace = "urn:xmpp:hints"
 
+const jingleMessageNamespace = "urn:xmpp:jingle-message:0"
+
 const offlineDeliveredCtxKey = "offline:delivered"
 
 // Offline represents an offline server stream module.
@@ -122,5 +124,10 @@ func isMessageArchivable(message *xmpp.Message) bool {
 	if message.Elements().ChildNamespace("store", hintsNamespace) != nil {
 		return true
 	}
+	// ring offline resources about incoming calls (XEP-0353)
+	if message.Elements().ChildNamespace("propose", jingleMessageNamespace) != nil ||
+		message.Elements().ChildNamespace("retract", jingleMessageNamespace) != nil {
+		return true
+	}
 	return message.IsNormal() || (message.IsChat() && message.IsMessageWithBody())
 }
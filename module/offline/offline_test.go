@@ -70,6 +70,16 @@ func TestModule_Offline_ArchiveMessage(t *testing.T) {
 	require.Equal(t, msgID, elem.ID())
 }
 
+func TestModule_Offline_IsMessageArchivable(t *testing.T) {
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
+	require.False(t, isMessageArchivable(msg))
+
+	propose := xmpp.NewElementNamespace("propose", jingleMessageNamespace)
+	propose.SetAttribute("id", "s1")
+	msg.AppendElement(propose)
+	require.True(t, isMessageArchivable(msg))
+}
+
 func setupTest(domain string) (router.Router, *memorystorage.Offline) {
 	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
 
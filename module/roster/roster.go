@@ -28,15 +28,21 @@ type Config struct {
 	Versioning bool `yaml:"versioning"`
 }
 
+// PresenceHandler is notified of every available or unavailable presence directed to a contact.
+type PresenceHandler interface {
+	DirectedPresence(ctx context.Context, presence *xmpp.Presence)
+}
+
 // Roster represents a roster server stream module.
 type Roster struct {
-	cfg        *Config
-	runQueue   *runqueue.RunQueue
-	router     router.Router
-	userRep    repository.User
-	rosterRep  repository.Roster
-	pep        *xep0163.Pep
-	entityCaps *xep0115.EntityCaps
+	cfg              *Config
+	runQueue         *runqueue.RunQueue
+	router           router.Router
+	userRep          repository.User
+	rosterRep        repository.Roster
+	pep              *xep0163.Pep
+	entityCaps       *xep0115.EntityCaps
+	presenceHandlers []PresenceHandler
 }
 
 // New returns a roster server stream module.
@@ -71,6 +77,11 @@ func (x *Roster) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
 	})
 }
 
+// RegisterPresenceHandler registers a new directed presence handler.
+func (x *Roster) RegisterPresenceHandler(h PresenceHandler) {
+	x.runQueue.Run(func() { x.presenceHandlers = append(x.presenceHandlers, h) })
+}
+
 // ProcessPresence process an incoming roster presence.
 func (x *Roster) ProcessPresence(ctx context.Context, presence *xmpp.Presence) {
 	x.runQueue.Run(func() {
@@ -618,6 +629,9 @@ func (x *Roster) processAvailablePresence(ctx context.Context, presence *xmpp.Pr
 	if replyOnBehalf {
 		return x.broadcastPresence(ctx, presence)
 	}
+	for _, h := range x.presenceHandlers {
+		h.DirectedPresence(ctx, presence)
+	}
 	_ = x.router.Route(ctx, presence)
 	return nil
 }
@@ -0,0 +1,344 @@
+package ultrasound
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dantin/cubit/log"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/xmpp"
+)
+
+const (
+	createAction = "create"
+	updateAction = "update"
+	deleteAction = "delete"
+)
+
+func (x *Ultrasound) processSetIQ(ctx context.Context, iq *xmpp.IQ) {
+	e := iq.Elements()
+	if room := e.ChildNamespace("room", ultrasoundNamespace); room != nil {
+		if x.authorize(ctx, iq, rolesmodel.RoomsEdit) {
+			x.setRoom(ctx, iq, room)
+		}
+	} else if video := e.ChildNamespace("video_stream", ultrasoundNamespace); video != nil {
+		if x.authorize(ctx, iq, rolesmodel.RoomsEdit) {
+			x.setVideoStream(ctx, iq, video)
+		}
+	} else if binding := e.ChildNamespace("binding", ultrasoundNamespace); binding != nil {
+		if x.authorize(ctx, iq, rolesmodel.RoomsEdit) {
+			x.setBinding(ctx, iq, binding)
+		}
+	} else if device := e.ChildNamespace("device", ultrasoundNamespace); device != nil {
+		if x.authorize(ctx, iq, rolesmodel.DeviceEdit) {
+			x.setDevice(ctx, iq, device)
+		}
+	} else if role := e.ChildNamespace("role", ultrasoundNamespace); role != nil {
+		if x.authorize(ctx, iq, rolesmodel.RolesEdit) {
+			x.setRole(ctx, iq, role)
+		}
+	} else if userRole := e.ChildNamespace("user_role", ultrasoundNamespace); userRole != nil {
+		if x.authorize(ctx, iq, rolesmodel.RolesEdit) {
+			x.setUserRole(ctx, iq, userRole)
+		}
+	} else {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+	}
+}
+
+// authorize tells whether IQ sender has been granted a given permission,
+// replying with the corresponding stanza error otherwise.
+func (x *Ultrasound) authorize(ctx context.Context, iq *xmpp.IQ, perm rolesmodel.Permission) bool {
+	username := iq.FromJID().Node()
+	ok, err := x.authz.IsAllowed(ctx, username, perm)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return false
+	}
+	if !ok {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return false
+	}
+	return true
+}
+
+func (x *Ultrasound) setRoom(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	switch el.Attributes().Get("action") {
+	case createAction:
+		x.createRoom(ctx, iq, el)
+	case updateAction:
+		x.updateRoom(ctx, iq, el)
+	case deleteAction:
+		x.deleteRoom(ctx, iq, el)
+	default:
+		_ = x.router.Route(ctx, iq.BadRequestError())
+	}
+}
+
+func (x *Ultrasound) createRoom(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	attrs := el.Attributes()
+	room := roomsmodel.Room{
+		Name:     attrs.Get("name"),
+		Username: attrs.Get("username"),
+		Type:     roomsmodel.Normal,
+	}
+	if tp := attrs.Get("type"); len(tp) > 0 {
+		room.Type = roomsmodel.ParseRoomTypeString(tp)
+	}
+	if len(room.Name) == 0 || len(room.Username) == 0 || room.Type.String() == "" {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	existing, err := x.roomRep.FetchRoom(ctx, room.Username)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if existing != nil {
+		_ = x.router.Route(ctx, iq.ConflictError())
+		return
+	}
+	if err := x.roomRep.UpsertRoom(ctx, &room); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("room %d (%s) created by %s", room.ID, room.Name, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.RoomCreate, room.ID, room.Username, room.Name)
+
+	result := iq.ResultIQ()
+	roomNode := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	roomNode.SetAttribute("room_id", fmt.Sprintf("%d", room.ID))
+	result.AppendElement(roomNode)
+	_ = x.router.Route(ctx, result)
+
+	x.notifyRoomChange(ctx, room.ID, createAction, nil)
+}
+
+func (x *Ultrasound) updateRoom(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	attrs := el.Attributes()
+	if name := attrs.Get("name"); len(name) > 0 {
+		room.Name = name
+	}
+	if tp := attrs.Get("type"); len(tp) > 0 {
+		room.Type = roomsmodel.ParseRoomTypeString(tp)
+		if room.Type.String() == "" {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+			return
+		}
+	}
+	if username := attrs.Get("username"); len(username) > 0 && username != room.Username {
+		existing, err := x.roomRep.FetchRoom(ctx, username)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		if existing != nil {
+			_ = x.router.Route(ctx, iq.ConflictError())
+			return
+		}
+		room.Username = username
+	}
+	if err := x.roomRep.UpsertRoom(ctx, room); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("room %d updated by %s", room.ID, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.RoomUpdate, room.ID, room.Username, room.Name)
+	_ = x.router.Route(ctx, iq.ResultIQ())
+
+	x.notifyRoomChange(ctx, room.ID, updateAction, nil)
+}
+
+func (x *Ultrasound) deleteRoom(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, room.ID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if err := x.roomRep.DeleteRoom(ctx, room.ID); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if err := x.unbindRoomDevices(ctx, room.ID); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if err := x.deleteRoomReservations(ctx, room.ID); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if err := x.closeRoomQCSessions(ctx, room.ID); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	delete(x.viewers, room.ID)
+
+	log.Infof("room %d deleted by %s", room.ID, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.RoomDelete, room.ID, room.Username, room.Name)
+	_ = x.router.Route(ctx, iq.ResultIQ())
+
+	x.notifyRoomChange(ctx, room.ID, deleteAction, subscribers)
+}
+
+func (x *Ultrasound) setVideoStream(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	attrs := el.Attributes()
+	action := attrs.Get("action")
+	if action != createAction && action != updateAction && action != deleteAction {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	tp := roomsmodel.ParseVideoTypeString(attrs.Get("type"))
+	if tp == roomsmodel.Unknown {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	var existing *roomsmodel.VideoStream
+	for i, vs := range room.Streams {
+		if vs.Type == tp {
+			existing = &room.Streams[i]
+			break
+		}
+	}
+	switch {
+	case action == createAction && existing != nil:
+		_ = x.router.Route(ctx, iq.ConflictError())
+		return
+	case action != createAction && existing == nil:
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+
+	if action == deleteAction {
+		if err := x.roomRep.DeleteVideoStream(ctx, room.ID, tp); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		log.Infof("%s stream of room %d deleted by %s", tp.String(), room.ID, iq.FromJID().Node())
+		x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.StreamDelete, room.ID, tp.String(), "")
+		_ = x.router.Route(ctx, iq.ResultIQ())
+
+		x.notifyRoomChange(ctx, room.ID, updateAction, nil)
+		return
+	}
+
+	vs := roomsmodel.VideoStream{Type: tp, RoomID: room.ID}
+	if existing != nil {
+		vs = *existing
+	}
+	if in := attrs.Get("in"); len(in) > 0 {
+		vs.In = in
+	}
+	if broadcast := attrs.Get("broadcast"); len(broadcast) > 0 {
+		vs.Broadcast = broadcast
+	}
+	if route := strings.TrimSpace(el.Text()); len(route) > 0 {
+		vs.Route = route
+		if _, err := vs.Routes(); err != nil {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+			return
+		}
+	}
+	if err := readStreamMetadata(&vs.Metadata, attrs); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if err := x.roomRep.UpsertVideoStream(ctx, &vs); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("%s stream of room %d %sd by %s", tp.String(), room.ID, action, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.StreamUpdate, room.ID, tp.String(), vs.Route)
+	_ = x.router.Route(ctx, iq.ResultIQ())
+
+	x.notifyRoomChange(ctx, room.ID, updateAction, nil)
+}
+
+// readStreamMetadata updates md from protocol, codec, resolution, framerate, bitrate and latency attributes,
+// leaving unspecified properties untouched.
+func readStreamMetadata(md *roomsmodel.Metadata, attrs xmpp.AttributeSet) error {
+	if v := attrs.Get("protocol"); len(v) > 0 {
+		if md.Protocol = roomsmodel.ParseProtocolString(v); md.Protocol == roomsmodel.UnknownProtocol {
+			return fmt.Errorf("ultrasound: unrecognized stream protocol: %s", v)
+		}
+	}
+	if v := attrs.Get("codec"); len(v) > 0 {
+		md.Codec = v
+	}
+	if v := attrs.Get("resolution"); len(v) > 0 {
+		width, height, err := roomsmodel.ParseResolution(v)
+		if err != nil {
+			return err
+		}
+		md.Width, md.Height = width, height
+	}
+	if v := attrs.Get("framerate"); len(v) > 0 {
+		framerate, err := strconv.ParseFloat(v, 64)
+		if err != nil || framerate <= 0 {
+			return fmt.Errorf("ultrasound: invalid stream framerate: %s", v)
+		}
+		md.Framerate = framerate
+	}
+	if v := attrs.Get("bitrate"); len(v) > 0 {
+		bitrate, err := strconv.Atoi(v)
+		if err != nil || bitrate <= 0 {
+			return fmt.Errorf("ultrasound: invalid stream bitrate: %s", v)
+		}
+		md.Bitrate = bitrate
+	}
+	if v := attrs.Get("latency"); len(v) > 0 {
+		if md.Latency = roomsmodel.ParseLatencyClassString(v); md.Latency == roomsmodel.UnknownLatency {
+			return fmt.Errorf("ultrasound: unrecognized stream latency class: %s", v)
+		}
+	}
+	return nil
+}
+
+// fetchTargetRoom retrieves the room referenced by element 'room_id' attribute,
+// replying with the corresponding stanza error if it couldn't be found.
+func (x *Ultrasound) fetchTargetRoom(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) *roomsmodel.Room {
+	roomID, err := strconv.Atoi(el.Attributes().Get("room_id"))
+	if err != nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return nil
+	}
+	room, err := x.roomRep.FetchRoomByID(ctx, roomID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return nil
+	}
+	if room == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return nil
+	}
+	return room
+}
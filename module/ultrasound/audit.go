@@ -0,0 +1,134 @@
+package ultrasound
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dantin/cubit/audit"
+	"github.com/dantin/cubit/log"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	"github.com/dantin/cubit/xmpp"
+)
+
+const defaultAuditPageSize = 100
+
+// recordAudit emits an audit event performed by actor.
+func (x *Ultrasound) recordAudit(ctx context.Context, actor string, action auditmodel.Action, roomID int, target, details string) {
+	audit.Emit(ctx, x.auditRep, &auditmodel.Event{
+		Actor:   actor,
+		Action:  action,
+		RoomID:  roomID,
+		Target:  target,
+		Details: details,
+	})
+}
+
+// sendAuditEvents replies with audit log events matching actor, room_id, from, to and max request attributes.
+func (x *Ultrasound) sendAuditEvents(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	if !x.authorize(ctx, iq, rolesmodel.AuditView) {
+		return
+	}
+	filter, err := auditFilter(el)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	events, err := x.auditRep.FetchAuditEvents(ctx, filter)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	result := iq.ResultIQ()
+	auditNode := xmpp.NewElementNamespace("audit", ultrasoundNamespace)
+	for i := range events {
+		auditNode.AppendElement(auditEventElement(&events[i]))
+	}
+	result.AppendElement(auditNode)
+	_ = x.router.Route(ctx, result)
+}
+
+// sendAuditVerification replies whether or not the whole audit log hash chain is intact.
+func (x *Ultrasound) sendAuditVerification(ctx context.Context, iq *xmpp.IQ) {
+	if !x.authorize(ctx, iq, rolesmodel.AuditView) {
+		return
+	}
+	events, err := x.auditRep.FetchAuditEvents(ctx, &auditmodel.Filter{})
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	result := iq.ResultIQ()
+	verifyNode := xmpp.NewElementNamespace("audit_verify", ultrasoundNamespace)
+	verifyNode.SetAttribute("count", strconv.Itoa(len(events)))
+	if seq, ok := auditmodel.VerifyChain(events); ok {
+		verifyNode.SetAttribute("valid", "true")
+	} else {
+		log.Warnf("audit log tampered at event %d", seq)
+		verifyNode.SetAttribute("valid", "false")
+		verifyNode.SetAttribute("broken_at", strconv.FormatInt(seq, 10))
+	}
+	result.AppendElement(verifyNode)
+	_ = x.router.Route(ctx, result)
+}
+
+func auditFilter(el xmpp.XElement) (*auditmodel.Filter, error) {
+	attrs := el.Attributes()
+	filter := &auditmodel.Filter{Actor: attrs.Get("actor"), Max: defaultAuditPageSize}
+	if v := attrs.Get("room_id"); len(v) > 0 {
+		roomID, err := strconv.Atoi(v)
+		if err != nil || roomID <= 0 {
+			return nil, fmt.Errorf("ultrasound: invalid audit room id: %s", v)
+		}
+		filter.RoomID = roomID
+	}
+	if v := attrs.Get("max"); len(v) > 0 {
+		max, err := strconv.Atoi(v)
+		if err != nil || max <= 0 {
+			return nil, fmt.Errorf("ultrasound: invalid audit max: %s", v)
+		}
+		filter.Max = max
+	}
+	var err error
+	if filter.From, err = parseAuditTime(attrs.Get("from")); err != nil {
+		return nil, err
+	}
+	if filter.To, err = parseAuditTime(attrs.Get("to")); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func parseAuditTime(v string) (time.Time, error) {
+	if len(v) == 0 {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(timestampLayout, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ultrasound: invalid audit time: %s", v)
+	}
+	return t, nil
+}
+
+func auditEventElement(event *auditmodel.Event) *xmpp.Element {
+	eventNode := xmpp.NewElementName("event")
+	eventNode.SetAttribute("seq", strconv.FormatInt(event.Seq, 10))
+	eventNode.SetAttribute("actor", event.Actor)
+	eventNode.SetAttribute("action", string(event.Action))
+	if event.RoomID != 0 {
+		eventNode.SetAttribute("room_id", strconv.Itoa(event.RoomID))
+	}
+	if len(event.Target) > 0 {
+		eventNode.SetAttribute("target", event.Target)
+	}
+	eventNode.SetAttribute("timestamp", event.Timestamp.UTC().Format(timestampLayout))
+	eventNode.SetAttribute("prev_hash", event.PrevHash)
+	eventNode.SetAttribute("hash", event.Hash)
+	eventNode.SetText(event.Details)
+	return eventNode
+}
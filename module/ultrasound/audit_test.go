@@ -0,0 +1,107 @@
+package ultrasound
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Ultrasound_Audit(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+	auditRep := memorystorage.NewAudit()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+	room := roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal}
+	_ = roomRep.UpsertRoom(context.Background(), &room)
+	_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"user": "srt://127.0.0.1:9001"}`,
+		Type:   roomsmodel.Camera,
+		RoomID: room.ID,
+	})
+
+	j1, _ := jid.New("admin", "example.org", "desktop", true)
+	stm1 := stream.NewMockC2S(uuid.New().String(), j1)
+	stm1.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm1)
+
+	j2, _ := jid.New("alice", "example.org", "desktop", true)
+	stm2 := stream.NewMockC2S(uuid.New().String(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep, audit: auditRep})
+	defer func() { _ = x.Shutdown() }()
+
+	binding := xmpp.NewElementNamespace("binding", ultrasoundNamespace)
+	binding.SetAttribute("action", "bind")
+	binding.SetAttribute("room_id", "1")
+	binding.SetAttribute("username", "alice")
+	x.ProcessIQ(context.Background(), newSetIQ(j1, binding))
+	require.Equal(t, xmpp.ResultType, stm1.ReceiveElement().Type())
+	_ = requireRoomEvent(t, stm2, "bind")
+
+	x.ProcessIQ(context.Background(), newGetIQ(j2, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	require.Equal(t, xmpp.ResultType, stm2.ReceiveElement().Type())
+
+	// listing rooms is not audited
+	requireWatchRooms(t, x, j1, stm1)
+
+	// whole log
+	x.ProcessIQ(context.Background(), newGetIQ(j1, xmpp.NewElementNamespace("audit", ultrasoundNamespace)))
+	elem := stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	events := elem.Elements().ChildNamespace("audit", ultrasoundNamespace).Elements().Children("event")
+	require.Len(t, events, 2)
+	require.Equal(t, "1", events[0].Attributes().Get("seq"))
+	require.Equal(t, "admin", events[0].Attributes().Get("actor"))
+	require.Equal(t, "room.bind", events[0].Attributes().Get("action"))
+	require.Equal(t, "1", events[0].Attributes().Get("room_id"))
+	require.Equal(t, "alice", events[0].Attributes().Get("target"))
+	require.Equal(t, "alice", events[1].Attributes().Get("actor"))
+	require.Equal(t, "stream.view", events[1].Attributes().Get("action"))
+	require.Equal(t, events[0].Attributes().Get("hash"), events[1].Attributes().Get("prev_hash"))
+
+	// filtered by actor
+	req := xmpp.NewElementNamespace("audit", ultrasoundNamespace)
+	req.SetAttribute("actor", "alice")
+	x.ProcessIQ(context.Background(), newGetIQ(j1, req))
+	elem = stm1.ReceiveElement()
+	events = elem.Elements().ChildNamespace("audit", ultrasoundNamespace).Elements().Children("event")
+	require.Len(t, events, 1)
+	require.Equal(t, "2", events[0].Attributes().Get("seq"))
+
+	// filtered by time range
+	req = xmpp.NewElementNamespace("audit", ultrasoundNamespace)
+	req.SetAttribute("to", "2020-01-01T00:00:00Z")
+	x.ProcessIQ(context.Background(), newGetIQ(j1, req))
+	elem = stm1.ReceiveElement()
+	require.Len(t, elem.Elements().ChildNamespace("audit", ultrasoundNamespace).Elements().Children("event"), 0)
+
+	req = xmpp.NewElementNamespace("audit", ultrasoundNamespace)
+	req.SetAttribute("from", "yesterday")
+	x.ProcessIQ(context.Background(), newGetIQ(j1, req))
+	requireStanzaError(t, stm1, xmpp.ErrBadRequest)
+
+	// chain verification
+	x.ProcessIQ(context.Background(), newGetIQ(j1, xmpp.NewElementNamespace("audit_verify", ultrasoundNamespace)))
+	elem = stm1.ReceiveElement()
+	verify := elem.Elements().ChildNamespace("audit_verify", ultrasoundNamespace)
+	require.NotNil(t, verify)
+	require.Equal(t, "true", verify.Attributes().Get("valid"))
+	require.Equal(t, "2", verify.Attributes().Get("count"))
+
+	// users are not allowed to view audit log
+	x.ProcessIQ(context.Background(), newGetIQ(j2, xmpp.NewElementNamespace("audit", ultrasoundNamespace)))
+	requireStanzaError(t, stm2, xmpp.ErrForbidden)
+}
@@ -0,0 +1,159 @@
+package ultrasound
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/dantin/cubit/log"
+	"github.com/dantin/cubit/model"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+)
+
+const activeRoomCtxKey = "ultrasound:active_room"
+
+const (
+	bindAction   = "bind"
+	unbindAction = "unbind"
+)
+
+func (x *Ultrasound) sendBoundRooms(ctx context.Context, iq *xmpp.IQ) {
+	userJID := iq.FromJID()
+
+	rooms, err := x.roomRep.FetchBoundRooms(ctx, userJID.Node())
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	active := x.activeRoom(userJID, rooms)
+
+	result := iq.ResultIQ()
+	myRoomsNode := xmpp.NewElementNamespace("my_rooms", ultrasoundNamespace)
+	for i := range rooms {
+		room, err := x.fetchableRoom(ctx, userJID.Node(), &rooms[i])
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		roomNode, err := x.roomElement(xmpp.NewElementName("room"), room, userJID)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		roomNode.SetAttribute("type", room.Type.String())
+		if active != nil && active.ID == room.ID {
+			roomNode.SetAttribute("active", "true")
+		}
+		myRoomsNode.AppendElement(roomNode)
+	}
+	result.AppendElement(myRoomsNode)
+	_ = x.router.Route(ctx, result)
+}
+
+func (x *Ultrasound) setActiveRoom(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	userJID := iq.FromJID()
+
+	roomID, err := strconv.Atoi(el.Attributes().Get("room_id"))
+	if err != nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	stm := x.router.LocalStream(userJID.Node(), userJID.Resource())
+	if stm == nil {
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	rooms, err := x.roomRep.FetchBoundRooms(ctx, userJID.Node())
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	for _, room := range rooms {
+		if room.ID != roomID {
+			continue
+		}
+		if room.Type != roomsmodel.Normal {
+			_ = x.router.Route(ctx, iq.NotAcceptableError())
+			return
+		}
+		stm.SetValue(activeRoomCtxKey, roomID)
+		_ = x.router.Route(ctx, iq.ResultIQ())
+		return
+	}
+	_ = x.router.Route(ctx, iq.ForbiddenError())
+}
+
+// activeRoom returns the room currently selected by the user stream,
+// defaulting to the first bound normal room.
+func (x *Ultrasound) activeRoom(userJID *jid.JID, rooms []roomsmodel.Room) *roomsmodel.Room {
+	if stm := x.router.LocalStream(userJID.Node(), userJID.Resource()); stm != nil {
+		if roomID, ok := stm.Value(activeRoomCtxKey).(int); ok {
+			for i, room := range rooms {
+				if room.ID == roomID {
+					return &rooms[i]
+				}
+			}
+		}
+	}
+	for i, room := range rooms {
+		if room.Type == roomsmodel.Normal {
+			return &rooms[i]
+		}
+	}
+	return nil
+}
+
+func (x *Ultrasound) setBinding(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	action := el.Attributes().Get("action")
+	if action != bindAction && action != unbindAction {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	username := el.Attributes().Get("username")
+	if len(username) == 0 {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	if action == bindAction {
+		exists, err := x.userRep.UserExists(ctx, username)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		if !exists {
+			_ = x.router.Route(ctx, iq.ItemNotFoundError())
+			return
+		}
+		err = x.roomRep.BindRoom(ctx, room.ID, username)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	} else if err := x.roomRep.UnbindRoom(ctx, room.ID, username); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("room %d %s %s by %s", room.ID, action, username, iq.FromJID().Node())
+	if action == bindAction {
+		x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.RoomBind, room.ID, username, "")
+	} else {
+		x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.RoomUnbind, room.ID, username, "")
+	}
+	_ = x.router.Route(ctx, iq.ResultIQ())
+
+	x.notifyRoom(ctx, room.ID, room, action, []string{username}, model.Usr.String())
+	x.handleRoomChange(ctx, room.ID, false)
+}
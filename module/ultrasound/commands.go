@@ -0,0 +1,345 @@
+package ultrasound
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dantin/cubit/log"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/module/xep0004"
+	"github.com/dantin/cubit/module/xep0050"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+)
+
+const defaultCommandTimeout = time.Second * 10
+
+// deviceCommand represents an ad-hoc command relayed to every device of a given type bound to a room.
+type deviceCommand struct {
+	x      *Ultrasound
+	node   string
+	name   string
+	action string
+	types  []roomsmodel.VideoType
+	params []xep0004.Field
+}
+
+// commandRelay tracks device acknowledgements of a relayed command.
+type commandRelay struct {
+	action   string
+	pending  map[string]string // control IQ identifier -> device serial
+	acked    []string
+	failed   []string
+	timedOut []string
+	offline  []string
+	timer    *time.Timer
+	done     func(*xep0050.Result, *xmpp.StanzaError)
+}
+
+type commandAck struct {
+	relay *commandRelay
+	from  string
+}
+
+// Commands returns ultrasound remote device control ad-hoc commands.
+func (x *Ultrasound) Commands() []xep0050.Command {
+	return []xep0050.Command{
+		&deviceCommand{
+			x:      x,
+			node:   "ultrasound:encoder:start",
+			name:   "Start encoder",
+			action: "start_encoder",
+			types:  []roomsmodel.VideoType{roomsmodel.Box, roomsmodel.Device},
+		},
+		&deviceCommand{
+			x:      x,
+			node:   "ultrasound:encoder:stop",
+			name:   "Stop encoder",
+			action: "stop_encoder",
+			types:  []roomsmodel.VideoType{roomsmodel.Box, roomsmodel.Device},
+		},
+		&deviceCommand{
+			x:      x,
+			node:   "ultrasound:camera:preset",
+			name:   "Switch camera preset",
+			action: "preset",
+			types:  []roomsmodel.VideoType{roomsmodel.Camera},
+			params: []xep0004.Field{{Var: "preset", Type: xep0004.TextSingle, Label: "Preset", Required: true}},
+		},
+		&deviceCommand{
+			x:      x,
+			node:   "ultrasound:device:reboot",
+			name:   "Reboot device",
+			action: "reboot",
+			types:  []roomsmodel.VideoType{roomsmodel.Box, roomsmodel.Camera, roomsmodel.Device},
+		},
+	}
+}
+
+func (c *deviceCommand) Node() string { return c.node }
+
+func (c *deviceCommand) Name() string { return c.name }
+
+// IsAllowed tells whether or not requester has been granted device control, other than a device account.
+// Room operation rights are checked upon execution.
+func (c *deviceCommand) IsAllowed(ctx context.Context, requester *jid.JID) bool {
+	if devicesmodel.IsAccountName(requester.Node()) {
+		return false
+	}
+	ok, err := c.x.authz.IsAllowed(ctx, requester.Node(), rolesmodel.DeviceControl)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	return ok
+}
+
+func (c *deviceCommand) Form(_ context.Context, _ *jid.JID) *xep0004.DataForm {
+	form := &xep0004.DataForm{
+		Type:  xep0004.Form,
+		Title: c.name,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{c.node}},
+			{Var: "room_id", Type: xep0004.TextSingle, Label: "Room", Required: true},
+		},
+	}
+	if len(c.types) > 1 {
+		typeField := xep0004.Field{Var: "type", Type: xep0004.ListSingle, Label: "Device type", Required: true}
+		for _, tp := range c.types {
+			typeField.Options = append(typeField.Options, xep0004.Option{Value: tp.String()})
+		}
+		form.Fields = append(form.Fields, typeField)
+	}
+	form.Fields = append(form.Fields, c.params...)
+	return form
+}
+
+func (c *deviceCommand) Execute(ctx context.Context, requester *jid.JID, fields xep0004.Fields, done func(*xep0050.Result, *xmpp.StanzaError)) {
+	roomID, err := strconv.Atoi(fields.ValueForField("room_id"))
+	if err != nil {
+		done(nil, xmpp.ErrBadRequest)
+		return
+	}
+	tp := c.types[0]
+	if len(c.types) > 1 {
+		tp = roomsmodel.ParseVideoTypeString(fields.ValueForField("type"))
+		if !c.supportsType(tp) {
+			done(nil, xmpp.ErrBadRequest)
+			return
+		}
+	}
+	params := make(map[string]string, len(c.params))
+	for _, p := range c.params {
+		v := strings.TrimSpace(fields.ValueForField(p.Var))
+		if p.Required && len(v) == 0 {
+			done(nil, xmpp.ErrBadRequest)
+			return
+		}
+		params[p.Var] = v
+	}
+	c.x.runQueue.Run(func() {
+		c.x.relayCommand(ctx, requester, roomID, tp, c.action, params, done)
+	})
+}
+
+func (c *deviceCommand) supportsType(tp roomsmodel.VideoType) bool {
+	for _, t := range c.types {
+		if t == tp {
+			return true
+		}
+	}
+	return false
+}
+
+// relayCommand sends a control request to every provisioned device of type tp bound to a room,
+// completing the command once every online device acknowledged it or command timeout elapsed.
+func (x *Ultrasound) relayCommand(ctx context.Context, requester *jid.JID, roomID int, tp roomsmodel.VideoType, action string, params map[string]string, done func(*xep0050.Result, *xmpp.StanzaError)) {
+	room, err := x.roomRep.FetchRoomByID(ctx, roomID)
+	if err != nil {
+		log.Error(err)
+		done(nil, xmpp.ErrInternalServerError)
+		return
+	}
+	if room == nil {
+		done(nil, xmpp.ErrItemNotFound)
+		return
+	}
+	ok, err := x.isRoomOperatorUser(ctx, requester.Node(), roomID)
+	if err != nil {
+		log.Error(err)
+		done(nil, xmpp.ErrInternalServerError)
+		return
+	}
+	if !ok {
+		done(nil, xmpp.ErrForbidden)
+		return
+	}
+	devices, err := x.deviceRep.FetchRoomDevices(ctx, roomID)
+	if err != nil {
+		log.Error(err)
+		done(nil, xmpp.ErrInternalServerError)
+		return
+	}
+	relay := &commandRelay{
+		action:  action,
+		pending: make(map[string]string),
+		done:    done,
+	}
+	for _, device := range devices {
+		if device.Type != tp || len(device.Username) == 0 {
+			continue
+		}
+		streams := x.router.LocalStreams(device.Username)
+		if len(streams) == 0 {
+			relay.offline = append(relay.offline, device.Serial)
+			continue
+		}
+		srvJID, _ := jid.New("", streams[0].JID().Domain(), "", true)
+
+		controlNode := xmpp.NewElementNamespace("control", ultrasoundNamespace)
+		controlNode.SetAttribute("action", action)
+		controlNode.SetAttribute("serial", device.Serial)
+		for name, value := range params {
+			controlNode.SetAttribute(name, value)
+		}
+		iq := xmpp.NewIQType(uuid.New().String(), xmpp.SetType)
+		iq.SetFromJID(srvJID)
+		iq.SetToJID(streams[0].JID())
+		iq.AppendElement(controlNode)
+
+		relay.pending[iq.ID()] = device.Serial
+		x.addCommandAck(iq.ID(), &commandAck{relay: relay, from: streams[0].JID().String()})
+		_ = x.router.Route(ctx, iq)
+	}
+	if len(relay.pending) == 0 && len(relay.offline) == 0 {
+		done(nil, xmpp.ErrItemNotFound)
+		return
+	}
+	log.Infof("%s command relayed by %s to %d %s device(s) of room %d", action, requester.Node(), len(relay.pending), tp.String(), roomID)
+	x.recordAudit(ctx, requester.Node(), auditmodel.DeviceCommand, roomID, tp.String(), action)
+
+	if len(relay.pending) == 0 {
+		x.completeCommand(relay)
+		return
+	}
+	timeout := x.cfg.CommandTimeout
+	if timeout == 0 {
+		timeout = defaultCommandTimeout
+	}
+	relay.timer = time.AfterFunc(timeout, func() {
+		x.runQueue.Run(func() { x.expireCommand(relay) })
+	})
+}
+
+// isCommandAck tells whether or not an IQ is a device response to a relayed control request.
+func (x *Ultrasound) isCommandAck(iq *xmpp.IQ) bool {
+	if !iq.IsResult() && iq.Type() != xmpp.ErrorType {
+		return false
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	_, ok := x.commandAcks[iq.ID()]
+	return ok
+}
+
+func (x *Ultrasound) handleCommandAck(iq *xmpp.IQ) {
+	x.mu.Lock()
+	ack := x.commandAcks[iq.ID()]
+	if ack == nil || ack.from != iq.FromJID().String() {
+		x.mu.Unlock()
+		return
+	}
+	delete(x.commandAcks, iq.ID())
+	x.mu.Unlock()
+
+	relay := ack.relay
+	serial, ok := relay.pending[iq.ID()]
+	if !ok {
+		return // already expired
+	}
+	delete(relay.pending, iq.ID())
+	if iq.IsResult() {
+		relay.acked = append(relay.acked, serial)
+	} else {
+		relay.failed = append(relay.failed, serial)
+	}
+	if len(relay.pending) == 0 {
+		relay.timer.Stop()
+		x.completeCommand(relay)
+	}
+}
+
+func (x *Ultrasound) expireCommand(relay *commandRelay) {
+	if len(relay.pending) == 0 {
+		return // already completed
+	}
+	x.mu.Lock()
+	for id, serial := range relay.pending {
+		delete(x.commandAcks, id)
+		relay.timedOut = append(relay.timedOut, serial)
+	}
+	x.mu.Unlock()
+
+	relay.pending = make(map[string]string)
+	x.completeCommand(relay)
+}
+
+func (x *Ultrasound) completeCommand(relay *commandRelay) {
+	form := &xep0004.DataForm{Type: xep0004.Result}
+	for _, f := range []struct {
+		name    string
+		serials []string
+	}{
+		{"acked", relay.acked},
+		{"failed", relay.failed},
+		{"timed_out", relay.timedOut},
+		{"offline", relay.offline},
+	} {
+		if len(f.serials) > 0 {
+			form.Fields = append(form.Fields, xep0004.Field{Var: f.name, Type: xep0004.TextMulti, Values: f.serials})
+		}
+	}
+	total := len(relay.acked) + len(relay.failed) + len(relay.timedOut) + len(relay.offline)
+
+	res := &xep0050.Result{
+		Note: fmt.Sprintf("%s acknowledged by %d of %d device(s)", relay.action, len(relay.acked), total),
+		Form: form,
+	}
+	switch len(relay.acked) {
+	case total:
+		res.NoteType = xep0050.InfoNote
+	case 0:
+		res.NoteType = xep0050.ErrorNote
+	default:
+		res.NoteType = xep0050.WarnNote
+	}
+	relay.done(res, nil)
+}
+
+func (x *Ultrasound) addCommandAck(id string, ack *commandAck) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.commandAcks[id] = ack
+}
+
+// isRoomOperatorUser tells whether or not username is allowed to operate a room,
+// that is, a user bound to it or allowed to edit any room.
+func (x *Ultrasound) isRoomOperatorUser(ctx context.Context, username string, roomID int) (bool, error) {
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, roomID)
+	if err != nil {
+		return false, err
+	}
+	for _, subscriber := range subscribers {
+		if subscriber == username {
+			return true, nil
+		}
+	}
+	return x.authz.IsAllowed(ctx, username, rolesmodel.RoomsEdit)
+}
@@ -0,0 +1,135 @@
+package ultrasound
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dantin/cubit/model"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/module/xep0004"
+	"github.com/dantin/cubit/module/xep0050"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type commandOutcome struct {
+	res  *xep0050.Result
+	sErr *xmpp.StanzaError
+}
+
+func TestModule_Ultrasound_Commands(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+	deviceRep := memorystorage.NewDevice()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "room02", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0001", Type: roomsmodel.Camera, RoomID: 1, Username: "device-sn-0001"})
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0002", Type: roomsmodel.Camera, RoomID: 1, Username: "device-sn-0002"})
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0003", Type: roomsmodel.Camera, RoomID: 1, Username: "device-sn-0003"})
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0004", Type: roomsmodel.Box, RoomID: 1, Username: "device-sn-0004"})
+
+	adminJID, _ := jid.New("admin", "example.org", "desktop", true)
+	userJID, _ := jid.New("room02", "example.org", "desktop", true)
+
+	var deviceStreams []*stream.MockC2S
+	for _, username := range []string{"device-sn-0001", "device-sn-0002", "device-sn-0004"} {
+		j, _ := jid.New(username, "example.org", "box", true)
+		stm := stream.NewMockC2S(uuid.New().String(), j)
+		stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+		r.Bind(context.Background(), stm)
+		deviceStreams = append(deviceStreams, stm)
+	}
+
+	x := newTestUltrasound(&Config{CommandTimeout: time.Millisecond * 200}, r, testReps{user: userRep, room: roomRep, device: deviceRep})
+	defer func() { _ = x.Shutdown() }()
+
+	commands := make(map[string]xep0050.Command)
+	for _, cmd := range x.Commands() {
+		commands[cmd.Node()] = cmd
+	}
+	preset := commands["ultrasound:camera:preset"]
+	require.NotNil(t, preset)
+
+	deviceJID, _ := jid.New("device-sn-0001", "example.org", "box", true)
+	require.False(t, preset.IsAllowed(context.Background(), deviceJID))
+	require.True(t, preset.IsAllowed(context.Background(), userJID))
+
+	form := preset.Form(context.Background(), adminJID)
+	require.Equal(t, "preset", form.Fields[len(form.Fields)-1].Var)
+
+	var execute = func(cmd xep0050.Command, requester *jid.JID, fields xep0004.Fields) chan commandOutcome {
+		ch := make(chan commandOutcome, 1)
+		cmd.Execute(context.Background(), requester, fields, func(res *xep0050.Result, sErr *xmpp.StanzaError) {
+			ch <- commandOutcome{res: res, sErr: sErr}
+		})
+		return ch
+	}
+
+	// not bound to the room
+	outcome := <-execute(preset, userJID, xep0004.Fields{{Var: "room_id", Values: []string{"1"}}, {Var: "preset", Values: []string{"3"}}})
+	require.Equal(t, xmpp.ErrForbidden, outcome.sErr)
+
+	// missing preset
+	outcome = <-execute(preset, adminJID, xep0004.Fields{{Var: "room_id", Values: []string{"1"}}})
+	require.Equal(t, xmpp.ErrBadRequest, outcome.sErr)
+
+	ch := execute(preset, adminJID, xep0004.Fields{{Var: "room_id", Values: []string{"1"}}, {Var: "preset", Values: []string{"3"}}})
+
+	for i, stm := range deviceStreams[:2] {
+		elem := stm.ReceiveElement()
+		control := elem.Elements().ChildNamespace("control", ultrasoundNamespace)
+		require.NotNil(t, control)
+		require.Equal(t, "preset", control.Attributes().Get("action"))
+		require.Equal(t, "3", control.Attributes().Get("preset"))
+
+		iq, err := xmpp.NewIQFromElement(elem, parseJID(elem.From()), parseJID(elem.To()))
+		require.Nil(t, err)
+		var ack xmpp.Stanza = iq.ResultIQ()
+		if i == 1 {
+			ack = iq.InternalServerError()
+		}
+		ackIQ, _ := xmpp.NewIQFromElement(ack, stm.JID(), iq.FromJID())
+		require.True(t, x.MatchesIQ(ackIQ))
+		x.ProcessIQ(context.Background(), ackIQ)
+	}
+	outcome = <-ch
+	require.Nil(t, outcome.sErr)
+	require.Equal(t, xep0050.WarnNote, outcome.res.NoteType)
+	require.Equal(t, []string{"SN-0001"}, outcome.res.Form.Fields.ValuesForFieldOfType("acked", xep0004.TextMulti))
+	require.Equal(t, []string{"SN-0002"}, outcome.res.Form.Fields.ValuesForFieldOfType("failed", xep0004.TextMulti))
+	require.Equal(t, []string{"SN-0003"}, outcome.res.Form.Fields.ValuesForFieldOfType("offline", xep0004.TextMulti))
+
+	// unacknowledged command
+	ch = execute(commands["ultrasound:device:reboot"], adminJID, xep0004.Fields{{Var: "room_id", Values: []string{"1"}}, {Var: "type", Values: []string{"box"}}})
+
+	elem := deviceStreams[2].ReceiveElement()
+	require.Equal(t, "reboot", elem.Elements().ChildNamespace("control", ultrasoundNamespace).Attributes().Get("action"))
+
+	outcome = <-ch
+	require.Nil(t, outcome.sErr)
+	require.Equal(t, xep0050.ErrorNote, outcome.res.NoteType)
+	require.Equal(t, []string{"SN-0004"}, outcome.res.Form.Fields.ValuesForFieldOfType("timed_out", xep0004.TextMulti))
+
+	// late acknowledgement
+	iq, _ := xmpp.NewIQFromElement(elem, parseJID(elem.From()), parseJID(elem.To()))
+	lateIQ, _ := xmpp.NewIQFromElement(iq.ResultIQ(), deviceStreams[2].JID(), iq.FromJID())
+	require.False(t, x.MatchesIQ(lateIQ))
+
+	// no devices of requested type
+	outcome = <-execute(commands["ultrasound:encoder:start"], adminJID, xep0004.Fields{{Var: "room_id", Values: []string{"1"}}, {Var: "type", Values: []string{"device"}}})
+	require.Equal(t, xmpp.ErrItemNotFound, outcome.sErr)
+}
+
+func parseJID(s string) *jid.JID {
+	j, _ := jid.NewWithString(s, true)
+	return j
+}
@@ -0,0 +1,83 @@
+package ultrasound
+
+import (
+	"testing"
+	"time"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/util/urlsign"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestModule_Ultrasound_Config(t *testing.T) {
+	badCfg := `page_size [4]`
+	cfg := &Config{}
+	err := yaml.Unmarshal([]byte(badCfg), &cfg)
+	require.NotNil(t, err)
+
+	negativeCfg := `heartbeat_timeout: -1`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(negativeCfg), &cfg)
+	require.NotNil(t, err)
+
+	negativeCfg = `command_timeout: -1`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(negativeCfg), &cfg)
+	require.NotNil(t, err)
+
+	badCfg = `
+playback_keys:
+  - id: k1
+`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(badCfg), &cfg)
+	require.NotNil(t, err)
+
+	badCfg = `
+playback_keys:
+  - id: k1
+    secret: s1
+  - id: k1
+    secret: s2
+`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(badCfg), &cfg)
+	require.NotNil(t, err)
+
+	badCfg = `
+playback_type_ttl:
+  hologram: 60
+`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(badCfg), &cfg)
+	require.NotNil(t, err)
+
+	negativeCfg = `playback_ttl: -1`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(negativeCfg), &cfg)
+	require.NotNil(t, err)
+
+	goodCfg := `
+page_size: 8
+heartbeat_timeout: 90
+command_timeout: 15
+playback_keys:
+  - id: k2
+    secret: s2
+  - id: k1
+    secret: s1
+playback_ttl: 300
+playback_type_ttl:
+  camera: 60
+`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(goodCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, 8, cfg.PageSize)
+	require.Equal(t, 90*time.Second, cfg.HeartbeatTimeout)
+	require.Equal(t, 15*time.Second, cfg.CommandTimeout)
+	require.Equal(t, []urlsign.Key{{ID: "k2", Secret: []byte("s2")}, {ID: "k1", Secret: []byte("s1")}}, cfg.PlaybackKeys)
+	require.Equal(t, 300*time.Second, cfg.PlaybackTTL)
+	require.Equal(t, map[roomsmodel.VideoType]time.Duration{roomsmodel.Camera: 60 * time.Second}, cfg.PlaybackTypeTTL)
+}
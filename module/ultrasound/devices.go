@@ -0,0 +1,260 @@
+package ultrasound
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	streamerror "github.com/dantin/cubit/errors"
+	"github.com/dantin/cubit/log"
+	"github.com/dantin/cubit/model"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+)
+
+const (
+	registerAction   = "register"
+	unregisterAction = "unregister"
+	provisionAction  = "provision"
+	revokeAction     = "revoke"
+)
+
+const deviceCredentialLength = 16
+
+func (x *Ultrasound) sendDevices(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	if !x.authorize(ctx, iq, rolesmodel.DeviceEdit) {
+		return
+	}
+	var devices []devicesmodel.Device
+	var err error
+	if roomIDValue := el.Attributes().Get("room_id"); len(roomIDValue) > 0 {
+		roomID, convErr := strconv.Atoi(roomIDValue)
+		if convErr != nil {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+			return
+		}
+		devices, err = x.deviceRep.FetchRoomDevices(ctx, roomID)
+	} else {
+		devices, err = x.deviceRep.FetchDevices(ctx)
+	}
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	result := iq.ResultIQ()
+	devicesNode := xmpp.NewElementNamespace("devices", ultrasoundNamespace)
+	for _, device := range devices {
+		devicesNode.AppendElement(x.deviceElement(&device, iq.ToJID().Domain()))
+	}
+	result.AppendElement(devicesNode)
+	_ = x.router.Route(ctx, result)
+}
+
+func (x *Ultrasound) setDevice(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	attrs := el.Attributes()
+	action := attrs.Get("action")
+	serial := attrs.Get("serial")
+	if !devicesmodel.IsValidSerial(serial) {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if action == registerAction {
+		x.registerDevice(ctx, iq, serial, roomsmodel.ParseVideoTypeString(attrs.Get("type")))
+		return
+	}
+	device, err := x.deviceRep.FetchDevice(ctx, serial)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if device == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	switch action {
+	case bindAction:
+		room := x.fetchTargetRoom(ctx, iq, el)
+		if room == nil {
+			return
+		}
+		device.RoomID = room.ID
+		if err := x.deviceRep.UpsertDevice(ctx, device); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	case unbindAction:
+		device.RoomID = 0
+		if err := x.deviceRep.UpsertDevice(ctx, device); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	case provisionAction:
+		x.provisionDevice(ctx, iq, device)
+		return
+	case revokeAction:
+		if err := x.revokeDevice(ctx, device); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	case unregisterAction:
+		if err := x.revokeDevice(ctx, device); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		if err := x.deviceRep.DeleteDevice(ctx, device.Serial); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	default:
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	log.Infof("device %s %s by %s", device.Serial, action, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.DeviceUpdate, device.RoomID, device.Serial, action)
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+func (x *Ultrasound) registerDevice(ctx context.Context, iq *xmpp.IQ, serial string, tp roomsmodel.VideoType) {
+	if tp == roomsmodel.Unknown {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	existing, err := x.deviceRep.FetchDevice(ctx, serial)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if existing != nil {
+		_ = x.router.Route(ctx, iq.ConflictError())
+		return
+	}
+	if err := x.deviceRep.UpsertDevice(ctx, &devicesmodel.Device{Serial: serial, Type: tp}); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("%s device %s registered by %s", tp.String(), serial, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.DeviceUpdate, 0, serial, registerAction)
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+// provisionDevice issues an XMPP account to device, generating a new credential
+// if it was previously provisioned.
+func (x *Ultrasound) provisionDevice(ctx context.Context, iq *xmpp.IQ, device *devicesmodel.Device) {
+	username := devicesmodel.AccountName(device.Serial)
+	deviceJID, err := jid.New(username, iq.ToJID().Domain(), "", true)
+	if err != nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if device.Username != username {
+		exists, err := x.userRep.UserExists(ctx, username)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		if exists {
+			_ = x.router.Route(ctx, iq.ConflictError())
+			return
+		}
+	}
+	password, err := generateCredential()
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if err := x.userRep.UpsertUser(ctx, &model.User{Username: username, Password: password, Role: model.Usr}); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	device.Username = username
+	if err := x.deviceRep.UpsertDevice(ctx, device); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("device %s provisioned as %s by %s", device.Serial, username, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.DeviceUpdate, device.RoomID, device.Serial, provisionAction)
+
+	result := iq.ResultIQ()
+	deviceNode := xmpp.NewElementNamespace("device", ultrasoundNamespace)
+	deviceNode.SetAttribute("serial", device.Serial)
+	deviceNode.SetAttribute("jid", deviceJID.String())
+	deviceNode.SetAttribute("password", password)
+	result.AppendElement(deviceNode)
+	_ = x.router.Route(ctx, result)
+}
+
+// revokeDevice deletes device issued XMPP account, disconnecting any of its open sessions.
+func (x *Ultrasound) revokeDevice(ctx context.Context, device *devicesmodel.Device) error {
+	if len(device.Username) == 0 {
+		return nil
+	}
+	if err := x.userRep.DeleteUser(ctx, device.Username); err != nil {
+		return err
+	}
+	for _, stm := range x.router.LocalStreams(device.Username) {
+		stm.Disconnect(ctx, streamerror.ErrNotAuthorized)
+	}
+	device.Username = ""
+	return x.deviceRep.UpsertDevice(ctx, device)
+}
+
+// unbindRoomDevices unbinds every device bound to a given room.
+func (x *Ultrasound) unbindRoomDevices(ctx context.Context, roomID int) error {
+	devices, err := x.deviceRep.FetchRoomDevices(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		device.RoomID = 0
+		if err := x.deviceRep.UpsertDevice(ctx, &device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *Ultrasound) deviceElement(device *devicesmodel.Device, domain string) *xmpp.Element {
+	deviceNode := xmpp.NewElementName("device")
+	deviceNode.SetAttribute("serial", device.Serial)
+	deviceNode.SetAttribute("type", device.Type.String())
+	if device.RoomID > 0 {
+		deviceNode.SetAttribute("room_id", fmt.Sprintf("%d", device.RoomID))
+	}
+	if len(device.Username) > 0 {
+		deviceNode.SetAttribute("jid", device.Username+"@"+domain)
+		if len(x.router.LocalStreams(device.Username)) > 0 {
+			deviceNode.SetAttribute("online", "true")
+		}
+		if lastSeen, ok := x.lastSeen[device.Username]; ok {
+			deviceNode.SetAttribute("last_seen", lastSeen.UTC().Format(timestampLayout))
+		}
+	}
+	return deviceNode
+}
+
+func generateCredential() (string, error) {
+	b := make([]byte, deviceCredentialLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,161 @@
+package ultrasound
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Ultrasound_Devices(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+	deviceRep := memorystorage.NewDevice()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+
+	j, _ := jid.New("admin", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	j2, _ := jid.New("alice", "example.org", "desktop", true)
+	stm2 := stream.NewMockC2S(uuid.New().String(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep, device: deviceRep})
+	defer func() { _ = x.Shutdown() }()
+
+	device := xmpp.NewElementNamespace("device", ultrasoundNamespace)
+	device.SetAttribute("action", "register")
+	device.SetAttribute("serial", "SN-0001")
+	device.SetAttribute("type", "camera")
+	require.True(t, x.MatchesIQ(newSetIQ(j, device)))
+
+	// only admins can register devices
+	x.ProcessIQ(context.Background(), newSetIQ(j2, device))
+	elem := stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// register
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	d, _ := deviceRep.FetchDevice(context.Background(), "SN-0001")
+	require.Equal(t, &devicesmodel.Device{Serial: "SN-0001", Type: roomsmodel.Camera}, d)
+
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	// malformed serial
+	device.SetAttribute("serial", "SN 0002")
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// bind to room
+	device.SetAttribute("action", "bind")
+	device.SetAttribute("serial", "SN-0001")
+	device.SetAttribute("room_id", "1")
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	d, _ = deviceRep.FetchDevice(context.Background(), "SN-0001")
+	require.Equal(t, 1, d.RoomID)
+
+	device.SetAttribute("room_id", "2")
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// provision
+	device.SetAttribute("action", "provision")
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	provisioned := elem.Elements().ChildNamespace("device", ultrasoundNamespace)
+	require.NotNil(t, provisioned)
+	require.Equal(t, "device-sn-0001@example.org", provisioned.Attributes().Get("jid"))
+	password := provisioned.Attributes().Get("password")
+	require.Len(t, password, 2*deviceCredentialLength)
+
+	usr, _ := userRep.FetchUser(context.Background(), "device-sn-0001")
+	require.NotNil(t, usr)
+	require.Equal(t, password, usr.Password)
+
+	// provisioning again rotates credential
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.NotEqual(t, password, elem.Elements().ChildNamespace("device", ultrasoundNamespace).Attributes().Get("password"))
+
+	// device logs in
+	dj, _ := jid.New("device-sn-0001", "example.org", "box", true)
+	dstm := stream.NewMockC2S(uuid.New().String(), dj)
+	dstm.SetPresence(xmpp.NewPresence(dj, dj, xmpp.AvailableType))
+	r.Bind(context.Background(), dstm)
+
+	// list devices
+	devices := xmpp.NewElementNamespace("devices", ultrasoundNamespace)
+	devices.SetAttribute("room_id", "1")
+	x.ProcessIQ(context.Background(), newGetIQ(j, devices))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	items := elem.Elements().ChildNamespace("devices", ultrasoundNamespace).Elements().Children("device")
+	require.Len(t, items, 1)
+	require.Equal(t, "SN-0001", items[0].Attributes().Get("serial"))
+	require.Equal(t, "camera", items[0].Attributes().Get("type"))
+	require.Equal(t, "device-sn-0001@example.org", items[0].Attributes().Get("jid"))
+	require.Equal(t, "true", items[0].Attributes().Get("online"))
+
+	// revoke
+	device.SetAttribute("action", "revoke")
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	require.True(t, dstm.IsDisconnected())
+	exists, _ := userRep.UserExists(context.Background(), "device-sn-0001")
+	require.False(t, exists)
+	d, _ = deviceRep.FetchDevice(context.Background(), "SN-0001")
+	require.Equal(t, "", d.Username)
+
+	// room deletion unbinds its devices
+	del := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	del.SetAttribute("action", "delete")
+	del.SetAttribute("room_id", "1")
+	x.ProcessIQ(context.Background(), newSetIQ(j, del))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	d, _ = deviceRep.FetchDevice(context.Background(), "SN-0001")
+	require.Equal(t, 0, d.RoomID)
+
+	// unregister
+	device.SetAttribute("action", "unregister")
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	d, _ = deviceRep.FetchDevice(context.Background(), "SN-0001")
+	require.Nil(t, d)
+
+	x.ProcessIQ(context.Background(), newSetIQ(j, device))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+}
@@ -0,0 +1,169 @@
+package ultrasound
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dantin/cubit/log"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+)
+
+const timestampLayout = "2006-01-02T15:04:05Z"
+
+const (
+	staleStatus = "stale"
+	aliveStatus = "alive"
+)
+
+// Heartbeat records device account activity, satisfying xep0199.HeartbeatHandler interface.
+func (x *Ultrasound) Heartbeat(stm stream.C2S) {
+	username := stm.Username()
+	if !devicesmodel.IsAccountName(username) {
+		return
+	}
+	at := time.Now()
+	x.runQueue.Run(func() { x.lastSeen[username] = at })
+}
+
+func (x *Ultrasound) loop() {
+	for {
+		select {
+		case <-x.tc.C:
+			x.runQueue.Run(func() { x.checkHeartbeats(context.Background()) })
+		case <-x.doneCh:
+			return
+		}
+	}
+}
+
+// checkHeartbeats looks for devices bound to a room whose liveness changed since last check,
+// alerting room administrators about it.
+func (x *Ultrasound) checkHeartbeats(ctx context.Context) {
+	devices, err := x.deviceRep.FetchDevices(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, device := range devices {
+		if len(device.Username) == 0 || device.RoomID == 0 {
+			delete(x.stale, device.Serial)
+			continue
+		}
+		_, wasStale := x.stale[device.Serial]
+		alive := x.isDeviceAlive(device.Username)
+		switch {
+		case !alive && !wasStale:
+			x.stale[device.Serial] = struct{}{}
+			log.Warnf("device %s of room %d went stale", device.Serial, device.RoomID)
+			x.alertRoomAdmins(ctx, &device, staleStatus)
+		case alive && wasStale:
+			delete(x.stale, device.Serial)
+			log.Infof("device %s of room %d is alive", device.Serial, device.RoomID)
+			x.alertRoomAdmins(ctx, &device, aliveStatus)
+		}
+	}
+}
+
+// isDeviceAlive tells whether or not a device account is online and has been recently seen.
+func (x *Ultrasound) isDeviceAlive(username string) bool {
+	if len(x.router.LocalStreams(username)) == 0 {
+		return false
+	}
+	if x.cfg.HeartbeatTimeout == 0 {
+		return true
+	}
+	return time.Since(x.deviceLastSeen(username)) <= x.cfg.HeartbeatTimeout
+}
+
+// deviceLastSeen returns device account last activity time,
+// defaulting to module start time if it has not been seen yet.
+func (x *Ultrasound) deviceLastSeen(username string) time.Time {
+	if lastSeen, ok := x.lastSeen[username]; ok {
+		return lastSeen
+	}
+	return x.startedAt
+}
+
+// roomHealth aggregates liveness of every provisioned device bound to a room.
+func (x *Ultrasound) roomHealth(ctx context.Context, roomID int) (devicesmodel.Health, error) {
+	devices, err := x.deviceRep.FetchRoomDevices(ctx, roomID)
+	if err != nil {
+		return devicesmodel.OK, err
+	}
+	return x.aggregateHealth(devices), nil
+}
+
+// unhealthyRooms returns identifiers of every room whose aggregated health is either degraded or down.
+func (x *Ultrasound) unhealthyRooms(ctx context.Context) (map[devicesmodel.Health][]int, error) {
+	devices, err := x.deviceRep.FetchDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byRoom := make(map[int][]devicesmodel.Device)
+	for _, device := range devices {
+		if device.RoomID != 0 {
+			byRoom[device.RoomID] = append(byRoom[device.RoomID], device)
+		}
+	}
+	res := make(map[devicesmodel.Health][]int)
+	for roomID, roomDevices := range byRoom {
+		if h := x.aggregateHealth(roomDevices); h != devicesmodel.OK {
+			res[h] = append(res[h], roomID)
+		}
+	}
+	return res, nil
+}
+
+func (x *Ultrasound) aggregateHealth(devices []devicesmodel.Device) devicesmodel.Health {
+	var total, alive int
+	for _, device := range devices {
+		if len(device.Username) == 0 {
+			continue
+		}
+		total++
+		if x.isDeviceAlive(device.Username) {
+			alive++
+		}
+	}
+	switch {
+	case alive == total:
+		return devicesmodel.OK
+	case alive == 0:
+		return devicesmodel.Down
+	default:
+		return devicesmodel.Degraded
+	}
+}
+
+func (x *Ultrasound) alertRoomAdmins(ctx context.Context, device *devicesmodel.Device, status string) {
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, device.RoomID)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	var admins []string
+	for _, username := range subscribers {
+		ok, err := x.authz.IsAllowed(ctx, username, rolesmodel.RoomsEdit)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if ok {
+			admins = append(admins, username)
+		}
+	}
+	alertNode := xmpp.NewElementNamespace("alert", ultrasoundNamespace)
+	alertNode.SetAttribute("room_id", strconv.Itoa(device.RoomID))
+	alertNode.SetAttribute("serial", device.Serial)
+	alertNode.SetAttribute("type", device.Type.String())
+	alertNode.SetAttribute("status", status)
+	// devices never seen since module start carry no last activity time
+	if lastSeen, ok := x.lastSeen[device.Username]; ok {
+		alertNode.SetAttribute("last_seen", lastSeen.UTC().Format(timestampLayout))
+	}
+	x.notify(ctx, admins, alertNode)
+}
@@ -0,0 +1,104 @@
+package ultrasound
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dantin/cubit/model"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Ultrasound_Heartbeat(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+	deviceRep := memorystorage.NewDevice()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.BindRoom(context.Background(), 1, "admin")
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0001", Type: roomsmodel.Box, RoomID: 1, Username: "device-sn-0001"})
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0002", Type: roomsmodel.Camera, RoomID: 1, Username: "device-sn-0002"})
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0003", Type: roomsmodel.Camera, RoomID: 1})
+
+	j, _ := jid.New("admin", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	var devStms []*stream.MockC2S
+	for _, username := range []string{"device-sn-0001", "device-sn-0002"} {
+		dj, _ := jid.New(username, "example.org", "box", true)
+		dstm := stream.NewMockC2S(uuid.New().String(), dj)
+		dstm.SetPresence(xmpp.NewPresence(dj, dj, xmpp.AvailableType))
+		r.Bind(context.Background(), dstm)
+		devStms = append(devStms, dstm)
+	}
+
+	x := newTestUltrasound(&Config{HeartbeatTimeout: time.Millisecond * 600}, r, testReps{user: userRep, room: roomRep, device: deviceRep})
+	defer func() { _ = x.Shutdown() }()
+
+	requireHealth := func(health string) {
+		x.ProcessIQ(context.Background(), newGetIQ(j, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+		elem := stm.ReceiveElement()
+		require.Equal(t, xmpp.ResultType, elem.Type())
+		require.Equal(t, health, elem.Elements().ChildNamespace("room", ultrasoundNamespace).Attributes().Get("health"))
+	}
+	requireAlert := func(serial, status string, seen bool) {
+		elem := stm.ReceiveElement()
+		require.Equal(t, "message", elem.Name())
+		alert := elem.Elements().ChildNamespace("alert", ultrasoundNamespace)
+		require.NotNil(t, alert)
+		require.Equal(t, "1", alert.Attributes().Get("room_id"))
+		require.Equal(t, serial, alert.Attributes().Get("serial"))
+		require.Equal(t, status, alert.Attributes().Get("status"))
+		if seen {
+			require.NotEmpty(t, alert.Attributes().Get("last_seen"))
+		} else {
+			require.Empty(t, alert.Attributes().Get("last_seen"))
+		}
+	}
+	requireHealth("ok")
+
+	// keep first device beating
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		tc := time.NewTicker(time.Millisecond * 50)
+		defer tc.Stop()
+		for {
+			select {
+			case <-tc.C:
+				x.Heartbeat(devStms[0])
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	// second device drops off before its first heartbeat
+	r.Unbind(context.Background(), devStms[1].JID())
+	requireAlert("SN-0002", staleStatus, false)
+	requireHealth("degraded")
+
+	// first device goes silent
+	close(stopCh)
+	<-doneCh
+	requireAlert("SN-0001", staleStatus, true)
+	requireHealth("down")
+
+	// second device comes back
+	r.Bind(context.Background(), devStms[1])
+	x.Heartbeat(devStms[1])
+	requireAlert("SN-0002", aliveStatus, true)
+	requireHealth("degraded")
+}
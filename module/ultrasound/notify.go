@@ -0,0 +1,135 @@
+package ultrasound
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/dantin/cubit/log"
+	"github.com/dantin/cubit/model"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+)
+
+// watchRooms registers an administrator to be notified of any room change
+// for as long as it remains online.
+func (x *Ultrasound) watchRooms(username string) {
+	x.admins[username] = struct{}{}
+}
+
+// notifyRoomChange pushes a room change event to every online user bound to the room,
+// and to every online administrator watching the global room list.
+// Room subscribers can be provided in advance when the room is no longer bound to them.
+func (x *Ultrasound) notifyRoomChange(ctx context.Context, roomID int, action string, subscribers []string) {
+	var room *roomsmodel.Room
+	if action != deleteAction {
+		var err error
+		room, err = x.roomRep.FetchRoomByID(ctx, roomID)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if room == nil {
+			return
+		}
+	}
+	if subscribers == nil {
+		var err error
+		subscribers, err = x.roomRep.FetchBoundUsers(ctx, roomID)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+	}
+	x.notifyRoom(ctx, roomID, room, action, subscribers, model.Usr.String())
+	x.notifyRoom(ctx, roomID, room, action, x.onlineAdmins(), model.Admin.String())
+	x.handleRoomChange(ctx, roomID, action == deleteAction)
+}
+
+// handleRoomChange lets every registered room handler know about a room change.
+func (x *Ultrasound) handleRoomChange(ctx context.Context, roomID int, deleted bool) {
+	for _, h := range x.handlers {
+		if deleted {
+			h.RoomDeleted(ctx, roomID)
+		} else {
+			h.RoomUpdated(ctx, roomID)
+		}
+	}
+}
+
+// notifyRoom pushes a room event to every online resource of usernames,
+// exposing room video streams routed to target to those allowed to fetch them.
+func (x *Ultrasound) notifyRoom(ctx context.Context, roomID int, room *roomsmodel.Room, action string, usernames []string, target string) {
+	var routed *roomsmodel.Room
+	if room != nil {
+		var err error
+		routed, err = room.RoutedTo(target)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+	}
+	for _, username := range usernames {
+		var fetchable *roomsmodel.Room
+		if routed != nil {
+			var err error
+			fetchable, err = x.fetchableRoom(ctx, username, routed)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+		}
+		for _, stm := range x.router.LocalStreams(username) {
+			roomNode := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+			if fetchable != nil {
+				var err error
+				roomNode, err = x.roomElement(roomNode, fetchable, stm.JID())
+				if err != nil {
+					log.Error(err)
+					return
+				}
+				roomNode.SetAttribute("type", fetchable.Type.String())
+			} else {
+				roomNode.SetAttribute("room_id", strconv.Itoa(roomID))
+			}
+			roomNode.SetAttribute("action", action)
+			x.routeEvent(ctx, stm.JID(), roomNode)
+		}
+	}
+}
+
+// notify pushes an event payload to every online resource of usernames.
+func (x *Ultrasound) notify(ctx context.Context, usernames []string, payload xmpp.XElement) {
+	for _, username := range usernames {
+		for _, stm := range x.router.LocalStreams(username) {
+			x.routeEvent(ctx, stm.JID(), payload)
+		}
+	}
+}
+
+func (x *Ultrasound) routeEvent(ctx context.Context, toJID *jid.JID, payload xmpp.XElement) {
+	srvJID, _ := jid.New("", toJID.Domain(), "", true)
+
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.HeadlineType)
+	msg.SetFromJID(srvJID)
+	msg.SetToJID(toJID)
+	msg.AppendElement(payload)
+	_ = x.router.Route(ctx, msg)
+}
+
+// onlineAdmins returns watching administrators currently online,
+// forgetting about those who went offline.
+func (x *Ultrasound) onlineAdmins() []string {
+	var res []string
+	for username := range x.admins {
+		if len(x.router.LocalStreams(username)) == 0 {
+			delete(x.admins, username)
+			continue
+		}
+		res = append(res, username)
+	}
+	sort.Strings(res)
+	return res
+}
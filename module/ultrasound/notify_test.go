@@ -0,0 +1,91 @@
+package ultrasound
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Ultrasound_Notifications(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "dashboard", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`,
+		Type:   roomsmodel.Camera,
+		RoomID: 1,
+	})
+	_ = roomRep.BindRoom(context.Background(), 1, "alice")
+
+	j1, _ := jid.New("admin", "example.org", "desktop", true)
+	stm1 := stream.NewMockC2S(uuid.New().String(), j1)
+	stm1.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm1)
+
+	j2, _ := jid.New("dashboard", "example.org", "desktop", true)
+	stm2 := stream.NewMockC2S(uuid.New().String(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	j3, _ := jid.New("alice", "example.org", "desktop", true)
+	stm3 := stream.NewMockC2S(uuid.New().String(), j3)
+	stm3.SetPresence(xmpp.NewPresence(j3, j3, xmpp.AvailableType))
+	r.Bind(context.Background(), stm3)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	// administrators start watching rooms once they fetch them
+	requireWatchRooms(t, x, j1, stm1)
+	requireWatchRooms(t, x, j2, stm2)
+
+	update := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	update.SetAttribute("action", "update")
+	update.SetAttribute("room_id", "1")
+	update.SetAttribute("name", "Room 02")
+	x.ProcessIQ(context.Background(), newSetIQ(j1, update))
+	elem := stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// bound user gets its own stream view
+	event := requireRoomEvent(t, stm3, "update")
+	require.Equal(t, "Room 02", event.Attributes().Get("name"))
+	require.Equal(t, "srt://127.0.0.1:9001", event.Elements().Child("video_stream").Text())
+
+	// administrators get the global list view
+	for _, stm := range []*stream.MockC2S{stm1, stm2} {
+		event = requireRoomEvent(t, stm, "update")
+		require.Equal(t, "Room 02", event.Attributes().Get("name"))
+		require.Equal(t, "srt://127.0.0.1:9002", event.Elements().Child("video_stream").Text())
+	}
+
+	// offline administrators are no longer notified
+	r.Unbind(context.Background(), j2)
+	require.Equal(t, []string{"admin"}, x.onlineAdmins())
+
+	// deleted rooms are notified to former subscribers
+	del := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	del.SetAttribute("action", "delete")
+	del.SetAttribute("room_id", "1")
+	x.ProcessIQ(context.Background(), newSetIQ(j1, del))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	event = requireRoomEvent(t, stm3, "delete")
+	require.Equal(t, "1", event.Attributes().Get("room_id"))
+	require.Nil(t, event.Elements().Child("video_stream"))
+	requireRoomEvent(t, stm1, "delete")
+}
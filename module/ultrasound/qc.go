@@ -0,0 +1,268 @@
+package ultrasound
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dantin/cubit/log"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	qcmodel "github.com/dantin/cubit/model/qc"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+)
+
+const (
+	startAction = "start"
+	stopAction  = "stop"
+)
+
+func (x *Ultrasound) setQCSession(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	switch el.Attributes().Get("action") {
+	case startAction:
+		x.startQCSession(ctx, iq, el)
+	case stopAction:
+		x.stopQCSession(ctx, iq, el)
+	default:
+		_ = x.router.Route(ctx, iq.BadRequestError())
+	}
+}
+
+// startQCSession assigns IQ sender as reviewer of a QC room stream, claiming it for the whole session.
+func (x *Ultrasound) startQCSession(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	tp := roomsmodel.ParseVideoTypeString(el.Attributes().Get("type"))
+	if tp == roomsmodel.Unknown {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if !x.authorize(ctx, iq, rolesmodel.QCReview) {
+		return
+	}
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	if room.Type != roomsmodel.QC {
+		_ = x.router.Route(ctx, iq.NotAcceptableError())
+		return
+	}
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, room.ID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if !x.isRoomOperator(ctx, iq, subscribers) {
+		return
+	}
+	var found bool
+	for _, vs := range room.Streams {
+		if vs.Type == tp {
+			found = true
+			break
+		}
+	}
+	if !found {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	session := &qcmodel.Session{
+		ID:         uuid.New().String(),
+		RoomID:     room.ID,
+		StreamType: tp,
+		Reviewer:   iq.FromJID().Node(),
+		StartedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	claimed, err := x.qcRep.ClaimQCSession(ctx, session)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if !claimed {
+		_ = x.router.Route(ctx, iq.ConflictError())
+		return
+	}
+	claims := x.qcClaims[iq.FromJID().String()]
+	if claims == nil {
+		claims = make(map[string]struct{})
+		x.qcClaims[iq.FromJID().String()] = claims
+	}
+	claims[session.ID] = struct{}{}
+
+	log.Infof("qc session %s started on %s stream of room %d by %s", session.ID, tp.String(), room.ID, session.Reviewer)
+	x.recordAudit(ctx, session.Reviewer, auditmodel.QCSessionStart, room.ID, tp.String(), session.ID)
+
+	result := iq.ResultIQ()
+	result.AppendElement(qcSessionElement(session))
+	_ = x.router.Route(ctx, result)
+}
+
+// stopQCSession ends a QC session storing its verdict, and releases its stream claim.
+// Only session reviewer or an administrator are allowed to stop it.
+func (x *Ultrasound) stopQCSession(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	attrs := el.Attributes()
+	verdict := qcmodel.ParseVerdictString(attrs.Get("verdict"))
+	if verdict == qcmodel.None && len(attrs.Get("verdict")) > 0 {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	id := attrs.Get("id")
+	if len(id) == 0 {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	session, err := x.qcRep.FetchQCSession(ctx, id)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if session == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	if session.Reviewer != iq.FromJID().Node() && !x.authorize(ctx, iq, rolesmodel.RoomsEdit) {
+		return
+	}
+	if !session.IsOpen() {
+		_ = x.router.Route(ctx, iq.NotAcceptableError())
+		return
+	}
+	session.Verdict = verdict
+	if notes := el.Elements().Child("notes"); notes != nil {
+		session.Notes = strings.TrimSpace(notes.Text())
+	}
+	session.EndedAt = time.Now().UTC().Truncate(time.Second)
+	if err := x.qcRep.CloseQCSession(ctx, session); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	x.releaseQCClaim(session.ID)
+
+	log.Infof("qc session %s stopped by %s with verdict '%s'", session.ID, iq.FromJID().Node(), verdict.String())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.QCSessionStop, session.RoomID, session.StreamType.String(), session.ID+" "+verdict.String())
+
+	result := iq.ResultIQ()
+	result.AppendElement(qcSessionElement(session))
+	_ = x.router.Route(ctx, result)
+}
+
+// sendQCSessions replies with every QC session held on a room.
+func (x *Ultrasound) sendQCSessions(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, room.ID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if !x.isRoomOperator(ctx, iq, subscribers) {
+		return
+	}
+	sessions, err := x.qcRep.FetchQCSessions(ctx, room.ID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	result := iq.ResultIQ()
+	sessionsNode := xmpp.NewElementNamespace("qc_sessions", ultrasoundNamespace)
+	sessionsNode.SetAttribute("room_id", fmt.Sprintf("%d", room.ID))
+	for i := range sessions {
+		sessionsNode.AppendElement(qcSessionElement(&sessions[i]))
+	}
+	result.AppendElement(sessionsNode)
+	_ = x.router.Route(ctx, result)
+}
+
+// expireQCClaims ends every in progress QC session claimed by a disconnected reviewer stream.
+func (x *Ultrasound) expireQCClaims(ctx context.Context, j *jid.JID) error {
+	for id := range x.qcClaims[j.String()] {
+		session, err := x.qcRep.FetchQCSession(ctx, id)
+		if err != nil {
+			return err
+		}
+		if session == nil || !session.IsOpen() {
+			x.releaseQCClaim(id)
+			continue
+		}
+		if err := x.expireQCSession(ctx, session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeRoomQCSessions ends every in progress QC session held on a room.
+func (x *Ultrasound) closeRoomQCSessions(ctx context.Context, roomID int) error {
+	sessions, err := x.qcRep.FetchQCSessions(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	for i := range sessions {
+		if !sessions[i].IsOpen() {
+			continue
+		}
+		if err := x.expireQCSession(ctx, &sessions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireQCSession ends an abandoned QC session without verdict, releasing its stream claim.
+func (x *Ultrasound) expireQCSession(ctx context.Context, session *qcmodel.Session) error {
+	session.Verdict = qcmodel.None
+	session.EndedAt = time.Now().UTC().Truncate(time.Second)
+	if err := x.qcRep.CloseQCSession(ctx, session); err != nil {
+		return err
+	}
+	x.releaseQCClaim(session.ID)
+
+	log.Infof("qc session %s on %s stream of room %d expired", session.ID, session.StreamType.String(), session.RoomID)
+	x.recordAudit(ctx, session.Reviewer, auditmodel.QCSessionStop, session.RoomID, session.StreamType.String(), session.ID+" "+qcmodel.None.String())
+	return nil
+}
+
+func (x *Ultrasound) releaseQCClaim(id string) {
+	for reviewer, claims := range x.qcClaims {
+		if _, ok := claims[id]; !ok {
+			continue
+		}
+		delete(claims, id)
+		if len(claims) == 0 {
+			delete(x.qcClaims, reviewer)
+		}
+		return
+	}
+}
+
+func qcSessionElement(session *qcmodel.Session) *xmpp.Element {
+	sessionNode := xmpp.NewElementNamespace("qc_session", ultrasoundNamespace)
+	sessionNode.SetAttribute("id", session.ID)
+	sessionNode.SetAttribute("room_id", fmt.Sprintf("%d", session.RoomID))
+	sessionNode.SetAttribute("type", session.StreamType.String())
+	sessionNode.SetAttribute("reviewer", session.Reviewer)
+	sessionNode.SetAttribute("started_at", session.StartedAt.UTC().Format(timestampLayout))
+	if !session.IsOpen() {
+		sessionNode.SetAttribute("ended_at", session.EndedAt.UTC().Format(timestampLayout))
+		if session.Verdict != qcmodel.None {
+			sessionNode.SetAttribute("verdict", session.Verdict.String())
+		}
+	}
+	if len(session.Notes) > 0 {
+		notesNode := xmpp.NewElementName("notes")
+		notesNode.SetText(session.Notes)
+		sessionNode.AppendElement(notesNode)
+	}
+	return sessionNode
+}
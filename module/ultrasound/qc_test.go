@@ -0,0 +1,158 @@
+package ultrasound
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Ultrasound_QCSession(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "qc01", Role: model.Usr})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "qc02", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room QC", Username: "admin", Type: roomsmodel.QC})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	for _, roomID := range []int{1, 2} {
+		_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+			Route:  `{"qc01": "srt://127.0.0.1:9001", "qc02": "srt://127.0.0.1:9002"}`,
+			Type:   roomsmodel.Camera,
+			RoomID: roomID,
+		})
+	}
+	_ = roomRep.BindRoom(context.Background(), 1, "qc01")
+	_ = roomRep.BindRoom(context.Background(), 1, "qc02")
+	_ = roomRep.BindRoom(context.Background(), 2, "qc01")
+
+	j1, _ := jid.New("qc01", "example.org", "desktop", true)
+	stm1 := stream.NewMockC2S(uuid.New().String(), j1)
+	stm1.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm1)
+
+	j2, _ := jid.New("qc02", "example.org", "desktop", true)
+	stm2 := stream.NewMockC2S(uuid.New().String(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	j3, _ := jid.New("admin", "example.org", "desktop", true)
+	stm3 := stream.NewMockC2S(uuid.New().String(), j3)
+	stm3.SetPresence(xmpp.NewPresence(j3, j3, xmpp.AvailableType))
+	r.Bind(context.Background(), stm3)
+
+	qcRep := memorystorage.NewQC()
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep, qc: qcRep})
+	defer func() { _ = x.Shutdown() }()
+
+	r.RegisterUnbindHandler(x)
+
+	var qcSession = func(attrs ...string) *xmpp.Element {
+		el := xmpp.NewElementNamespace("qc_session", ultrasoundNamespace)
+		for i := 0; i+1 < len(attrs); i += 2 {
+			el.SetAttribute(attrs[i], attrs[i+1])
+		}
+		return el
+	}
+	start := qcSession("action", "start", "room_id", "1", "type", "camera")
+	require.True(t, x.MatchesIQ(newSetIQ(j1, start)))
+
+	// not a qc room
+	x.ProcessIQ(context.Background(), newSetIQ(j1, qcSession("action", "start", "room_id", "2", "type", "camera")))
+	elem := stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	// stream not present
+	x.ProcessIQ(context.Background(), newSetIQ(j1, qcSession("action", "start", "room_id", "1", "type", "box")))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(context.Background(), newSetIQ(j1, start))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	session := elem.Elements().ChildNamespace("qc_session", ultrasoundNamespace)
+	require.NotNil(t, session)
+	sessionID := session.Attributes().Get("id")
+	require.NotEmpty(t, sessionID)
+	require.Equal(t, "qc01", session.Attributes().Get("reviewer"))
+	require.Equal(t, "camera", session.Attributes().Get("type"))
+	require.NotEmpty(t, session.Attributes().Get("started_at"))
+
+	// stream already claimed
+	x.ProcessIQ(context.Background(), newSetIQ(j2, start))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	// not session reviewer
+	stop := qcSession("action", "stop", "id", sessionID, "verdict", "fail")
+	notes := xmpp.NewElementName("notes")
+	notes.SetText("probe out of focus")
+	stop.AppendElement(notes)
+	x.ProcessIQ(context.Background(), newSetIQ(j2, stop))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// invalid verdict
+	x.ProcessIQ(context.Background(), newSetIQ(j1, qcSession("action", "stop", "id", sessionID, "verdict", "maybe")))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(context.Background(), newSetIQ(j1, stop))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	session = elem.Elements().ChildNamespace("qc_session", ultrasoundNamespace)
+	require.Equal(t, "fail", session.Attributes().Get("verdict"))
+	require.NotEmpty(t, session.Attributes().Get("ended_at"))
+	require.Equal(t, "probe out of focus", session.Elements().Child("notes").Text())
+
+	// already stopped
+	x.ProcessIQ(context.Background(), newSetIQ(j1, stop))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	// claim released
+	x.ProcessIQ(context.Background(), newSetIQ(j2, start))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	sessions := xmpp.NewElementNamespace("qc_sessions", ultrasoundNamespace)
+	sessions.SetAttribute("room_id", "1")
+	x.ProcessIQ(context.Background(), newGetIQ(j2, sessions))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	list := elem.Elements().ChildNamespace("qc_sessions", ultrasoundNamespace).Elements().Children("qc_session")
+	require.Len(t, list, 2)
+	require.Equal(t, sessionID, list[0].Attributes().Get("id"))
+	require.Equal(t, "qc02", list[1].Attributes().Get("reviewer"))
+	require.Empty(t, list[1].Attributes().Get("ended_at"))
+
+	// claim released on reviewer disconnection
+	r.Unbind(context.Background(), j2)
+
+	x.ProcessIQ(context.Background(), newSetIQ(j1, start))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	sessionID = elem.Elements().ChildNamespace("qc_session", ultrasoundNamespace).Attributes().Get("id")
+
+	// claim released on room deletion
+	del := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	del.SetAttribute("action", "delete")
+	del.SetAttribute("room_id", "1")
+	x.ProcessIQ(context.Background(), newSetIQ(j3, del))
+	elem = stm3.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	s, _ := qcRep.FetchQCSession(context.Background(), sessionID)
+	require.NotNil(t, s)
+	require.False(t, s.IsOpen())
+	require.Empty(t, x.qcClaims)
+}
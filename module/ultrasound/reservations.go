@@ -0,0 +1,268 @@
+package ultrasound
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dantin/cubit/log"
+	"github.com/dantin/cubit/model"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/google/uuid"
+)
+
+const (
+	bookAction   = "book"
+	cancelAction = "cancel"
+)
+
+func (x *Ultrasound) setReservation(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	switch el.Attributes().Get("action") {
+	case bookAction:
+		x.bookReservation(ctx, iq, el)
+	case cancelAction:
+		x.cancelReservation(ctx, iq, el)
+	default:
+		_ = x.router.Route(ctx, iq.BadRequestError())
+	}
+}
+
+// bookReservation reserves a room on behalf of IQ sender, unless it's already reserved within requested time range.
+func (x *Ultrasound) bookReservation(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	attrs := el.Attributes()
+	start, err := time.Parse(timestampLayout, attrs.Get("start"))
+	if err != nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	end, err := time.Parse(timestampLayout, attrs.Get("end"))
+	if err != nil || !end.After(start) || !end.After(time.Now()) {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, room.ID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if !x.isRoomOperator(ctx, iq, subscribers) {
+		return
+	}
+	reservation := &roomsmodel.Reservation{
+		ID:       uuid.New().String(),
+		RoomID:   room.ID,
+		Username: iq.FromJID().Node(),
+		Purpose:  attrs.Get("purpose"),
+		Start:    start,
+		End:      end,
+	}
+	inserted, err := x.rsvRep.InsertReservation(ctx, reservation)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if !inserted {
+		_ = x.router.Route(ctx, iq.ConflictError())
+		return
+	}
+	log.Infof("room %d reserved by %s from %s to %s", room.ID, reservation.Username, attrs.Get("start"), attrs.Get("end"))
+	x.recordAudit(ctx, reservation.Username, auditmodel.ReservationBook, room.ID, reservation.ID, reservation.Purpose)
+
+	result := iq.ResultIQ()
+	result.AppendElement(reservationElement(reservation))
+	_ = x.router.Route(ctx, result)
+}
+
+// cancelReservation deletes a reservation.
+// Only reservation holder or an administrator are allowed to cancel it.
+func (x *Ultrasound) cancelReservation(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	id := el.Attributes().Get("id")
+	if len(id) == 0 {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	reservation, err := x.rsvRep.FetchReservation(ctx, id)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if reservation == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	if reservation.Username != iq.FromJID().Node() && !x.authorize(ctx, iq, rolesmodel.RoomsEdit) {
+		return
+	}
+	if err := x.rsvRep.DeleteReservation(ctx, id); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("reservation %s of room %d cancelled by %s", id, reservation.RoomID, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.ReservationCancel, reservation.RoomID, reservation.ID, reservation.Username)
+
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+// sendReservations replies with room reservations overlapping from and to request attributes.
+func (x *Ultrasound) sendReservations(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	attrs := el.Attributes()
+	var from, to time.Time
+	var err error
+	if v := attrs.Get("from"); len(v) > 0 {
+		if from, err = time.Parse(timestampLayout, v); err != nil {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+			return
+		}
+	}
+	if v := attrs.Get("to"); len(v) > 0 {
+		if to, err = time.Parse(timestampLayout, v); err != nil {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+			return
+		}
+	}
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, room.ID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if !x.isRoomOperator(ctx, iq, subscribers) {
+		return
+	}
+	reservations, err := x.rsvRep.FetchReservations(ctx, room.ID, from, to)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	result := iq.ResultIQ()
+	reservationsNode := xmpp.NewElementNamespace("reservations", ultrasoundNamespace)
+	reservationsNode.SetAttribute("room_id", strconv.Itoa(room.ID))
+	for i := range reservations {
+		reservationsNode.AppendElement(reservationElement(&reservations[i]))
+	}
+	result.AppendElement(reservationsNode)
+	_ = x.router.Route(ctx, result)
+}
+
+// reservedRooms returns every room username holds an active reservation for,
+// with its video streams resolved to user route target.
+func (x *Ultrasound) reservedRooms(ctx context.Context, username string) ([]roomsmodel.Room, error) {
+	now := time.Now()
+	reservations, err := x.rsvRep.FetchUserReservations(ctx, username, now, now)
+	if err != nil {
+		return nil, err
+	}
+	var rooms []roomsmodel.Room
+	for _, reservation := range reservations {
+		if !reservation.IsActive(now) {
+			continue
+		}
+		room, err := x.roomRep.FetchRoomByID(ctx, reservation.RoomID)
+		if err != nil {
+			return nil, err
+		}
+		if room == nil {
+			continue
+		}
+		routed, err := room.RoutedTo(model.Usr.String())
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, *routed)
+	}
+	return rooms, nil
+}
+
+// canFetchReservedRoom tells whether or not a user is allowed to fetch room streams, that is,
+// room is not currently reserved, user holds its active reservation or is a room operator.
+func (x *Ultrasound) canFetchReservedRoom(ctx context.Context, username string, roomID int) (bool, error) {
+	now := time.Now()
+	reservations, err := x.rsvRep.FetchReservations(ctx, roomID, now, now)
+	if err != nil {
+		return false, err
+	}
+	for _, reservation := range reservations {
+		if !reservation.IsActive(now) || reservation.Username == username {
+			continue
+		}
+		return x.isBoundRoomOperator(ctx, username, roomID)
+	}
+	return true, nil
+}
+
+// isBoundRoomOperator tells whether or not username is allowed to operate a room reserved by someone else,
+// that is, a user bound to it granted to control its devices, or allowed to edit any room.
+func (x *Ultrasound) isBoundRoomOperator(ctx context.Context, username string, roomID int) (bool, error) {
+	allowed, err := x.authz.IsAllowed(ctx, username, rolesmodel.RoomsEdit)
+	if err != nil || allowed {
+		return allowed, err
+	}
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, roomID)
+	if err != nil {
+		return false, err
+	}
+	for _, subscriber := range subscribers {
+		if subscriber == username {
+			return x.authz.IsAllowed(ctx, username, rolesmodel.DeviceControl)
+		}
+	}
+	return false, nil
+}
+
+// fetchableRoom returns room as username is allowed to see it, that is,
+// without any video stream while someone else holds its active reservation.
+func (x *Ultrasound) fetchableRoom(ctx context.Context, username string, room *roomsmodel.Room) (*roomsmodel.Room, error) {
+	allowed, err := x.canFetchReservedRoom(ctx, username, room.ID)
+	if err != nil {
+		return nil, err
+	}
+	if allowed {
+		return room, nil
+	}
+	res := *room
+	res.Streams = nil
+	return &res, nil
+}
+
+// deleteRoomReservations deletes every reservation of a room.
+func (x *Ultrasound) deleteRoomReservations(ctx context.Context, roomID int) error {
+	reservations, err := x.rsvRep.FetchReservations(ctx, roomID, time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, reservation := range reservations {
+		if err := x.rsvRep.DeleteReservation(ctx, reservation.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reservationElement(reservation *roomsmodel.Reservation) *xmpp.Element {
+	reservationNode := xmpp.NewElementNamespace("reservation", ultrasoundNamespace)
+	reservationNode.SetAttribute("id", reservation.ID)
+	reservationNode.SetAttribute("room_id", strconv.Itoa(reservation.RoomID))
+	reservationNode.SetAttribute("username", reservation.Username)
+	reservationNode.SetAttribute("start", reservation.Start.UTC().Format(timestampLayout))
+	reservationNode.SetAttribute("end", reservation.End.UTC().Format(timestampLayout))
+	if len(reservation.Purpose) > 0 {
+		reservationNode.SetAttribute("purpose", reservation.Purpose)
+	}
+	return reservationNode
+}
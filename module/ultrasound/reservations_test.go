@@ -0,0 +1,177 @@
+package ultrasound
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dantin/cubit/model"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Ultrasound_Reservations(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+	rsvRep := memorystorage.NewReservation()
+	roleRep := memorystorage.NewRole()
+
+	_ = roleRep.UpsertRole(context.Background(), &rolesmodel.Role{Name: "viewer", Permissions: []rolesmodel.Permission{rolesmodel.QCReview}})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "bob", Role: model.Role("viewer")})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "carol", Role: model.Usr})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "dave", Role: model.Usr})
+	for i, username := range []string{"room01", "room02"} {
+		_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: username, Username: username, Type: roomsmodel.Normal})
+		_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+			Route:  fmt.Sprintf(`{"user": "srt://127.0.0.1:900%d"}`, i+1),
+			Type:   roomsmodel.Camera,
+			RoomID: i + 1,
+		})
+	}
+	for _, username := range []string{"admin", "alice", "bob", "carol"} {
+		_ = roomRep.BindRoom(context.Background(), 1, username)
+	}
+
+	var streams []*stream.MockC2S
+	var jids []*jid.JID
+	for _, username := range []string{"admin", "alice", "bob", "carol", "dave"} {
+		j, _ := jid.New(username, "example.org", "desktop", true)
+		stm := stream.NewMockC2S(uuid.New().String(), j)
+		stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+		r.Bind(context.Background(), stm)
+		streams = append(streams, stm)
+		jids = append(jids, j)
+	}
+	adminStm, aliceStm, bobStm, carolStm, daveStm := streams[0], streams[1], streams[2], streams[3], streams[4]
+	adminJID, aliceJID, bobJID, carolJID, daveJID := jids[0], jids[1], jids[2], jids[3], jids[4]
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep, rsv: rsvRep, role: roleRep})
+	defer func() { _ = x.Shutdown() }()
+
+	now := time.Now().UTC()
+	start := now.Add(-time.Minute).Format(timestampLayout)
+	end := now.Add(time.Hour).Format(timestampLayout)
+
+	book := reservationRequest("reservation", "action", "book", "room_id", "1", "start", start, "end", end, "purpose", "abdominal exam")
+	require.True(t, x.MatchesIQ(newSetIQ(aliceJID, book)))
+
+	// invalid time range
+	x.ProcessIQ(context.Background(), newSetIQ(aliceJID, reservationRequest("reservation", "action", "book", "room_id", "1", "start", end, "end", start)))
+	requireStanzaError(t, aliceStm, xmpp.ErrBadRequest)
+
+	x.ProcessIQ(context.Background(), newSetIQ(aliceJID, book))
+	elem := aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	reservation := elem.Elements().ChildNamespace("reservation", ultrasoundNamespace)
+	require.NotNil(t, reservation)
+	reservationID := reservation.Attributes().Get("id")
+	require.NotEmpty(t, reservationID)
+	require.Equal(t, "alice", reservation.Attributes().Get("username"))
+	require.Equal(t, end, reservation.Attributes().Get("end"))
+	require.Equal(t, "abdominal exam", reservation.Attributes().Get("purpose"))
+
+	// overlapping reservation
+	x.ProcessIQ(context.Background(), newSetIQ(bobJID, reservationRequest("reservation", "action", "book", "room_id", "1", "start", now.Add(time.Minute*30).Format(timestampLayout), "end", now.Add(time.Hour*2).Format(timestampLayout))))
+	requireStanzaError(t, bobStm, xmpp.ErrConflict)
+
+	// back to back reservation
+	x.ProcessIQ(context.Background(), newSetIQ(bobJID, reservationRequest("reservation", "action", "book", "room_id", "1", "start", end, "end", now.Add(time.Hour*2).Format(timestampLayout))))
+	require.Equal(t, xmpp.ResultType, bobStm.ReceiveElement().Type())
+
+	x.ProcessIQ(context.Background(), newGetIQ(bobJID, reservationRequest("reservations", "room_id", "1")))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	reservations := elem.Elements().ChildNamespace("reservations", ultrasoundNamespace).Elements().Children("reservation")
+	require.Len(t, reservations, 2)
+	require.Equal(t, "alice", reservations[0].Attributes().Get("username"))
+	require.Equal(t, "bob", reservations[1].Attributes().Get("username"))
+
+	x.ProcessIQ(context.Background(), newGetIQ(bobJID, reservationRequest("reservations", "room_id", "1", "to", now.Format(timestampLayout))))
+	elem = bobStm.ReceiveElement()
+	require.Len(t, elem.Elements().ChildNamespace("reservations", ultrasoundNamespace).Elements().Children("reservation"), 1)
+
+	// only reservation holder or operators fetch reserved room streams
+	x.ProcessIQ(context.Background(), newGetIQ(bobJID, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	requireStanzaError(t, bobStm, xmpp.ErrForbidden)
+
+	x.ProcessIQ(context.Background(), newGetIQ(carolJID, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem = carolStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.Equal(t, "srt://127.0.0.1:9001", elem.Elements().ChildNamespace("room", ultrasoundNamespace).Elements().Child("video_stream").Text())
+
+	// reservation holders fetch room streams even if not bound to it
+	x.ProcessIQ(context.Background(), newGetIQ(daveJID, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	requireStanzaError(t, daveStm, xmpp.ErrItemNotFound)
+
+	inserted, _ := rsvRep.InsertReservation(context.Background(), &roomsmodel.Reservation{ID: uuid.New().String(), RoomID: 2, Username: "dave", Start: now.Add(-time.Minute), End: now.Add(time.Hour)})
+	require.True(t, inserted)
+
+	x.ProcessIQ(context.Background(), newGetIQ(daveJID, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem = daveStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	room := elem.Elements().ChildNamespace("room", ultrasoundNamespace)
+	require.Equal(t, "2", room.Attributes().Get("room_id"))
+	require.Equal(t, "srt://127.0.0.1:9002", room.Elements().Child("video_stream").Text())
+
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	require.Equal(t, xmpp.ResultType, aliceStm.ReceiveElement().Type())
+
+	x.ProcessIQ(context.Background(), newGetIQ(adminJID, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	require.Equal(t, xmpp.ResultType, adminStm.ReceiveElement().Type())
+
+	// reserved room streams are not listed either
+	x.ProcessIQ(context.Background(), newGetIQ(bobJID, xmpp.NewElementNamespace("my_rooms", ultrasoundNamespace)))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	myRooms := elem.Elements().ChildNamespace("my_rooms", ultrasoundNamespace).Elements().Children("room")
+	require.Len(t, myRooms, 1)
+	require.Nil(t, myRooms[0].Elements().Child("video_stream"))
+
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, xmpp.NewElementNamespace("my_rooms", ultrasoundNamespace)))
+	elem = aliceStm.ReceiveElement()
+	myRooms = elem.Elements().ChildNamespace("my_rooms", ultrasoundNamespace).Elements().Children("room")
+	require.NotNil(t, myRooms[0].Elements().Child("video_stream"))
+
+	// nor notified
+	update := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	update.SetAttribute("action", "update")
+	update.SetAttribute("room_id", "1")
+	update.SetAttribute("name", "Room 1")
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, update))
+	require.Equal(t, xmpp.ResultType, adminStm.ReceiveElement().Type())
+	require.NotNil(t, requireRoomEvent(t, adminStm, "update").Elements().Child("video_stream"))
+	require.NotNil(t, requireRoomEvent(t, aliceStm, "update").Elements().Child("video_stream"))
+	require.Nil(t, requireRoomEvent(t, bobStm, "update").Elements().Child("video_stream"))
+
+	// not reservation holder
+	cancel := reservationRequest("reservation", "action", "cancel", "id", reservationID)
+	x.ProcessIQ(context.Background(), newSetIQ(bobJID, cancel))
+	requireStanzaError(t, bobStm, xmpp.ErrForbidden)
+
+	x.ProcessIQ(context.Background(), newSetIQ(aliceJID, cancel))
+	require.Equal(t, xmpp.ResultType, aliceStm.ReceiveElement().Type())
+
+	x.ProcessIQ(context.Background(), newSetIQ(aliceJID, cancel))
+	requireStanzaError(t, aliceStm, xmpp.ErrItemNotFound)
+
+	x.ProcessIQ(context.Background(), newGetIQ(bobJID, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	require.Equal(t, xmpp.ResultType, bobStm.ReceiveElement().Type())
+}
+
+func reservationRequest(name string, attrs ...string) *xmpp.Element {
+	el := xmpp.NewElementNamespace(name, ultrasoundNamespace)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		el.SetAttribute(attrs[i], attrs[i+1])
+	}
+	return el
+}
@@ -0,0 +1,172 @@
+package ultrasound
+
+import (
+	"context"
+
+	"github.com/dantin/cubit/log"
+	"github.com/dantin/cubit/model"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	"github.com/dantin/cubit/xmpp"
+)
+
+func (x *Ultrasound) sendRoles(ctx context.Context, iq *xmpp.IQ) {
+	if !x.authorize(ctx, iq, rolesmodel.RolesEdit) {
+		return
+	}
+	roles, err := x.authz.Roles(ctx)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	result := iq.ResultIQ()
+	rolesNode := xmpp.NewElementNamespace("roles", ultrasoundNamespace)
+	for _, role := range roles {
+		rolesNode.AppendElement(roleElement(&role))
+	}
+	result.AppendElement(rolesNode)
+	_ = x.router.Route(ctx, result)
+}
+
+// setRole defines or deletes a role. Root role cannot be modified,
+// while the rest of built-in roles can be redefined but not deleted.
+func (x *Ultrasound) setRole(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	attrs := el.Attributes()
+	action := attrs.Get("action")
+	name := attrs.Get("name")
+	if !model.IsValidRoleName(name) {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if name == model.Root.String() {
+		_ = x.router.Route(ctx, iq.NotAllowedError())
+		return
+	}
+	switch action {
+	case updateAction:
+		role := &rolesmodel.Role{Name: name}
+		for _, permNode := range el.Elements().Children("permission") {
+			perm := rolesmodel.Permission(permNode.Text())
+			if !rolesmodel.IsValidPermission(perm) {
+				_ = x.router.Route(ctx, iq.BadRequestError())
+				return
+			}
+			if !role.HasPermission(perm) {
+				role.Permissions = append(role.Permissions, perm)
+			}
+		}
+		role.SortPermissions()
+		if err := x.roleRep.UpsertRole(ctx, role); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	case deleteAction:
+		if rolesmodel.IsBuiltIn(name) {
+			_ = x.router.Route(ctx, iq.NotAllowedError())
+			return
+		}
+		role, err := x.roleRep.FetchRole(ctx, name)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		if role == nil {
+			_ = x.router.Route(ctx, iq.ItemNotFoundError())
+			return
+		}
+		if err := x.roleRep.DeleteRole(ctx, name); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	default:
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	log.Infof("role %s %s by %s", name, action, iq.FromJID().Node())
+	if action == updateAction {
+		x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.RoleUpdate, 0, name, "")
+	} else {
+		x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.RoleDelete, 0, name, "")
+	}
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+// setUserRole assigns a role to a user. Only root users are allowed to grant or revoke root role.
+func (x *Ultrasound) setUserRole(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	attrs := el.Attributes()
+	username := attrs.Get("username")
+	roleName := attrs.Get("role")
+	if len(username) == 0 || len(roleName) == 0 {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	role, err := x.authz.Role(ctx, roleName)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if role == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	user, err := x.userRep.FetchUser(ctx, username)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if user == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	if roleName == model.Root.String() || user.Role == model.Root {
+		requester, err := x.userRep.FetchUser(ctx, iq.FromJID().Node())
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		if requester == nil || requester.Role != model.Root {
+			_ = x.router.Route(ctx, iq.ForbiddenError())
+			return
+		}
+	}
+	// built-in roles might not be stored yet, while assignments must refer to a stored role.
+	stored, err := x.roleRep.FetchRole(ctx, roleName)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if stored == nil {
+		if err := x.roleRep.UpsertRole(ctx, role); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	}
+	if err := x.userRep.UpdateUserRole(ctx, username, model.Role(roleName)); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("role %s assigned to %s by %s", roleName, username, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.RoleAssign, 0, username, roleName)
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+func roleElement(role *rolesmodel.Role) xmpp.XElement {
+	roleNode := xmpp.NewElementName("role")
+	roleNode.SetAttribute("name", role.Name)
+	for _, perm := range role.Permissions {
+		permNode := xmpp.NewElementName("permission")
+		permNode.SetText(string(perm))
+		roleNode.AppendElement(permNode)
+	}
+	return roleNode
+}
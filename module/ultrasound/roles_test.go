@@ -0,0 +1,144 @@
+package ultrasound
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Ultrasound_Roles(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+	roleRep := memorystorage.NewRole()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "root", Role: model.Root})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "room01", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+
+	var streams []*stream.MockC2S
+	var jids []*jid.JID
+	for _, username := range []string{"root", "admin", "room01"} {
+		j, _ := jid.New(username, "example.org", "desktop", true)
+		stm := stream.NewMockC2S(uuid.New().String(), j)
+		stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+		r.Bind(context.Background(), stm)
+		streams = append(streams, stm)
+		jids = append(jids, j)
+	}
+	rootJID, adminJID, userJID := jids[0], jids[1], jids[2]
+	rootStm, adminStm, userStm := streams[0], streams[1], streams[2]
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep, role: roleRep})
+	defer func() { _ = x.Shutdown() }()
+
+	// root is allowed to list rooms
+	x.ProcessIQ(context.Background(), newGetIQ(rootJID, roomsRequest(nil)))
+	require.Equal(t, xmpp.ResultType, rootStm.ReceiveElement().Type())
+
+	// users are not allowed to manage roles
+	x.ProcessIQ(context.Background(), newGetIQ(userJID, xmpp.NewElementNamespace("roles", ultrasoundNamespace)))
+	requireStanzaError(t, userStm, xmpp.ErrForbidden)
+
+	// define a new role
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, roleRequest("update", "viewer", "rooms.list", "qc.review", "rooms.list")))
+	require.Equal(t, xmpp.ResultType, adminStm.ReceiveElement().Type())
+
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, roleRequest("update", "viewer", "rooms.delete")))
+	requireStanzaError(t, adminStm, xmpp.ErrBadRequest)
+
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, roleRequest("update", "Viewer ", "rooms.list")))
+	requireStanzaError(t, adminStm, xmpp.ErrBadRequest)
+
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, roleRequest("update", "root")))
+	requireStanzaError(t, adminStm, xmpp.ErrNotAllowed)
+
+	x.ProcessIQ(context.Background(), newGetIQ(adminJID, xmpp.NewElementNamespace("roles", ultrasoundNamespace)))
+	elem := adminStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	roles := elem.Elements().ChildNamespace("roles", ultrasoundNamespace).Elements().Children("role")
+	require.Len(t, roles, 4)
+	require.Equal(t, "viewer", roles[3].Attributes().Get("name"))
+	perms := roles[3].Elements().Children("permission")
+	require.Len(t, perms, 2)
+	require.Equal(t, "qc.review", perms[0].Text())
+	require.Equal(t, "rooms.list", perms[1].Text())
+
+	// assign it
+	x.ProcessIQ(context.Background(), newGetIQ(userJID, roomsRequest(nil)))
+	requireStanzaError(t, userStm, xmpp.ErrForbidden)
+
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, userRoleRequest("room01", "viewer")))
+	require.Equal(t, xmpp.ResultType, adminStm.ReceiveElement().Type())
+
+	x.ProcessIQ(context.Background(), newGetIQ(userJID, roomsRequest(nil)))
+	require.Equal(t, xmpp.ResultType, userStm.ReceiveElement().Type())
+
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, userRoleRequest("room01", "none")))
+	requireStanzaError(t, adminStm, xmpp.ErrItemNotFound)
+
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, userRoleRequest("room02", "viewer")))
+	requireStanzaError(t, adminStm, xmpp.ErrItemNotFound)
+
+	// only root grants root role
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, userRoleRequest("room01", "root")))
+	requireStanzaError(t, adminStm, xmpp.ErrForbidden)
+
+	x.ProcessIQ(context.Background(), newSetIQ(adminJID, userRoleRequest("root", "user")))
+	requireStanzaError(t, adminStm, xmpp.ErrForbidden)
+
+	x.ProcessIQ(context.Background(), newSetIQ(rootJID, userRoleRequest("admin", "user")))
+	require.Equal(t, xmpp.ResultType, rootStm.ReceiveElement().Type())
+
+	usr, _ := userRep.FetchUser(context.Background(), "admin")
+	require.Equal(t, model.Usr, usr.Role)
+	stored, _ := roleRep.FetchRole(context.Background(), "user")
+	require.NotNil(t, stored)
+
+	// delete roles
+	x.ProcessIQ(context.Background(), newSetIQ(rootJID, roleRequest("delete", "admin")))
+	requireStanzaError(t, rootStm, xmpp.ErrNotAllowed)
+
+	x.ProcessIQ(context.Background(), newSetIQ(rootJID, roleRequest("delete", "editor")))
+	requireStanzaError(t, rootStm, xmpp.ErrItemNotFound)
+
+	x.ProcessIQ(context.Background(), newSetIQ(rootJID, roleRequest("delete", "viewer")))
+	require.Equal(t, xmpp.ResultType, rootStm.ReceiveElement().Type())
+
+	x.ProcessIQ(context.Background(), newGetIQ(userJID, roomsRequest(nil)))
+	requireStanzaError(t, userStm, xmpp.ErrForbidden)
+}
+
+func requireStanzaError(t *testing.T, stm *stream.MockC2S, sErr *xmpp.StanzaError) {
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.Equal(t, sErr.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func roleRequest(action, name string, perms ...string) *xmpp.Element {
+	role := xmpp.NewElementNamespace("role", ultrasoundNamespace)
+	role.SetAttribute("action", action)
+	role.SetAttribute("name", name)
+	for _, perm := range perms {
+		permNode := xmpp.NewElementName("permission")
+		permNode.SetText(perm)
+		role.AppendElement(permNode)
+	}
+	return role
+}
+
+func userRoleRequest(username, role string) *xmpp.Element {
+	userRole := xmpp.NewElementNamespace("user_role", ultrasoundNamespace)
+	userRole.SetAttribute("username", username)
+	userRole.SetAttribute("role", role)
+	return userRole
+}
@@ -0,0 +1,118 @@
+package ultrasound
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dantin/cubit/log"
+	"github.com/dantin/cubit/model"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/xmpp"
+)
+
+func (x *Ultrasound) setRoute(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	attrs := el.Attributes()
+	tp := roomsmodel.ParseVideoTypeString(attrs.Get("type"))
+	if tp == roomsmodel.Unknown {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	route := roomsmodel.Route{Target: attrs.Get("target"), URL: strings.TrimSpace(el.Text())}
+	if err := route.Validate(); err != nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	// routes are shared by every room subscriber, thus only room editors may change them
+	if !x.authorize(ctx, iq, rolesmodel.RoomsEdit) {
+		return
+	}
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, room.ID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	var vs *roomsmodel.VideoStream
+	for i := range room.Streams {
+		if room.Streams[i].Type == tp {
+			vs = &room.Streams[i]
+			break
+		}
+	}
+	if vs == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	routes, err := vs.Routes()
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	vs.Route = routes.Set(route).String()
+	if err := x.roomRep.UpsertVideoStream(ctx, vs); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("%s stream of room %d routed to %s by %s", tp.String(), room.ID, route.Target, iq.FromJID().Node())
+	x.recordAudit(ctx, iq.FromJID().Node(), auditmodel.StreamRoute, room.ID, tp.String(), route.Target)
+	_ = x.router.Route(ctx, iq.ResultIQ())
+
+	// only route target subscribers and administrators get to know the new route
+	recipients := x.onlineAdmins()
+	for _, subscriber := range subscribers {
+		if route.Target == model.Usr.String() || route.Target == subscriber {
+			recipients = append(recipients, subscriber)
+		}
+	}
+	x.notifyRoute(ctx, room.ID, tp, &route, recipients)
+
+	x.notifyRoom(ctx, room.ID, room, updateAction, x.onlineAdmins(), model.Admin.String())
+}
+
+// notifyRoute pushes a route change event to every online resource of usernames,
+// signing route URL for each one of them.
+func (x *Ultrasound) notifyRoute(ctx context.Context, roomID int, tp roomsmodel.VideoType, route *roomsmodel.Route, usernames []string) {
+	notified := make(map[string]struct{}, len(usernames))
+	for _, username := range usernames {
+		if _, ok := notified[username]; ok {
+			continue
+		}
+		notified[username] = struct{}{}
+
+		for _, stm := range x.router.LocalStreams(username) {
+			routeURL, expiresAt, err := x.playbackURL(tp, route.URL, stm.JID())
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			routeNode := xmpp.NewElementNamespace("route", ultrasoundNamespace)
+			routeNode.SetAttribute("room_id", fmt.Sprintf("%d", roomID))
+			routeNode.SetAttribute("type", tp.String())
+			routeNode.SetAttribute("target", route.Target)
+			setPlaybackExpiry(routeNode, expiresAt)
+			routeNode.SetText(routeURL)
+			x.routeEvent(ctx, stm.JID(), routeNode)
+		}
+	}
+}
+
+// isRoomOperator tells whether IQ sender is allowed to operate a room,
+// that is, a user bound to it or allowed to edit any room.
+func (x *Ultrasound) isRoomOperator(ctx context.Context, iq *xmpp.IQ, subscribers []string) bool {
+	username := iq.FromJID().Node()
+	for _, subscriber := range subscribers {
+		if subscriber == username {
+			return true
+		}
+	}
+	return x.authorize(ctx, iq, rolesmodel.RoomsEdit)
+}
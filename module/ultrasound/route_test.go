@@ -0,0 +1,139 @@
+package ultrasound
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Ultrasound_SetRoute(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "room01", Role: model.Usr})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "room02", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`,
+		Type:   roomsmodel.Camera,
+		RoomID: 1,
+	})
+	_ = roomRep.BindRoom(context.Background(), 1, "room01")
+
+	j, _ := jid.New("admin", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	j2, _ := jid.New("room01", "example.org", "desktop", true)
+	stm2 := stream.NewMockC2S(uuid.New().String(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	j3, _ := jid.New("room02", "example.org", "desktop", true)
+	stm3 := stream.NewMockC2S(uuid.New().String(), j3)
+	stm3.SetPresence(xmpp.NewPresence(j3, j3, xmpp.AvailableType))
+	r.Bind(context.Background(), stm3)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	requireWatchRooms(t, x, j, stm)
+
+	route := xmpp.NewElementNamespace("route", ultrasoundNamespace)
+	route.SetAttribute("room_id", "1")
+	route.SetAttribute("type", "camera")
+	route.SetAttribute("target", "user")
+	route.SetText("srt://127.0.0.1:9003")
+	require.True(t, x.MatchesIQ(newSetIQ(j, route)))
+
+	// not bound to the room
+	x.ProcessIQ(context.Background(), newSetIQ(j3, route))
+	elem := stm3.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// bound operators can't redirect routes shared by every subscriber
+	x.ProcessIQ(context.Background(), newSetIQ(j2, route))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// invalid route
+	bad := xmpp.NewElementNamespace("route", ultrasoundNamespace)
+	bad.SetAttribute("room_id", "1")
+	bad.SetAttribute("type", "camera")
+	bad.SetAttribute("target", "user")
+	bad.SetText("127.0.0.1:9003")
+	x.ProcessIQ(context.Background(), newSetIQ(j, bad))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// stream not present
+	missing := xmpp.NewElementNamespace("route", ultrasoundNamespace)
+	missing.SetAttribute("room_id", "1")
+	missing.SetAttribute("type", "device")
+	missing.SetAttribute("target", "user")
+	missing.SetText("srt://127.0.0.1:9003")
+	x.ProcessIQ(context.Background(), newSetIQ(j, missing))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(context.Background(), newSetIQ(j, route))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// subscriber notification
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, xmpp.HeadlineType, elem.Type())
+	notification := elem.Elements().ChildNamespace("route", ultrasoundNamespace)
+	require.NotNil(t, notification)
+	require.Equal(t, "1", notification.Attributes().Get("room_id"))
+	require.Equal(t, "camera", notification.Attributes().Get("type"))
+	require.Equal(t, "user", notification.Attributes().Get("target"))
+	require.Equal(t, "srt://127.0.0.1:9003", notification.Text())
+
+	// administrators watching rooms get the new route and the updated room
+	elem = stm.ReceiveElement()
+	require.Equal(t, "srt://127.0.0.1:9003", elem.Elements().ChildNamespace("route", ultrasoundNamespace).Text())
+	requireRoomEvent(t, stm, "update")
+
+	rm, _ := roomRep.FetchRoom(context.Background(), "room01")
+	require.Equal(t, "srt://127.0.0.1:9003", rm.Streams[0].Stream)
+	require.Equal(t, "", rm.Streams[0].Route)
+
+	rm, _ = roomRep.FetchRoomByID(context.Background(), 1)
+	routes, _ := rm.Streams[0].Routes()
+	u, _ := routes.Lookup("admin")
+	require.Equal(t, "srt://127.0.0.1:9002", u)
+
+	// other targets are only notified to administrators
+	route.SetAttribute("target", "admin")
+	route.SetText("srt://127.0.0.1:9004")
+	x.ProcessIQ(context.Background(), newSetIQ(j, route))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	elem = stm.ReceiveElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "admin", elem.Elements().ChildNamespace("route", ultrasoundNamespace).Attributes().Get("target"))
+
+	event := requireRoomEvent(t, stm, "update")
+	videos := event.Elements().Children("video_stream")
+	require.Len(t, videos, 1)
+	require.Equal(t, "srt://127.0.0.1:9004", videos[0].Text())
+
+	// bound users are not notified of other targets
+	x.ProcessIQ(context.Background(), newGetIQ(j2, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "iq", elem.Name())
+}
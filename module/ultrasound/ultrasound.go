@@ -4,56 +4,203 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/dantin/cubit/authz"
 	"github.com/dantin/cubit/log"
 	"github.com/dantin/cubit/model"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
 	"github.com/dantin/cubit/module/xep0030"
+	"github.com/dantin/cubit/module/xep0059"
 	"github.com/dantin/cubit/router"
 	"github.com/dantin/cubit/storage/repository"
 	"github.com/dantin/cubit/util/runqueue"
+	"github.com/dantin/cubit/util/urlsign"
 	"github.com/dantin/cubit/xmpp"
 	"github.com/dantin/cubit/xmpp/jid"
 )
 
 const ultrasoundNamespace = "hc:apm:ultrasound"
 
+const defaultPageSize = 4
+
+const defaultPlaybackTTL = time.Minute * 5
+
 // Config represents customized ultrasound module configuration.
 type Config struct {
-	PageSize int `yaml:"page_size"`
+	PageSize         int
+	HeartbeatTimeout time.Duration
+	CommandTimeout   time.Duration
+	PlaybackKeys     []urlsign.Key
+	PlaybackTTL      time.Duration
+	PlaybackTypeTTL  map[roomsmodel.VideoType]time.Duration
+}
+
+type playbackKeyProxy struct {
+	ID     string `yaml:"id"`
+	Secret string `yaml:"secret"`
+}
+
+type configProxy struct {
+	PageSize         int                `yaml:"page_size"`
+	HeartbeatTimeout int                `yaml:"heartbeat_timeout"`
+	CommandTimeout   int                `yaml:"command_timeout"`
+	PlaybackKeys     []playbackKeyProxy `yaml:"playback_keys"`
+	PlaybackTTL      int                `yaml:"playback_ttl"`
+	PlaybackTypeTTL  map[string]int     `yaml:"playback_type_ttl"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if p.HeartbeatTimeout < 0 {
+		return fmt.Errorf("ultrasound.Config: heartbeat timeout must be 0 or higher")
+	}
+	if p.CommandTimeout < 0 {
+		return fmt.Errorf("ultrasound.Config: command timeout must be 0 or higher")
+	}
+	if p.PlaybackTTL < 0 {
+		return fmt.Errorf("ultrasound.Config: playback ttl must be 0 or higher")
+	}
+	keyIDs := make(map[string]struct{})
+	for _, k := range p.PlaybackKeys {
+		if len(k.ID) == 0 || len(k.Secret) == 0 {
+			return fmt.Errorf("ultrasound.Config: playback key id and secret must be specified")
+		}
+		if _, ok := keyIDs[k.ID]; ok {
+			return fmt.Errorf("ultrasound.Config: duplicated playback key: %s", k.ID)
+		}
+		keyIDs[k.ID] = struct{}{}
+		c.PlaybackKeys = append(c.PlaybackKeys, urlsign.Key{ID: k.ID, Secret: []byte(k.Secret)})
+	}
+	for tp, ttl := range p.PlaybackTypeTTL {
+		vt := roomsmodel.ParseVideoTypeString(tp)
+		if vt == roomsmodel.Unknown {
+			return fmt.Errorf("ultrasound.Config: unrecognized playback video type: %s", tp)
+		}
+		if ttl < 0 {
+			return fmt.Errorf("ultrasound.Config: playback ttl must be 0 or higher")
+		}
+		if c.PlaybackTypeTTL == nil {
+			c.PlaybackTypeTTL = make(map[roomsmodel.VideoType]time.Duration)
+		}
+		c.PlaybackTypeTTL[vt] = time.Second * time.Duration(ttl)
+	}
+	c.PageSize = p.PageSize
+	c.HeartbeatTimeout = time.Second * time.Duration(p.HeartbeatTimeout)
+	c.CommandTimeout = time.Second * time.Duration(p.CommandTimeout)
+	c.PlaybackTTL = time.Second * time.Duration(p.PlaybackTTL)
+	return nil
+}
+
+// RoomHandler is notified every time a room, or any of its user bindings, changes.
+type RoomHandler interface {
+	RoomUpdated(ctx context.Context, roomID int)
+	RoomDeleted(ctx context.Context, roomID int)
 }
 
 // Ultrasound represents a ultrasound module.
 type Ultrasound struct {
-	cfg      *Config
-	router   router.Router
-	runQueue *runqueue.RunQueue
-	userRep  repository.User
-	roomRep  repository.Room
+	cfg       *Config
+	router    router.Router
+	runQueue  *runqueue.RunQueue
+	userRep   repository.User
+	roomRep   repository.Room
+	deviceRep repository.Device
+	qcRep     repository.QC
+	rsvRep    repository.Reservation
+	roleRep   repository.Role
+	auditRep  repository.Audit
+	authz     *authz.Authorizer
+	signer    *urlsign.Signer
+	admins    map[string]struct{}
+	startedAt time.Time
+	lastSeen  map[string]time.Time
+	stale     map[string]struct{}
+	viewers   map[int]map[string]struct{}
+	qcClaims  map[string]map[string]struct{}
+	handlers  []RoomHandler
+	tc        *time.Ticker
+	doneCh    chan struct{}
+
+	mu          sync.RWMutex
+	commandAcks map[string]*commandAck
 }
 
 // New returns a ultrasound IQ handler module.
-func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, roomRep repository.Room) *Ultrasound {
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, roomRep repository.Room, deviceRep repository.Device, qcRep repository.QC, rsvRep repository.Reservation, roleRep repository.Role, auditRep repository.Audit) *Ultrasound {
 	v := &Ultrasound{
-		cfg:      config,
-		router:   router,
-		runQueue: runqueue.New("ultrasound"),
-		userRep:  userRep,
-		roomRep:  roomRep,
+		cfg:       config,
+		router:    router,
+		runQueue:  runqueue.New("ultrasound"),
+		userRep:   userRep,
+		roomRep:   roomRep,
+		deviceRep: deviceRep,
+		qcRep:     qcRep,
+		rsvRep:    rsvRep,
+		roleRep:   roleRep,
+		auditRep:  auditRep,
+		authz:     authz.New(userRep, roleRep),
+		admins:    make(map[string]struct{}),
+		startedAt: time.Now(),
+		lastSeen:  make(map[string]time.Time),
+		stale:     make(map[string]struct{}),
+		viewers:   make(map[int]map[string]struct{}),
+		qcClaims:  make(map[string]map[string]struct{}),
+		doneCh:    make(chan struct{}),
+
+		commandAcks: make(map[string]*commandAck),
+	}
+	if len(config.PlaybackKeys) > 0 {
+		v.signer = urlsign.NewSigner(config.PlaybackKeys...)
+	}
+	if config.HeartbeatTimeout > 0 {
+		v.tc = time.NewTicker(config.HeartbeatTimeout / 2)
+		go v.loop()
 	}
 	if disco != nil {
 		disco.RegisterServerFeature(ultrasoundNamespace)
+		disco.RegisterServerFeature(xep0059.RSMNamespace)
 	}
 	return v
 }
 
 // MatchesIQ returns whether or not an IQ should be processed by the ultrasound module.
 func (x *Ultrasound) MatchesIQ(iq *xmpp.IQ) bool {
+	if x.isCommandAck(iq) {
+		return true
+	}
 	e := iq.Elements()
 	profile := e.ChildNamespace("profile", ultrasoundNamespace)
 	rooms := e.ChildNamespace("rooms", ultrasoundNamespace)
 	room := e.ChildNamespace("room", ultrasoundNamespace)
 	qc := e.ChildNamespace("qc", ultrasoundNamespace)
-	return (iq.IsGet() && (profile != nil || rooms != nil || room != nil || qc != nil))
+	myRooms := e.ChildNamespace("my_rooms", ultrasoundNamespace)
+	activeRoom := e.ChildNamespace("active_room", ultrasoundNamespace)
+	video := e.ChildNamespace("video_stream", ultrasoundNamespace)
+	binding := e.ChildNamespace("binding", ultrasoundNamespace)
+	route := e.ChildNamespace("route", ultrasoundNamespace)
+	device := e.ChildNamespace("device", ultrasoundNamespace)
+	devices := e.ChildNamespace("devices", ultrasoundNamespace)
+	qcSession := e.ChildNamespace("qc_session", ultrasoundNamespace)
+	qcSessions := e.ChildNamespace("qc_sessions", ultrasoundNamespace)
+	roles := e.ChildNamespace("roles", ultrasoundNamespace)
+	role := e.ChildNamespace("role", ultrasoundNamespace)
+	userRole := e.ChildNamespace("user_role", ultrasoundNamespace)
+	auditLog := e.ChildNamespace("audit", ultrasoundNamespace)
+	auditVerify := e.ChildNamespace("audit_verify", ultrasoundNamespace)
+	viewing := e.ChildNamespace("viewing", ultrasoundNamespace)
+	reservation := e.ChildNamespace("reservation", ultrasoundNamespace)
+	reservations := e.ChildNamespace("reservations", ultrasoundNamespace)
+	return (iq.IsGet() && (profile != nil || rooms != nil || room != nil || qc != nil || myRooms != nil || devices != nil || qcSessions != nil || roles != nil || auditLog != nil || auditVerify != nil || reservations != nil)) ||
+		(iq.IsSet() && (room != nil || video != nil || binding != nil || activeRoom != nil || route != nil || device != nil || qcSession != nil || role != nil || userRole != nil || viewing != nil || reservation != nil))
 }
 
 // ProcessIQ process a ultrasound IQ talking according action over the associated stream.
@@ -63,8 +210,17 @@ func (x *Ultrasound) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
 	})
 }
 
+// RegisterRoomHandler registers a new room change handler.
+func (x *Ultrasound) RegisterRoomHandler(h RoomHandler) {
+	x.runQueue.Run(func() { x.handlers = append(x.handlers, h) })
+}
+
 // Shutdown shuts down ultrasound module.
 func (x *Ultrasound) Shutdown() error {
+	if x.tc != nil {
+		x.tc.Stop()
+		close(x.doneCh)
+	}
 	c := make(chan struct{})
 	x.runQueue.Stop(func() { close(c) })
 	<-c
@@ -72,7 +228,35 @@ func (x *Ultrasound) Shutdown() error {
 }
 
 func (x *Ultrasound) processIQ(ctx context.Context, iq *xmpp.IQ) {
+	if x.isCommandAck(iq) {
+		x.handleCommandAck(iq)
+		return
+	}
 	e := iq.Elements()
+	if iq.IsSet() {
+		if activeRoom := e.ChildNamespace("active_room", ultrasoundNamespace); activeRoom != nil {
+			x.setActiveRoom(ctx, iq, activeRoom)
+			return
+		}
+		if route := e.ChildNamespace("route", ultrasoundNamespace); route != nil {
+			x.setRoute(ctx, iq, route)
+			return
+		}
+		if qcSession := e.ChildNamespace("qc_session", ultrasoundNamespace); qcSession != nil {
+			x.setQCSession(ctx, iq, qcSession)
+			return
+		}
+		if viewing := e.ChildNamespace("viewing", ultrasoundNamespace); viewing != nil {
+			x.setViewing(ctx, iq, viewing)
+			return
+		}
+		if reservation := e.ChildNamespace("reservation", ultrasoundNamespace); reservation != nil {
+			x.setReservation(ctx, iq, reservation)
+			return
+		}
+		x.processSetIQ(ctx, iq)
+		return
+	}
 	if profile := e.ChildNamespace("profile", ultrasoundNamespace); profile != nil {
 		x.sendProfile(ctx, iq)
 	} else if rooms := e.ChildNamespace("rooms", ultrasoundNamespace); rooms != nil {
@@ -81,6 +265,20 @@ func (x *Ultrasound) processIQ(ctx context.Context, iq *xmpp.IQ) {
 		x.sendRoom(ctx, iq)
 	} else if qc := e.ChildNamespace("qc", ultrasoundNamespace); qc != nil {
 		x.sendQCStream(ctx, iq)
+	} else if myRooms := e.ChildNamespace("my_rooms", ultrasoundNamespace); myRooms != nil {
+		x.sendBoundRooms(ctx, iq)
+	} else if devices := e.ChildNamespace("devices", ultrasoundNamespace); devices != nil {
+		x.sendDevices(ctx, iq, devices)
+	} else if qcSessions := e.ChildNamespace("qc_sessions", ultrasoundNamespace); qcSessions != nil {
+		x.sendQCSessions(ctx, iq, qcSessions)
+	} else if roles := e.ChildNamespace("roles", ultrasoundNamespace); roles != nil {
+		x.sendRoles(ctx, iq)
+	} else if auditLog := e.ChildNamespace("audit", ultrasoundNamespace); auditLog != nil {
+		x.sendAuditEvents(ctx, iq, auditLog)
+	} else if auditVerify := e.ChildNamespace("audit_verify", ultrasoundNamespace); auditVerify != nil {
+		x.sendAuditVerification(ctx, iq)
+	} else if reservations := e.ChildNamespace("reservations", ultrasoundNamespace); reservations != nil {
+		x.sendReservations(ctx, iq, reservations)
 	} else {
 		_ = x.router.Route(ctx, iq.BadRequestError())
 	}
@@ -95,6 +293,10 @@ func (x *Ultrasound) sendProfile(ctx context.Context, iq *xmpp.IQ) {
 	user, err := x.userRep.FetchUser(ctx, username)
 	if err != nil {
 		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if user == nil {
 		_ = x.router.Route(ctx, iq.ItemNotFoundError())
 		return
 	}
@@ -119,16 +321,10 @@ func (x *Ultrasound) sendRooms(ctx context.Context, iq *xmpp.IQ) {
 
 	log.Debugf("retrieving video stream for %s", username)
 
-	user, err := x.userRep.FetchUser(ctx, username)
-	if err != nil {
-		log.Error(err)
-		_ = x.router.Route(ctx, iq.ItemNotFoundError())
-		return
-	}
-	if user.Role != model.Admin {
-		_ = x.router.Route(ctx, iq.ForbiddenError())
+	if !x.authorize(ctx, iq, rolesmodel.RoomsList) {
 		return
 	}
+	x.watchRooms(username)
 
 	req := iq.Elements().ChildNamespace("rooms", ultrasoundNamespace)
 	if req == nil {
@@ -136,61 +332,64 @@ func (x *Ultrasound) sendRooms(ctx context.Context, iq *xmpp.IQ) {
 		_ = x.router.Route(ctx, iq.InternalServerError())
 		return
 	}
-	var page, size int
-	pageValue := req.Attributes().Get("page")
-	if len(pageValue) > 0 {
-		if page, err = strconv.Atoi(pageValue); err != nil {
-			page = 0
-		}
-	} else {
-		page = 0
-	}
-	sizeValue := req.Attributes().Get("size")
-	if len(sizeValue) > 0 {
-		if size, err = strconv.Atoi(sizeValue); err != nil {
-			size = 4
-		}
-	} else {
-		size = 4
+	filter, err := x.roomsFilter(ctx, req)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
 	}
-	rooms, err := x.roomRep.FetchRooms(ctx, page, size)
+	page, err := x.roomsPage(req)
 	if err != nil {
 		log.Error(err)
-		_ = x.router.Route(ctx, iq.InternalServerError())
+		_ = x.router.Route(ctx, iq.BadRequestError())
 		return
 	}
-	total, err := x.roomRep.CountRooms(ctx)
+	total, err := x.roomRep.CountRooms(ctx, filter)
 	if err != nil {
 		log.Error(err)
 		_ = x.router.Route(ctx, iq.InternalServerError())
 		return
 	}
+	var rooms []roomsmodel.Room
+	var index int
+	if page.Max > 0 {
+		rooms, index, err = x.roomRep.FetchRooms(ctx, filter, page)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	}
 
 	result := iq.ResultIQ()
 	roomsNode := xmpp.NewElementNamespace("rooms", ultrasoundNamespace)
-	roomsNode.SetAttribute("page", fmt.Sprintf("%d", page))
-	roomsNode.SetAttribute("pages", fmt.Sprintf("%d", total/size))
-	roomsNode.SetAttribute("size", fmt.Sprintf("%d", size))
-	for _, room := range rooms {
-		roomNode := xmpp.NewElementName("room")
-		roomJID, err := jid.New(room.Username, userJID.Domain(), userJID.Resource(), true)
+	for i := range rooms {
+		room, err := x.fetchableRoom(ctx, username, &rooms[i])
 		if err != nil {
 			log.Error(err)
 			_ = x.router.Route(ctx, iq.InternalServerError())
 			return
 		}
-		roomNode.SetAttribute("jid", roomJID.String())
-		roomNode.SetAttribute("room_id", fmt.Sprintf("%d", room.ID))
-		roomNode.SetAttribute("name", room.Name)
-
-		for _, video := range room.Streams {
-			videoNode := xmpp.NewElementName("video_stream")
-			videoNode.SetAttribute("type", video.Type.String())
-			videoNode.SetText(video.Stream)
-			roomNode.AppendElement(videoNode)
+		roomNode, err := x.roomElement(xmpp.NewElementName("room"), room, userJID)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
 		}
+		if err := x.setRoomHealth(ctx, roomNode, room.ID); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		x.setRoomViewers(roomNode, room.ID)
 		roomsNode.AppendElement(roomNode)
 	}
+	rs := xep0059.Result{Index: index, Count: total}
+	if len(rooms) > 0 {
+		rs.First = strconv.Itoa(rooms[0].ID)
+		rs.Last = strconv.Itoa(rooms[len(rooms)-1].ID)
+	}
+	roomsNode.AppendElement(rs.Element())
 	result.AppendElement(roomsNode)
 	_ = x.router.Route(ctx, result)
 }
@@ -201,45 +400,52 @@ func (x *Ultrasound) sendRoom(ctx context.Context, iq *xmpp.IQ) {
 
 	log.Debugf("retrieving video stream for %s", username)
 
-	user, err := x.userRep.FetchUser(ctx, username)
+	rooms, err := x.roomRep.FetchBoundRooms(ctx, username)
 	if err != nil {
 		log.Error(err)
-		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		_ = x.router.Route(ctx, iq.InternalServerError())
 		return
 	}
-	if user.Role != model.Usr {
-		_ = x.router.Route(ctx, iq.ForbiddenError())
+	reserved, err := x.reservedRooms(ctx, username)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
 		return
 	}
-
-	room, err := x.roomRep.FetchRoom(ctx, username)
+	// currently reserved rooms take precedence over bound ones
+	room := x.activeRoom(userJID, append(reserved, rooms...))
+	if room == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	allowed, err := x.canFetchReservedRoom(ctx, username, room.ID)
 	if err != nil {
 		log.Error(err)
 		_ = x.router.Route(ctx, iq.InternalServerError())
 		return
 	}
+	if !allowed {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
 
 	result := iq.ResultIQ()
-	roomNode := xmpp.NewElementNamespace("room", ultrasoundNamespace)
-	roomJID, err := jid.New(room.Username, userJID.Domain(), userJID.Resource(), true)
+	roomNode, err := x.roomElement(xmpp.NewElementNamespace("room", ultrasoundNamespace), room, userJID)
 	if err != nil {
 		log.Error(err)
 		_ = x.router.Route(ctx, iq.InternalServerError())
 		return
 	}
-	roomNode.SetAttribute("jid", roomJID.String())
-	roomNode.SetAttribute("room_id", fmt.Sprintf("%d", room.ID))
-	roomNode.SetAttribute("name", room.Name)
-
-	for _, video := range room.Streams {
-		videoNode := xmpp.NewElementName("video_stream")
-		videoNode.SetAttribute("type", video.Type.String())
-		videoNode.SetText(video.Stream)
-		roomNode.AppendElement(videoNode)
+	if err := x.setRoomHealth(ctx, roomNode, room.ID); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
 	}
-
+	x.setRoomViewers(roomNode, room.ID)
 	result.AppendElement(roomNode)
 	_ = x.router.Route(ctx, result)
+
+	x.recordAudit(ctx, username, auditmodel.StreamView, room.ID, "", "")
 }
 
 func (x *Ultrasound) sendQCStream(ctx context.Context, iq *xmpp.IQ) {
@@ -248,27 +454,185 @@ func (x *Ultrasound) sendQCStream(ctx context.Context, iq *xmpp.IQ) {
 
 	log.Debugf("retrieving video stream for %s", username)
 
-	user, err := x.userRep.FetchUser(ctx, username)
+	if !x.authorize(ctx, iq, rolesmodel.QCReview) {
+		return
+	}
+	video, err := x.roomRep.FetchQCStream(ctx, username)
 	if err != nil {
 		log.Error(err)
-		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		_ = x.router.Route(ctx, iq.InternalServerError())
 		return
 	}
-	if user.Role != model.Usr {
-		_ = x.router.Route(ctx, iq.ForbiddenError())
+	if video == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
 		return
 	}
 
-	video, err := x.roomRep.FetchQCStream(ctx, username)
+	streamURL, expiresAt, err := x.playbackURL(video.Type, video.Stream, userJID)
 	if err != nil {
 		log.Error(err)
 		_ = x.router.Route(ctx, iq.InternalServerError())
 		return
 	}
-
 	result := iq.ResultIQ()
 	qcNode := xmpp.NewElementNamespace("qc", ultrasoundNamespace)
-	qcNode.SetText(video.Stream)
+	setPlaybackExpiry(qcNode, expiresAt)
+	qcNode.SetText(streamURL)
+	appendStreamMetadata(qcNode, &video.Metadata)
 	result.AppendElement(qcNode)
 	_ = x.router.Route(ctx, result)
+
+	x.recordAudit(ctx, username, auditmodel.StreamView, video.RoomID, video.Type.String(), "")
+}
+
+// setRoomHealth sets room aggregated device health as a roomNode attribute.
+func (x *Ultrasound) setRoomHealth(ctx context.Context, roomNode *xmpp.Element, roomID int) error {
+	health, err := x.roomHealth(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	roomNode.SetAttribute("health", health.String())
+	return nil
+}
+
+// roomsFilter reads rooms listing filter from name, type and health request attributes.
+func (x *Ultrasound) roomsFilter(ctx context.Context, req xmpp.XElement) (*roomsmodel.Filter, error) {
+	filter := &roomsmodel.Filter{Name: req.Attributes().Get("name"), Type: roomsmodel.Normal}
+	if tp := req.Attributes().Get("type"); len(tp) > 0 {
+		if filter.Type = roomsmodel.ParseRoomTypeString(tp); filter.Type == 0 {
+			return nil, fmt.Errorf("ultrasound: invalid room type: %s", tp)
+		}
+	}
+	hv := req.Attributes().Get("health")
+	if len(hv) == 0 {
+		return filter, nil
+	}
+	health, ok := devicesmodel.ParseHealthString(hv)
+	if !ok {
+		return nil, fmt.Errorf("ultrasound: invalid room health: %s", hv)
+	}
+	unhealthy, err := x.unhealthyRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch health {
+	case devicesmodel.OK:
+		degraded, down := unhealthy[devicesmodel.Degraded], unhealthy[devicesmodel.Down]
+		ids := make([]int, 0, len(degraded)+len(down))
+		filter.ExcludedIDs = append(append(ids, degraded...), down...)
+	default:
+		filter.IDs = append([]int{}, unhealthy[health]...)
+	}
+	return filter, nil
+}
+
+// roomsPage reads rooms listing page from request result set management element.
+func (x *Ultrasound) roomsPage(req xmpp.XElement) (*roomsmodel.Page, error) {
+	rsm, err := xep0059.NewRequestFromElement(req.Elements().ChildNamespace("set", xep0059.RSMNamespace))
+	if err != nil {
+		return nil, err
+	}
+	page := &roomsmodel.Page{Max: rsm.Max, Last: rsm.LastPage}
+	if page.Max == -1 {
+		page.Max = defaultPageSize
+		if x.cfg.PageSize > 0 {
+			page.Max = x.cfg.PageSize
+		}
+	}
+	if rsm.Index > 0 {
+		page.Index = rsm.Index
+	}
+	if len(rsm.After) > 0 {
+		if page.After, err = strconv.Atoi(rsm.After); err != nil {
+			return nil, fmt.Errorf("ultrasound: invalid set after: %s", rsm.After)
+		}
+	}
+	if len(rsm.Before) > 0 {
+		if page.Before, err = strconv.Atoi(rsm.Before); err != nil {
+			return nil, fmt.Errorf("ultrasound: invalid set before: %s", rsm.Before)
+		}
+	}
+	return page, nil
+}
+
+// roomElement fills in roomNode with room attributes and its video streams,
+// already resolved to requester route target, so that no other broadcast URL is ever disclosed.
+func (x *Ultrasound) roomElement(roomNode *xmpp.Element, room *roomsmodel.Room, userJID *jid.JID) (*xmpp.Element, error) {
+	roomJID, err := jid.New(room.Username, userJID.Domain(), userJID.Resource(), true)
+	if err != nil {
+		return nil, err
+	}
+	roomNode.SetAttribute("jid", roomJID.String())
+	roomNode.SetAttribute("room_id", fmt.Sprintf("%d", room.ID))
+	roomNode.SetAttribute("name", room.Name)
+
+	for _, video := range room.Streams {
+		videoNode := xmpp.NewElementName("video_stream")
+		videoNode.SetAttribute("type", video.Type.String())
+
+		streamURL, expiresAt, err := x.playbackURL(video.Type, video.Stream, userJID)
+		if err != nil {
+			return nil, err
+		}
+		setPlaybackExpiry(videoNode, expiresAt)
+		videoNode.SetText(streamURL)
+		appendStreamMetadata(videoNode, &video.Metadata)
+		roomNode.AppendElement(videoNode)
+	}
+	return roomNode, nil
+}
+
+// appendStreamMetadata appends every specified metadata property to videoNode as a child element.
+func appendStreamMetadata(videoNode *xmpp.Element, md *roomsmodel.Metadata) {
+	appendText := func(name, value string) {
+		if len(value) == 0 {
+			return
+		}
+		node := xmpp.NewElementName(name)
+		node.SetText(value)
+		videoNode.AppendElement(node)
+	}
+	appendText("protocol", md.Protocol.String())
+	appendText("codec", md.Codec)
+	if md.Width > 0 && md.Height > 0 {
+		resolutionNode := xmpp.NewElementName("resolution")
+		resolutionNode.SetAttribute("width", strconv.Itoa(md.Width))
+		resolutionNode.SetAttribute("height", strconv.Itoa(md.Height))
+		videoNode.AppendElement(resolutionNode)
+	}
+	if md.Framerate > 0 {
+		appendText("framerate", strconv.FormatFloat(md.Framerate, 'f', -1, 64))
+	}
+	if md.Bitrate > 0 {
+		appendText("bitrate", strconv.Itoa(md.Bitrate))
+	}
+	appendText("latency", md.Latency.String())
+}
+
+// playbackURL returns streamURL signed for userJID, along with its expiration time.
+// Stream URLs are returned unsigned whenever no playback keys have been configured.
+func (x *Ultrasound) playbackURL(tp roomsmodel.VideoType, streamURL string, userJID *jid.JID) (string, time.Time, error) {
+	if x.signer == nil || len(streamURL) == 0 {
+		return streamURL, time.Time{}, nil
+	}
+	ttl, ok := x.cfg.PlaybackTypeTTL[tp]
+	if !ok || ttl == 0 {
+		ttl = x.cfg.PlaybackTTL
+	}
+	if ttl == 0 {
+		ttl = defaultPlaybackTTL
+	}
+	expiresAt := time.Now().Add(ttl)
+	signed, err := x.signer.Sign(streamURL, userJID.ToBareJID().String(), expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+func setPlaybackExpiry(node *xmpp.Element, expiresAt time.Time) {
+	if expiresAt.IsZero() {
+		return
+	}
+	node.SetAttribute("expires", expiresAt.UTC().Format(timestampLayout))
 }
@@ -3,13 +3,21 @@ package ultrasound
 import (
 	"context"
 	"crypto/tls"
+	"strconv"
 	"testing"
+	"time"
 
 	c2srouter "github.com/dantin/cubit/c2s/router"
+	"github.com/dantin/cubit/model"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/module/xep0059"
 	"github.com/dantin/cubit/router"
 	"github.com/dantin/cubit/router/host"
 	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/storage/repository"
 	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/util/urlsign"
 	"github.com/dantin/cubit/xmpp"
 	"github.com/dantin/cubit/xmpp/jid"
 	"github.com/google/uuid"
@@ -28,7 +36,7 @@ func TestModule_Ultrasound_MatchesIQ(t *testing.T) {
 	r.Bind(context.Background(), stm)
 
 	cfg := Config{}
-	x := New(&cfg, nil, r, nil, nil)
+	x := newTestUltrasound(&cfg, r, testReps{})
 	defer func() { _ = x.Shutdown() }()
 
 	// test MatchesIQ
@@ -49,6 +57,344 @@ func TestModule_Ultrasound_MatchesIQ(t *testing.T) {
 	query = xmpp.NewElementNamespace("rooms", ultrasoundNamespace)
 	iq.AppendElement(query)
 	require.True(t, x.MatchesIQ(iq))
+
+	iq.ClearElements()
+	iq.SetType(xmpp.SetType)
+	iq.AppendElement(xmpp.NewElementNamespace("rooms", ultrasoundNamespace))
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.ClearElements()
+	iq.AppendElement(xmpp.NewElementNamespace("room", ultrasoundNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq.ClearElements()
+	iq.AppendElement(xmpp.NewElementNamespace("video_stream", ultrasoundNamespace))
+	require.True(t, x.MatchesIQ(iq))
+}
+
+func TestModule_Ultrasound_SetRoom(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Role: model.Usr})
+
+	j, _ := jid.New("admin", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	j2, _ := jid.New("user", "example.org", "desktop", true)
+	stm2 := stream.NewMockC2S(uuid.New().String(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	requireWatchRooms(t, x, j, stm)
+
+	// create room
+	room := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	room.SetAttribute("action", "create")
+	room.SetAttribute("name", "Room 01")
+	room.SetAttribute("username", "room01")
+	iq := newSetIQ(j, room)
+
+	x.ProcessIQ(context.Background(), iq)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	created := elem.Elements().ChildNamespace("room", ultrasoundNamespace)
+	require.NotNil(t, created)
+	require.Equal(t, "1", created.Attributes().Get("room_id"))
+
+	event := requireRoomEvent(t, stm, "create")
+	require.Equal(t, "1", event.Attributes().Get("room_id"))
+	require.Equal(t, "Room 01", event.Attributes().Get("name"))
+
+	rm, _ := roomRep.FetchRoomByID(context.Background(), 1)
+	require.NotNil(t, rm)
+	require.Equal(t, "Room 01", rm.Name)
+	require.Equal(t, roomsmodel.Normal, rm.Type)
+
+	// username already bound to a room
+	x.ProcessIQ(context.Background(), newSetIQ(j, room))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	// forbidden for regular users
+	x.ProcessIQ(context.Background(), newSetIQ(j2, room))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// missing attributes
+	bad := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	bad.SetAttribute("action", "create")
+	bad.SetAttribute("name", "Room 02")
+	x.ProcessIQ(context.Background(), newSetIQ(j, bad))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// update room
+	update := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	update.SetAttribute("action", "update")
+	update.SetAttribute("room_id", "1")
+	update.SetAttribute("name", "Room QC")
+	update.SetAttribute("type", "qc")
+	x.ProcessIQ(context.Background(), newSetIQ(j, update))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	event = requireRoomEvent(t, stm, "update")
+	require.Equal(t, "qc", event.Attributes().Get("type"))
+
+	rm, _ = roomRep.FetchRoomByID(context.Background(), 1)
+	require.Equal(t, "Room QC", rm.Name)
+	require.Equal(t, "room01", rm.Username)
+	require.Equal(t, roomsmodel.QC, rm.Type)
+
+	// update unknown room
+	update.SetAttribute("room_id", "2")
+	x.ProcessIQ(context.Background(), newSetIQ(j, update))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// delete room
+	del := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	del.SetAttribute("action", "delete")
+	del.SetAttribute("room_id", "1")
+	x.ProcessIQ(context.Background(), newSetIQ(j, del))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	event = requireRoomEvent(t, stm, "delete")
+	require.Equal(t, "1", event.Attributes().Get("room_id"))
+
+	rm, _ = roomRep.FetchRoomByID(context.Background(), 1)
+	require.Nil(t, rm)
+
+	x.ProcessIQ(context.Background(), newSetIQ(j, del))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// storage error
+	memorystorage.EnableMockedError()
+	x.ProcessIQ(context.Background(), newSetIQ(j, room))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
+	memorystorage.DisableMockedError()
+}
+
+type fakeRoomHandler struct {
+	events chan string
+}
+
+func (h *fakeRoomHandler) RoomUpdated(_ context.Context, roomID int) {
+	h.events <- "update:" + strconv.Itoa(roomID)
+}
+
+func (h *fakeRoomHandler) RoomDeleted(_ context.Context, roomID int) {
+	h.events <- "delete:" + strconv.Itoa(roomID)
+}
+
+func TestModule_Ultrasound_RoomHandler(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+
+	j, _ := jid.New("admin", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	requireWatchRooms(t, x, j, stm)
+
+	h := &fakeRoomHandler{events: make(chan string, 8)}
+	x.RegisterRoomHandler(h)
+
+	room := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	room.SetAttribute("action", "create")
+	room.SetAttribute("name", "Room 01")
+	room.SetAttribute("username", "room01")
+	x.ProcessIQ(context.Background(), newSetIQ(j, room))
+	require.Equal(t, xmpp.ResultType, stm.ReceiveElement().Type())
+	require.Equal(t, "update:1", <-h.events)
+	_ = requireRoomEvent(t, stm, "create")
+
+	binding := xmpp.NewElementNamespace("binding", ultrasoundNamespace)
+	binding.SetAttribute("action", "bind")
+	binding.SetAttribute("room_id", "1")
+	binding.SetAttribute("username", "alice")
+	x.ProcessIQ(context.Background(), newSetIQ(j, binding))
+	require.Equal(t, xmpp.ResultType, stm.ReceiveElement().Type())
+	require.Equal(t, "update:1", <-h.events)
+
+	del := xmpp.NewElementNamespace("room", ultrasoundNamespace)
+	del.SetAttribute("action", "delete")
+	del.SetAttribute("room_id", "1")
+	x.ProcessIQ(context.Background(), newSetIQ(j, del))
+	require.Equal(t, xmpp.ResultType, stm.ReceiveElement().Type())
+	require.Equal(t, "delete:1", <-h.events)
+}
+
+func TestModule_Ultrasound_SetVideoStream(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "root", Role: model.Root})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+
+	j, _ := jid.New("root", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	requireWatchRooms(t, x, j, stm)
+
+	video := xmpp.NewElementNamespace("video_stream", ultrasoundNamespace)
+	video.SetAttribute("action", "create")
+	video.SetAttribute("room_id", "1")
+	video.SetAttribute("type", "camera")
+	video.SetAttribute("in", "srt://127.0.0.1:9001")
+	video.SetText(`{"user": "srt://127.0.0.1:9002", "admin": "srt://127.0.0.1:9003"}`)
+
+	x.ProcessIQ(context.Background(), newSetIQ(j, video))
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	event := requireRoomEvent(t, stm, "update")
+	videos := event.Elements().Children("video_stream")
+	require.Len(t, videos, 1)
+	require.Equal(t, "srt://127.0.0.1:9003", videos[0].Text())
+
+	rm, _ := roomRep.FetchRoomByID(context.Background(), 1)
+	require.Len(t, rm.Streams, 1)
+	require.Equal(t, "srt://127.0.0.1:9001", rm.Streams[0].In)
+	require.Equal(t, roomsmodel.Camera, rm.Streams[0].Type)
+
+	// conflict
+	x.ProcessIQ(context.Background(), newSetIQ(j, video))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	// malformed route
+	video.SetAttribute("action", "update")
+	video.SetText("srt://127.0.0.1:9002")
+	x.ProcessIQ(context.Background(), newSetIQ(j, video))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// update
+	video.SetAttribute("broadcast", "srt://127.0.0.1:9004")
+	video.SetText("")
+	x.ProcessIQ(context.Background(), newSetIQ(j, video))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	requireRoomEvent(t, stm, "update")
+
+	rm, _ = roomRep.FetchRoomByID(context.Background(), 1)
+	require.Len(t, rm.Streams, 1)
+	require.Equal(t, "srt://127.0.0.1:9004", rm.Streams[0].Broadcast)
+	require.Equal(t, `{"user": "srt://127.0.0.1:9002", "admin": "srt://127.0.0.1:9003"}`, rm.Streams[0].Route)
+
+	// metadata
+	video.SetAttribute("protocol", "srt")
+	video.SetAttribute("codec", "h264")
+	video.SetAttribute("resolution", "1920x1080")
+	video.SetAttribute("framerate", "29.97")
+	video.SetAttribute("bitrate", "4000")
+	video.SetAttribute("latency", "low")
+	x.ProcessIQ(context.Background(), newSetIQ(j, video))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	event = requireRoomEvent(t, stm, "update")
+	videos = event.Elements().Children("video_stream")
+	require.Len(t, videos, 1)
+	require.Equal(t, "srt", videos[0].Elements().Child("protocol").Text())
+	require.Equal(t, "h264", videos[0].Elements().Child("codec").Text())
+	require.Equal(t, "1920", videos[0].Elements().Child("resolution").Attributes().Get("width"))
+	require.Equal(t, "1080", videos[0].Elements().Child("resolution").Attributes().Get("height"))
+	require.Equal(t, "29.97", videos[0].Elements().Child("framerate").Text())
+	require.Equal(t, "4000", videos[0].Elements().Child("bitrate").Text())
+	require.Equal(t, "low", videos[0].Elements().Child("latency").Text())
+
+	rm, _ = roomRep.FetchRoomByID(context.Background(), 1)
+	require.Equal(t, roomsmodel.Metadata{
+		Protocol:  roomsmodel.SRT,
+		Codec:     "h264",
+		Width:     1920,
+		Height:    1080,
+		Framerate: 29.97,
+		Bitrate:   4000,
+		Latency:   roomsmodel.LowLatency,
+	}, rm.Streams[0].Metadata)
+
+	for attr, value := range map[string]string{"protocol": "dash", "resolution": "1080p", "framerate": "0", "bitrate": "fast", "latency": "high"} {
+		invalid := xmpp.NewElementFromElement(video)
+		invalid.SetAttribute(attr, value)
+		x.ProcessIQ(context.Background(), newSetIQ(j, invalid))
+		requireStanzaError(t, stm, xmpp.ErrBadRequest)
+	}
+	for _, attr := range []string{"protocol", "codec", "resolution", "framerate", "bitrate", "latency"} {
+		video.RemoveAttribute(attr)
+	}
+
+	// unknown stream
+	video.SetAttribute("type", "device")
+	x.ProcessIQ(context.Background(), newSetIQ(j, video))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// delete
+	video.SetAttribute("action", "delete")
+	video.SetAttribute("type", "camera")
+	x.ProcessIQ(context.Background(), newSetIQ(j, video))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	event = requireRoomEvent(t, stm, "update")
+	require.Len(t, event.Elements().Children("video_stream"), 0)
+
+	rm, _ = roomRep.FetchRoomByID(context.Background(), 1)
+	require.Len(t, rm.Streams, 0)
+}
+
+// requireWatchRooms lets an administrator fetch room list, so that it gets notified of any further room change.
+func requireWatchRooms(t *testing.T, x *Ultrasound, j *jid.JID, stm *stream.MockC2S) {
+	x.ProcessIQ(context.Background(), newGetIQ(j, xmpp.NewElementNamespace("rooms", ultrasoundNamespace)))
+	require.Equal(t, xmpp.ResultType, stm.ReceiveElement().Type())
+}
+
+func requireRoomEvent(t *testing.T, stm *stream.MockC2S, action string) xmpp.XElement {
+	elem := stm.ReceiveElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, xmpp.HeadlineType, elem.Type())
+	event := elem.Elements().ChildNamespace("room", ultrasoundNamespace)
+	require.NotNil(t, event)
+	require.Equal(t, action, event.Attributes().Get("action"))
+	return event
+}
+
+func newSetIQ(from *jid.JID, elem xmpp.XElement) *xmpp.IQ {
+	srvJID, _ := jid.New("", "example.org", "", true)
+
+	iq := xmpp.NewIQType(uuid.New().String(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(srvJID)
+	iq.AppendElement(elem)
+	return iq
 }
 
 func setupTest() router.Router {
@@ -60,3 +406,443 @@ func setupTest() router.Router {
 	)
 	return r
 }
+
+// testReps holds the repositories a test ultrasound module is built upon.
+// Unset ones default to empty memory storages.
+type testReps struct {
+	user   repository.User
+	room   repository.Room
+	device repository.Device
+	qc     repository.QC
+	rsv    repository.Reservation
+	role   repository.Role
+	audit  repository.Audit
+}
+
+func newTestUltrasound(cfg *Config, r router.Router, reps testReps) *Ultrasound {
+	if reps.user == nil {
+		reps.user = memorystorage.NewUser()
+	}
+	if reps.room == nil {
+		reps.room = memorystorage.NewRoom()
+	}
+	if reps.device == nil {
+		reps.device = memorystorage.NewDevice()
+	}
+	if reps.qc == nil {
+		reps.qc = memorystorage.NewQC()
+	}
+	if reps.rsv == nil {
+		reps.rsv = memorystorage.NewReservation()
+	}
+	if reps.role == nil {
+		reps.role = memorystorage.NewRole()
+	}
+	if reps.audit == nil {
+		reps.audit = memorystorage.NewAudit()
+	}
+	return New(cfg, nil, r, reps.user, reps.room, reps.device, reps.qc, reps.rsv, reps.role, reps.audit)
+}
+
+func TestModule_Ultrasound_GetRoom(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "room01", Role: model.Usr})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "room02", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`,
+		Type:   roomsmodel.Camera,
+		RoomID: 1,
+	})
+	_ = roomRep.BindRoom(context.Background(), 1, "room01")
+
+	j, _ := jid.New("room01", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	j2, _ := jid.New("room02", "example.org", "desktop", true)
+	stm2 := stream.NewMockC2S(uuid.New().String(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	x.ProcessIQ(context.Background(), newGetIQ(j, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	room := elem.Elements().ChildNamespace("room", ultrasoundNamespace)
+	require.NotNil(t, room)
+	require.Equal(t, "1", room.Attributes().Get("room_id"))
+	videos := room.Elements().Children("video_stream")
+	require.Len(t, videos, 1)
+	require.Equal(t, "camera", videos[0].Attributes().Get("type"))
+	require.Equal(t, "srt://127.0.0.1:9001", videos[0].Text()) // admin route is not disclosed
+	require.Len(t, videos[0].Elements().Children("route"), 0)
+
+	// not bound to any room
+	x.ProcessIQ(context.Background(), newGetIQ(j2, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestModule_Ultrasound_SignedPlayback(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "room01", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`,
+		Type:   roomsmodel.Camera,
+		RoomID: 1,
+	})
+	_ = roomRep.BindRoom(context.Background(), 1, "room01")
+
+	j, _ := jid.New("room01", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	keys := []urlsign.Key{{ID: "k2", Secret: []byte("secret-2")}, {ID: "k1", Secret: []byte("secret-1")}}
+	cfg := &Config{
+		PlaybackKeys:    keys,
+		PlaybackTTL:     time.Hour,
+		PlaybackTypeTTL: map[roomsmodel.VideoType]time.Duration{roomsmodel.Camera: time.Minute},
+	}
+	x := newTestUltrasound(cfg, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	x.ProcessIQ(context.Background(), newGetIQ(j, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	video := elem.Elements().ChildNamespace("room", ultrasoundNamespace).Elements().Child("video_stream")
+	require.NotNil(t, video)
+
+	expiresAt, err := time.Parse(timestampLayout, video.Attributes().Get("expires"))
+	require.Nil(t, err)
+	require.True(t, expiresAt.Before(time.Now().Add(time.Minute+time.Second)))
+
+	verifier := urlsign.NewSigner(keys[1:]...)
+	_, err = verifier.Verify(video.Text(), time.Now())
+	require.Equal(t, urlsign.ErrUnknownKey, err)
+
+	verifier = urlsign.NewSigner(keys...)
+	sub, err := verifier.Verify(video.Text(), time.Now())
+	require.Nil(t, err)
+	require.Equal(t, "room01@example.org", sub)
+
+	_, err = verifier.Verify(video.Text(), time.Now().Add(time.Minute+time.Second))
+	require.Equal(t, urlsign.ErrExpired, err)
+}
+
+func TestModule_Ultrasound_GetRooms(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	for _, username := range []string{"room01", "room02", "room03"} {
+		room := roomsmodel.Room{Name: username, Username: username, Type: roomsmodel.Normal}
+		_ = roomRep.UpsertRoom(context.Background(), &room)
+		_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+			Route:  `{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`,
+			Type:   roomsmodel.Camera,
+			RoomID: room.ID,
+		})
+	}
+
+	j, _ := jid.New("admin", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	deviceRep := memorystorage.NewDevice()
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0001", Type: roomsmodel.Camera, RoomID: 2, Username: "device-sn-0001"})
+
+	x := newTestUltrasound(&Config{PageSize: 2}, r, testReps{user: userRep, room: roomRep, device: deviceRep})
+	defer func() { _ = x.Shutdown() }()
+
+	// first page
+	x.ProcessIQ(context.Background(), newGetIQ(j, xmpp.NewElementNamespace("rooms", ultrasoundNamespace)))
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	res := elem.Elements().ChildNamespace("rooms", ultrasoundNamespace)
+	require.NotNil(t, res)
+	require.Len(t, res.Elements().Children("room"), 2)
+	require.Equal(t, "srt://127.0.0.1:9002", res.Elements().Children("room")[0].Elements().Child("video_stream").Text())
+	set := res.Elements().ChildNamespace("set", xep0059.RSMNamespace)
+	require.NotNil(t, set)
+	require.Equal(t, "1", set.Elements().Child("first").Text())
+	require.Equal(t, "0", set.Elements().Child("first").Attributes().Get("index"))
+	require.Equal(t, "2", set.Elements().Child("last").Text())
+	require.Equal(t, "3", set.Elements().Child("count").Text())
+
+	// next page
+	x.ProcessIQ(context.Background(), newGetIQ(j, roomsRequest(nil, "max", "2", "after", "2")))
+	elem = stm.ReceiveElement()
+	res = elem.Elements().ChildNamespace("rooms", ultrasoundNamespace)
+	require.Len(t, res.Elements().Children("room"), 1)
+	require.Equal(t, "3", res.Elements().Children("room")[0].Attributes().Get("room_id"))
+	set = res.Elements().ChildNamespace("set", xep0059.RSMNamespace)
+	require.Equal(t, "2", set.Elements().Child("first").Attributes().Get("index"))
+
+	// last page
+	x.ProcessIQ(context.Background(), newGetIQ(j, roomsRequest(nil, "max", "2", "before", "")))
+	elem = stm.ReceiveElement()
+	res = elem.Elements().ChildNamespace("rooms", ultrasoundNamespace)
+	require.Len(t, res.Elements().Children("room"), 2)
+	require.Equal(t, "2", res.Elements().Children("room")[0].Attributes().Get("room_id"))
+
+	// count only
+	x.ProcessIQ(context.Background(), newGetIQ(j, roomsRequest(nil, "max", "0")))
+	elem = stm.ReceiveElement()
+	res = elem.Elements().ChildNamespace("rooms", ultrasoundNamespace)
+	require.Len(t, res.Elements().Children("room"), 0)
+	set = res.Elements().ChildNamespace("set", xep0059.RSMNamespace)
+	require.Nil(t, set.Elements().Child("first"))
+	require.Equal(t, "3", set.Elements().Child("count").Text())
+
+	// invalid set
+	x.ProcessIQ(context.Background(), newGetIQ(j, roomsRequest(nil, "after", "room01")))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestModule_Ultrasound_FilterRooms(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room QC", Username: "admin", Type: roomsmodel.QC})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Cardiology 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Cardiology 02", Username: "room02", Type: roomsmodel.Normal})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Obstetrics 01", Username: "room03", Type: roomsmodel.Normal})
+	deviceRep := memorystorage.NewDevice()
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0001", Type: roomsmodel.Camera, RoomID: 3, Username: "device-sn-0001"})
+
+	j, _ := jid.New("admin", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep, device: deviceRep})
+	defer func() { _ = x.Shutdown() }()
+
+	var roomIDs = func(attrs ...string) []string {
+		x.ProcessIQ(context.Background(), newGetIQ(j, roomsRequest(attrs)))
+		elem := stm.ReceiveElement()
+		require.Equal(t, xmpp.ResultType, elem.Type())
+		var ids []string
+		for _, room := range elem.Elements().ChildNamespace("rooms", ultrasoundNamespace).Elements().Children("room") {
+			ids = append(ids, room.Attributes().Get("room_id"))
+		}
+		return ids
+	}
+	require.Equal(t, []string{"2", "3", "4"}, roomIDs())
+	require.Equal(t, []string{"2", "3"}, roomIDs("name", "cardio"))
+	require.Equal(t, []string{"1"}, roomIDs("type", "qc"))
+	require.Equal(t, []string{"3"}, roomIDs("health", "down"))
+	require.Equal(t, []string{"2", "4"}, roomIDs("health", "ok"))
+	require.Nil(t, roomIDs("health", "degraded"))
+	require.Nil(t, roomIDs("name", "obstetrics", "health", "down"))
+
+	for _, attrs := range [][]string{{"type", "icu"}, {"health", "unknown"}} {
+		x.ProcessIQ(context.Background(), newGetIQ(j, roomsRequest(attrs)))
+		elem := stm.ReceiveElement()
+		require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+	}
+}
+
+// roomsRequest returns a rooms element with attrs attributes, and a result set child built from set name/value pairs.
+func roomsRequest(attrs []string, set ...string) *xmpp.Element {
+	rooms := xmpp.NewElementNamespace("rooms", ultrasoundNamespace)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		rooms.SetAttribute(attrs[i], attrs[i+1])
+	}
+	if len(set) > 0 {
+		setElem := xmpp.NewElementNamespace("set", xep0059.RSMNamespace)
+		for i := 0; i+1 < len(set); i += 2 {
+			e := xmpp.NewElementName(set[i])
+			e.SetText(set[i+1])
+			setElem.AppendElement(e)
+		}
+		rooms.AppendElement(setElem)
+	}
+	return rooms
+}
+
+func TestModule_Ultrasound_GetQCStream(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "room01", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room QC", Username: "admin", Type: roomsmodel.QC})
+	_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"room01": "srt://127.0.0.1:39991"}`,
+		Type:   roomsmodel.Camera,
+		RoomID: 1,
+	})
+	_ = roomRep.BindRoom(context.Background(), 1, "room01")
+
+	j, _ := jid.New("room01", "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	x.ProcessIQ(context.Background(), newGetIQ(j, xmpp.NewElementNamespace("qc", ultrasoundNamespace)))
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	qc := elem.Elements().ChildNamespace("qc", ultrasoundNamespace)
+	require.NotNil(t, qc)
+	require.Equal(t, "srt://127.0.0.1:39991", qc.Text())
+
+	// not allowed to review
+	j2, _ := jid.New("room02", "example.org", "desktop", true)
+	stm2 := stream.NewMockC2S(uuid.New().String(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	x.ProcessIQ(context.Background(), newGetIQ(j2, xmpp.NewElementNamespace("qc", ultrasoundNamespace)))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestModule_Ultrasound_Bindings(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room QC", Username: "qc", Type: roomsmodel.QC})
+	for _, username := range []string{"room01", "room02"} {
+		room := roomsmodel.Room{Name: username, Username: username, Type: roomsmodel.Normal}
+		_ = roomRep.UpsertRoom(context.Background(), &room)
+		_ = roomRep.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+			Route:  `{"user": "srt://` + username + `", "admin": "srt://127.0.0.1:9002"}`,
+			Type:   roomsmodel.Camera,
+			RoomID: room.ID,
+		})
+	}
+
+	j1, _ := jid.New("admin", "example.org", "desktop", true)
+	stm1 := stream.NewMockC2S(uuid.New().String(), j1)
+	stm1.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm1)
+
+	j2, _ := jid.New("alice", "example.org", "desktop", true)
+	stm2 := stream.NewMockC2S(uuid.New().String(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	// bind alice to all rooms
+	for _, roomID := range []string{"1", "2", "3"} {
+		binding := xmpp.NewElementNamespace("binding", ultrasoundNamespace)
+		binding.SetAttribute("action", "bind")
+		binding.SetAttribute("room_id", roomID)
+		binding.SetAttribute("username", "alice")
+		x.ProcessIQ(context.Background(), newSetIQ(j1, binding))
+		elem := stm1.ReceiveElement()
+		require.Equal(t, xmpp.ResultType, elem.Type())
+
+		event := requireRoomEvent(t, stm2, "bind")
+		require.Equal(t, roomID, event.Attributes().Get("room_id"))
+	}
+
+	// unknown user
+	binding := xmpp.NewElementNamespace("binding", ultrasoundNamespace)
+	binding.SetAttribute("action", "bind")
+	binding.SetAttribute("room_id", "1")
+	binding.SetAttribute("username", "bob")
+	x.ProcessIQ(context.Background(), newSetIQ(j1, binding))
+	elem := stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// only admins can bind
+	x.ProcessIQ(context.Background(), newSetIQ(j2, binding))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// my rooms
+	x.ProcessIQ(context.Background(), newGetIQ(j2, xmpp.NewElementNamespace("my_rooms", ultrasoundNamespace)))
+	elem = stm2.ReceiveElement()
+	myRooms := elem.Elements().ChildNamespace("my_rooms", ultrasoundNamespace)
+	require.NotNil(t, myRooms)
+	rooms := myRooms.Elements().Children("room")
+	require.Len(t, rooms, 3)
+	require.Equal(t, "qc", rooms[0].Attributes().Get("type"))
+	require.Equal(t, "true", rooms[1].Attributes().Get("active"))
+	require.Equal(t, "", rooms[2].Attributes().Get("active"))
+
+	// default active room
+	x.ProcessIQ(context.Background(), newGetIQ(j2, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem = stm2.ReceiveElement()
+	room := elem.Elements().ChildNamespace("room", ultrasoundNamespace)
+	require.Equal(t, "2", room.Attributes().Get("room_id"))
+	require.Equal(t, "srt://room01", room.Elements().Child("video_stream").Text())
+
+	// switch active room
+	activeRoom := xmpp.NewElementNamespace("active_room", ultrasoundNamespace)
+	activeRoom.SetAttribute("room_id", "3")
+	x.ProcessIQ(context.Background(), newSetIQ(j2, activeRoom))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	x.ProcessIQ(context.Background(), newGetIQ(j2, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem = stm2.ReceiveElement()
+	room = elem.Elements().ChildNamespace("room", ultrasoundNamespace)
+	require.Equal(t, "3", room.Attributes().Get("room_id"))
+	require.Equal(t, "srt://room02", room.Elements().Child("video_stream").Text())
+
+	// qc rooms can't be activated
+	activeRoom.SetAttribute("room_id", "1")
+	x.ProcessIQ(context.Background(), newSetIQ(j2, activeRoom))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	// unbind and try to activate
+	binding.SetAttribute("action", "unbind")
+	binding.SetAttribute("room_id", "3")
+	binding.SetAttribute("username", "alice")
+	x.ProcessIQ(context.Background(), newSetIQ(j1, binding))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	event := requireRoomEvent(t, stm2, "unbind")
+	require.Equal(t, "3", event.Attributes().Get("room_id"))
+
+	activeRoom.SetAttribute("room_id", "3")
+	x.ProcessIQ(context.Background(), newSetIQ(j2, activeRoom))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// active room falls back to a bound one
+	x.ProcessIQ(context.Background(), newGetIQ(j2, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem = stm2.ReceiveElement()
+	room = elem.Elements().ChildNamespace("room", ultrasoundNamespace)
+	require.Equal(t, "2", room.Attributes().Get("room_id"))
+}
+
+func newGetIQ(from *jid.JID, elem xmpp.XElement) *xmpp.IQ {
+	srvJID, _ := jid.New("", "example.org", "", true)
+
+	iq := xmpp.NewIQType(uuid.New().String(), xmpp.GetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(srvJID)
+	iq.AppendElement(elem)
+	return iq
+}
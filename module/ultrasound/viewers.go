@@ -0,0 +1,188 @@
+package ultrasound
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/dantin/cubit/log"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+)
+
+const (
+	joinAction  = "join"
+	leaveAction = "leave"
+)
+
+// Unbound satisfies router.UnbindHandler interface, expiring every room view
+// and QC session claim of a disconnected stream.
+func (x *Ultrasound) Unbound(_ context.Context, j *jid.JID) {
+	x.runQueue.Run(func() {
+		// stream context is cancelled as soon as it gets unbound
+		ctx := context.Background()
+		for roomID, viewers := range x.viewers {
+			if _, ok := viewers[j.String()]; ok {
+				x.leaveRoom(ctx, roomID, j)
+			}
+		}
+		if err := x.expireQCClaims(ctx, j); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
+// DirectedPresence satisfies roster.PresenceHandler interface,
+// joining or leaving a room whenever a presence is directed to its JID.
+func (x *Ultrasound) DirectedPresence(ctx context.Context, presence *xmpp.Presence) {
+	x.runQueue.Run(func() {
+		x.processDirectedPresence(ctx, presence)
+	})
+}
+
+func (x *Ultrasound) processDirectedPresence(ctx context.Context, presence *xmpp.Presence) {
+	toJID := presence.ToJID()
+	if len(toJID.Node()) == 0 || !x.router.Hosts().IsLocalHost(toJID.Domain()) {
+		return
+	}
+	room, err := x.roomRep.FetchRoom(ctx, toJID.Node())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if room == nil || room.Type != roomsmodel.Normal {
+		return
+	}
+	fromJID := presence.FromJID()
+	if presence.IsUnavailable() {
+		x.leaveRoom(ctx, room.ID, fromJID)
+		return
+	}
+	allowed, err := x.canViewRoom(ctx, fromJID.Node(), room.ID)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if allowed {
+		x.joinRoom(ctx, room.ID, fromJID)
+	}
+}
+
+// setViewing joins or leaves a room on behalf of the requesting resource.
+func (x *Ultrasound) setViewing(ctx context.Context, iq *xmpp.IQ, el xmpp.XElement) {
+	action := el.Attributes().Get("action")
+	if action != joinAction && action != leaveAction {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	room := x.fetchTargetRoom(ctx, iq, el)
+	if room == nil {
+		return
+	}
+	fromJID := iq.FromJID()
+	if action == leaveAction {
+		_ = x.router.Route(ctx, iq.ResultIQ())
+		x.leaveRoom(ctx, room.ID, fromJID)
+		return
+	}
+	if room.Type != roomsmodel.Normal {
+		_ = x.router.Route(ctx, iq.NotAcceptableError())
+		return
+	}
+	allowed, err := x.canViewRoom(ctx, fromJID.Node(), room.ID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if !allowed {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	_ = x.router.Route(ctx, iq.ResultIQ())
+	x.joinRoom(ctx, room.ID, fromJID)
+}
+
+// canViewRoom tells whether or not a user is bound to a room or allowed to list every room.
+func (x *Ultrasound) canViewRoom(ctx context.Context, username string, roomID int) (bool, error) {
+	subscribers, err := x.roomRep.FetchBoundUsers(ctx, roomID)
+	if err != nil {
+		return false, err
+	}
+	for _, subscriber := range subscribers {
+		if subscriber == username {
+			return true, nil
+		}
+	}
+	return x.authz.IsAllowed(ctx, username, rolesmodel.RoomsList)
+}
+
+func (x *Ultrasound) joinRoom(ctx context.Context, roomID int, viewerJID *jid.JID) {
+	viewers := x.viewers[roomID]
+	if viewers == nil {
+		viewers = make(map[string]struct{})
+		x.viewers[roomID] = viewers
+	}
+	if _, ok := viewers[viewerJID.String()]; ok {
+		return
+	}
+	viewers[viewerJID.String()] = struct{}{}
+	log.Infof("%s joined room %d", viewerJID.String(), roomID)
+
+	x.notifyViewers(ctx, roomID, joinAction, viewerJID)
+}
+
+func (x *Ultrasound) leaveRoom(ctx context.Context, roomID int, viewerJID *jid.JID) {
+	viewers := x.viewers[roomID]
+	if _, ok := viewers[viewerJID.String()]; !ok {
+		return
+	}
+	delete(viewers, viewerJID.String())
+	if len(viewers) == 0 {
+		delete(x.viewers, roomID)
+	}
+	log.Infof("%s left room %d", viewerJID.String(), roomID)
+
+	x.notifyViewers(ctx, roomID, leaveAction, viewerJID)
+}
+
+// roomViewers returns sorted JIDs of the resources currently viewing a room.
+func (x *Ultrasound) roomViewers(roomID int) []string {
+	var res []string
+	for viewer := range x.viewers[roomID] {
+		res = append(res, viewer)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// setRoomViewers appends room live viewers to roomNode.
+func (x *Ultrasound) setRoomViewers(roomNode *xmpp.Element, roomID int) {
+	viewers := x.roomViewers(roomID)
+
+	viewersNode := xmpp.NewElementName("viewers")
+	viewersNode.SetAttribute("count", strconv.Itoa(len(viewers)))
+	for _, viewer := range viewers {
+		viewerNode := xmpp.NewElementName("viewer")
+		viewerNode.SetAttribute("jid", viewer)
+		viewersNode.AppendElement(viewerNode)
+	}
+	roomNode.AppendElement(viewersNode)
+}
+
+// notifyViewers pushes a room viewers change event to every online administrator watching the global room list.
+func (x *Ultrasound) notifyViewers(ctx context.Context, roomID int, action string, viewerJID *jid.JID) {
+	count := strconv.Itoa(len(x.viewers[roomID]))
+	for _, username := range x.onlineAdmins() {
+		for _, stm := range x.router.LocalStreams(username) {
+			viewersNode := xmpp.NewElementNamespace("viewers", ultrasoundNamespace)
+			viewersNode.SetAttribute("room_id", strconv.Itoa(roomID))
+			viewersNode.SetAttribute("action", action)
+			viewersNode.SetAttribute("jid", viewerJID.String())
+			viewersNode.SetAttribute("count", count)
+			x.routeEvent(ctx, stm.JID(), viewersNode)
+		}
+	}
+}
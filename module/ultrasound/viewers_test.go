@@ -0,0 +1,114 @@
+package ultrasound
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Ultrasound_Viewers(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "admin", Role: model.Admin})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "bob", Role: model.Usr})
+	room := roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal}
+	_ = roomRep.UpsertRoom(context.Background(), &room)
+	_ = roomRep.BindRoom(context.Background(), room.ID, "alice")
+
+	var streams []*stream.MockC2S
+	var jids []*jid.JID
+	for _, fullJID := range []string{"admin@example.org/desktop", "alice@example.org/desktop", "alice@example.org/mobile", "bob@example.org/desktop"} {
+		j, _ := jid.NewWithString(fullJID, true)
+		stm := stream.NewMockC2S(uuid.New().String(), j)
+		stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+		r.Bind(context.Background(), stm)
+		streams = append(streams, stm)
+		jids = append(jids, j)
+	}
+	adminStm, aliceStm, bobStm := streams[0], streams[1], streams[3]
+	adminJID, aliceJID, aliceMobileJID, bobJID := jids[0], jids[1], jids[2], jids[3]
+
+	x := newTestUltrasound(&Config{}, r, testReps{user: userRep, room: roomRep})
+	defer func() { _ = x.Shutdown() }()
+
+	r.RegisterUnbindHandler(x)
+
+	// admin starts watching room list
+	x.ProcessIQ(context.Background(), newGetIQ(adminJID, roomsRequest(nil)))
+	elem := adminStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	viewers := elem.Elements().ChildNamespace("rooms", ultrasoundNamespace).Elements().Child("room").Elements().Child("viewers")
+	require.NotNil(t, viewers)
+	require.Equal(t, "0", viewers.Attributes().Get("count"))
+
+	// join through IQ
+	x.ProcessIQ(context.Background(), newSetIQ(aliceJID, viewingRequest("join", "1")))
+	require.Equal(t, xmpp.ResultType, aliceStm.ReceiveElement().Type())
+	requireViewersEvent(t, adminStm, "join", aliceJID, "1")
+
+	// not bound to room
+	x.ProcessIQ(context.Background(), newSetIQ(bobJID, viewingRequest("join", "1")))
+	requireStanzaError(t, bobStm, xmpp.ErrForbidden)
+
+	x.ProcessIQ(context.Background(), newSetIQ(bobJID, viewingRequest("watch", "1")))
+	requireStanzaError(t, bobStm, xmpp.ErrBadRequest)
+
+	// join through directed presence
+	roomJID, _ := jid.New("room01", "example.org", "", true)
+	x.DirectedPresence(context.Background(), xmpp.NewPresence(aliceMobileJID, roomJID, xmpp.AvailableType))
+	requireViewersEvent(t, adminStm, "join", aliceMobileJID, "2")
+
+	x.DirectedPresence(context.Background(), xmpp.NewPresence(bobJID, roomJID, xmpp.AvailableType))
+
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, xmpp.NewElementNamespace("room", ultrasoundNamespace)))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	viewers = elem.Elements().ChildNamespace("room", ultrasoundNamespace).Elements().Child("viewers")
+	require.NotNil(t, viewers)
+	require.Equal(t, "2", viewers.Attributes().Get("count"))
+	viewerNodes := viewers.Elements().Children("viewer")
+	require.Len(t, viewerNodes, 2)
+	require.Equal(t, aliceJID.String(), viewerNodes[0].Attributes().Get("jid"))
+	require.Equal(t, aliceMobileJID.String(), viewerNodes[1].Attributes().Get("jid"))
+
+	// leave through directed presence
+	x.DirectedPresence(context.Background(), xmpp.NewPresence(aliceMobileJID, roomJID, xmpp.UnavailableType))
+	requireViewersEvent(t, adminStm, "leave", aliceMobileJID, "1")
+
+	// expire on disconnection
+	r.Unbind(context.Background(), aliceJID)
+	requireViewersEvent(t, adminStm, "leave", aliceJID, "0")
+
+	x.ProcessIQ(context.Background(), newGetIQ(adminJID, roomsRequest(nil)))
+	elem = adminStm.ReceiveElement()
+	viewers = elem.Elements().ChildNamespace("rooms", ultrasoundNamespace).Elements().Child("room").Elements().Child("viewers")
+	require.Equal(t, "0", viewers.Attributes().Get("count"))
+}
+
+func requireViewersEvent(t *testing.T, stm *stream.MockC2S, action string, viewerJID *jid.JID, count string) {
+	elem := stm.ReceiveElement()
+	require.Equal(t, "message", elem.Name())
+	event := elem.Elements().ChildNamespace("viewers", ultrasoundNamespace)
+	require.NotNil(t, event)
+	require.Equal(t, action, event.Attributes().Get("action"))
+	require.Equal(t, viewerJID.String(), event.Attributes().Get("jid"))
+	require.Equal(t, count, event.Attributes().Get("count"))
+}
+
+func viewingRequest(action, roomID string) *xmpp.Element {
+	viewing := xmpp.NewElementNamespace("viewing", ultrasoundNamespace)
+	viewing.SetAttribute("action", action)
+	viewing.SetAttribute("room_id", roomID)
+	return viewing
+}
@@ -73,6 +73,16 @@ func (x *DiscoInfo) UnregisterAccountFeature(feature string) {
 	x.srvProvider.unregisterAccountFeature(feature)
 }
 
+// RegisterServerNodeProvider registers a new disco info provider associated to a server domain node.
+func (x *DiscoInfo) RegisterServerNodeProvider(node string, provider InfoProvider) {
+	x.srvProvider.registerNodeProvider(node, provider)
+}
+
+// UnregisterServerNodeProvider unregisters a previously registered server node provider.
+func (x *DiscoInfo) UnregisterServerNodeProvider(node string) {
+	x.srvProvider.unregisterNodeProvider(node)
+}
+
 // RegisterProvider registers a new disco info provider associated to a domain.
 func (x *DiscoInfo) RegisterProvider(domain string, provider InfoProvider) {
 	x.mu.Lock()
@@ -20,10 +20,14 @@ type serverProvider struct {
 	serverItems     []Item
 	serverFeatures  []Feature
 	accountFeatures []Feature
+	nodeProviders   map[string]InfoProvider
 }
 
-func (sp *serverProvider) Identities(_ context.Context, toJID, _ *jid.JID, node string) []Identity {
+func (sp *serverProvider) Identities(ctx context.Context, toJID, fromJID *jid.JID, node string) []Identity {
 	if node != "" {
+		if prov := sp.nodeProvider(toJID, node); prov != nil {
+			return prov.Identities(ctx, toJID, fromJID, node)
+		}
 		return nil
 	}
 	if toJID.IsServer() {
@@ -34,6 +38,9 @@ func (sp *serverProvider) Identities(_ context.Context, toJID, _ *jid.JID, node
 
 func (sp *serverProvider) Items(ctx context.Context, toJID, fromJID *jid.JID, node string) ([]Item, *xmpp.StanzaError) {
 	if node != "" {
+		if prov := sp.nodeProvider(toJID, node); prov != nil {
+			return prov.Items(ctx, toJID, fromJID, node)
+		}
 		return nil, nil
 	}
 	var items []Item
@@ -55,12 +62,15 @@ func (sp *serverProvider) Items(ctx context.Context, toJID, fromJID *jid.JID, no
 }
 
 func (sp *serverProvider) Features(ctx context.Context, toJID, fromJID *jid.JID, node string) ([]Feature, *xmpp.StanzaError) {
-	sp.mu.RLock()
-	defer sp.mu.RUnlock()
-
 	if node != "" {
+		if prov := sp.nodeProvider(toJID, node); prov != nil {
+			return prov.Features(ctx, toJID, fromJID, node)
+		}
 		return nil, nil
 	}
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+
 	if toJID.IsServer() {
 		return sp.serverFeatures, nil
 	}
@@ -70,7 +80,12 @@ func (sp *serverProvider) Features(ctx context.Context, toJID, fromJID *jid.JID,
 	return nil, xmpp.ErrSubscriptionRequired
 }
 
-func (sp *serverProvider) Form(_ context.Context, _, _ *jid.JID, _ string) (*xep0004.DataForm, *xmpp.StanzaError) {
+func (sp *serverProvider) Form(ctx context.Context, toJID, fromJID *jid.JID, node string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	if node != "" {
+		if prov := sp.nodeProvider(toJID, node); prov != nil {
+			return prov.Form(ctx, toJID, fromJID, node)
+		}
+	}
 	return nil, nil
 }
 
@@ -146,6 +161,33 @@ func (sp *serverProvider) unregisterAccountFeature(feature Feature) {
 	}
 }
 
+func (sp *serverProvider) registerNodeProvider(node string, provider InfoProvider) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.nodeProviders == nil {
+		sp.nodeProviders = make(map[string]InfoProvider)
+	}
+	sp.nodeProviders[node] = provider
+}
+
+func (sp *serverProvider) unregisterNodeProvider(node string) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	delete(sp.nodeProviders, node)
+}
+
+func (sp *serverProvider) nodeProvider(toJID *jid.JID, node string) InfoProvider {
+	if !toJID.IsServer() {
+		return nil
+	}
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+
+	return sp.nodeProviders[node]
+}
+
 func (sp *serverProvider) isSubscribedTo(ctx context.Context, contact *jid.JID, userJID *jid.JID) bool {
 	if contact.MatchesWithOptions(userJID, jid.MatchesBare) {
 		return true
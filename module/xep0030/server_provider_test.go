@@ -115,3 +115,30 @@ func TestModule_XEP0030_ServerProvider_Items(t *testing.T) {
 	})
 	require.Nil(t, sErr)
 }
+
+func TestModule_XEP0030_ServerProvider_NodeProvider(t *testing.T) {
+	r, rosterRep := setupTest("example.org")
+
+	var sp = serverProvider{router: r, rosterRep: rosterRep}
+
+	srvJID, _ := jid.New("", "example.org", "", true)
+	accJID, _ := jid.New("user", "example.org", "desktop", true)
+
+	sp.registerNodeProvider("node", &testDiscoInfoProvider{})
+
+	require.Equal(t, []Identity{{Name: "test_identity"}}, sp.Identities(context.Background(), srvJID, accJID, "node"))
+	features, sErr := sp.Features(context.Background(), srvJID, accJID, "node")
+	require.Nil(t, sErr)
+	require.Equal(t, []Feature{"com.example.org.feature"}, features)
+	items, sErr := sp.Items(context.Background(), srvJID, accJID, "node")
+	require.Nil(t, sErr)
+	require.Equal(t, []Item{{Jid: "test.example.org"}}, items)
+
+	// only server domain nodes are provided
+	features, sErr = sp.Features(context.Background(), accJID.ToBareJID(), accJID, "node")
+	require.Nil(t, sErr)
+	require.Nil(t, features)
+
+	sp.unregisterNodeProvider("node")
+	require.Nil(t, sp.Identities(context.Background(), srvJID, accJID, "node"))
+}
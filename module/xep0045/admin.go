@@ -0,0 +1,314 @@
+package xep0045
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/dantin/cubit/log"
+	mucmodel "github.com/dantin/cubit/model/muc"
+	"github.com/dantin/cubit/module/xep0004"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+)
+
+const roomConfigFormType = "http://jabber.org/protocol/muc#roomconfig"
+
+const (
+	roomNameField    = "muc#roomconfig_roomname"
+	membersOnlyField = "muc#roomconfig_membersonly"
+)
+
+// processAdminIQ handles room affiliation and role lists retrieval and modification.
+func (x *MUC) processAdminIQ(ctx context.Context, iq *xmpp.IQ, q xmpp.XElement) {
+	r, actorAff, ok := x.fetchIQRoom(ctx, iq)
+	if !ok {
+		return
+	}
+	items := q.Elements().Children("item")
+	if len(items) == 0 {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if iq.IsGet() {
+		x.sendAdminItems(ctx, iq, r, actorAff, items[0])
+		return
+	}
+	for _, item := range items {
+		var sErr *xmpp.StanzaError
+		attrs := item.Attributes()
+		switch {
+		case len(attrs.Get("affiliation")) > 0:
+			sErr = x.setAffiliation(ctx, r, actorAff, item)
+		case len(attrs.Get("role")) > 0:
+			sErr = x.setRole(ctx, r, iq.FromJID(), actorAff, item)
+		default:
+			sErr = xmpp.ErrBadRequest
+		}
+		if sErr != nil {
+			_ = x.router.Route(ctx, xmpp.NewErrorStanzaFromStanza(iq, sErr, nil))
+			return
+		}
+	}
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+func (x *MUC) sendAdminItems(ctx context.Context, iq *xmpp.IQ, r *room, actorAff mucmodel.AffiliationType, item xmpp.XElement) {
+	query := xmpp.NewElementNamespace("query", mucAdminNamespace)
+	attrs := item.Attributes()
+	if role := attrs.Get("role"); len(role) > 0 {
+		if actor := r.occupantByJID(iq.FromJID()); actorAff < mucmodel.Admin && (actor == nil || actor.role != moderatorRole) {
+			_ = x.router.Route(ctx, iq.ForbiddenError())
+			return
+		}
+		for _, occ := range r.occupants {
+			if occ.role != role {
+				continue
+			}
+			_, itemEl := userElement(occ)
+			itemEl.SetAttribute("nick", occ.nick)
+			query.AppendElement(itemEl)
+		}
+	} else {
+		aff, ok := mucmodel.ParseAffiliationString(attrs.Get("affiliation"))
+		if !ok || aff == mucmodel.None {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+			return
+		}
+		if actorAff < mucmodel.Admin {
+			_ = x.router.Route(ctx, iq.ForbiddenError())
+			return
+		}
+		affiliations, err := x.mucRep.FetchMUCAffiliations(ctx, r.model.Name)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		for _, a := range affiliations {
+			if a.Affiliation != aff {
+				continue
+			}
+			itemEl := xmpp.NewElementName("item")
+			itemEl.SetAttribute("affiliation", a.Affiliation.String())
+			itemEl.SetAttribute("jid", a.JID)
+			query.AppendElement(itemEl)
+		}
+	}
+	result := iq.ResultIQ()
+	result.AppendElement(query)
+	_ = x.router.Route(ctx, result)
+}
+
+// setAffiliation changes a user affiliation. Owners may grant any affiliation,
+// while admins are only allowed to manage members and outcasts.
+func (x *MUC) setAffiliation(ctx context.Context, r *room, actorAff mucmodel.AffiliationType, item xmpp.XElement) *xmpp.StanzaError {
+	attrs := item.Attributes()
+	aff, ok := mucmodel.ParseAffiliationString(attrs.Get("affiliation"))
+	if !ok {
+		return xmpp.ErrBadRequest
+	}
+	userJID, err := jid.NewWithString(attrs.Get("jid"), false)
+	if err != nil {
+		return xmpp.ErrJidMalformed
+	}
+	userJID = userJID.ToBareJID()
+
+	currentAff, err := x.affiliation(ctx, r, userJID)
+	if err != nil {
+		log.Error(err)
+		return xmpp.ErrInternalServerError
+	}
+	if actorAff < mucmodel.Admin || (actorAff == mucmodel.Admin && (currentAff >= mucmodel.Admin || aff >= mucmodel.Admin)) {
+		return xmpp.ErrForbidden
+	}
+	if aff == mucmodel.None {
+		err = x.mucRep.DeleteMUCAffiliation(ctx, r.model.Name, userJID.String())
+	} else {
+		err = x.mucRep.UpsertMUCAffiliation(ctx, &mucmodel.Affiliation{RoomName: r.model.Name, JID: userJID.String(), Affiliation: aff})
+	}
+	if err != nil {
+		log.Error(err)
+		return xmpp.ErrInternalServerError
+	}
+	log.Infof("%s affiliation within room %s set to %s", userJID.String(), r.jid.String(), aff.String())
+
+	// effective affiliation may differ on linked rooms
+	if aff, err = x.affiliation(ctx, r, userJID); err != nil {
+		log.Error(err)
+		return xmpp.ErrInternalServerError
+	}
+	x.applyAffiliation(ctx, r, userJID, aff)
+	return nil
+}
+
+// applyAffiliation updates every occupant session of a user after an affiliation change,
+// removing them from room whenever they're no longer allowed to stay.
+func (x *MUC) applyAffiliation(ctx context.Context, r *room, userJID *jid.JID, aff mucmodel.AffiliationType) {
+	for _, occ := range r.occupantsByBareJID(userJID) {
+		occ.affiliation = aff
+		switch {
+		case aff == mucmodel.Outcast:
+			x.removeOccupant(ctx, r, occ, nil, bannedStatus)
+		case r.model.MembersOnly && aff < mucmodel.Member:
+			x.removeOccupant(ctx, r, occ, nil, affiliationStatus)
+		default:
+			occ.role = roleFor(aff)
+			x.broadcastPresence(ctx, r, occ)
+		}
+	}
+}
+
+// setRole changes an occupant role. Moderators are allowed to kick participants,
+// while granting or revoking moderation is reserved to admins.
+func (x *MUC) setRole(ctx context.Context, r *room, actorJID *jid.JID, actorAff mucmodel.AffiliationType, item xmpp.XElement) *xmpp.StanzaError {
+	attrs := item.Attributes()
+	target := r.occupantByNick(attrs.Get("nick"))
+	if target == nil {
+		return xmpp.ErrItemNotFound
+	}
+	actor := r.occupantByJID(actorJID)
+	isModerator := actorAff >= mucmodel.Admin || (actor != nil && actor.role == moderatorRole)
+	if !isModerator {
+		return xmpp.ErrForbidden
+	}
+	if target.affiliation >= mucmodel.Admin {
+		return xmpp.ErrNotAllowed
+	}
+	switch role := attrs.Get("role"); role {
+	case noneRole:
+		x.removeOccupant(ctx, r, target, nil, kickedStatus)
+		log.Infof("%s kicked from room %s", target.jid.String(), r.jid.String())
+
+	case participantRole, moderatorRole:
+		if actorAff < mucmodel.Admin {
+			return xmpp.ErrForbidden
+		}
+		target.role = role
+		x.broadcastPresence(ctx, r, target)
+
+	default:
+		return xmpp.ErrBadRequest
+	}
+	return nil
+}
+
+// processOwnerIQ handles room configuration and destruction requests.
+func (x *MUC) processOwnerIQ(ctx context.Context, iq *xmpp.IQ, q xmpp.XElement) {
+	r, actorAff, ok := x.fetchIQRoom(ctx, iq)
+	if !ok {
+		return
+	}
+	if actorAff != mucmodel.Owner {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	if iq.IsGet() {
+		result := iq.ResultIQ()
+		query := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+		query.AppendElement(roomConfigForm(&r.model).Element())
+		result.AppendElement(query)
+		_ = x.router.Route(ctx, result)
+		return
+	}
+	if destroy := q.Elements().Child("destroy"); destroy != nil {
+		if r.model.IsLinked() {
+			_ = x.router.Route(ctx, iq.NotAllowedError())
+			return
+		}
+		var reason string
+		if reasonEl := destroy.Elements().Child("reason"); reasonEl != nil {
+			reason = reasonEl.Text()
+		}
+		if err := x.destroyRoom(ctx, r, reason); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		_ = x.router.Route(ctx, iq.ResultIQ())
+		return
+	}
+	formEl := q.Elements().ChildNamespace("x", xep0004.FormNamespace)
+	if formEl == nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	form, err := xep0004.NewFormFromElement(formEl)
+	if err != nil || (form.Type != xep0004.Submit && form.Type != xep0004.Cancel) {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if form.Type == xep0004.Submit {
+		if sErr := x.configureRoom(ctx, r, form.Fields); sErr != nil {
+			_ = x.router.Route(ctx, xmpp.NewErrorStanzaFromStanza(iq, sErr, nil))
+			return
+		}
+	}
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+func (x *MUC) configureRoom(ctx context.Context, r *room, fields xep0004.Fields) *xmpp.StanzaError {
+	m := r.model
+	if title := fields.ValueForField(roomNameField); len(title) > 0 {
+		m.Title = title
+	}
+	if v := fields.ValueForField(membersOnlyField); len(v) > 0 {
+		m.MembersOnly, _ = strconv.ParseBool(v)
+		if r.model.IsLinked() && !m.MembersOnly {
+			return xmpp.ErrNotAllowed
+		}
+	}
+	if err := x.mucRep.UpsertMUCRoom(ctx, &m); err != nil {
+		log.Error(err)
+		return xmpp.ErrInternalServerError
+	}
+	wasMembersOnly := r.model.MembersOnly
+	r.model = m
+	log.Infof("room %s configured", r.jid.String())
+
+	if !wasMembersOnly && m.MembersOnly {
+		for _, occ := range append([]*occupant(nil), r.occupants...) {
+			if occ.affiliation < mucmodel.Member {
+				x.removeOccupant(ctx, r, occ, nil, membersOnlyStatus)
+			}
+		}
+	}
+	return nil
+}
+
+// fetchIQRoom returns IQ target room along with sender affiliation,
+// replying with an error whenever room doesn't exist.
+func (x *MUC) fetchIQRoom(ctx context.Context, iq *xmpp.IQ) (*room, mucmodel.AffiliationType, bool) {
+	r, err := x.fetchRoom(ctx, iq.ToJID().ToBareJID())
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return nil, mucmodel.None, false
+	}
+	if r == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return nil, mucmodel.None, false
+	}
+	aff, err := x.affiliation(ctx, r, iq.FromJID())
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return nil, mucmodel.None, false
+	}
+	return r, aff, true
+}
+
+func roomConfigForm(m *mucmodel.Room) *xep0004.DataForm {
+	membersOnly := "0"
+	if m.MembersOnly {
+		membersOnly = "1"
+	}
+	return &xep0004.DataForm{
+		Type:  xep0004.Form,
+		Title: "Room configuration",
+		Fields: xep0004.Fields{
+			{Var: "FORM_TYPE", Type: xep0004.Hidden, Values: []string{roomConfigFormType}},
+			{Var: roomNameField, Type: xep0004.TextSingle, Label: "Room name", Values: []string{m.Title}},
+			{Var: membersOnlyField, Type: xep0004.Boolean, Label: "Make room members-only", Values: []string{membersOnly}},
+		},
+	}
+}
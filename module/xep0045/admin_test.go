@@ -0,0 +1,206 @@
+package xep0045
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/module/xep0004"
+	"github.com/dantin/cubit/router"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_MUC_SetAffiliation(t *testing.T) {
+	r := setupTest()
+	x := New(&Config{AllowCreate: true}, nil, r, memorystorage.NewUser(), memorystorage.NewRoom(), memorystorage.NewMUC(), memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+	bobJID, bobStm := bindUser(r, "bob")
+	enterRoom(t, r, aliceJID, aliceStm, "chat@conference.example.org/alice", 2)
+	enterRoom(t, r, bobJID, bobStm, "chat@conference.example.org/bob", 3)
+	_ = aliceStm.ReceiveElement()
+
+	// participants can't manage affiliations
+	_ = r.Route(context.Background(), adminIQ(bobJID, "chat@conference.example.org", xmpp.SetType, affiliationItem("alice@example.org", "outcast")))
+	elem := bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	_ = r.Route(context.Background(), adminIQ(aliceJID, "chat@conference.example.org", xmpp.SetType, affiliationItem("bob@example.org", "admin")))
+	elem = bobStm.ReceiveElement()
+	requireUserItem(t, elem, "admin", moderatorRole)
+	elem = aliceStm.ReceiveElement()
+	requireUserItem(t, elem, "admin", moderatorRole)
+	require.Equal(t, xmpp.ResultType, aliceStm.ReceiveElement().Type())
+
+	_ = r.Route(context.Background(), adminIQ(aliceJID, "chat@conference.example.org", xmpp.GetType, affiliationItem("", "admin")))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	items := elem.Elements().ChildNamespace("query", mucAdminNamespace).Elements().Children("item")
+	require.Len(t, items, 1)
+	require.Equal(t, "bob@example.org", items[0].Attributes().Get("jid"))
+
+	// admins can't ban owners
+	_ = r.Route(context.Background(), adminIQ(bobJID, "chat@conference.example.org", xmpp.SetType, affiliationItem("alice@example.org", "outcast")))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	_ = r.Route(context.Background(), adminIQ(aliceJID, "chat@conference.example.org", xmpp.SetType, affiliationItem("bob@example.org", "outcast")))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{bannedStatus, selfPresenceStatus}, statusCodes(elem))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, []string{bannedStatus}, statusCodes(elem))
+	require.Equal(t, xmpp.ResultType, aliceStm.ReceiveElement().Type())
+
+	_ = r.Route(context.Background(), enterPresence(bobJID, "chat@conference.example.org/bob"))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestModule_MUC_Kick(t *testing.T) {
+	r := setupTest()
+	x := New(&Config{AllowCreate: true}, nil, r, memorystorage.NewUser(), memorystorage.NewRoom(), memorystorage.NewMUC(), memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+	bobJID, bobStm := bindUser(r, "bob")
+	enterRoom(t, r, aliceJID, aliceStm, "chat@conference.example.org/alice", 2)
+	enterRoom(t, r, bobJID, bobStm, "chat@conference.example.org/bob", 3)
+	_ = aliceStm.ReceiveElement()
+
+	_ = r.Route(context.Background(), adminIQ(bobJID, "chat@conference.example.org", xmpp.SetType, roleItem("alice", noneRole)))
+	elem := bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	_ = r.Route(context.Background(), adminIQ(aliceJID, "chat@conference.example.org", xmpp.GetType, roleItem("", participantRole)))
+	elem = aliceStm.ReceiveElement()
+	items := elem.Elements().ChildNamespace("query", mucAdminNamespace).Elements().Children("item")
+	require.Len(t, items, 1)
+	require.Equal(t, "bob", items[0].Attributes().Get("nick"))
+
+	_ = r.Route(context.Background(), adminIQ(aliceJID, "chat@conference.example.org", xmpp.SetType, roleItem("bob", noneRole)))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{kickedStatus, selfPresenceStatus}, statusCodes(elem))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, []string{kickedStatus}, statusCodes(elem))
+	require.Equal(t, xmpp.ResultType, aliceStm.ReceiveElement().Type())
+
+	_ = r.Route(context.Background(), adminIQ(aliceJID, "chat@conference.example.org", xmpp.SetType, roleItem("bob", noneRole)))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestModule_MUC_ConfigureRoom(t *testing.T) {
+	r := setupTest()
+	mucRep := memorystorage.NewMUC()
+	x := New(&Config{AllowCreate: true}, nil, r, memorystorage.NewUser(), memorystorage.NewRoom(), mucRep, memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+	bobJID, bobStm := bindUser(r, "bob")
+	enterRoom(t, r, aliceJID, aliceStm, "chat@conference.example.org/alice", 2)
+	enterRoom(t, r, bobJID, bobStm, "chat@conference.example.org/bob", 3)
+	_ = aliceStm.ReceiveElement()
+
+	_ = r.Route(context.Background(), ownerIQ(bobJID, "chat@conference.example.org", xmpp.GetType, nil))
+	elem := bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	_ = r.Route(context.Background(), ownerIQ(aliceJID, "chat@conference.example.org", xmpp.GetType, nil))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	formEl := elem.Elements().ChildNamespace("query", mucOwnerNamespace).Elements().ChildNamespace("x", xep0004.FormNamespace)
+	form, err := xep0004.NewFormFromElement(formEl)
+	require.Nil(t, err)
+	require.Equal(t, "chat", form.Fields.ValueForFieldOfType(roomNameField, xep0004.TextSingle))
+	require.Equal(t, "0", form.Fields.ValueForFieldOfType(membersOnlyField, xep0004.Boolean))
+
+	submit := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: "FORM_TYPE", Values: []string{roomConfigFormType}},
+			{Var: roomNameField, Values: []string{"Chat"}},
+			{Var: membersOnlyField, Values: []string{"1"}},
+		},
+	}
+	_ = r.Route(context.Background(), ownerIQ(aliceJID, "chat@conference.example.org", xmpp.SetType, submit.Element()))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{membersOnlyStatus, selfPresenceStatus}, statusCodes(elem))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, []string{membersOnlyStatus}, statusCodes(elem))
+	require.Equal(t, xmpp.ResultType, aliceStm.ReceiveElement().Type())
+
+	m, _ := mucRep.FetchMUCRoom(context.Background(), "chat")
+	require.Equal(t, "Chat", m.Title)
+	require.True(t, m.MembersOnly)
+
+	destroy := xmpp.NewElementName("destroy")
+	reason := xmpp.NewElementName("reason")
+	reason.SetText("bye")
+	destroy.AppendElement(reason)
+	_ = r.Route(context.Background(), ownerIQ(aliceJID, "chat@conference.example.org", xmpp.SetType, destroy))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	destroyEl := elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("destroy")
+	require.NotNil(t, destroyEl)
+	require.Equal(t, "bye", destroyEl.Elements().Child("reason").Text())
+	require.Equal(t, xmpp.ResultType, aliceStm.ReceiveElement().Type())
+
+	m, _ = mucRep.FetchMUCRoom(context.Background(), "chat")
+	require.Nil(t, m)
+}
+
+// enterRoom makes a user enter a room, discarding the expected number of received stanzas.
+func enterRoom(t *testing.T, r router.Router, from *jid.JID, stm *stream.MockC2S, to string, count int) {
+	_ = r.Route(context.Background(), enterPresence(from, to))
+	for i := 0; i < count; i++ {
+		require.NotEqual(t, xmpp.ErrorType, stm.ReceiveElement().Type())
+	}
+}
+
+func adminIQ(from *jid.JID, to string, iqType string, item xmpp.XElement) *xmpp.IQ {
+	iq := xmpp.NewIQType(uuid.New().String(), iqType)
+	iq.SetFromJID(from)
+	iq.SetToJID(roomJID(to))
+	q := xmpp.NewElementNamespace("query", mucAdminNamespace)
+	q.AppendElement(item)
+	iq.AppendElement(q)
+	return iq
+}
+
+func ownerIQ(from *jid.JID, to string, iqType string, child xmpp.XElement) *xmpp.IQ {
+	iq := xmpp.NewIQType(uuid.New().String(), iqType)
+	iq.SetFromJID(from)
+	iq.SetToJID(roomJID(to))
+	q := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+	if child != nil {
+		q.AppendElement(child)
+	}
+	iq.AppendElement(q)
+	return iq
+}
+
+func affiliationItem(j string, aff string) xmpp.XElement {
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("affiliation", aff)
+	if len(j) > 0 {
+		item.SetAttribute("jid", j)
+	}
+	return item
+}
+
+func roleItem(nick string, role string) xmpp.XElement {
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("role", role)
+	if len(nick) > 0 {
+		item.SetAttribute("nick", nick)
+	}
+	return item
+}
@@ -0,0 +1,55 @@
+package xep0045
+
+import "fmt"
+
+const (
+	defaultService     = "conference"
+	defaultHistorySize = 20
+)
+
+// Config represents Multi-User Chat module (XEP-0045) configuration.
+type Config struct {
+	// Service is the subdomain label chat service is reachable through, on every local domain.
+	Service string
+
+	// HistorySize is the maximum number of history messages sent to occupants on room entrance.
+	HistorySize int
+
+	// AllowCreate allows local users to create rooms not linked to ultrasound rooms.
+	AllowCreate bool
+}
+
+type configProxy struct {
+	Service     string `yaml:"service"`
+	HistorySize int    `yaml:"history_size"`
+	AllowCreate bool   `yaml:"allow_create"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if p.HistorySize < 0 {
+		return fmt.Errorf("xep0045.Config: history size must be 0 or higher")
+	}
+	cfg.Service = p.Service
+	cfg.HistorySize = p.HistorySize
+	cfg.AllowCreate = p.AllowCreate
+	return nil
+}
+
+func (cfg *Config) service() string {
+	if len(cfg.Service) == 0 {
+		return defaultService
+	}
+	return cfg.Service
+}
+
+func (cfg *Config) historySize() int {
+	if cfg.HistorySize == 0 {
+		return defaultHistorySize
+	}
+	return cfg.HistorySize
+}
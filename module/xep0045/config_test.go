@@ -0,0 +1,27 @@
+package xep0045
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestModule_MUC_Config(t *testing.T) {
+	cfg := &Config{}
+	err := yaml.Unmarshal([]byte(`history_size: -1`), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`allow_create: true`), &cfg)
+	require.Nil(t, err)
+	require.True(t, cfg.AllowCreate)
+	require.Equal(t, defaultService, cfg.service())
+	require.Equal(t, defaultHistorySize, cfg.historySize())
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("service: muc\nhistory_size: 5"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "muc", cfg.service())
+	require.Equal(t, 5, cfg.historySize())
+}
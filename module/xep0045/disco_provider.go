@@ -0,0 +1,109 @@
+package xep0045
+
+import (
+	"context"
+
+	"github.com/dantin/cubit/log"
+	mucmodel "github.com/dantin/cubit/model/muc"
+	"github.com/dantin/cubit/module/xep0004"
+	"github.com/dantin/cubit/module/xep0030"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+)
+
+const (
+	mucPersistentFeature   = "muc_persistent"
+	mucMembersOnlyFeature  = "muc_membersonly"
+	mucOpenFeature         = "muc_open"
+	mucNonAnonymousFeature = "muc_nonanonymous"
+	mucUnmoderatedFeature  = "muc_unmoderated"
+	conferenceCategory     = "conference"
+	conferenceIdentityType = "text"
+	conferenceServiceName  = "Chatrooms"
+)
+
+// discoInfoProvider exposes chat service and rooms through service discovery.
+// Since it runs outside of module run queue, only storage is consulted.
+type discoInfoProvider struct {
+	muc *MUC
+}
+
+func (p *discoInfoProvider) Identities(ctx context.Context, toJID, _ *jid.JID, _ string) []xep0030.Identity {
+	name := conferenceServiceName
+	if len(toJID.Node()) > 0 {
+		m, err := p.muc.mucRep.FetchMUCRoom(ctx, toJID.Node())
+		if err != nil {
+			log.Error(err)
+			return nil
+		}
+		if m == nil {
+			return nil
+		}
+		name = m.Title
+	}
+	return []xep0030.Identity{{Category: conferenceCategory, Type: conferenceIdentityType, Name: name}}
+}
+
+func (p *discoInfoProvider) Features(ctx context.Context, toJID, _ *jid.JID, _ string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	if len(toJID.Node()) == 0 {
+		return []xep0030.Feature{mucNamespace}, nil
+	}
+	m, err := p.muc.mucRep.FetchMUCRoom(ctx, toJID.Node())
+	if err != nil {
+		log.Error(err)
+		return nil, xmpp.ErrInternalServerError
+	}
+	if m == nil {
+		return nil, nil
+	}
+	access := mucOpenFeature
+	if m.MembersOnly {
+		access = mucMembersOnlyFeature
+	}
+	return []xep0030.Feature{mucNamespace, mucPersistentFeature, access, mucNonAnonymousFeature, mucUnmoderatedFeature}, nil
+}
+
+func (p *discoInfoProvider) Form(_ context.Context, _, _ *jid.JID, _ string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	return nil, nil
+}
+
+// Items lists every open room, along with those members-only rooms requester is affiliated to.
+func (p *discoInfoProvider) Items(ctx context.Context, toJID, fromJID *jid.JID, _ string) ([]xep0030.Item, *xmpp.StanzaError) {
+	if len(toJID.Node()) > 0 {
+		return nil, nil // occupants are not disclosed
+	}
+	rooms, err := p.muc.mucRep.FetchMUCRooms(ctx)
+	if err != nil {
+		log.Error(err)
+		return nil, xmpp.ErrInternalServerError
+	}
+	var items []xep0030.Item
+	for _, m := range rooms {
+		if m.MembersOnly {
+			ok, err := p.isAffiliated(ctx, m.Name, fromJID)
+			if err != nil {
+				log.Error(err)
+				return nil, xmpp.ErrInternalServerError
+			}
+			if !ok {
+				continue
+			}
+		}
+		items = append(items, xep0030.Item{Jid: m.Name + "@" + toJID.Domain(), Name: m.Title})
+	}
+	return items, nil
+}
+
+func (p *discoInfoProvider) isAffiliated(ctx context.Context, roomName string, userJID *jid.JID) (bool, error) {
+	affiliations, err := p.muc.mucRep.FetchMUCAffiliations(ctx, roomName)
+	if err != nil {
+		return false, err
+	}
+	bareJID := userJID.ToBareJID().String()
+	for _, a := range affiliations {
+		if a.JID == bareJID {
+			return a.Affiliation >= mucmodel.Member, nil
+		}
+	}
+	return false, nil
+}
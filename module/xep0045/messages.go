@@ -0,0 +1,102 @@
+package xep0045
+
+import (
+	"context"
+	"time"
+
+	"github.com/dantin/cubit/log"
+	mucmodel "github.com/dantin/cubit/model/muc"
+	"github.com/dantin/cubit/xmpp"
+)
+
+func (x *MUC) processMessage(ctx context.Context, message *xmpp.Message) {
+	toJID := message.ToJID()
+	if len(toJID.Node()) == 0 {
+		_ = x.router.Route(ctx, message.ServiceUnavailableError())
+		return
+	}
+	r := x.rooms[toJID.ToBareJID().String()]
+	if r == nil {
+		_ = x.router.Route(ctx, message.ItemNotFoundError())
+		return
+	}
+	occ := r.occupantByJID(message.FromJID())
+	if occ == nil {
+		_ = x.router.Route(ctx, message.NotAcceptableError())
+		return
+	}
+	if toJID.IsFull() {
+		x.sendPrivateMessage(ctx, r, occ, message)
+		return
+	}
+	if !message.IsGroupChat() {
+		_ = x.router.Route(ctx, message.BadRequestError())
+		return
+	}
+	if subject := message.Elements().Child("subject"); subject != nil {
+		x.setSubject(ctx, r, occ, message, subject.Text())
+		return
+	}
+	x.sendGroupChatMessage(ctx, r, occ, message)
+}
+
+// sendGroupChatMessage reflects an occupant message to every room occupant, appending it to room history.
+func (x *MUC) sendGroupChatMessage(ctx context.Context, r *room, occ *occupant, message *xmpp.Message) {
+	if occ.role != moderatorRole && occ.role != participantRole {
+		_ = x.router.Route(ctx, message.ForbiddenError())
+		return
+	}
+	if body := message.Elements().Child("body"); body != nil {
+		m := &mucmodel.Message{
+			RoomName: r.model.Name,
+			Nick:     occ.nick,
+			JID:      occ.jid.ToBareJID().String(),
+			Body:     body.Text(),
+			Stamp:    time.Now(),
+		}
+		if err := x.mucRep.InsertMUCMessage(ctx, m); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, message.InternalServerError())
+			return
+		}
+	}
+	for _, o := range r.occupants {
+		msg, _ := xmpp.NewMessageFromElement(message, r.occupantJID(occ.nick), o.jid)
+		_ = x.router.Route(ctx, msg)
+	}
+}
+
+// setSubject changes room subject on behalf of a moderator.
+func (x *MUC) setSubject(ctx context.Context, r *room, occ *occupant, message *xmpp.Message, subject string) {
+	if occ.role != moderatorRole {
+		_ = x.router.Route(ctx, message.ForbiddenError())
+		return
+	}
+	r.model.Subject = subject
+	if err := x.mucRep.UpsertMUCRoom(ctx, &r.model); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, message.InternalServerError())
+		return
+	}
+	for _, o := range r.occupants {
+		msg, _ := xmpp.NewMessageFromElement(message, r.occupantJID(occ.nick), o.jid)
+		_ = x.router.Route(ctx, msg)
+	}
+	log.Infof("room %s subject changed by %s", r.jid.String(), occ.jid.String())
+}
+
+// sendPrivateMessage relays a message between two room occupants.
+func (x *MUC) sendPrivateMessage(ctx context.Context, r *room, occ *occupant, message *xmpp.Message) {
+	if message.IsGroupChat() {
+		_ = x.router.Route(ctx, message.BadRequestError())
+		return
+	}
+	target := r.occupantByNick(message.ToJID().Resource())
+	if target == nil {
+		_ = x.router.Route(ctx, message.ItemNotFoundError())
+		return
+	}
+	msg, _ := xmpp.NewMessageFromElement(message, r.occupantJID(occ.nick), target.jid)
+	msg.AppendElement(xmpp.NewElementNamespace("x", mucUserNamespace))
+	_ = x.router.Route(ctx, msg)
+}
@@ -0,0 +1,171 @@
+package xep0045
+
+import (
+	"context"
+
+	"github.com/dantin/cubit/authz"
+	mucmodel "github.com/dantin/cubit/model/muc"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	"github.com/dantin/cubit/module/xep0030"
+	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/storage/repository"
+	"github.com/dantin/cubit/util/runqueue"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+)
+
+const (
+	mucNamespace      = "http://jabber.org/protocol/muc"
+	mucUserNamespace  = "http://jabber.org/protocol/muc#user"
+	mucAdminNamespace = "http://jabber.org/protocol/muc#admin"
+	mucOwnerNamespace = "http://jabber.org/protocol/muc#owner"
+)
+
+// MUC represents a Multi-User Chat service module.
+type MUC struct {
+	cfg      *Config
+	router   router.Router
+	disco    *xep0030.DiscoInfo
+	authz    *authz.Authorizer
+	roomRep  repository.Room
+	mucRep   repository.MUC
+	runQueue *runqueue.RunQueue
+	rooms    map[string]*room // active rooms, by bare room JID
+}
+
+// New returns a Multi-User Chat service module, reachable through a service subdomain of every local domain.
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, roomRep repository.Room, mucRep repository.MUC, roleRep repository.Role) *MUC {
+	x := &MUC{
+		cfg:      config,
+		router:   router,
+		disco:    disco,
+		authz:    authz.New(userRep, roleRep),
+		roomRep:  roomRep,
+		mucRep:   mucRep,
+		runQueue: runqueue.New("xep0045"),
+		rooms:    make(map[string]*room),
+	}
+	for _, host := range router.Hosts().HostName() {
+		service := config.service() + "." + host
+		router.RegisterComponent(service, x)
+		if disco != nil {
+			disco.RegisterProvider(service, &discoInfoProvider{muc: x})
+			disco.RegisterServerItem(xep0030.Item{Jid: service, Name: conferenceServiceName})
+		}
+	}
+	return x
+}
+
+// ProcessStanza processes a stanza addressed to chat service, taking according actions over the involved rooms.
+func (x *MUC) ProcessStanza(ctx context.Context, stanza xmpp.Stanza) {
+	x.runQueue.Run(func() { x.processStanza(ctx, stanza) })
+}
+
+// Unbound removes a disconnected user from every room it was occupying.
+func (x *MUC) Unbound(ctx context.Context, j *jid.JID) {
+	x.runQueue.Run(func() {
+		for _, r := range x.activeRooms() {
+			if occ := r.occupantByJID(j); occ != nil {
+				x.removeOccupant(ctx, r, occ, nil)
+			}
+		}
+	})
+}
+
+// Shutdown shuts down Multi-User Chat module.
+func (x *MUC) Shutdown() error {
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return nil
+}
+
+func (x *MUC) processStanza(ctx context.Context, stanza xmpp.Stanza) {
+	if stanza.Type() == xmpp.ErrorType {
+		return // never answer back to an error
+	}
+	fromJID := stanza.FromJID()
+	if len(fromJID.Node()) == 0 || !x.router.Hosts().IsLocalHost(fromJID.Domain()) {
+		_ = x.router.Route(ctx, xmpp.NewErrorStanzaFromStanza(stanza, xmpp.ErrForbidden, nil))
+		return
+	}
+	switch stanza := stanza.(type) {
+	case *xmpp.Presence:
+		x.processPresence(ctx, stanza)
+	case *xmpp.IQ:
+		x.processIQ(ctx, stanza)
+	case *xmpp.Message:
+		x.processMessage(ctx, stanza)
+	}
+}
+
+func (x *MUC) processIQ(ctx context.Context, iq *xmpp.IQ) {
+	toJID := iq.ToJID()
+	if !toJID.IsFull() && x.disco != nil && x.disco.MatchesIQ(iq) {
+		x.disco.ProcessIQ(ctx, iq)
+		return
+	}
+	if len(toJID.Node()) > 0 && !toJID.IsFull() {
+		e := iq.Elements()
+		if q := e.ChildNamespace("query", mucAdminNamespace); q != nil {
+			x.processAdminIQ(ctx, iq, q)
+			return
+		}
+		if q := e.ChildNamespace("query", mucOwnerNamespace); q != nil {
+			x.processOwnerIQ(ctx, iq, q)
+			return
+		}
+	}
+	if iq.IsGet() || iq.IsSet() {
+		_ = x.router.Route(ctx, iq.ServiceUnavailableError())
+	}
+}
+
+// affiliation returns the effective affiliation of a user within a room.
+// On linked rooms users granted to edit rooms are owners and ultrasound room bindings grant membership,
+// unless user has been explicitly banned.
+func (x *MUC) affiliation(ctx context.Context, r *room, userJID *jid.JID) (mucmodel.AffiliationType, error) {
+	bareJID := userJID.ToBareJID().String()
+	affiliations, err := x.mucRep.FetchMUCAffiliations(ctx, r.model.Name)
+	if err != nil {
+		return mucmodel.None, err
+	}
+	aff := mucmodel.None
+	for _, a := range affiliations {
+		if a.JID == bareJID {
+			aff = a.Affiliation
+			break
+		}
+	}
+	if !r.model.IsLinked() || aff == mucmodel.Outcast || aff == mucmodel.Owner {
+		return aff, nil
+	}
+	isRoomsEditor, err := x.authz.IsAllowed(ctx, userJID.Node(), rolesmodel.RoomsEdit)
+	if err != nil {
+		return mucmodel.None, err
+	}
+	if isRoomsEditor {
+		return mucmodel.Owner, nil
+	}
+	if aff != mucmodel.None {
+		return aff, nil
+	}
+	usernames, err := x.roomRep.FetchBoundUsers(ctx, r.model.RoomID)
+	if err != nil {
+		return mucmodel.None, err
+	}
+	for _, username := range usernames {
+		if username == userJID.Node() {
+			return mucmodel.Member, nil
+		}
+	}
+	return mucmodel.None, nil
+}
+
+func (x *MUC) activeRooms() []*room {
+	rooms := make([]*room, 0, len(x.rooms))
+	for _, r := range x.rooms {
+		rooms = append(rooms, r)
+	}
+	return rooms
+}
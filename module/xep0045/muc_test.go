@@ -0,0 +1,343 @@
+package xep0045
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	c2srouter "github.com/dantin/cubit/c2s/router"
+	"github.com/dantin/cubit/model"
+	mucmodel "github.com/dantin/cubit/model/muc"
+	"github.com/dantin/cubit/module/xep0030"
+	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/router/host"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_MUC_EnterRoom(t *testing.T) {
+	r := setupTest()
+	x := New(&Config{AllowCreate: true}, nil, r, memorystorage.NewUser(), memorystorage.NewRoom(), memorystorage.NewMUC(), memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+	bobJID, bobStm := bindUser(r, "bob")
+
+	// room is created on first entrance
+	_ = r.Route(context.Background(), enterPresence(aliceJID, "chat@conference.example.org/alice"))
+	elem := aliceStm.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, "chat@conference.example.org/alice", elem.From())
+	requireUserItem(t, elem, "owner", moderatorRole)
+	require.Equal(t, []string{selfPresenceStatus, nonAnonymousStatus, roomCreatedStatus}, statusCodes(elem))
+
+	elem = aliceStm.ReceiveElement() // empty subject
+	require.Equal(t, "message", elem.Name())
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	_ = r.Route(context.Background(), enterPresence(bobJID, "chat@conference.example.org/bob"))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, "chat@conference.example.org/alice", elem.From())
+	require.Equal(t, aliceJID.String(), elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("item").Attributes().Get("jid"))
+
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, "chat@conference.example.org/bob", elem.From())
+	requireUserItem(t, elem, "none", participantRole)
+	require.Equal(t, []string{selfPresenceStatus, nonAnonymousStatus}, statusCodes(elem))
+
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, "chat@conference.example.org/bob", elem.From())
+	require.Len(t, statusCodes(elem), 0)
+
+	_ = bobStm.ReceiveElement() // subject
+
+	// nick conflict
+	carolJID, carolStm := bindUser(r, "carol")
+	_ = r.Route(context.Background(), enterPresence(carolJID, "chat@conference.example.org/bob"))
+	elem = carolStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	// leave room
+	_ = r.Route(context.Background(), xmpp.NewPresence(bobJID, roomJID("chat@conference.example.org/bob"), xmpp.UnavailableType))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{selfPresenceStatus}, statusCodes(elem))
+
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, "chat@conference.example.org/bob", elem.From())
+}
+
+func TestModule_MUC_EnterRoomErrors(t *testing.T) {
+	r := setupTest()
+	mucRep := memorystorage.NewMUC()
+	x := New(&Config{}, nil, r, memorystorage.NewUser(), memorystorage.NewRoom(), mucRep, memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+
+	// room creation not allowed
+	_ = r.Route(context.Background(), enterPresence(aliceJID, "chat@conference.example.org/alice"))
+	elem := aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// missing nick
+	_ = r.Route(context.Background(), enterPresence(aliceJID, "chat@conference.example.org"))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrJidMalformed.Error(), elem.Error().Elements().All()[0].Name())
+
+	// members-only room
+	_ = mucRep.UpsertMUCRoom(context.Background(), &mucmodel.Room{Name: "private", Title: "Private", MembersOnly: true})
+	_ = r.Route(context.Background(), enterPresence(aliceJID, "private@conference.example.org/alice"))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrRegistrationRequired.Error(), elem.Error().Elements().All()[0].Name())
+
+	// banned user
+	_ = mucRep.UpsertMUCRoom(context.Background(), &mucmodel.Room{Name: "open", Title: "Open"})
+	_ = mucRep.UpsertMUCAffiliation(context.Background(), &mucmodel.Affiliation{RoomName: "open", JID: "alice@example.org", Affiliation: mucmodel.Outcast})
+	_ = r.Route(context.Background(), enterPresence(aliceJID, "open@conference.example.org/alice"))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// member user
+	_ = mucRep.UpsertMUCAffiliation(context.Background(), &mucmodel.Affiliation{RoomName: "private", JID: "alice@example.org", Affiliation: mucmodel.Member})
+	_ = r.Route(context.Background(), enterPresence(aliceJID, "private@conference.example.org/alice"))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.AvailableType, elem.Type())
+	requireUserItem(t, elem, "member", participantRole)
+}
+
+func TestModule_MUC_ChangeNick(t *testing.T) {
+	r := setupTest()
+	x := New(&Config{AllowCreate: true}, nil, r, memorystorage.NewUser(), memorystorage.NewRoom(), memorystorage.NewMUC(), memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+	_ = r.Route(context.Background(), enterPresence(aliceJID, "chat@conference.example.org/alice"))
+	_ = aliceStm.ReceiveElement()
+	_ = aliceStm.ReceiveElement()
+
+	_ = r.Route(context.Background(), xmpp.NewPresence(aliceJID, roomJID("chat@conference.example.org/ali"), xmpp.AvailableType))
+	elem := aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{nickChangedStatus, selfPresenceStatus}, statusCodes(elem))
+	require.Equal(t, "ali", elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("item").Attributes().Get("nick"))
+
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.AvailableType, elem.Type())
+	require.Equal(t, "chat@conference.example.org/ali", elem.From())
+}
+
+func TestModule_MUC_GroupChat(t *testing.T) {
+	r := setupTest()
+	mucRep := memorystorage.NewMUC()
+	x := New(&Config{AllowCreate: true, HistorySize: 2}, nil, r, memorystorage.NewUser(), memorystorage.NewRoom(), mucRep, memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+	bobJID, bobStm := bindUser(r, "bob")
+
+	_ = r.Route(context.Background(), enterPresence(aliceJID, "chat@conference.example.org/alice"))
+	_ = aliceStm.ReceiveElement()
+	_ = aliceStm.ReceiveElement()
+
+	// not an occupant
+	_ = r.Route(context.Background(), groupChatMessage(bobJID, "chat@conference.example.org", "hi!"))
+	elem := bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	for _, body := range []string{"one", "two", "three"} {
+		_ = r.Route(context.Background(), groupChatMessage(aliceJID, "chat@conference.example.org", body))
+		elem = aliceStm.ReceiveElement()
+		require.Equal(t, "chat@conference.example.org/alice", elem.From())
+		require.Equal(t, body, elem.Elements().Child("body").Text())
+	}
+	history, _ := mucRep.FetchMUCHistory(context.Background(), "chat", 10)
+	require.Len(t, history, 3)
+
+	// history limited to last two messages
+	_ = r.Route(context.Background(), enterPresence(bobJID, "chat@conference.example.org/bob"))
+	_ = bobStm.ReceiveElement() // alice presence
+	_ = bobStm.ReceiveElement() // self presence
+	_ = aliceStm.ReceiveElement()
+
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, "two", elem.Elements().Child("body").Text())
+	require.NotNil(t, elem.Elements().ChildNamespace("delay", delayNamespace))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, "three", elem.Elements().Child("body").Text())
+	elem = bobStm.ReceiveElement()
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	// private message
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
+	msg.SetFromJID(bobJID)
+	msg.SetToJID(roomJID("chat@conference.example.org/alice"))
+	body := xmpp.NewElementName("body")
+	body.SetText("psst")
+	msg.AppendElement(body)
+	_ = r.Route(context.Background(), msg)
+
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, "chat@conference.example.org/bob", elem.From())
+	require.Equal(t, xmpp.ChatType, elem.Type())
+	require.NotNil(t, elem.Elements().ChildNamespace("x", mucUserNamespace))
+
+	// only moderators change subject
+	subject := xmpp.NewMessageType(uuid.New().String(), xmpp.GroupChatType)
+	subject.SetFromJID(bobJID)
+	subject.SetToJID(roomJID("chat@conference.example.org"))
+	subjectEl := xmpp.NewElementName("subject")
+	subjectEl.SetText("news")
+	subject.AppendElement(subjectEl)
+	_ = r.Route(context.Background(), subject)
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	subject, _ = xmpp.NewMessageFromElement(subject, aliceJID, subject.ToJID())
+	_ = r.Route(context.Background(), subject)
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, "news", elem.Elements().Child("subject").Text())
+
+	m, _ := mucRep.FetchMUCRoom(context.Background(), "chat")
+	require.Equal(t, "news", m.Subject)
+}
+
+func TestModule_MUC_Unbound(t *testing.T) {
+	r := setupTest()
+	x := New(&Config{AllowCreate: true}, nil, r, memorystorage.NewUser(), memorystorage.NewRoom(), memorystorage.NewMUC(), memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	r.RegisterUnbindHandler(x)
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+	bobJID, bobStm := bindUser(r, "bob")
+
+	_ = r.Route(context.Background(), enterPresence(aliceJID, "chat@conference.example.org/alice"))
+	_ = aliceStm.ReceiveElement()
+	_ = aliceStm.ReceiveElement()
+	_ = r.Route(context.Background(), enterPresence(bobJID, "chat@conference.example.org/bob"))
+	_ = aliceStm.ReceiveElement()
+
+	// wait until bob has fully entered the room
+	require.Equal(t, "presence", bobStm.ReceiveElement().Name())
+	require.Equal(t, "presence", bobStm.ReceiveElement().Name())
+	require.Equal(t, "message", bobStm.ReceiveElement().Name()) // subject
+
+	r.Unbind(context.Background(), bobJID)
+	elem := aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, "chat@conference.example.org/bob", elem.From())
+}
+
+func TestModule_MUC_Disco(t *testing.T) {
+	r := setupTest()
+	mucRep := memorystorage.NewMUC()
+	disco := xep0030.New(r, memorystorage.NewRoster())
+	x := New(&Config{}, disco, r, memorystorage.NewUser(), memorystorage.NewRoom(), mucRep, memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+	defer func() { _ = disco.Shutdown() }()
+
+	_ = mucRep.UpsertMUCRoom(context.Background(), &mucmodel.Room{Name: "open", Title: "Open"})
+	_ = mucRep.UpsertMUCRoom(context.Background(), &mucmodel.Room{Name: "private", Title: "Private", MembersOnly: true})
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+
+	iq := xmpp.NewIQType(uuid.New().String(), xmpp.GetType)
+	iq.SetFromJID(aliceJID)
+	iq.SetToJID(roomJID("conference.example.org"))
+	iq.AppendElement(xmpp.NewElementNamespace("query", "http://jabber.org/protocol/disco#items"))
+	_ = r.Route(context.Background(), iq)
+
+	elem := aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	items := elem.Elements().Child("query").Elements().Children("item")
+	require.Len(t, items, 1)
+	require.Equal(t, "open@conference.example.org", items[0].Attributes().Get("jid"))
+
+	iq = xmpp.NewIQType(uuid.New().String(), xmpp.GetType)
+	iq.SetFromJID(aliceJID)
+	iq.SetToJID(roomJID("private@conference.example.org"))
+	iq.AppendElement(xmpp.NewElementNamespace("query", "http://jabber.org/protocol/disco#info"))
+	_ = r.Route(context.Background(), iq)
+
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	q := elem.Elements().Child("query")
+	require.Equal(t, "Private", q.Elements().Child("identity").Attributes().Get("name"))
+
+	var features []string
+	for _, f := range q.Elements().Children("feature") {
+		features = append(features, f.Attributes().Get("var"))
+	}
+	require.Contains(t, features, mucMembersOnlyFeature)
+}
+
+func setupTest() router.Router {
+	hosts, _ := host.New([]host.Config{{Name: "example.org", Certificate: tls.Certificate{}}})
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r
+}
+
+func bindUser(r router.Router, username string) (*jid.JID, *stream.MockC2S) {
+	j, _ := jid.New(username, "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+	return j, stm
+}
+
+func bindAdmin(r router.Router, userRep *memorystorage.User, username string) (*jid.JID, *stream.MockC2S) {
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: username, Role: model.Admin})
+	return bindUser(r, username)
+}
+
+func roomJID(s string) *jid.JID {
+	j, _ := jid.NewWithString(s, true)
+	return j
+}
+
+func enterPresence(from *jid.JID, to string) *xmpp.Presence {
+	p := xmpp.NewPresence(from, roomJID(to), xmpp.AvailableType)
+	p.AppendElement(xmpp.NewElementNamespace("x", mucNamespace))
+	return p
+}
+
+func groupChatMessage(from *jid.JID, to string, text string) *xmpp.Message {
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.GroupChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(roomJID(to))
+	body := xmpp.NewElementName("body")
+	body.SetText(text)
+	msg.AppendElement(body)
+	return msg
+}
+
+func requireUserItem(t *testing.T, elem xmpp.XElement, affiliation, role string) {
+	userEl := elem.Elements().ChildNamespace("x", mucUserNamespace)
+	require.NotNil(t, userEl)
+	item := userEl.Elements().Child("item")
+	require.NotNil(t, item)
+	require.Equal(t, affiliation, item.Attributes().Get("affiliation"))
+	require.Equal(t, role, item.Attributes().Get("role"))
+}
+
+func statusCodes(elem xmpp.XElement) []string {
+	var codes []string
+	userEl := elem.Elements().ChildNamespace("x", mucUserNamespace)
+	if userEl == nil {
+		return nil
+	}
+	for _, st := range userEl.Elements().Children("status") {
+		codes = append(codes, st.Attributes().Get("code"))
+	}
+	return codes
+}
@@ -0,0 +1,423 @@
+package xep0045
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dantin/cubit/log"
+	mucmodel "github.com/dantin/cubit/model/muc"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+)
+
+// occupant roles
+const (
+	moderatorRole   = "moderator"
+	participantRole = "participant"
+	noneRole        = "none"
+)
+
+// presence status codes
+const (
+	nonAnonymousStatus = "100"
+	selfPresenceStatus = "110"
+	roomCreatedStatus  = "201"
+	bannedStatus       = "301"
+	nickChangedStatus  = "303"
+	kickedStatus       = "307"
+	affiliationStatus  = "321"
+	membersOnlyStatus  = "322"
+)
+
+const delayNamespace = "urn:xmpp:delay"
+
+const timestampLayout = "2006-01-02T15:04:05Z"
+
+type occupant struct {
+	jid         *jid.JID // real full JID
+	nick        string
+	affiliation mucmodel.AffiliationType
+	role        string
+	presence    *xmpp.Presence
+}
+
+type room struct {
+	jid       *jid.JID // bare room JID
+	model     mucmodel.Room
+	occupants []*occupant // sorted by entrance
+}
+
+func (r *room) occupantByNick(nick string) *occupant {
+	for _, occ := range r.occupants {
+		if occ.nick == nick {
+			return occ
+		}
+	}
+	return nil
+}
+
+func (r *room) occupantByJID(j *jid.JID) *occupant {
+	for _, occ := range r.occupants {
+		if occ.jid.Matches(j) {
+			return occ
+		}
+	}
+	return nil
+}
+
+func (r *room) occupantsByBareJID(j *jid.JID) []*occupant {
+	var res []*occupant
+	for _, occ := range r.occupants {
+		if occ.jid.MatchesWithOptions(j, jid.MatchesNode|jid.MatchesDomain) {
+			res = append(res, occ)
+		}
+	}
+	return res
+}
+
+func (r *room) occupantJID(nick string) *jid.JID {
+	j, _ := jid.New(r.jid.Node(), r.jid.Domain(), nick, true)
+	return j
+}
+
+func (r *room) removeOccupant(occ *occupant) {
+	for i, o := range r.occupants {
+		if o == occ {
+			r.occupants = append(r.occupants[:i], r.occupants[i+1:]...)
+			return
+		}
+	}
+}
+
+func roleFor(aff mucmodel.AffiliationType) string {
+	switch aff {
+	case mucmodel.Owner, mucmodel.Admin:
+		return moderatorRole
+	case mucmodel.Outcast:
+		return noneRole
+	default:
+		return participantRole
+	}
+}
+
+func (x *MUC) processPresence(ctx context.Context, presence *xmpp.Presence) {
+	toJID := presence.ToJID()
+	if len(toJID.Node()) == 0 {
+		return // presences addressed to chat service itself are ignored
+	}
+	if !presence.IsAvailable() && !presence.IsUnavailable() {
+		return
+	}
+	fromJID := presence.FromJID()
+	if len(toJID.Resource()) == 0 {
+		if presence.IsAvailable() {
+			_ = x.router.Route(ctx, presence.JidMalformedError())
+		}
+		return
+	}
+	r := x.rooms[toJID.ToBareJID().String()]
+	var occ *occupant
+	if r != nil {
+		occ = r.occupantByJID(fromJID)
+	}
+	switch {
+	case presence.IsUnavailable():
+		if occ != nil {
+			x.removeOccupant(ctx, r, occ, presence)
+		}
+	case occ == nil:
+		x.enterRoom(ctx, presence)
+	case occ.nick != toJID.Resource():
+		x.changeNick(ctx, r, occ, presence)
+	default:
+		occ.presence = presence
+		x.broadcastPresence(ctx, r, occ)
+	}
+}
+
+// enterRoom handles a room entrance request, creating room if it doesn't exist yet.
+func (x *MUC) enterRoom(ctx context.Context, presence *xmpp.Presence) {
+	toJID := presence.ToJID()
+	fromJID := presence.FromJID()
+
+	r, created, err := x.loadRoom(ctx, toJID.ToBareJID(), fromJID)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, presence.InternalServerError())
+		return
+	}
+	if r == nil {
+		_ = x.router.Route(ctx, presence.ItemNotFoundError())
+		return
+	}
+	aff := mucmodel.Owner
+	if !created {
+		if aff, err = x.affiliation(ctx, r, fromJID); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, presence.InternalServerError())
+			return
+		}
+	}
+	switch {
+	case aff == mucmodel.Outcast:
+		_ = x.router.Route(ctx, presence.ForbiddenError())
+		return
+	case r.model.MembersOnly && aff < mucmodel.Member:
+		_ = x.router.Route(ctx, presence.RegistrationRequiredError())
+		return
+	case r.occupantByNick(toJID.Resource()) != nil:
+		_ = x.router.Route(ctx, presence.ConflictError())
+		return
+	}
+	occ := &occupant{
+		jid:         fromJID,
+		nick:        toJID.Resource(),
+		affiliation: aff,
+		role:        roleFor(aff),
+		presence:    presence,
+	}
+	// send current occupants presence to the newcomer
+	for _, o := range r.occupants {
+		_ = x.router.Route(ctx, x.presenceOf(r, o, occ.jid, xmpp.AvailableType))
+	}
+	r.occupants = append(r.occupants, occ)
+	x.rooms[r.jid.String()] = r
+
+	statuses := []string{selfPresenceStatus, nonAnonymousStatus}
+	if created {
+		statuses = append(statuses, roomCreatedStatus)
+	}
+	for _, o := range r.occupants {
+		if o == occ {
+			_ = x.router.Route(ctx, x.presenceOf(r, occ, o.jid, xmpp.AvailableType, statuses...))
+			continue
+		}
+		_ = x.router.Route(ctx, x.presenceOf(r, occ, o.jid, xmpp.AvailableType))
+	}
+	log.Infof("%s entered room %s as %s", fromJID.String(), r.jid.String(), occ.nick)
+
+	if err := x.sendHistory(ctx, r, occ, presence.Elements().ChildNamespace("x", mucNamespace)); err != nil {
+		log.Error(err)
+	}
+	x.sendSubject(ctx, r, occ.jid)
+}
+
+// loadRoom returns an active room, loading it from storage if required.
+// Whenever room doesn't exist and room creation is allowed, a new room owned by requester is created.
+func (x *MUC) loadRoom(ctx context.Context, roomJID, requester *jid.JID) (*room, bool, error) {
+	r, err := x.fetchRoom(ctx, roomJID)
+	if err != nil || r != nil || !x.cfg.AllowCreate {
+		return r, false, err
+	}
+	m := &mucmodel.Room{Name: roomJID.Node(), Title: roomJID.Node()}
+	if err := x.mucRep.UpsertMUCRoom(ctx, m); err != nil {
+		return nil, false, err
+	}
+	owner := &mucmodel.Affiliation{RoomName: m.Name, JID: requester.ToBareJID().String(), Affiliation: mucmodel.Owner}
+	if err := x.mucRep.UpsertMUCAffiliation(ctx, owner); err != nil {
+		return nil, false, err
+	}
+	log.Infof("room %s created by %s", roomJID.String(), requester.ToBareJID().String())
+	return &room{jid: roomJID, model: *m}, true, nil
+}
+
+// fetchRoom returns an active room, or a stored one.
+// Linked rooms are lazily created for ultrasound rooms not having a chat room yet.
+func (x *MUC) fetchRoom(ctx context.Context, roomJID *jid.JID) (*room, error) {
+	if r := x.rooms[roomJID.String()]; r != nil {
+		return r, nil
+	}
+	m, err := x.mucRep.FetchMUCRoom(ctx, roomJID.Node())
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		usRoom, err := x.roomRep.FetchRoom(ctx, roomJID.Node())
+		if err != nil || usRoom == nil {
+			return nil, err
+		}
+		if m, err = x.syncRoom(ctx, usRoom.ID); err != nil || m == nil {
+			return nil, err
+		}
+	}
+	return &room{jid: roomJID, model: *m}, nil
+}
+
+func (x *MUC) changeNick(ctx context.Context, r *room, occ *occupant, presence *xmpp.Presence) {
+	nick := presence.ToJID().Resource()
+	if r.occupantByNick(nick) != nil {
+		_ = x.router.Route(ctx, presence.ConflictError())
+		return
+	}
+	for _, o := range r.occupants {
+		userEl, itemEl := userElement(occ, nickStatuses(o == occ)...)
+		itemEl.SetAttribute("nick", nick)
+		_ = x.router.Route(ctx, x.occupantPresence(r, occ, o.jid, xmpp.UnavailableType, userEl))
+	}
+	occ.nick = nick
+	occ.presence = presence
+	for _, o := range r.occupants {
+		if o == occ {
+			_ = x.router.Route(ctx, x.presenceOf(r, occ, o.jid, xmpp.AvailableType, selfPresenceStatus))
+			continue
+		}
+		_ = x.router.Route(ctx, x.presenceOf(r, occ, o.jid, xmpp.AvailableType))
+	}
+}
+
+func nickStatuses(self bool) []string {
+	if self {
+		return []string{nickChangedStatus, selfPresenceStatus}
+	}
+	return []string{nickChangedStatus}
+}
+
+// removeOccupant makes an occupant leave a room, notifying every remaining occupant.
+func (x *MUC) removeOccupant(ctx context.Context, r *room, occ *occupant, presence *xmpp.Presence, statuses ...string) {
+	occ.role = noneRole
+	if presence != nil {
+		occ.presence = presence
+	}
+	for _, o := range r.occupants {
+		if o == occ {
+			_ = x.router.Route(ctx, x.presenceOf(r, occ, o.jid, xmpp.UnavailableType, append(statuses, selfPresenceStatus)...))
+			continue
+		}
+		_ = x.router.Route(ctx, x.presenceOf(r, occ, o.jid, xmpp.UnavailableType, statuses...))
+	}
+	r.removeOccupant(occ)
+	if len(r.occupants) == 0 {
+		delete(x.rooms, r.jid.String())
+	}
+	log.Infof("%s left room %s", occ.jid.String(), r.jid.String())
+}
+
+func (x *MUC) broadcastPresence(ctx context.Context, r *room, occ *occupant) {
+	for _, o := range r.occupants {
+		if o == occ {
+			_ = x.router.Route(ctx, x.presenceOf(r, occ, o.jid, xmpp.AvailableType, selfPresenceStatus))
+			continue
+		}
+		_ = x.router.Route(ctx, x.presenceOf(r, occ, o.jid, xmpp.AvailableType))
+	}
+}
+
+// presenceOf returns occupant presence as seen by a given user, along with a set of status codes.
+func (x *MUC) presenceOf(r *room, occ *occupant, to *jid.JID, presenceType string, statuses ...string) *xmpp.Presence {
+	userEl, _ := userElement(occ, statuses...)
+	return x.occupantPresence(r, occ, to, presenceType, userEl)
+}
+
+// occupantPresence returns occupant presence as seen by a given user, carrying a muc#user extension element.
+func (x *MUC) occupantPresence(r *room, occ *occupant, to *jid.JID, presenceType string, userEl *xmpp.Element) *xmpp.Presence {
+	p := xmpp.NewPresence(r.occupantJID(occ.nick), to, presenceType)
+	if occ.presence != nil {
+		for _, el := range occ.presence.Elements().All() {
+			if el.Namespace() == mucNamespace || el.Namespace() == mucUserNamespace {
+				continue
+			}
+			if presenceType == xmpp.UnavailableType && el.Name() != "status" {
+				continue
+			}
+			p.AppendElement(el)
+		}
+	}
+	p.AppendElement(userEl)
+	return p
+}
+
+// userElement returns occupant muc#user extension element, along with its item child.
+// Occupant real JID is always disclosed as rooms are non-anonymous.
+func userElement(occ *occupant, statuses ...string) (*xmpp.Element, *xmpp.Element) {
+	userEl := xmpp.NewElementNamespace("x", mucUserNamespace)
+	itemEl := xmpp.NewElementName("item")
+	itemEl.SetAttribute("affiliation", occ.affiliation.String())
+	itemEl.SetAttribute("role", occ.role)
+	itemEl.SetAttribute("jid", occ.jid.String())
+	userEl.AppendElement(itemEl)
+	for _, code := range statuses {
+		statusEl := xmpp.NewElementName("status")
+		statusEl.SetAttribute("code", code)
+		userEl.AppendElement(statusEl)
+	}
+	return userEl, itemEl
+}
+
+// sendHistory sends room discussion history to a new occupant, honoring its history request limits.
+func (x *MUC) sendHistory(ctx context.Context, r *room, occ *occupant, mucEl xmpp.XElement) error {
+	limit := x.cfg.historySize()
+	var since time.Time
+	if mucEl != nil {
+		if historyEl := mucEl.Elements().Child("history"); historyEl != nil {
+			attrs := historyEl.Attributes()
+			if v, err := strconv.Atoi(attrs.Get("maxstanzas")); err == nil && v >= 0 && v < limit {
+				limit = v
+			}
+			if v, err := strconv.Atoi(attrs.Get("seconds")); err == nil && v >= 0 {
+				since = time.Now().Add(-time.Duration(v) * time.Second)
+			}
+			if t, err := time.Parse(timestampLayout, attrs.Get("since")); err == nil && t.After(since) {
+				since = t
+			}
+		}
+	}
+	if limit == 0 {
+		return nil
+	}
+	messages, err := x.mucRep.FetchMUCHistory(ctx, r.model.Name, limit)
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		if !since.IsZero() && m.Stamp.Before(since) {
+			continue
+		}
+		msg := xmpp.NewMessageType(uuid.New().String(), xmpp.GroupChatType)
+		msg.SetFromJID(r.occupantJID(m.Nick))
+		msg.SetToJID(occ.jid)
+		body := xmpp.NewElementName("body")
+		body.SetText(m.Body)
+		msg.AppendElement(body)
+
+		delay := xmpp.NewElementNamespace("delay", delayNamespace)
+		delay.SetAttribute("from", r.jid.String())
+		delay.SetAttribute("stamp", m.Stamp.UTC().Format(timestampLayout))
+		msg.AppendElement(delay)
+		_ = x.router.Route(ctx, msg)
+	}
+	return nil
+}
+
+func (x *MUC) sendSubject(ctx context.Context, r *room, to *jid.JID) {
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.GroupChatType)
+	msg.SetFromJID(r.jid)
+	msg.SetToJID(to)
+	subject := xmpp.NewElementName("subject")
+	subject.SetText(r.model.Subject)
+	msg.AppendElement(subject)
+	_ = x.router.Route(ctx, msg)
+}
+
+// destroyRoom makes every occupant leave a room, which is removed from storage along with its history.
+func (x *MUC) destroyRoom(ctx context.Context, r *room, reason string) error {
+	if err := x.mucRep.DeleteMUCRoom(ctx, r.model.Name); err != nil {
+		return err
+	}
+	for _, occ := range r.occupants {
+		occ.role = noneRole
+		userEl, _ := userElement(occ)
+		destroyEl := xmpp.NewElementName("destroy")
+		if len(reason) > 0 {
+			reasonEl := xmpp.NewElementName("reason")
+			reasonEl.SetText(reason)
+			destroyEl.AppendElement(reasonEl)
+		}
+		userEl.AppendElement(destroyEl)
+		_ = x.router.Route(ctx, x.occupantPresence(r, occ, occ.jid, xmpp.UnavailableType, userEl))
+	}
+	delete(x.rooms, r.jid.String())
+	log.Infof("room %s destroyed", r.jid.String())
+	return nil
+}
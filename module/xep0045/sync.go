@@ -0,0 +1,145 @@
+package xep0045
+
+import (
+	"context"
+
+	"github.com/dantin/cubit/log"
+	mucmodel "github.com/dantin/cubit/model/muc"
+	"github.com/dantin/cubit/xmpp/jid"
+)
+
+const roomDeletedReason = "ultrasound room deleted"
+
+// RoomUpdated keeps the chat room linked to an ultrasound room in sync with it,
+// granting membership to every user bound to the room and revoking it from unbound ones.
+func (x *MUC) RoomUpdated(ctx context.Context, roomID int) {
+	x.runQueue.Run(func() {
+		if _, err := x.syncRoom(ctx, roomID); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
+// RoomDeleted destroys the chat room linked to a deleted ultrasound room.
+func (x *MUC) RoomDeleted(ctx context.Context, roomID int) {
+	x.runQueue.Run(func() {
+		m, err := x.linkedRoom(ctx, roomID)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if m == nil {
+			return
+		}
+		if err := x.deleteRoom(ctx, m.Name, roomDeletedReason); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
+// syncRoom converges the chat room linked to an ultrasound room, creating it if required.
+func (x *MUC) syncRoom(ctx context.Context, roomID int) (*mucmodel.Room, error) {
+	usRoom, err := x.roomRep.FetchRoomByID(ctx, roomID)
+	if err != nil || usRoom == nil {
+		return nil, err
+	}
+	m, err := x.linkedRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil && m.Name != usRoom.Username {
+		// chat room address follows ultrasound room username
+		if err := x.deleteRoom(ctx, m.Name, ""); err != nil {
+			return nil, err
+		}
+		m = nil
+	}
+	if m == nil {
+		m = &mucmodel.Room{Name: usRoom.Username, MembersOnly: true, RoomID: roomID}
+		log.Infof("room %s linked to ultrasound room %d", m.Name, roomID)
+	}
+	m.Title = usRoom.Name
+	if err := x.mucRep.UpsertMUCRoom(ctx, m); err != nil {
+		return nil, err
+	}
+	usernames, err := x.roomRep.FetchBoundUsers(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	affiliations, err := x.mucRep.FetchMUCAffiliations(ctx, m.Name)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[string]struct{}, len(usernames))
+	for _, username := range usernames {
+		members[x.userJID(username).String()] = struct{}{}
+	}
+	affiliated := make(map[string]struct{}, len(affiliations))
+	var revoked []*jid.JID
+	for _, aff := range affiliations {
+		affiliated[aff.JID] = struct{}{}
+		if _, ok := members[aff.JID]; ok || aff.Affiliation != mucmodel.Member {
+			continue
+		}
+		if err := x.mucRep.DeleteMUCAffiliation(ctx, m.Name, aff.JID); err != nil {
+			return nil, err
+		}
+		if j, err := jid.NewWithString(aff.JID, true); err == nil {
+			revoked = append(revoked, j)
+		}
+	}
+	for _, username := range usernames {
+		userJID := x.userJID(username).String()
+		if _, ok := affiliated[userJID]; ok {
+			continue
+		}
+		err := x.mucRep.UpsertMUCAffiliation(ctx, &mucmodel.Affiliation{RoomName: m.Name, JID: userJID, Affiliation: mucmodel.Member})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range x.activeRooms() {
+		if r.model.Name != m.Name {
+			continue
+		}
+		r.model = *m
+		for _, userJID := range revoked {
+			aff, err := x.affiliation(ctx, r, userJID)
+			if err != nil {
+				return nil, err
+			}
+			x.applyAffiliation(ctx, r, userJID, aff)
+		}
+	}
+	return m, nil
+}
+
+// deleteRoom removes a chat room from storage, making every occupant leave it.
+func (x *MUC) deleteRoom(ctx context.Context, name, reason string) error {
+	for _, r := range x.activeRooms() {
+		if r.model.Name == name {
+			if err := x.destroyRoom(ctx, r, reason); err != nil {
+				return err
+			}
+		}
+	}
+	return x.mucRep.DeleteMUCRoom(ctx, name)
+}
+
+func (x *MUC) linkedRoom(ctx context.Context, roomID int) (*mucmodel.Room, error) {
+	rooms, err := x.mucRep.FetchMUCRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range rooms {
+		if m.RoomID == roomID {
+			return &m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (x *MUC) userJID(username string) *jid.JID {
+	j, _ := jid.New(username, x.router.Hosts().DefaultHostName(), "", true)
+	return j
+}
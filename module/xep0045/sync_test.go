@@ -0,0 +1,150 @@
+package xep0045
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	mucmodel "github.com/dantin/cubit/model/muc"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_MUC_LinkedRoom(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+	mucRep := memorystorage.NewMUC()
+	x := New(&Config{}, nil, r, userRep, roomRep, mucRep, memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.BindRoom(context.Background(), 1, "alice")
+
+	adminJID, adminStm := bindAdmin(r, userRep, "admin")
+	aliceJID, aliceStm := bindUser(r, "alice")
+	bobJID, bobStm := bindUser(r, "bob")
+
+	// chat room is lazily linked on first entrance
+	_ = r.Route(context.Background(), enterPresence(adminJID, "room01@conference.example.org/admin"))
+	elem := adminStm.ReceiveElement()
+	requireUserItem(t, elem, "owner", moderatorRole)
+	_ = adminStm.ReceiveElement()
+
+	m, _ := mucRep.FetchMUCRoom(context.Background(), "room01")
+	require.NotNil(t, m)
+	require.Equal(t, "Room 01", m.Title)
+	require.Equal(t, 1, m.RoomID)
+	require.True(t, m.MembersOnly)
+
+	enterRoom(t, r, aliceJID, aliceStm, "room01@conference.example.org/alice", 3)
+	_ = adminStm.ReceiveElement()
+
+	_ = r.Route(context.Background(), enterPresence(bobJID, "room01@conference.example.org/bob"))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrRegistrationRequired.Error(), elem.Error().Elements().All()[0].Name())
+
+	// linked rooms can't be destroyed by owners
+	_ = r.Route(context.Background(), ownerIQ(adminJID, "room01@conference.example.org", xmpp.SetType, xmpp.NewElementName("destroy")))
+	elem = adminStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
+
+	// binding grants membership
+	_ = roomRep.BindRoom(context.Background(), 1, "bob")
+	x.RoomUpdated(context.Background(), 1)
+	waitRunQueue(x)
+
+	affiliations, _ := mucRep.FetchMUCAffiliations(context.Background(), "room01")
+	require.Contains(t, affiliations, mucmodel.Affiliation{RoomName: "room01", JID: "bob@example.org", Affiliation: mucmodel.Member})
+
+	// unbinding revokes membership
+	_ = roomRep.UnbindRoom(context.Background(), 1, "alice")
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{ID: 1, Name: "Room 1", Username: "room01", Type: roomsmodel.Normal})
+	x.RoomUpdated(context.Background(), 1)
+
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{affiliationStatus, selfPresenceStatus}, statusCodes(elem))
+	elem = adminStm.ReceiveElement()
+	require.Equal(t, []string{affiliationStatus}, statusCodes(elem))
+
+	m, _ = mucRep.FetchMUCRoom(context.Background(), "room01")
+	require.Equal(t, "Room 1", m.Title)
+
+	// deleting ultrasound room destroys chat room
+	_ = roomRep.DeleteRoom(context.Background(), 1)
+	x.RoomDeleted(context.Background(), 1)
+
+	elem = adminStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.NotNil(t, elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("destroy"))
+
+	waitRunQueue(x)
+	m, _ = mucRep.FetchMUCRoom(context.Background(), "room01")
+	require.Nil(t, m)
+}
+
+func TestModule_MUC_LinkedRoomOwnerPermission(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+	roleRep := memorystorage.NewRole()
+	x := New(&Config{}, nil, r, userRep, roomRep, memorystorage.NewMUC(), roleRep)
+	defer func() { _ = x.Shutdown() }()
+
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+
+	// administrators not granted to edit rooms are no longer owners
+	_ = roleRep.UpsertRole(context.Background(), &rolesmodel.Role{Name: model.Admin.String(), Permissions: []rolesmodel.Permission{rolesmodel.RoomsList}})
+	adminJID, adminStm := bindAdmin(r, userRep, "admin")
+
+	_ = r.Route(context.Background(), enterPresence(adminJID, "room01@conference.example.org/admin"))
+	elem := adminStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrRegistrationRequired.Error(), elem.Error().Elements().All()[0].Name())
+
+	// any role granted to edit rooms owns linked rooms
+	_ = roleRep.UpsertRole(context.Background(), &rolesmodel.Role{Name: "editor", Permissions: []rolesmodel.Permission{rolesmodel.RoomsEdit}})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "editor", Role: model.Role("editor")})
+	editorJID, editorStm := bindUser(r, "editor")
+
+	_ = r.Route(context.Background(), enterPresence(editorJID, "room01@conference.example.org/editor"))
+	elem = editorStm.ReceiveElement()
+	requireUserItem(t, elem, "owner", moderatorRole)
+}
+
+func TestModule_MUC_LinkedRoomRenamed(t *testing.T) {
+	r := setupTest()
+	roomRep := memorystorage.NewRoom()
+	mucRep := memorystorage.NewMUC()
+	x := New(&Config{}, nil, r, memorystorage.NewUser(), roomRep, mucRep, memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.BindRoom(context.Background(), 1, "alice")
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+	enterRoom(t, r, aliceJID, aliceStm, "room01@conference.example.org/alice", 2)
+
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{ID: 1, Name: "Room 01", Username: "room02", Type: roomsmodel.Normal})
+	x.RoomUpdated(context.Background(), 1)
+
+	elem := aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, "room01@conference.example.org/alice", elem.From())
+
+	waitRunQueue(x)
+	m, _ := mucRep.FetchMUCRoom(context.Background(), "room01")
+	require.Nil(t, m)
+	m, _ = mucRep.FetchMUCRoom(context.Background(), "room02")
+	require.NotNil(t, m)
+	require.Equal(t, 1, m.RoomID)
+}
+
+func waitRunQueue(x *MUC) {
+	c := make(chan struct{})
+	x.runQueue.Run(func() { close(c) })
+	<-c
+}
@@ -0,0 +1,309 @@
+package xep0050
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dantin/cubit/log"
+	"github.com/dantin/cubit/module/xep0004"
+	"github.com/dantin/cubit/module/xep0030"
+	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/util/runqueue"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+)
+
+// CommandsNamespace specifies XEP-0050 namespace constant value.
+const CommandsNamespace = "http://jabber.org/protocol/commands"
+
+const defaultSessionTimeout = time.Minute * 5
+
+const (
+	executeAction  = "execute"
+	completeAction = "complete"
+	cancelAction   = "cancel"
+)
+
+const (
+	executingStatus = "executing"
+	completedStatus = "completed"
+	canceledStatus  = "canceled"
+)
+
+type session struct {
+	node      string
+	requester string
+	timer     *time.Timer
+}
+
+// AdHoc represents an ad-hoc commands module.
+type AdHoc struct {
+	router         router.Router
+	disco          *xep0030.DiscoInfo
+	runQueue       *runqueue.RunQueue
+	mu             sync.RWMutex
+	commands       map[string]Command
+	nodes          []string
+	sessions       map[string]*session
+	sessionTimeout time.Duration
+}
+
+// New returns an ad-hoc commands IQ handler module.
+func New(disco *xep0030.DiscoInfo, router router.Router) *AdHoc {
+	x := &AdHoc{
+		router:         router,
+		disco:          disco,
+		runQueue:       runqueue.New("xep0050"),
+		commands:       make(map[string]Command),
+		sessions:       make(map[string]*session),
+		sessionTimeout: defaultSessionTimeout,
+	}
+	if disco != nil {
+		disco.RegisterServerFeature(CommandsNamespace)
+		disco.RegisterServerNodeProvider(CommandsNamespace, x)
+	}
+	return x
+}
+
+// RegisterCommand registers a new server ad-hoc command, replacing any previous command sharing its node.
+func (x *AdHoc) RegisterCommand(cmd Command) {
+	x.mu.Lock()
+	if _, ok := x.commands[cmd.Node()]; !ok {
+		x.nodes = append(x.nodes, cmd.Node())
+	}
+	x.commands[cmd.Node()] = cmd
+	x.mu.Unlock()
+
+	if x.disco != nil {
+		x.disco.RegisterServerNodeProvider(cmd.Node(), x)
+	}
+}
+
+// UnregisterCommand unregisters a previously registered command.
+func (x *AdHoc) UnregisterCommand(node string) {
+	x.mu.Lock()
+	delete(x.commands, node)
+	for i, n := range x.nodes {
+		if n == node {
+			x.nodes = append(x.nodes[:i], x.nodes[i+1:]...)
+			break
+		}
+	}
+	x.mu.Unlock()
+
+	if x.disco != nil {
+		x.disco.UnregisterServerNodeProvider(node)
+	}
+}
+
+// Identities satisfies xep0030.InfoProvider interface.
+func (x *AdHoc) Identities(ctx context.Context, _, fromJID *jid.JID, node string) []xep0030.Identity {
+	if node == CommandsNamespace {
+		return []xep0030.Identity{{Category: "automation", Type: "command-list", Name: "Commands"}}
+	}
+	if cmd := x.command(node); cmd != nil && cmd.IsAllowed(ctx, fromJID) {
+		return []xep0030.Identity{{Category: "automation", Type: "command-node", Name: cmd.Name()}}
+	}
+	return nil
+}
+
+// Items satisfies xep0030.InfoProvider interface.
+func (x *AdHoc) Items(ctx context.Context, toJID, fromJID *jid.JID, node string) ([]xep0030.Item, *xmpp.StanzaError) {
+	if node != CommandsNamespace {
+		return nil, nil
+	}
+	var items []xep0030.Item
+	for _, cmd := range x.allCommands() {
+		if cmd.IsAllowed(ctx, fromJID) {
+			items = append(items, xep0030.Item{Jid: toJID.String(), Node: cmd.Node(), Name: cmd.Name()})
+		}
+	}
+	return items, nil
+}
+
+// Features satisfies xep0030.InfoProvider interface.
+func (x *AdHoc) Features(ctx context.Context, _, fromJID *jid.JID, node string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	if node == CommandsNamespace {
+		return []xep0030.Feature{CommandsNamespace}, nil
+	}
+	cmd := x.command(node)
+	if cmd == nil {
+		return nil, nil
+	}
+	if !cmd.IsAllowed(ctx, fromJID) {
+		return nil, xmpp.ErrForbidden
+	}
+	return []xep0030.Feature{CommandsNamespace, xep0004.FormNamespace}, nil
+}
+
+// Form satisfies xep0030.InfoProvider interface.
+func (x *AdHoc) Form(_ context.Context, _, _ *jid.JID, _ string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	return nil, nil
+}
+
+// MatchesIQ returns whether or not an IQ should be processed by the ad-hoc commands module.
+func (x *AdHoc) MatchesIQ(iq *xmpp.IQ) bool {
+	return iq.IsSet() && iq.Elements().ChildNamespace("command", CommandsNamespace) != nil && iq.ToJID().IsServer()
+}
+
+// ProcessIQ processes an ad-hoc command IQ taking according actions over the associated stream.
+func (x *AdHoc) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
+	x.runQueue.Run(func() {
+		x.processIQ(ctx, iq)
+	})
+}
+
+// Shutdown shuts down ad-hoc commands module.
+func (x *AdHoc) Shutdown() error {
+	c := make(chan struct{})
+	x.runQueue.Stop(func() {
+		for _, sess := range x.sessions {
+			sess.timer.Stop()
+		}
+		close(c)
+	})
+	<-c
+	return nil
+}
+
+func (x *AdHoc) processIQ(ctx context.Context, iq *xmpp.IQ) {
+	cmdEl := iq.Elements().ChildNamespace("command", CommandsNamespace)
+	node := cmdEl.Attributes().Get("node")
+	cmd := x.command(node)
+	if cmd == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	requester := iq.FromJID()
+	if !cmd.IsAllowed(ctx, requester) {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	action := cmdEl.Attributes().Get("action")
+	sessionID := cmdEl.Attributes().Get("sessionid")
+	if len(sessionID) == 0 {
+		if len(action) > 0 && action != executeAction {
+			_ = x.router.Route(ctx, commandError(iq, xmpp.ErrBadRequest, "bad-action"))
+			return
+		}
+		sessionID = uuid.New().String()
+		form := cmd.Form(ctx, requester)
+		if form == nil {
+			x.execute(ctx, iq, cmd, sessionID, nil)
+			return
+		}
+		x.startSession(sessionID, node, requester)
+
+		result := iq.ResultIQ()
+		resCmdEl := commandElement(node, sessionID, executingStatus)
+		actionsEl := xmpp.NewElementName("actions")
+		actionsEl.SetAttribute("execute", completeAction)
+		actionsEl.AppendElement(xmpp.NewElementName(completeAction))
+		resCmdEl.AppendElement(actionsEl)
+		resCmdEl.AppendElement(form.Element())
+		result.AppendElement(resCmdEl)
+		_ = x.router.Route(ctx, result)
+		return
+	}
+	sess := x.sessions[sessionID]
+	if sess == nil || sess.node != node || sess.requester != requester.String() {
+		_ = x.router.Route(ctx, commandError(iq, xmpp.ErrBadRequest, "bad-sessionid"))
+		return
+	}
+	switch action {
+	case cancelAction:
+		x.endSession(sessionID)
+
+		result := iq.ResultIQ()
+		result.AppendElement(commandElement(node, sessionID, canceledStatus))
+		_ = x.router.Route(ctx, result)
+
+	case "", executeAction, completeAction:
+		formEl := cmdEl.Elements().ChildNamespace("x", xep0004.FormNamespace)
+		if formEl == nil {
+			_ = x.router.Route(ctx, commandError(iq, xmpp.ErrBadRequest, "bad-payload"))
+			return
+		}
+		form, err := xep0004.NewFormFromElement(formEl)
+		if err != nil || form.Type != xep0004.Submit {
+			_ = x.router.Route(ctx, commandError(iq, xmpp.ErrBadRequest, "bad-payload"))
+			return
+		}
+		x.endSession(sessionID)
+		x.execute(ctx, iq, cmd, sessionID, form.Fields)
+
+	default:
+		_ = x.router.Route(ctx, commandError(iq, xmpp.ErrBadRequest, "bad-action"))
+	}
+}
+
+func (x *AdHoc) execute(ctx context.Context, iq *xmpp.IQ, cmd Command, sessionID string, fields xep0004.Fields) {
+	log.Infof("executing command %s on behalf of %s", cmd.Node(), iq.FromJID().String())
+
+	cmd.Execute(ctx, iq.FromJID(), fields, func(res *Result, sErr *xmpp.StanzaError) {
+		if sErr != nil {
+			_ = x.router.Route(ctx, xmpp.NewErrorStanzaFromStanza(iq, sErr, nil))
+			return
+		}
+		result := iq.ResultIQ()
+		resCmdEl := commandElement(cmd.Node(), sessionID, completedStatus)
+		if res != nil {
+			resCmdEl.AppendElements(res.elements())
+		}
+		result.AppendElement(resCmdEl)
+		_ = x.router.Route(ctx, result)
+	})
+}
+
+func (x *AdHoc) startSession(sessionID, node string, requester *jid.JID) {
+	x.sessions[sessionID] = &session{
+		node:      node,
+		requester: requester.String(),
+		timer: time.AfterFunc(x.sessionTimeout, func() {
+			x.runQueue.Run(func() {
+				if _, ok := x.sessions[sessionID]; ok {
+					log.Infof("command session %s expired", sessionID)
+					delete(x.sessions, sessionID)
+				}
+			})
+		}),
+	}
+}
+
+func (x *AdHoc) endSession(sessionID string) {
+	if sess := x.sessions[sessionID]; sess != nil {
+		sess.timer.Stop()
+		delete(x.sessions, sessionID)
+	}
+}
+
+func (x *AdHoc) command(node string) Command {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.commands[node]
+}
+
+func (x *AdHoc) allCommands() []Command {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	res := make([]Command, 0, len(x.nodes))
+	for _, node := range x.nodes {
+		res = append(res, x.commands[node])
+	}
+	return res
+}
+
+func commandElement(node, sessionID, status string) *xmpp.Element {
+	cmdEl := xmpp.NewElementNamespace("command", CommandsNamespace)
+	cmdEl.SetAttribute("node", node)
+	cmdEl.SetAttribute("sessionid", sessionID)
+	cmdEl.SetAttribute("status", status)
+	return cmdEl
+}
+
+func commandError(iq *xmpp.IQ, sErr *xmpp.StanzaError, condition string) xmpp.Stanza {
+	return xmpp.NewErrorStanzaFromStanza(iq, sErr, []xmpp.XElement{xmpp.NewElementNamespace(condition, CommandsNamespace)})
+}
@@ -0,0 +1,224 @@
+package xep0050
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	c2srouter "github.com/dantin/cubit/c2s/router"
+	"github.com/dantin/cubit/module/xep0004"
+	"github.com/dantin/cubit/module/xep0030"
+	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/router/host"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type testCommand struct {
+	node    string
+	form    bool
+	allowed string
+}
+
+func (c *testCommand) Node() string { return c.node }
+
+func (c *testCommand) Name() string { return "Test " + c.node }
+
+func (c *testCommand) IsAllowed(_ context.Context, requester *jid.JID) bool {
+	return len(c.allowed) == 0 || requester.Node() == c.allowed
+}
+
+func (c *testCommand) Form(_ context.Context, _ *jid.JID) *xep0004.DataForm {
+	if !c.form {
+		return nil
+	}
+	return &xep0004.DataForm{
+		Type:   xep0004.Form,
+		Fields: xep0004.Fields{{Var: "preset", Type: xep0004.TextSingle, Required: true}},
+	}
+}
+
+func (c *testCommand) Execute(_ context.Context, _ *jid.JID, fields xep0004.Fields, done func(*Result, *xmpp.StanzaError)) {
+	if c.form && len(fields.ValueForField("preset")) == 0 {
+		go done(nil, xmpp.ErrNotAcceptable)
+		return
+	}
+	go done(&Result{Note: "done " + fields.ValueForField("preset")}, nil)
+}
+
+func TestXEP0050_Matching(t *testing.T) {
+	r := setupTest()
+
+	x := New(nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	srvJID, _ := jid.New("", "example.org", "", true)
+	j, _ := jid.New("ortuman", "example.org", "desktop", true)
+
+	iq := newCommandIQ(j, srvJID, xmpp.NewElementNamespace("command", CommandsNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq = newCommandIQ(j, j.ToBareJID(), xmpp.NewElementNamespace("command", CommandsNamespace))
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0050_Discovery(t *testing.T) {
+	r := setupTest()
+
+	srvJID, _ := jid.New("", "example.org", "", true)
+	j, _ := jid.New("ortuman", "example.org", "desktop", true)
+
+	disco := xep0030.New(r, memorystorage.NewRoster())
+	defer func() { _ = disco.Shutdown() }()
+
+	x := New(disco, r)
+	defer func() { _ = x.Shutdown() }()
+
+	x.RegisterCommand(&testCommand{node: "reboot"})
+	x.RegisterCommand(&testCommand{node: "shutdown", allowed: "admin"})
+
+	items, sErr := x.Items(context.Background(), srvJID, j, CommandsNamespace)
+	require.Nil(t, sErr)
+	require.Equal(t, []xep0030.Item{{Jid: "example.org", Node: "reboot", Name: "Test reboot"}}, items)
+
+	features, sErr := x.Features(context.Background(), srvJID, j, "reboot")
+	require.Nil(t, sErr)
+	require.Equal(t, []xep0030.Feature{CommandsNamespace, xep0004.FormNamespace}, features)
+
+	_, sErr = x.Features(context.Background(), srvJID, j, "shutdown")
+	require.Equal(t, xmpp.ErrForbidden, sErr)
+
+	require.Equal(t, "command-node", x.Identities(context.Background(), srvJID, j, "reboot")[0].Type)
+	require.Equal(t, "command-list", x.Identities(context.Background(), srvJID, j, CommandsNamespace)[0].Type)
+
+	x.UnregisterCommand("reboot")
+	items, _ = x.Items(context.Background(), srvJID, j, CommandsNamespace)
+	require.Nil(t, items)
+}
+
+func TestXEP0050_Execute(t *testing.T) {
+	r := setupTest()
+
+	srvJID, _ := jid.New("", "example.org", "", true)
+	j, _ := jid.New("ortuman", "example.org", "desktop", true)
+
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := New(nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	x.RegisterCommand(&testCommand{node: "reboot"})
+	x.RegisterCommand(&testCommand{node: "preset", form: true})
+	x.RegisterCommand(&testCommand{node: "shutdown", allowed: "admin"})
+
+	// unknown command
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, commandRequest("unknown", "", "")))
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// not allowed
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, commandRequest("shutdown", "", "")))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// single stage command
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, commandRequest("reboot", "", "")))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	cmdEl := elem.Elements().ChildNamespace("command", CommandsNamespace)
+	require.Equal(t, completedStatus, cmdEl.Attributes().Get("status"))
+	require.NotEmpty(t, cmdEl.Attributes().Get("sessionid"))
+	require.Equal(t, InfoNote, cmdEl.Elements().Child("note").Attributes().Get("type"))
+
+	// form command
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, commandRequest("preset", "", executeAction)))
+	elem = stm.ReceiveElement()
+	cmdEl = elem.Elements().ChildNamespace("command", CommandsNamespace)
+	require.Equal(t, executingStatus, cmdEl.Attributes().Get("status"))
+	require.NotNil(t, cmdEl.Elements().Child("actions"))
+	require.NotNil(t, cmdEl.Elements().ChildNamespace("x", xep0004.FormNamespace))
+	sessionID := cmdEl.Attributes().Get("sessionid")
+
+	// missing payload
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, commandRequest("preset", sessionID, completeAction)))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+	require.NotNil(t, elem.Error().Elements().ChildNamespace("bad-payload", CommandsNamespace))
+
+	submit := commandRequest("preset", sessionID, completeAction)
+	submit.AppendElement((&xep0004.DataForm{
+		Type:   xep0004.Submit,
+		Fields: xep0004.Fields{{Var: "preset", Values: []string{"3"}}},
+	}).Element())
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, submit))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	cmdEl = elem.Elements().ChildNamespace("command", CommandsNamespace)
+	require.Equal(t, completedStatus, cmdEl.Attributes().Get("status"))
+	require.Equal(t, "done 3", cmdEl.Elements().Child("note").Text())
+
+	// session already completed
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, submit))
+	elem = stm.ReceiveElement()
+	require.NotNil(t, elem.Error().Elements().ChildNamespace("bad-sessionid", CommandsNamespace))
+
+	// canceled session
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, commandRequest("preset", "", "")))
+	elem = stm.ReceiveElement()
+	sessionID = elem.Elements().ChildNamespace("command", CommandsNamespace).Attributes().Get("sessionid")
+
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, commandRequest("preset", sessionID, cancelAction)))
+	elem = stm.ReceiveElement()
+	require.Equal(t, canceledStatus, elem.Elements().ChildNamespace("command", CommandsNamespace).Attributes().Get("status"))
+
+	// expired session
+	x.sessionTimeout = time.Millisecond * 50
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, commandRequest("preset", "", "")))
+	elem = stm.ReceiveElement()
+	sessionID = elem.Elements().ChildNamespace("command", CommandsNamespace).Attributes().Get("sessionid")
+
+	time.Sleep(time.Millisecond * 150)
+
+	submit = commandRequest("preset", sessionID, completeAction)
+	submit.AppendElement((&xep0004.DataForm{Type: xep0004.Submit}).Element())
+	x.ProcessIQ(context.Background(), newCommandIQ(j, srvJID, submit))
+	elem = stm.ReceiveElement()
+	require.NotNil(t, elem.Error().Elements().ChildNamespace("bad-sessionid", CommandsNamespace))
+}
+
+func commandRequest(node, sessionID, action string) *xmpp.Element {
+	cmdEl := xmpp.NewElementNamespace("command", CommandsNamespace)
+	cmdEl.SetAttribute("node", node)
+	if len(sessionID) > 0 {
+		cmdEl.SetAttribute("sessionid", sessionID)
+	}
+	if len(action) > 0 {
+		cmdEl.SetAttribute("action", action)
+	}
+	return cmdEl
+}
+
+func newCommandIQ(from, to *jid.JID, cmdEl xmpp.XElement) *xmpp.IQ {
+	iq := xmpp.NewIQType(uuid.New().String(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	iq.AppendElement(cmdEl)
+	return iq
+}
+
+func setupTest() router.Router {
+	hosts, _ := host.New([]host.Config{{Name: "example.org", Certificate: tls.Certificate{}}})
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r
+}
@@ -0,0 +1,63 @@
+package xep0050
+
+import (
+	"context"
+
+	"github.com/dantin/cubit/module/xep0004"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+)
+
+const (
+	// InfoNote represents an informative command note.
+	InfoNote = "info"
+	// WarnNote represents a warning command note.
+	WarnNote = "warn"
+	// ErrorNote represents an error command note.
+	ErrorNote = "error"
+)
+
+// Command represents an ad-hoc command.
+type Command interface {
+	// Node returns command node identifier.
+	Node() string
+
+	// Name returns command human-readable name.
+	Name() string
+
+	// IsAllowed tells whether or not requester is allowed to discover and execute the command.
+	IsAllowed(ctx context.Context, requester *jid.JID) bool
+
+	// Form returns command input form, or nil if command takes no input.
+	Form(ctx context.Context, requester *jid.JID) *xep0004.DataForm
+
+	// Execute executes command with submitted form fields, invoking done once its outcome is known.
+	// done can be safely invoked from any goroutine.
+	Execute(ctx context.Context, requester *jid.JID, fields xep0004.Fields, done func(*Result, *xmpp.StanzaError))
+}
+
+// Result represents a completed command outcome.
+type Result struct {
+	NoteType string
+	Note     string
+	Form     *xep0004.DataForm
+}
+
+// elements returns command result XMPP representation.
+func (r *Result) elements() []xmpp.XElement {
+	var res []xmpp.XElement
+	if len(r.Note) > 0 {
+		noteEl := xmpp.NewElementName("note")
+		noteType := r.NoteType
+		if len(noteType) == 0 {
+			noteType = InfoNote
+		}
+		noteEl.SetAttribute("type", noteType)
+		noteEl.SetText(r.Note)
+		res = append(res, noteEl)
+	}
+	if r.Form != nil {
+		res = append(res, r.Form.Element())
+	}
+	return res
+}
@@ -0,0 +1,106 @@
+package xep0059
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dantin/cubit/xmpp"
+)
+
+// RSMNamespace specifies XEP-0059 namespace constant value.
+const RSMNamespace = "http://jabber.org/protocol/rsm"
+
+// Request represents a result set management request.
+type Request struct {
+	// Max is the maximum number of items requested, or -1 if not specified.
+	Max int
+
+	// After is the identifier of the item preceding requested page.
+	After string
+
+	// Before is the identifier of the item following requested page.
+	Before string
+
+	// LastPage tells whether or not the last page has been requested (empty 'before' element).
+	LastPage bool
+
+	// Index is the position of the first requested item, or -1 if not specified.
+	Index int
+}
+
+// NewRequestFromElement returns a new result set management request reading it from it's XMPP representation.
+// A nil element yields an unrestricted request.
+func NewRequestFromElement(elem xmpp.XElement) (*Request, error) {
+	req := &Request{Max: -1, Index: -1}
+	if elem == nil {
+		return req, nil
+	}
+	if n := elem.Name(); n != "set" {
+		return nil, fmt.Errorf("invalid set name: %s", n)
+	}
+	if ns := elem.Namespace(); ns != RSMNamespace {
+		return nil, fmt.Errorf("invalid set namespace: %s", ns)
+	}
+	if maxElem := elem.Elements().Child("max"); maxElem != nil {
+		max, err := strconv.Atoi(maxElem.Text())
+		if err != nil || max < 0 {
+			return nil, fmt.Errorf("invalid set max: %s", maxElem.Text())
+		}
+		req.Max = max
+	}
+	if afterElem := elem.Elements().Child("after"); afterElem != nil {
+		if len(afterElem.Text()) == 0 {
+			return nil, fmt.Errorf("empty set after")
+		}
+		req.After = afterElem.Text()
+	}
+	if beforeElem := elem.Elements().Child("before"); beforeElem != nil {
+		req.Before = beforeElem.Text()
+		req.LastPage = len(req.Before) == 0
+	}
+	if indexElem := elem.Elements().Child("index"); indexElem != nil {
+		index, err := strconv.Atoi(indexElem.Text())
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid set index: %s", indexElem.Text())
+		}
+		req.Index = index
+	}
+	if len(req.After) > 0 && (len(req.Before) > 0 || req.LastPage) {
+		return nil, fmt.Errorf("both set after and before specified")
+	}
+	return req, nil
+}
+
+// Result represents a result set management response.
+type Result struct {
+	// First is the identifier of the first item within the returned page.
+	First string
+
+	// Last is the identifier of the last item within the returned page.
+	Last string
+
+	// Index is the position of the first item within the whole result set.
+	Index int
+
+	// Count is the whole result set size.
+	Count int
+}
+
+// Element returns result set XMPP representation.
+func (r *Result) Element() xmpp.XElement {
+	setElem := xmpp.NewElementNamespace("set", RSMNamespace)
+	if len(r.First) > 0 {
+		firstElem := xmpp.NewElementName("first")
+		firstElem.SetAttribute("index", strconv.Itoa(r.Index))
+		firstElem.SetText(r.First)
+		setElem.AppendElement(firstElem)
+
+		lastElem := xmpp.NewElementName("last")
+		lastElem.SetText(r.Last)
+		setElem.AppendElement(lastElem)
+	}
+	countElem := xmpp.NewElementName("count")
+	countElem.SetText(strconv.Itoa(r.Count))
+	setElem.AppendElement(countElem)
+	return setElem
+}
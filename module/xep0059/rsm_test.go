@@ -0,0 +1,72 @@
+package xep0059
+
+import (
+	"testing"
+
+	"github.com/dantin/cubit/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func newSetElement(children ...xmpp.XElement) *xmpp.Element {
+	setElem := xmpp.NewElementNamespace("set", RSMNamespace)
+	setElem.AppendElements(children)
+	return setElem
+}
+
+func newTextElement(name, text string) xmpp.XElement {
+	e := xmpp.NewElementName(name)
+	e.SetText(text)
+	return e
+}
+
+func TestRSM_Request(t *testing.T) {
+	req, err := NewRequestFromElement(nil)
+	require.Nil(t, err)
+	require.Equal(t, &Request{Max: -1, Index: -1}, req)
+
+	_, err = NewRequestFromElement(xmpp.NewElementNamespace("set", "ns"))
+	require.NotNil(t, err)
+	_, err = NewRequestFromElement(xmpp.NewElementNamespace("query", RSMNamespace))
+	require.NotNil(t, err)
+
+	req, err = NewRequestFromElement(newSetElement(newTextElement("max", "10"), newTextElement("after", "3")))
+	require.Nil(t, err)
+	require.Equal(t, &Request{Max: 10, After: "3", Index: -1}, req)
+
+	req, err = NewRequestFromElement(newSetElement(newTextElement("max", "10"), xmpp.NewElementName("before")))
+	require.Nil(t, err)
+	require.Equal(t, &Request{Max: 10, LastPage: true, Index: -1}, req)
+
+	req, err = NewRequestFromElement(newSetElement(newTextElement("before", "5"), newTextElement("index", "2")))
+	require.Nil(t, err)
+	require.Equal(t, &Request{Max: -1, Before: "5", Index: 2}, req)
+
+	_, err = NewRequestFromElement(newSetElement(newTextElement("max", "-1")))
+	require.NotNil(t, err)
+	_, err = NewRequestFromElement(newSetElement(newTextElement("max", "ten")))
+	require.NotNil(t, err)
+	_, err = NewRequestFromElement(newSetElement(xmpp.NewElementName("after")))
+	require.NotNil(t, err)
+	_, err = NewRequestFromElement(newSetElement(newTextElement("index", "x")))
+	require.NotNil(t, err)
+	_, err = NewRequestFromElement(newSetElement(newTextElement("after", "1"), newTextElement("before", "3")))
+	require.NotNil(t, err)
+}
+
+func TestRSM_Result(t *testing.T) {
+	res := Result{First: "3", Last: "5", Index: 2, Count: 10}
+	elem := res.Element()
+	require.Equal(t, "set", elem.Name())
+	require.Equal(t, RSMNamespace, elem.Namespace())
+	require.Equal(t, "3", elem.Elements().Child("first").Text())
+	require.Equal(t, "2", elem.Elements().Child("first").Attributes().Get("index"))
+	require.Equal(t, "5", elem.Elements().Child("last").Text())
+	require.Equal(t, "10", elem.Elements().Child("count").Text())
+
+	// empty page
+	res = Result{Count: 10}
+	elem = res.Element()
+	require.Nil(t, elem.Elements().Child("first"))
+	require.Nil(t, elem.Elements().Child("last"))
+	require.Equal(t, "10", elem.Elements().Child("count").Text())
+}
@@ -3,8 +3,10 @@ package xep0077
 import (
 	"context"
 
+	"github.com/dantin/cubit/audit"
 	"github.com/dantin/cubit/log"
 	"github.com/dantin/cubit/model"
+	auditmodel "github.com/dantin/cubit/model/audit"
 	"github.com/dantin/cubit/module/xep0030"
 	"github.com/dantin/cubit/router"
 	"github.com/dantin/cubit/storage/repository"
@@ -31,15 +33,17 @@ type Register struct {
 	router   router.Router
 	runQueue *runqueue.RunQueue
 	rep      repository.User
+	auditRep repository.Audit
 }
 
 // New returns an in-band registration IQ handler.
-func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User) *Register {
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, auditRep repository.Audit) *Register {
 	r := &Register{
 		cfg:      config,
 		router:   router,
 		runQueue: runqueue.New("xep0077"),
 		rep:      userRep,
+		auditRep: auditRep,
 	}
 	if disco != nil {
 		disco.RegisterServerFeature(registerNamespace)
@@ -162,6 +166,8 @@ func (x *Register) registerNewUser(ctx context.Context, iq *xmpp.IQ, query xmpp.
 		stm.SendElement(ctx, iq.InternalServerError())
 		return
 	}
+	audit.Emit(ctx, x.auditRep, &auditmodel.Event{Actor: user.Username, Action: auditmodel.AccountRegister, Target: user.Username})
+
 	stm.SendElement(ctx, iq.ResultIQ())
 	stm.SetValue(xep077RegisteredCtxKey, true) // mark as registered
 }
@@ -180,6 +186,7 @@ func (x *Register) cancelRegistration(ctx context.Context, iq *xmpp.IQ, query xm
 		stm.SendElement(ctx, iq.InternalServerError())
 		return
 	}
+	audit.Emit(ctx, x.auditRep, &auditmodel.Event{Actor: stm.Username(), Action: auditmodel.AccountDelete, Target: stm.Username()})
 	stm.SendElement(ctx, iq.ResultIQ())
 }
 
@@ -214,6 +221,7 @@ func (x *Register) changePassword(ctx context.Context, password string, username
 			stm.SendElement(ctx, iq.InternalServerError())
 			return
 		}
+		audit.Emit(ctx, x.auditRep, &auditmodel.Event{Actor: username, Action: auditmodel.AccountPassword, Target: username})
 	}
 	stm.SendElement(ctx, iq.ResultIQ())
 }
@@ -22,7 +22,7 @@ func TestModule_XEP0077_Matching(t *testing.T) {
 
 	j, _ := jid.New("user", "example.org", "desktop", true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	// test MatchesIQ
@@ -43,7 +43,7 @@ func TestModule_XEP0077_InvalidToJID(t *testing.T) {
 	stm1 := stream.NewMockC2S(uuid.New().String(), j1)
 	r.Bind(context.Background(), stm1)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New().String(), xmpp.SetType)
@@ -68,7 +68,7 @@ func TestModule_XEP0077_NotAuthenticatedErrors(t *testing.T) {
 	stm := stream.NewMockC2S(uuid.New().String(), j)
 	r.Bind(context.Background(), stm)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New().String(), xmpp.ResultType)
@@ -85,7 +85,7 @@ func TestModule_XEP0077_NotAuthenticatedErrors(t *testing.T) {
 	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
 	// allow registration...
-	x = New(&Config{AllowRegistration: true}, nil, r, s)
+	x = New(&Config{AllowRegistration: true}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	q := xmpp.NewElementNamespace("query", registerNamespace)
@@ -116,7 +116,7 @@ func TestModule_XEP0077_AuthenticatedErrors(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New().String(), xmpp.ResultType)
@@ -144,7 +144,7 @@ func TestModule_XEP0077_RegisterUser(t *testing.T) {
 	stm := stream.NewMockC2S(uuid.New().String(), j)
 	r.Bind(context.Background(), stm)
 
-	x := New(&Config{AllowRegistration: true}, nil, r, s)
+	x := New(&Config{AllowRegistration: true}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New().String(), xmpp.GetType)
@@ -205,7 +205,7 @@ func TestModule_XEP0077_CancelRegistration(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	_ = s.UpsertUser(context.Background(), &model.User{Username: "user", Password: "password"})
@@ -222,7 +222,7 @@ func TestModule_XEP0077_CancelRegistration(t *testing.T) {
 	elem := stm.ReceiveElement()
 	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
-	x = New(&Config{AllowCancel: true}, nil, r, s)
+	x = New(&Config{AllowCancel: true}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	q.AppendElement(xmpp.NewElementName("remove2"))
@@ -258,7 +258,7 @@ func TestModule_XEP0077_ChangePassword(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	_ = s.UpsertUser(context.Background(), &model.User{Username: "user", Password: "password"})
@@ -280,7 +280,7 @@ func TestModule_XEP0077_ChangePassword(t *testing.T) {
 	elem := stm.ReceiveElement()
 	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
-	x = New(&Config{AllowChange: true}, nil, r, s)
+	x = New(&Config{AllowChange: true}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	x.ProcessIQ(context.Background(), iq)
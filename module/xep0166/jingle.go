@@ -0,0 +1,362 @@
+package xep0166
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dantin/cubit/log"
+	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/storage/repository"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+)
+
+const (
+	jingleNamespace        = "urn:xmpp:jingle:1"
+	jingleMessageNamespace = "urn:xmpp:jingle-message:0"
+)
+
+// jingle actions
+const (
+	sessionInitiate  = "session-initiate"
+	sessionAccept    = "session-accept"
+	sessionTerminate = "session-terminate"
+)
+
+// jingle message initiation actions
+const (
+	proposeAction = "propose"
+	retractAction = "retract"
+	proceedAction = "proceed"
+	rejectAction  = "reject"
+)
+
+type sessionState int
+
+const (
+	proposed sessionState = iota
+	pending
+	active
+)
+
+type session struct {
+	sid       string
+	iqID      string   // session-initiate request identifier
+	initiator *jid.JID // full JID
+	responder *jid.JID // bare JID until proposal gets answered
+	roomID    int
+	state     sessionState
+}
+
+// Jingle represents a Jingle session tracking module.
+// Sessions involving a device are only allowed when the other party is a user bound to device room.
+type Jingle struct {
+	router    router.Router
+	roomRep   repository.Room
+	deviceRep repository.Device
+
+	mu       sync.RWMutex
+	sessions map[string]*session // by initiator JID and session identifier
+}
+
+// New returns a Jingle session tracking module.
+func New(router router.Router, roomRep repository.Room, deviceRep repository.Device) *Jingle {
+	return &Jingle{
+		router:    router,
+		roomRep:   roomRep,
+		deviceRep: deviceRep,
+		sessions:  make(map[string]*session),
+	}
+}
+
+// InterceptStanza inspects Jingle session signaling as it gets routed,
+// satisfying router.Interceptor interface.
+func (x *Jingle) InterceptStanza(ctx context.Context, stanza xmpp.Stanza) bool {
+	switch stanza := stanza.(type) {
+	case *xmpp.IQ:
+		return x.interceptIQ(ctx, stanza)
+	case *xmpp.Message:
+		return x.interceptMessage(ctx, stanza)
+	}
+	return true
+}
+
+// Unbound terminates every session a disconnected stream was taking part in, on its behalf.
+func (x *Jingle) Unbound(ctx context.Context, j *jid.JID) {
+	var gone []*session
+
+	x.mu.Lock()
+	for key, s := range x.sessions {
+		if s.initiator.Matches(j) || s.responder.Matches(j) {
+			gone = append(gone, s)
+			delete(x.sessions, key)
+		}
+	}
+	x.mu.Unlock()
+
+	for _, s := range gone {
+		peer := s.responder
+		if s.responder.Matches(j) {
+			if s.state == proposed {
+				continue // remaining resources are still ringing
+			}
+			peer = s.initiator
+		}
+		if s.state == proposed {
+			_ = x.router.Route(ctx, jingleMessage(j, peer, retractAction, s.sid))
+		} else {
+			_ = x.router.Route(ctx, terminateIQ(j, peer, s.sid))
+		}
+		log.Infof("jingle session %s terminated: %s is gone", s.sid, j.String())
+	}
+}
+
+// Shutdown shuts down Jingle module.
+func (x *Jingle) Shutdown() error {
+	return nil
+}
+
+func (x *Jingle) interceptIQ(ctx context.Context, iq *xmpp.IQ) bool {
+	if iq.IsError() {
+		// session initiation failed
+		x.mu.Lock()
+		for key, s := range x.sessions {
+			if s.state == pending && s.iqID == iq.ID() && s.initiator.Matches(iq.ToJID()) {
+				delete(x.sessions, key)
+			}
+		}
+		x.mu.Unlock()
+		return true
+	}
+	j := iq.Elements().ChildNamespace("jingle", jingleNamespace)
+	if j == nil || !iq.IsSet() {
+		return true
+	}
+	attrs := j.Attributes()
+	sid := attrs.Get("sid")
+	if len(sid) == 0 {
+		return true
+	}
+	fromJID, toJID := iq.FromJID(), iq.ToJID()
+
+	switch attrs.Get("action") {
+	case sessionInitiate:
+		key := sessionKey(fromJID, sid)
+
+		var roomID int
+		var wasProposed bool
+
+		x.mu.RLock()
+		if s := x.sessions[key]; s != nil && s.state == proposed && s.responder.Matches(toJID) {
+			roomID, wasProposed = s.roomID, true
+		}
+		x.mu.RUnlock()
+
+		if !wasProposed {
+			var tracked, allowed bool
+			var err error
+			roomID, tracked, allowed, err = x.authorize(ctx, fromJID, toJID)
+			if err != nil {
+				log.Error(err)
+				_ = x.router.Route(ctx, iq.InternalServerError())
+				return false
+			}
+			if !allowed {
+				_ = x.router.Route(ctx, iq.ForbiddenError())
+				return false
+			}
+			if !tracked {
+				return true
+			}
+		}
+		if x.isUnreachable(toJID) {
+			return true // delivery will fail
+		}
+		x.mu.Lock()
+		x.sessions[key] = &session{sid: sid, iqID: iq.ID(), initiator: fromJID, responder: toJID, roomID: roomID, state: pending}
+		x.mu.Unlock()
+		log.Infof("jingle session %s initiated: %s -> %s", sid, fromJID.String(), toJID.String())
+
+	case sessionAccept:
+		x.mu.Lock()
+		if s := x.sessions[sessionKey(toJID, sid)]; s != nil && s.responder.Matches(fromJID) {
+			s.state = active
+			log.Infof("jingle session %s accepted by %s", sid, fromJID.String())
+		}
+		x.mu.Unlock()
+
+	case sessionTerminate:
+		x.mu.Lock()
+		for _, key := range []string{sessionKey(fromJID, sid), sessionKey(toJID, sid)} {
+			if _, ok := x.sessions[key]; ok {
+				delete(x.sessions, key)
+				log.Infof("jingle session %s terminated by %s", sid, fromJID.String())
+			}
+		}
+		x.mu.Unlock()
+	}
+	return true
+}
+
+// interceptMessage tracks Jingle Message Initiation (XEP-0353) proposals,
+// making sure proposals and retractions addressed to a bare JID ring every available resource.
+func (x *Jingle) interceptMessage(ctx context.Context, message *xmpp.Message) bool {
+	var el xmpp.XElement
+	for _, e := range message.Elements().All() {
+		if e.Namespace() == jingleMessageNamespace {
+			el = e
+			break
+		}
+	}
+	if el == nil || message.IsError() {
+		return true
+	}
+	sid := el.Attributes().Get("id")
+	if len(sid) == 0 {
+		return true
+	}
+	fromJID, toJID := message.FromJID(), message.ToJID()
+
+	switch el.Name() {
+	case proposeAction:
+		key := sessionKey(fromJID, sid)
+
+		x.mu.RLock()
+		_, ok := x.sessions[key]
+		x.mu.RUnlock()
+		if ok {
+			break // already tracked proposal
+		}
+		roomID, tracked, allowed, err := x.authorize(ctx, fromJID, toJID)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, message.InternalServerError())
+			return false
+		}
+		if !allowed {
+			_ = x.router.Route(ctx, message.ForbiddenError())
+			return false
+		}
+		if tracked {
+			x.mu.Lock()
+			x.sessions[key] = &session{sid: sid, initiator: fromJID, responder: toJID.ToBareJID(), roomID: roomID, state: proposed}
+			x.mu.Unlock()
+			log.Infof("jingle session %s proposed: %s -> %s", sid, fromJID.String(), toJID.String())
+		}
+
+	case retractAction:
+		x.mu.Lock()
+		delete(x.sessions, sessionKey(fromJID, sid))
+		x.mu.Unlock()
+
+	case proceedAction:
+		x.mu.Lock()
+		if s := x.sessions[sessionKey(toJID, sid)]; s != nil && s.state == proposed && s.responder.MatchesWithOptions(fromJID, jid.MatchesBare) {
+			s.responder = fromJID
+		}
+		x.mu.Unlock()
+
+	case rejectAction:
+		x.mu.Lock()
+		if s := x.sessions[sessionKey(toJID, sid)]; s != nil && s.state == proposed && s.responder.MatchesWithOptions(fromJID, jid.MatchesBare) {
+			delete(x.sessions, sessionKey(toJID, sid))
+		}
+		x.mu.Unlock()
+
+	default:
+		return true
+	}
+	if el.Name() == proposeAction || el.Name() == retractAction {
+		return !x.ring(ctx, message)
+	}
+	return true
+}
+
+// ring delivers a message addressed to a local bare JID to every available resource.
+// Returns false if there's no available resource, thus being handled by regular routing.
+func (x *Jingle) ring(ctx context.Context, message *xmpp.Message) bool {
+	toJID := message.ToJID()
+	if toJID.IsFull() || !x.router.Hosts().IsLocalHost(toJID.Domain()) {
+		return false
+	}
+	var rung bool
+	for _, stm := range x.router.LocalStreams(toJID.Node()) {
+		if p := stm.Presence(); p == nil || !p.IsAvailable() {
+			continue
+		}
+		msg, _ := xmpp.NewMessageFromElement(message, message.FromJID(), stm.JID())
+		if err := x.router.Route(ctx, msg); err == nil {
+			rung = true
+		}
+	}
+	return rung
+}
+
+// authorize tells whether or not a session between two parties is allowed.
+// Only sessions involving a device get tracked, being allowed if the other party is bound to device room.
+func (x *Jingle) authorize(ctx context.Context, initiator, responder *jid.JID) (roomID int, tracked bool, allowed bool, err error) {
+	for _, parties := range [][2]*jid.JID{{responder, initiator}, {initiator, responder}} {
+		deviceJID, peerJID := parties[0], parties[1]
+		if !x.router.Hosts().IsLocalHost(deviceJID.Domain()) || len(deviceJID.Node()) == 0 {
+			continue
+		}
+		device, err := x.deviceRep.FetchDeviceByUsername(ctx, deviceJID.Node())
+		if err != nil {
+			return 0, false, false, err
+		}
+		if device == nil {
+			continue
+		}
+		if device.RoomID == 0 || !x.router.Hosts().IsLocalHost(peerJID.Domain()) {
+			return 0, true, false, nil
+		}
+		usernames, err := x.roomRep.FetchBoundUsers(ctx, device.RoomID)
+		if err != nil {
+			return 0, false, false, err
+		}
+		for _, username := range usernames {
+			if username == peerJID.Node() {
+				return device.RoomID, true, true, nil
+			}
+		}
+		return 0, true, false, nil
+	}
+	return 0, false, true, nil
+}
+
+func (x *Jingle) isUnreachable(j *jid.JID) bool {
+	if !j.IsFullWithUser() || !x.router.Hosts().IsLocalHost(j.Domain()) {
+		return false
+	}
+	return x.router.LocalStream(j.Node(), j.Resource()) == nil
+}
+
+func sessionKey(initiator *jid.JID, sid string) string {
+	return initiator.String() + "#" + sid
+}
+
+func terminateIQ(from, to *jid.JID, sid string) *xmpp.IQ {
+	iq := xmpp.NewIQType(uuid.New().String(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+
+	j := xmpp.NewElementNamespace("jingle", jingleNamespace)
+	j.SetAttribute("action", sessionTerminate)
+	j.SetAttribute("sid", sid)
+	reason := xmpp.NewElementName("reason")
+	reason.AppendElement(xmpp.NewElementName("gone"))
+	j.AppendElement(reason)
+	iq.AppendElement(j)
+	return iq
+}
+
+func jingleMessage(from, to *jid.JID, action, sid string) *xmpp.Message {
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	el := xmpp.NewElementNamespace(action, jingleMessageNamespace)
+	el.SetAttribute("id", sid)
+	msg.AppendElement(el)
+	return msg
+}
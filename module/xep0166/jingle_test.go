@@ -0,0 +1,219 @@
+package xep0166
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	c2srouter "github.com/dantin/cubit/c2s/router"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/router/host"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_XEP0166_Authorization(t *testing.T) {
+	r, x := setupTest()
+
+	aliceJID, _ := bindUser(r, "alice", "desktop")
+	bobJID, bobStm := bindUser(r, "bob", "desktop")
+	devJID, devStm := bindUser(r, "device-sn0001", "camera")
+
+	// not bound to device room
+	_ = r.Route(context.Background(), jingleIQ(bobJID, devJID, sessionInitiate, "s1"))
+	elem := bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+	require.Len(t, x.sessions, 0)
+
+	// device initiating a session towards a non bound user
+	_ = r.Route(context.Background(), jingleIQ(devJID, bobJID, sessionInitiate, "s2"))
+	elem = devStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// sessions not involving a device are neither checked nor tracked
+	_ = r.Route(context.Background(), jingleIQ(bobJID, aliceJID, sessionInitiate, "s3"))
+	require.Len(t, x.sessions, 0)
+}
+
+func TestModule_XEP0166_SessionLifecycle(t *testing.T) {
+	r, x := setupTest()
+
+	aliceJID, aliceStm := bindUser(r, "alice", "desktop")
+	devJID, devStm := bindUser(r, "device-sn0001", "camera")
+
+	initiate := jingleIQ(aliceJID, devJID, sessionInitiate, "s1")
+	_ = r.Route(context.Background(), initiate)
+	elem := devStm.ReceiveElement()
+	require.Equal(t, initiate.ID(), elem.ID())
+
+	s := x.sessions[sessionKey(aliceJID, "s1")]
+	require.NotNil(t, s)
+	require.Equal(t, pending, s.state)
+	require.Equal(t, 1, s.roomID)
+
+	_ = r.Route(context.Background(), jingleIQ(devJID, aliceJID, sessionAccept, "s1"))
+	_ = aliceStm.ReceiveElement()
+	require.Equal(t, active, x.sessions[sessionKey(aliceJID, "s1")].state)
+
+	_ = r.Route(context.Background(), jingleIQ(devJID, aliceJID, sessionTerminate, "s1"))
+	_ = aliceStm.ReceiveElement()
+	require.Len(t, x.sessions, 0)
+
+	// responder rejecting session initiation
+	initiate = jingleIQ(aliceJID, devJID, sessionInitiate, "s2")
+	_ = r.Route(context.Background(), initiate)
+	_ = devStm.ReceiveElement()
+	require.Len(t, x.sessions, 1)
+
+	errIQ := xmpp.NewIQType(initiate.ID(), xmpp.ErrorType)
+	errIQ.SetFromJID(devJID)
+	errIQ.SetToJID(aliceJID)
+	_ = r.Route(context.Background(), errIQ)
+	_ = aliceStm.ReceiveElement()
+	require.Len(t, x.sessions, 0)
+}
+
+func TestModule_XEP0166_Unbound(t *testing.T) {
+	r, x := setupTest()
+
+	aliceJID, aliceStm := bindUser(r, "alice", "desktop")
+	devJID, devStm := bindUser(r, "device-sn0001", "camera")
+
+	_ = r.Route(context.Background(), jingleIQ(aliceJID, devJID, sessionInitiate, "s1"))
+	_ = devStm.ReceiveElement()
+	_ = r.Route(context.Background(), jingleIQ(devJID, aliceJID, sessionAccept, "s1"))
+	_ = aliceStm.ReceiveElement()
+
+	// device goes away
+	x.Unbound(context.Background(), devJID)
+	require.Len(t, x.sessions, 0)
+
+	elem := aliceStm.ReceiveElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, xmpp.SetType, elem.Type())
+	j := elem.Elements().ChildNamespace("jingle", jingleNamespace)
+	require.NotNil(t, j)
+	require.Equal(t, sessionTerminate, j.Attributes().Get("action"))
+	require.Equal(t, "s1", j.Attributes().Get("sid"))
+	require.NotNil(t, j.Elements().Child("reason").Elements().Child("gone"))
+}
+
+func TestModule_XEP0166_Propose(t *testing.T) {
+	r, x := setupTest()
+
+	aliceJID, aliceStm := bindUser(r, "alice", "desktop")
+	_, aliceMobileStm := bindUser(r, "alice", "mobile")
+	devJID, devStm := bindUser(r, "device-sn0001", "camera")
+
+	// every available resource rings
+	aliceBareJID := aliceJID.ToBareJID()
+	_ = r.Route(context.Background(), jingleMessage(devJID, aliceBareJID, proposeAction, "s1"))
+
+	for _, stm := range []*stream.MockC2S{aliceStm, aliceMobileStm} {
+		elem := stm.ReceiveElement()
+		require.Equal(t, "message", elem.Name())
+		require.NotNil(t, elem.Elements().ChildNamespace(proposeAction, jingleMessageNamespace))
+	}
+	s := x.sessions[sessionKey(devJID, "s1")]
+	require.NotNil(t, s)
+	require.Equal(t, proposed, s.state)
+
+	// answered from a concrete resource
+	_ = r.Route(context.Background(), jingleMessage(aliceJID, devJID, proceedAction, "s1"))
+	_ = devStm.ReceiveElement()
+	require.Equal(t, aliceJID.String(), x.sessions[sessionKey(devJID, "s1")].responder.String())
+
+	_ = r.Route(context.Background(), jingleIQ(devJID, aliceJID, sessionInitiate, "s1"))
+	_ = aliceStm.ReceiveElement()
+	require.Equal(t, pending, x.sessions[sessionKey(devJID, "s1")].state)
+
+	// pending proposal
+	_ = r.Route(context.Background(), jingleMessage(devJID, aliceBareJID, proposeAction, "s2"))
+	_ = aliceStm.ReceiveElement()
+	_ = aliceMobileStm.ReceiveElement()
+	require.Len(t, x.sessions, 2)
+
+	x.Unbound(context.Background(), devJID)
+	require.Len(t, x.sessions, 0)
+
+	// proposals retracted, established sessions terminated
+	var retracted, terminated int
+	for i := 0; i < 3; i++ {
+		var elem xmpp.XElement
+		if i < 2 {
+			elem = aliceStm.ReceiveElement()
+		} else {
+			elem = aliceMobileStm.ReceiveElement()
+		}
+		switch {
+		case elem.Elements().ChildNamespace(retractAction, jingleMessageNamespace) != nil:
+			retracted++
+		case elem.Elements().ChildNamespace("jingle", jingleNamespace) != nil:
+			terminated++
+		}
+	}
+	require.Equal(t, 2, retracted)
+	require.Equal(t, 1, terminated)
+
+	// forbidden proposal
+	bobJID, bobStm := bindUser(r, "bob", "desktop")
+	_ = r.Route(context.Background(), jingleMessage(bobJID, devJID, proposeAction, "s3"))
+	elem := bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+	require.Len(t, x.sessions, 0)
+
+	// session initiated towards a party other than the proposal responder
+	_ = r.Route(context.Background(), jingleMessage(devJID, aliceBareJID, proposeAction, "s4"))
+	_ = aliceStm.ReceiveElement()
+	_ = aliceMobileStm.ReceiveElement()
+
+	_ = r.Route(context.Background(), jingleIQ(devJID, bobJID, sessionInitiate, "s4"))
+	elem = devStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+	require.Equal(t, proposed, x.sessions[sessionKey(devJID, "s4")].state)
+}
+
+func jingleIQ(from, to *jid.JID, action, sid string) *xmpp.IQ {
+	iq := xmpp.NewIQType(uuid.New().String(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+
+	j := xmpp.NewElementNamespace("jingle", jingleNamespace)
+	j.SetAttribute("action", action)
+	j.SetAttribute("sid", sid)
+	iq.AppendElement(j)
+	return iq
+}
+
+func bindUser(r router.Router, username, resource string) (*jid.JID, *stream.MockC2S) {
+	j, _ := jid.New(username, "example.org", resource, true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+	return j, stm
+}
+
+func setupTest() (router.Router, *Jingle) {
+	hosts, _ := host.New([]host.Config{{Name: "example.org", Certificate: tls.Certificate{}}})
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		nil,
+	)
+	roomRep := memorystorage.NewRoom()
+	deviceRep := memorystorage.NewDevice()
+
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.BindRoom(context.Background(), 1, "alice")
+	_ = deviceRep.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN0001", Type: roomsmodel.Camera, RoomID: 1, Username: "device-sn0001"})
+
+	x := New(r, roomRep, deviceRep)
+	r.RegisterInterceptor(x)
+	return r, x
+}
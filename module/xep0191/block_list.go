@@ -3,8 +3,10 @@ package xep0191
 import (
 	"context"
 
+	"github.com/dantin/cubit/audit"
 	"github.com/dantin/cubit/log"
 	"github.com/dantin/cubit/model"
+	auditmodel "github.com/dantin/cubit/model/audit"
 	rostermodel "github.com/dantin/cubit/model/roster"
 	"github.com/dantin/cubit/module/xep0030"
 	"github.com/dantin/cubit/module/xep0115"
@@ -29,16 +31,18 @@ type BlockingCommand struct {
 	router       router.Router
 	blockListRep repository.BlockList
 	rosterRep    repository.Roster
+	auditRep     repository.Audit
 	entityCaps   *xep0115.EntityCaps
 }
 
 // New returns a blocking command IQ handler module.
-func New(disco *xep0030.DiscoInfo, entityCaps *xep0115.EntityCaps, router router.Router, rosterRep repository.Roster, blockListRep repository.BlockList) *BlockingCommand {
+func New(disco *xep0030.DiscoInfo, entityCaps *xep0115.EntityCaps, router router.Router, rosterRep repository.Roster, blockListRep repository.BlockList, auditRep repository.Audit) *BlockingCommand {
 	b := &BlockingCommand{
 		runQueue:     runqueue.New("xep0191"),
 		router:       router,
 		blockListRep: blockListRep,
 		rosterRep:    rosterRep,
+		auditRep:     auditRep,
 		entityCaps:   entityCaps,
 	}
 	if disco != nil {
@@ -142,6 +146,7 @@ func (x *BlockingCommand) block(ctx context.Context, iq *xmpp.IQ, block xmpp.XEl
 			stm.SendElement(ctx, iq.InternalServerError())
 			return
 		}
+		audit.Emit(ctx, x.auditRep, &auditmodel.Event{Actor: username, Action: auditmodel.BlockListBlock, Target: j.String()})
 		x.broadcastPresenceMatchingJID(ctx, j, ris, xmpp.UnavailableType, stm)
 	}
 
@@ -178,6 +183,7 @@ func (x *BlockingCommand) unblock(ctx context.Context, iq *xmpp.IQ, unblock xmpp
 				stm.SendElement(ctx, iq.InternalServerError())
 				return
 			}
+			audit.Emit(ctx, x.auditRep, &auditmodel.Event{Actor: username, Action: auditmodel.BlockListUnblock, Target: j.String()})
 			x.broadcastPresenceMatchingJID(ctx, j, ris, xmpp.AvailableType, stm)
 		}
 	} else { // remove all block list items
@@ -187,6 +193,7 @@ func (x *BlockingCommand) unblock(ctx context.Context, iq *xmpp.IQ, unblock xmpp
 				stm.SendElement(ctx, iq.InternalServerError())
 				return
 			}
+			audit.Emit(ctx, x.auditRep, &auditmodel.Event{Actor: username, Action: auditmodel.BlockListUnblock, Target: blItem.JID})
 			j, _ := jid.NewWithString(blItem.JID, true)
 
 			x.broadcastPresenceMatchingJID(ctx, j, ris, xmpp.AvailableType, stm)
@@ -32,7 +32,7 @@ func TestModule_XEP0191_Matching(t *testing.T) {
 	ph := xep0115.New(r, presencesRep, "seq-123")
 	defer func() { _ = ph.Shutdown() }()
 
-	x := New(nil, ph, r, rosterRep, blockListRep)
+	x := New(nil, ph, r, rosterRep, blockListRep, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	// test MatchesIQ
@@ -66,7 +66,7 @@ func TestModule_XEP0191_GetBlockList(t *testing.T) {
 	ph := xep0115.New(r, presencesRep, "seq-123")
 	defer func() { _ = ph.Shutdown() }()
 
-	x := New(nil, ph, r, rosterRep, blockListRep)
+	x := New(nil, ph, r, rosterRep, blockListRep, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	_ = blockListRep.InsertBlockListItem(context.Background(), &model.BlockListItem{
@@ -106,7 +106,7 @@ func TestModule_XEP191_BlockAndUnblock(t *testing.T) {
 	caps := xep0115.New(r, presencesRep, "seq-123")
 	defer func() { _ = caps.Shutdown() }()
 
-	x := New(nil, caps, r, rosterRep, blockListRep)
+	x := New(nil, caps, r, rosterRep, blockListRep, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	j1, _ := jid.New("alice", "example.org", "desktop", true)
@@ -46,6 +46,11 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// HeartbeatHandler is notified every time a bound stream shows signs of life.
+type HeartbeatHandler interface {
+	Heartbeat(stm stream.C2S)
+}
+
 type ping struct {
 	identifier string
 	timer      *time.Timer
@@ -59,6 +64,7 @@ type Ping struct {
 	pings         map[string]*ping
 	activePingsMu sync.RWMutex
 	activePings   map[string]*ping
+	handlers      []HeartbeatHandler
 	runQueue      *runqueue.RunQueue
 }
 
@@ -96,7 +102,15 @@ func (x *Ping) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
 
 // SchedulePing schedules a new ping in a 'send interval' period, cancelling previous scheduled ping.
 func (x *Ping) SchedulePing(stm stream.C2S) {
-	x.runQueue.Run(func() { x.schedulePing(stm) })
+	x.runQueue.Run(func() {
+		x.schedulePing(stm)
+		x.heartbeat(stm)
+	})
+}
+
+// RegisterHeartbeatHandler registers a new stream heartbeat handler.
+func (x *Ping) RegisterHeartbeatHandler(h HeartbeatHandler) {
+	x.runQueue.Run(func() { x.handlers = append(x.handlers, h) })
 }
 
 // CancelPing cancels a previous scheduled ping.
@@ -158,6 +172,12 @@ func (x *Ping) schedulePing(stm stream.C2S) {
 	x.schedulePingTimer(stm)
 }
 
+func (x *Ping) heartbeat(stm stream.C2S) {
+	for _, h := range x.handlers {
+		h.Heartbeat(stm)
+	}
+}
+
 func (x *Ping) cancelPing(stm stream.C2S) {
 	if !x.cfg.Send || !stm.JID().IsFull() {
 		return
@@ -192,6 +212,7 @@ func (x *Ping) handlePongIQ(iq *xmpp.IQ, stm stream.C2S) {
 
 		pi.timer.Stop()
 		x.schedulePingTimer(stm)
+		x.heartbeat(stm)
 	}
 }
 
@@ -137,6 +137,42 @@ func TestModule_XEP0199_Disconnect(t *testing.T) {
 
 }
 
+type heartbeatHandler struct {
+	ch chan string
+}
+
+func (h *heartbeatHandler) Heartbeat(stm stream.C2S) { h.ch <- stm.JID().String() }
+
+func TestModule_XEP0199_Heartbeat(t *testing.T) {
+	r := setupTest()
+
+	j1, _ := jid.New("alice", "example.org", "desktop", true)
+	j2, _ := jid.New("", "example.org", "", true)
+
+	stm := stream.NewMockC2S(uuid.New().String(), j1)
+	r.Bind(context.Background(), stm)
+
+	x := New(&Config{Send: true, SendInterval: time.Second}, nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	h := &heartbeatHandler{ch: make(chan string, 2)}
+	x.RegisterHeartbeatHandler(h)
+
+	// stream activity
+	x.SchedulePing(stm)
+	require.Equal(t, j1.String(), <-h.ch)
+
+	elem := stm.ReceiveElement()
+	require.NotNil(t, elem.Elements().ChildNamespace("ping", pingNamespace))
+
+	// pong
+	pong := xmpp.NewIQType(elem.ID(), xmpp.ResultType)
+	pong.SetFromJID(j1)
+	pong.SetToJID(j2)
+	x.ProcessIQ(context.Background(), pong)
+	require.Equal(t, j1.String(), <-h.ch)
+}
+
 func setupTest() router.Router {
 	hosts, _ := host.New([]host.Config{{Name: "example.org", Certificate: tls.Certificate{}}})
 	r, _ := router.New(
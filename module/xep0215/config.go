@@ -0,0 +1,82 @@
+package xep0215
+
+import (
+	"fmt"
+	"time"
+)
+
+const defaultCredentialsTTL = time.Hour
+
+// Service represents an external STUN/TURN service advertised to clients.
+type Service struct {
+	Type       string `yaml:"type"`
+	Host       string `yaml:"host"`
+	Port       int    `yaml:"port"`
+	Transport  string `yaml:"transport"`
+	Name       string `yaml:"name"`
+	Restricted bool   `yaml:"restricted"`
+}
+
+// Config represents External Service Discovery module (XEP-0215) configuration.
+type Config struct {
+	// Secret is the shared secret TURN REST credentials are signed with.
+	Secret string
+
+	// CredentialsTTL is the lifetime of issued credentials.
+	CredentialsTTL time.Duration
+
+	// Services contains every advertised service.
+	// Restricted services require credentials issued by the server.
+	Services []Service
+}
+
+type configProxy struct {
+	Secret         string    `yaml:"secret"`
+	CredentialsTTL int       `yaml:"credentials_ttl"`
+	Services       []Service `yaml:"services"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if p.CredentialsTTL < 0 {
+		return fmt.Errorf("xep0215.Config: credentials ttl must be 0 or higher")
+	}
+	for _, s := range p.Services {
+		switch s.Type {
+		case "stun", "stuns", "turn", "turns":
+			break
+		default:
+			return fmt.Errorf("xep0215.Config: unrecognized service type: %s", s.Type)
+		}
+		if len(s.Host) == 0 {
+			return fmt.Errorf("xep0215.Config: service host must be specified")
+		}
+		if s.Port < 0 || s.Port > 65535 {
+			return fmt.Errorf("xep0215.Config: invalid service port: %d", s.Port)
+		}
+		switch s.Transport {
+		case "", "udp", "tcp":
+			break
+		default:
+			return fmt.Errorf("xep0215.Config: unrecognized service transport: %s", s.Transport)
+		}
+		if s.Restricted && len(p.Secret) == 0 {
+			return fmt.Errorf("xep0215.Config: secret must be specified for restricted services")
+		}
+	}
+	cfg.Secret = p.Secret
+	cfg.CredentialsTTL = time.Second * time.Duration(p.CredentialsTTL)
+	cfg.Services = p.Services
+	return nil
+}
+
+func (cfg *Config) credentialsTTL() time.Duration {
+	if cfg.CredentialsTTL == 0 {
+		return defaultCredentialsTTL
+	}
+	return cfg.CredentialsTTL
+}
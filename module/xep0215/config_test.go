@@ -0,0 +1,45 @@
+package xep0215
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestModule_XEP0215_Config(t *testing.T) {
+	cfg := &Config{}
+	err := yaml.Unmarshal([]byte(`credentials_ttl: -1`), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("services:\n  - type: ftp\n    host: ftp.example.org"), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("services:\n  - type: turn\n    host: turn.example.org\n    port: 70000"), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("services:\n  - type: turn\n    host: turn.example.org\n    transport: sctp"), &cfg)
+	require.NotNil(t, err)
+
+	// restricted services require a secret
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("services:\n  - type: turn\n    host: turn.example.org\n    restricted: true"), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("secret: s3cr3t\nservices:\n  - type: turn\n    host: turn.example.org\n    port: 3478\n    transport: udp\n    restricted: true"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "s3cr3t", cfg.Secret)
+	require.Equal(t, defaultCredentialsTTL, cfg.credentialsTTL())
+	require.Len(t, cfg.Services, 1)
+	require.Equal(t, Service{Type: "turn", Host: "turn.example.org", Port: 3478, Transport: "udp", Restricted: true}, cfg.Services[0])
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`credentials_ttl: 600`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, time.Minute*10, cfg.credentialsTTL())
+}
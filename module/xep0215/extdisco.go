@@ -0,0 +1,190 @@
+package xep0215
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/dantin/cubit/authz"
+	"github.com/dantin/cubit/log"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	"github.com/dantin/cubit/module/xep0030"
+	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/storage/repository"
+	"github.com/dantin/cubit/util/runqueue"
+	"github.com/dantin/cubit/xmpp"
+)
+
+const extDiscoNamespace = "urn:xmpp:extdisco:2"
+
+const timestampLayout = "2006-01-02T15:04:05Z"
+
+// ExternalServices represents an external service discovery module.
+type ExternalServices struct {
+	cfg      *Config
+	router   router.Router
+	roomRep  repository.Room
+	authz    *authz.Authorizer
+	runQueue *runqueue.RunQueue
+}
+
+// New returns an external service discovery IQ handler module.
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, roomRep repository.Room, roleRep repository.Role) *ExternalServices {
+	x := &ExternalServices{
+		cfg:      config,
+		router:   router,
+		roomRep:  roomRep,
+		authz:    authz.New(userRep, roleRep),
+		runQueue: runqueue.New("xep0215"),
+	}
+	if disco != nil {
+		disco.RegisterServerFeature(extDiscoNamespace)
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be processed by the external service discovery module.
+func (x *ExternalServices) MatchesIQ(iq *xmpp.IQ) bool {
+	e := iq.Elements()
+	return iq.IsGet() && iq.ToJID().IsServer() &&
+		(e.ChildNamespace("services", extDiscoNamespace) != nil || e.ChildNamespace("credentials", extDiscoNamespace) != nil)
+}
+
+// ProcessIQ processes an external service discovery IQ taking according actions over the associated stream.
+func (x *ExternalServices) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
+	x.runQueue.Run(func() {
+		x.processIQ(ctx, iq)
+	})
+}
+
+// Shutdown shuts down external service discovery module.
+func (x *ExternalServices) Shutdown() error {
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return nil
+}
+
+func (x *ExternalServices) processIQ(ctx context.Context, iq *xmpp.IQ) {
+	fromJID := iq.FromJID()
+	if !x.router.Hosts().IsLocalHost(fromJID.Domain()) {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	allowed, err := x.isAllowed(ctx, fromJID.Node())
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	if !allowed {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	e := iq.Elements()
+	if services := e.ChildNamespace("services", extDiscoNamespace); services != nil {
+		x.sendServices(ctx, iq, services.Attributes().Get("type"))
+		return
+	}
+	x.sendCredentials(ctx, iq, e.ChildNamespace("credentials", extDiscoNamespace))
+}
+
+// sendServices sends every configured service of a given type, or all of them if no type is specified.
+// Restricted services are sent along with a set of freshly issued credentials.
+func (x *ExternalServices) sendServices(ctx context.Context, iq *xmpp.IQ, tp string) {
+	username := iq.FromJID().Node()
+	expires := time.Now().Add(x.cfg.credentialsTTL())
+
+	services := xmpp.NewElementNamespace("services", extDiscoNamespace)
+	if len(tp) > 0 {
+		services.SetAttribute("type", tp)
+	}
+	for _, s := range x.cfg.Services {
+		if len(tp) > 0 && s.Type != tp {
+			continue
+		}
+		services.AppendElement(x.serviceElement(&s, username, expires))
+	}
+	result := iq.ResultIQ()
+	result.AppendElement(services)
+	_ = x.router.Route(ctx, result)
+}
+
+// sendCredentials sends a set of freshly issued credentials for a restricted service.
+func (x *ExternalServices) sendCredentials(ctx context.Context, iq *xmpp.IQ, credentials xmpp.XElement) {
+	serviceEl := credentials.Elements().Child("service")
+	if serviceEl == nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	attrs := serviceEl.Attributes()
+	host, tp := attrs.Get("host"), attrs.Get("type")
+	if len(host) == 0 || len(tp) == 0 {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	port, _ := strconv.Atoi(attrs.Get("port"))
+
+	for _, s := range x.cfg.Services {
+		if !s.Restricted || s.Host != host || s.Type != tp || (port > 0 && s.Port != port) {
+			continue
+		}
+		credentialsEl := xmpp.NewElementNamespace("credentials", extDiscoNamespace)
+		credentialsEl.AppendElement(x.serviceElement(&s, iq.FromJID().Node(), time.Now().Add(x.cfg.credentialsTTL())))
+
+		result := iq.ResultIQ()
+		result.AppendElement(credentialsEl)
+		_ = x.router.Route(ctx, result)
+		return
+	}
+	_ = x.router.Route(ctx, iq.ItemNotFoundError())
+}
+
+func (x *ExternalServices) serviceElement(s *Service, username string, expires time.Time) xmpp.XElement {
+	el := xmpp.NewElementName("service")
+	el.SetAttribute("type", s.Type)
+	el.SetAttribute("host", s.Host)
+	if s.Port > 0 {
+		el.SetAttribute("port", strconv.Itoa(s.Port))
+	}
+	if len(s.Transport) > 0 {
+		el.SetAttribute("transport", s.Transport)
+	}
+	if len(s.Name) > 0 {
+		el.SetAttribute("name", s.Name)
+	}
+	if s.Restricted {
+		user, password := x.credentials(username, expires)
+		el.SetAttribute("restricted", "true")
+		el.SetAttribute("username", user)
+		el.SetAttribute("password", password)
+		el.SetAttribute("expires", expires.UTC().Format(timestampLayout))
+	}
+	return el
+}
+
+// credentials issues a TURN REST credentials pair, that is, an expiration prefixed username
+// and its base64 encoded HMAC-SHA1 signature keyed by the shared secret.
+func (x *ExternalServices) credentials(username string, expires time.Time) (string, string) {
+	user := strconv.FormatInt(expires.Unix(), 10) + ":" + username
+
+	h := hmac.New(sha1.New, []byte(x.cfg.Secret))
+	_, _ = h.Write([]byte(user))
+	return user, base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// isAllowed tells whether or not a user is allowed to view at least one room,
+// that is, is bound to any room or has been granted permission to list every room.
+func (x *ExternalServices) isAllowed(ctx context.Context, username string) (bool, error) {
+	rooms, err := x.roomRep.FetchBoundRooms(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	if len(rooms) > 0 {
+		return true, nil
+	}
+	return x.authz.IsAllowed(ctx, username, rolesmodel.RoomsList)
+}
@@ -0,0 +1,209 @@
+package xep0215
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	c2srouter "github.com/dantin/cubit/c2s/router"
+	"github.com/dantin/cubit/model"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/router/host"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_XEP0215_Matching(t *testing.T) {
+	x := New(&Config{}, nil, setupTest(), memorystorage.NewUser(), memorystorage.NewRoom(), memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	j, _ := jid.New("alice", "example.org", "desktop", true)
+
+	iq := newGetIQ(j, xmpp.NewElementNamespace("services", extDiscoNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq = newGetIQ(j, xmpp.NewElementNamespace("credentials", extDiscoNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq.SetType(xmpp.SetType)
+	require.False(t, x.MatchesIQ(iq))
+
+	iq = newGetIQ(j, xmpp.NewElementNamespace("services", "urn:xmpp:extdisco:1"))
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestModule_XEP0215_Services(t *testing.T) {
+	r := setupTest()
+	userRep := memorystorage.NewUser()
+	roomRep := memorystorage.NewRoom()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "bob", Role: model.Usr})
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.BindRoom(context.Background(), 1, "alice")
+
+	cfg := &Config{
+		Secret:         "s3cr3t",
+		CredentialsTTL: time.Minute * 10,
+		Services: []Service{
+			{Type: "stun", Host: "stun.example.org", Port: 3478, Transport: "udp"},
+			{Type: "turn", Host: "turn.example.org", Port: 3478, Transport: "udp", Restricted: true},
+		},
+	}
+	x := New(cfg, nil, r, userRep, roomRep, memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+	bobJID, bobStm := bindUser(r, "bob")
+
+	// not allowed to view any room
+	x.ProcessIQ(context.Background(), newGetIQ(bobJID, xmpp.NewElementNamespace("services", extDiscoNamespace)))
+	elem := bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, xmpp.NewElementNamespace("services", extDiscoNamespace)))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	services := elem.Elements().ChildNamespace("services", extDiscoNamespace).Elements().Children("service")
+	require.Len(t, services, 2)
+	require.Equal(t, "stun.example.org", services[0].Attributes().Get("host"))
+	require.Equal(t, "", services[0].Attributes().Get("username"))
+	requireCredentials(t, services[1], "alice", "s3cr3t", time.Minute*10)
+
+	// filter by type
+	byType := xmpp.NewElementNamespace("services", extDiscoNamespace)
+	byType.SetAttribute("type", "stun")
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, byType))
+	elem = aliceStm.ReceiveElement()
+	services = elem.Elements().ChildNamespace("services", extDiscoNamespace).Elements().Children("service")
+	require.Len(t, services, 1)
+	require.Equal(t, "stun", services[0].Attributes().Get("type"))
+
+	// room listing permission grants access
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "bob", Role: model.Admin})
+	x.ProcessIQ(context.Background(), newGetIQ(bobJID, xmpp.NewElementNamespace("services", extDiscoNamespace)))
+	elem = bobStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// storage error
+	memorystorage.EnableMockedError()
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, xmpp.NewElementNamespace("services", extDiscoNamespace)))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
+	memorystorage.DisableMockedError()
+}
+
+func TestModule_XEP0215_Credentials(t *testing.T) {
+	r := setupTest()
+	roomRep := memorystorage.NewRoom()
+
+	_ = roomRep.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal})
+	_ = roomRep.BindRoom(context.Background(), 1, "alice")
+
+	cfg := &Config{
+		Secret: "s3cr3t",
+		Services: []Service{
+			{Type: "stun", Host: "stun.example.org", Port: 3478},
+			{Type: "turn", Host: "turn.example.org", Port: 3478, Transport: "udp", Restricted: true},
+		},
+	}
+	x := New(cfg, nil, r, memorystorage.NewUser(), roomRep, memorystorage.NewRole())
+	defer func() { _ = x.Shutdown() }()
+
+	aliceJID, aliceStm := bindUser(r, "alice")
+
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, credentialsRequest("turn.example.org", "turn", "3478")))
+	elem := aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	service := elem.Elements().ChildNamespace("credentials", extDiscoNamespace).Elements().Child("service")
+	requireCredentials(t, service, "alice", "s3cr3t", defaultCredentialsTTL)
+
+	// non restricted service
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, credentialsRequest("stun.example.org", "stun", "")))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// port mismatch
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, credentialsRequest("turn.example.org", "turn", "5349")))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(context.Background(), newGetIQ(aliceJID, credentialsRequest("", "turn", "")))
+	elem = aliceStm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func requireCredentials(t *testing.T, service xmpp.XElement, username, secret string, ttl time.Duration) {
+	attrs := service.Attributes()
+	require.Equal(t, "true", attrs.Get("restricted"))
+
+	user := attrs.Get("username")
+	parts := strings.SplitN(user, ":", 2)
+	require.Len(t, parts, 2)
+	require.Equal(t, username, parts[1])
+
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	require.Nil(t, err)
+	require.InDelta(t, time.Now().Add(ttl).Unix(), expiry, 5)
+
+	expires, err := time.Parse(timestampLayout, attrs.Get("expires"))
+	require.Nil(t, err)
+	require.Equal(t, expiry, expires.Unix())
+
+	h := hmac.New(sha1.New, []byte(secret))
+	_, _ = h.Write([]byte(user))
+	require.Equal(t, base64.StdEncoding.EncodeToString(h.Sum(nil)), attrs.Get("password"))
+}
+
+func credentialsRequest(host, tp, port string) xmpp.XElement {
+	service := xmpp.NewElementName("service")
+	if len(host) > 0 {
+		service.SetAttribute("host", host)
+	}
+	service.SetAttribute("type", tp)
+	if len(port) > 0 {
+		service.SetAttribute("port", port)
+	}
+	credentials := xmpp.NewElementNamespace("credentials", extDiscoNamespace)
+	credentials.AppendElement(service)
+	return credentials
+}
+
+func newGetIQ(from *jid.JID, elem xmpp.XElement) *xmpp.IQ {
+	srvJID, _ := jid.New("", "example.org", "", true)
+
+	iq := xmpp.NewIQType(uuid.New().String(), xmpp.GetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(srvJID)
+	iq.AppendElement(elem)
+	return iq
+}
+
+func bindUser(r router.Router, username string) (*jid.JID, *stream.MockC2S) {
+	j, _ := jid.New(username, "example.org", "desktop", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+	return j, stm
+}
+
+func setupTest() router.Router {
+	hosts, _ := host.New([]host.Config{{Name: "example.org", Certificate: tls.Certificate{}}})
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r
+}
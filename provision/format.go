@@ -0,0 +1,257 @@
+package provision
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Format represents a site configuration file format.
+type Format int
+
+const (
+	// YAML represents a nested YAML site description.
+	YAML Format = iota + 1
+	// CSV represents a flat CSV site description, one room, stream, device or binding per row.
+	CSV
+)
+
+func (f Format) String() string {
+	switch f {
+	case YAML:
+		return "yaml"
+	case CSV:
+		return "csv"
+	}
+	return ""
+}
+
+// ParseFormatString converts a string to Format.
+func ParseFormatString(f string) Format {
+	switch strings.ToLower(f) {
+	case "yaml", "yml":
+		return YAML
+	case "csv":
+		return CSV
+	default:
+		return 0
+	}
+}
+
+// FormatOf infers a site configuration file format from its extension.
+func FormatOf(filename string) Format {
+	return ParseFormatString(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// CSV row kinds.
+const (
+	roomKind    = "room"
+	streamKind  = "stream"
+	deviceKind  = "device"
+	bindingKind = "binding"
+)
+
+var csvHeader = []string{
+	"kind", "room", "name", "type", "in", "broadcast", "route",
+	"protocol", "codec", "resolution", "framerate", "bitrate", "latency", "serial", "username",
+}
+
+// Read decodes a site configuration written in a given format.
+func Read(r io.Reader, format Format) (*Site, error) {
+	var site *Site
+	var err error
+	switch format {
+	case YAML:
+		site, err = readYAML(r)
+	case CSV:
+		site, err = readCSV(r)
+	default:
+		return nil, fmt.Errorf("provision: unsupported format")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := site.Validate(); err != nil {
+		return nil, err
+	}
+	return site, nil
+}
+
+// Write encodes a site configuration using a given format.
+func Write(w io.Writer, site *Site, format Format) error {
+	switch format {
+	case YAML:
+		return writeYAML(w, site)
+	case CSV:
+		return writeCSV(w, site)
+	default:
+		return fmt.Errorf("provision: unsupported format")
+	}
+}
+
+func readYAML(r io.Reader) (*Site, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var site Site
+	if err := yaml.UnmarshalStrict(b, &site); err != nil {
+		return nil, err
+	}
+	return &site, nil
+}
+
+func writeYAML(w io.Writer, site *Site) error {
+	b, err := yaml.Marshal(site)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func readCSV(r io.Reader) (*Site, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range csvHeader {
+		if strings.TrimSpace(header[i]) != csvHeader[i] {
+			return nil, fmt.Errorf("provision: unexpected csv column %d: %s", i+1, header[i])
+		}
+	}
+	site := &Site{}
+	rooms := make(map[string]int) // room username to site rooms index
+	lookupRoom := func(line int, username string) (*Room, error) {
+		i, ok := rooms[username]
+		if !ok {
+			return nil, fmt.Errorf("provision: csv line %d: undeclared room: %s", line, username)
+		}
+		return &site.Rooms[i], nil
+	}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		col := make(map[string]string, len(csvHeader))
+		for i, name := range csvHeader {
+			col[name] = strings.TrimSpace(rec[i])
+		}
+		switch col["kind"] {
+		case roomKind:
+			if _, ok := rooms[col["room"]]; ok {
+				return nil, fmt.Errorf("provision: csv line %d: duplicated room: %s", line, col["room"])
+			}
+			rooms[col["room"]] = len(site.Rooms)
+			site.Rooms = append(site.Rooms, Room{Username: col["room"], Name: col["name"], Type: col["type"]})
+
+		case streamKind:
+			room, err := lookupRoom(line, col["room"])
+			if err != nil {
+				return nil, err
+			}
+			stream := Stream{
+				Type:       col["type"],
+				In:         col["in"],
+				Broadcast:  col["broadcast"],
+				Route:      col["route"],
+				Protocol:   col["protocol"],
+				Codec:      col["codec"],
+				Resolution: col["resolution"],
+				Latency:    col["latency"],
+			}
+			if v := col["framerate"]; len(v) > 0 {
+				if stream.Framerate, err = strconv.ParseFloat(v, 64); err != nil {
+					return nil, fmt.Errorf("provision: csv line %d: invalid framerate: %s", line, v)
+				}
+			}
+			if v := col["bitrate"]; len(v) > 0 {
+				if stream.Bitrate, err = strconv.Atoi(v); err != nil {
+					return nil, fmt.Errorf("provision: csv line %d: invalid bitrate: %s", line, v)
+				}
+			}
+			room.Streams = append(room.Streams, stream)
+
+		case deviceKind:
+			room, err := lookupRoom(line, col["room"])
+			if err != nil {
+				return nil, err
+			}
+			room.Devices = append(room.Devices, Device{Serial: col["serial"], Type: col["type"]})
+
+		case bindingKind:
+			room, err := lookupRoom(line, col["room"])
+			if err != nil {
+				return nil, err
+			}
+			room.Users = append(room.Users, col["username"])
+
+		default:
+			return nil, fmt.Errorf("provision: csv line %d: unknown row kind: %s", line, col["kind"])
+		}
+	}
+	return site, nil
+}
+
+func writeCSV(w io.Writer, site *Site) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, room := range site.Rooms {
+		rows := [][]string{csvRow(map[string]string{"kind": roomKind, "room": room.Username, "name": room.Name, "type": room.Type})}
+		for _, stream := range room.Streams {
+			row := map[string]string{
+				"kind":       streamKind,
+				"room":       room.Username,
+				"type":       stream.Type,
+				"in":         stream.In,
+				"broadcast":  stream.Broadcast,
+				"route":      stream.Route,
+				"protocol":   stream.Protocol,
+				"codec":      stream.Codec,
+				"resolution": stream.Resolution,
+				"latency":    stream.Latency,
+			}
+			if stream.Framerate > 0 {
+				row["framerate"] = strconv.FormatFloat(stream.Framerate, 'f', -1, 64)
+			}
+			if stream.Bitrate > 0 {
+				row["bitrate"] = strconv.Itoa(stream.Bitrate)
+			}
+			rows = append(rows, csvRow(row))
+		}
+		for _, device := range room.Devices {
+			rows = append(rows, csvRow(map[string]string{"kind": deviceKind, "room": room.Username, "type": device.Type, "serial": device.Serial}))
+		}
+		for _, username := range room.Users {
+			rows = append(rows, csvRow(map[string]string{"kind": bindingKind, "room": room.Username, "username": username}))
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvRow(col map[string]string) []string {
+	row := make([]string, len(csvHeader))
+	for i, name := range csvHeader {
+		row[i] = col[name]
+	}
+	return row
+}
@@ -0,0 +1,96 @@
+package provision
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSite() *Site {
+	return &Site{Rooms: []Room{
+		{
+			Username: "room01",
+			Name:     "Room 01",
+			Type:     "normal",
+			Streams: []Stream{
+				{Type: "camera", In: "rtmp://127.0.0.1/in", Route: `{"user": "srt://127.0.0.1:9001"}`, Protocol: "srt", Codec: "h264", Resolution: "1920x1080", Framerate: 29.97, Bitrate: 4000, Latency: "low"},
+				{Type: "device", Route: `{"admin": "srt://127.0.0.1:9002"}`},
+			},
+			Devices: []Device{{Serial: "SN-0001", Type: "box"}},
+			Users:   []string{"alice", "bob"},
+		},
+		{Username: "qc01", Name: "QC 01", Type: "qc"},
+	}}
+}
+
+func TestFormat_Parse(t *testing.T) {
+	require.Equal(t, YAML, ParseFormatString("yml"))
+	require.Equal(t, CSV, ParseFormatString("CSV"))
+	require.Equal(t, Format(0), ParseFormatString("xml"))
+
+	require.Equal(t, YAML, FormatOf("site.yaml"))
+	require.Equal(t, CSV, FormatOf("/tmp/site.csv"))
+	require.Equal(t, Format(0), FormatOf("site"))
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	for _, format := range []Format{YAML, CSV} {
+		buf := bytes.NewBuffer(nil)
+		require.Nil(t, Write(buf, testSite(), format))
+
+		site, err := Read(buf, format)
+		require.Nil(t, err, format.String())
+		require.Equal(t, testSite(), site, format.String())
+	}
+}
+
+func TestFormat_ReadCSV(t *testing.T) {
+	header := strings.Join(csvHeader, ",") + "\n"
+
+	_, err := Read(strings.NewReader(header+"stream,room01,,camera,,,,,,,,,,,\n"), CSV)
+	require.NotNil(t, err) // undeclared room
+
+	_, err = Read(strings.NewReader(header+"room,room01,Room 01,normal,,,,,,,,,,,\nbox,room01,,,,,,,,,,,,,\n"), CSV)
+	require.NotNil(t, err) // unknown row kind
+
+	_, err = Read(strings.NewReader(header+"room,room01,Room 01,normal,,,,,,,,,,,\nstream,room01,,camera,,,,,,,fast,,,,\n"), CSV)
+	require.NotNil(t, err) // bad framerate
+
+	_, err = Read(strings.NewReader("kind,room\n"), CSV)
+	require.NotNil(t, err)
+
+	site, err := Read(strings.NewReader(header+"room,room01,Room 01,normal,,,,,,,,,,,\nbinding,room01,,,,,,,,,,,,,alice\n"), CSV)
+	require.Nil(t, err)
+	require.Len(t, site.Rooms, 1)
+	require.Equal(t, []string{"alice"}, site.Rooms[0].Users)
+}
+
+func TestSite_Validate(t *testing.T) {
+	site := testSite()
+	site.Rooms[1].Username = "room01"
+	require.NotNil(t, site.Validate())
+
+	site = testSite()
+	site.Rooms[0].Type = "ward"
+	require.NotNil(t, site.Validate())
+
+	site = testSite()
+	site.Rooms[0].Streams[1].Type = "camera"
+	require.NotNil(t, site.Validate())
+
+	site = testSite()
+	site.Rooms[0].Streams[0].Route = `{"user": "nowhere"}`
+	require.NotNil(t, site.Validate())
+
+	site = testSite()
+	site.Rooms[0].Streams[0].Resolution = "hd"
+	require.NotNil(t, site.Validate())
+
+	site = testSite()
+	site.Rooms[1].Devices = []Device{{Serial: "SN-0001", Type: "box"}}
+	require.NotNil(t, site.Validate())
+
+	require.Nil(t, testSite().Validate())
+}
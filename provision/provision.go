@@ -0,0 +1,297 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/storage/repository"
+)
+
+// Op represents a provisioning change operation.
+type Op int
+
+const (
+	// Create represents a newly created entity.
+	Create Op = iota + 1
+	// Update represents a modified entity.
+	Update
+)
+
+func (op Op) String() string {
+	switch op {
+	case Create:
+		return "+"
+	case Update:
+		return "~"
+	}
+	return ""
+}
+
+// Change represents a single entity change required to converge storage into a site configuration.
+type Change struct {
+	Op   Op
+	Kind string // room, stream, device or binding
+	Key  string
+
+	// Fields describes modified fields in 'name: old -> new' form, on updates.
+	Fields []string
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s %s %s", c.Op, c.Kind, c.Key)
+	if len(c.Fields) > 0 {
+		s += " (" + strings.Join(c.Fields, ", ") + ")"
+	}
+	return s
+}
+
+// Provisioner imports and exports site configurations.
+type Provisioner struct {
+	roomRep   repository.Room
+	userRep   repository.User
+	deviceRep repository.Device
+}
+
+// New returns a provisioner instance.
+func New(roomRep repository.Room, userRep repository.User, deviceRep repository.Device) *Provisioner {
+	return &Provisioner{roomRep: roomRep, userRep: userRep, deviceRep: deviceRep}
+}
+
+// Export returns the current site configuration.
+func (p *Provisioner) Export(ctx context.Context) (*Site, error) {
+	rooms, _, err := p.roomRep.FetchRooms(ctx, &roomsmodel.Filter{}, &roomsmodel.Page{})
+	if err != nil {
+		return nil, err
+	}
+	site := &Site{}
+	for _, rm := range rooms {
+		room, err := p.roomRep.FetchRoomByID(ctx, rm.ID)
+		if err != nil {
+			return nil, err
+		}
+		if room == nil {
+			continue // deleted in the meantime
+		}
+		devices, err := p.deviceRep.FetchRoomDevices(ctx, room.ID)
+		if err != nil {
+			return nil, err
+		}
+		users, err := p.roomRep.FetchBoundUsers(ctx, room.ID)
+		if err != nil {
+			return nil, err
+		}
+		site.Rooms = append(site.Rooms, newRoom(room, devices, users))
+	}
+	return site, nil
+}
+
+// Import converges storage into a site configuration, returning the applied changes.
+// Entities missing from site are left untouched. No change is written if dryRun is set.
+func (p *Provisioner) Import(ctx context.Context, site *Site, dryRun bool) ([]Change, error) {
+	if err := site.Validate(); err != nil {
+		return nil, err
+	}
+	if err := p.validateUsers(ctx, site); err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for i := range site.Rooms {
+		roomChanges, err := p.importRoom(ctx, &site.Rooms[i], dryRun)
+		changes = append(changes, roomChanges...)
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+func (p *Provisioner) importRoom(ctx context.Context, r *Room, dryRun bool) ([]Change, error) {
+	var changes []Change
+
+	room, _ := r.model()
+	var current *roomsmodel.Room
+	existing, err := p.roomRep.FetchRoom(ctx, room.Username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// fetch raw video stream definitions
+		if current, err = p.roomRep.FetchRoomByID(ctx, existing.ID); err != nil {
+			return nil, err
+		}
+	}
+	if current == nil {
+		changes = append(changes, Change{Op: Create, Kind: roomKind, Key: room.Username})
+	} else {
+		room.ID = current.ID
+		fields := diffFields(
+			"name", current.Name, room.Name,
+			"type", current.Type.String(), room.Type.String(),
+		)
+		if len(fields) > 0 {
+			changes = append(changes, Change{Op: Update, Kind: roomKind, Key: room.Username, Fields: fields})
+		}
+	}
+	if len(changes) > 0 && !dryRun {
+		if err := p.roomRep.UpsertRoom(ctx, room); err != nil {
+			return changes, err
+		}
+	}
+
+	// video streams
+	for i := range r.Streams {
+		vs, _ := r.Streams[i].model()
+		vs.RoomID = room.ID
+
+		key := room.Username + "/" + vs.Type.String()
+		var change *Change
+		if prev := findStream(current, vs.Type); prev == nil {
+			change = &Change{Op: Create, Kind: streamKind, Key: key}
+		} else if fields := diffStreams(newStream(prev), newStream(vs)); len(fields) > 0 {
+			change = &Change{Op: Update, Kind: streamKind, Key: key, Fields: fields}
+		}
+		if change == nil {
+			continue
+		}
+		changes = append(changes, *change)
+		if !dryRun {
+			if err := p.roomRep.UpsertVideoStream(ctx, vs); err != nil {
+				return changes, err
+			}
+		}
+	}
+
+	// devices
+	for i := range r.Devices {
+		device, _ := r.Devices[i].model()
+		device.RoomID = room.ID
+
+		prev, err := p.deviceRep.FetchDevice(ctx, device.Serial)
+		if err != nil {
+			return changes, err
+		}
+		var change *Change
+		if prev == nil {
+			change = &Change{Op: Create, Kind: deviceKind, Key: device.Serial}
+		} else {
+			device.Username = prev.Username
+			prevRoom, err := p.roomUsername(ctx, prev.RoomID)
+			if err != nil {
+				return changes, err
+			}
+			fields := diffFields(
+				"type", prev.Type.String(), device.Type.String(),
+				"room", prevRoom, room.Username,
+			)
+			if len(fields) > 0 {
+				change = &Change{Op: Update, Kind: deviceKind, Key: device.Serial, Fields: fields}
+			}
+		}
+		if change == nil {
+			continue
+		}
+		changes = append(changes, *change)
+		if !dryRun {
+			if err := p.deviceRep.UpsertDevice(ctx, device); err != nil {
+				return changes, err
+			}
+		}
+	}
+
+	// user bindings
+	var boundUsers []string
+	if current != nil {
+		if boundUsers, err = p.roomRep.FetchBoundUsers(ctx, room.ID); err != nil {
+			return changes, err
+		}
+	}
+	for _, username := range r.Users {
+		if containsString(boundUsers, username) {
+			continue
+		}
+		changes = append(changes, Change{Op: Create, Kind: bindingKind, Key: room.Username + "/" + username})
+		if !dryRun {
+			if err := p.roomRep.BindRoom(ctx, room.ID, username); err != nil {
+				return changes, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// validateUsers checks every user bound by site exists, so that nothing gets written otherwise.
+func (p *Provisioner) validateUsers(ctx context.Context, site *Site) error {
+	for i := range site.Rooms {
+		room := &site.Rooms[i]
+		for _, username := range room.Users {
+			exists, err := p.userRep.UserExists(ctx, username)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("provision: room %s: unknown user: %s", room.Username, username)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Provisioner) roomUsername(ctx context.Context, roomID int) (string, error) {
+	if roomID == 0 {
+		return "", nil
+	}
+	room, err := p.roomRep.FetchRoomByID(ctx, roomID)
+	if err != nil || room == nil {
+		return "", err
+	}
+	return room.Username, nil
+}
+
+func findStream(room *roomsmodel.Room, tp roomsmodel.VideoType) *roomsmodel.VideoStream {
+	if room == nil {
+		return nil
+	}
+	for i := range room.Streams {
+		if room.Streams[i].Type == tp {
+			return &room.Streams[i]
+		}
+	}
+	return nil
+}
+
+func diffStreams(prev, next Stream) []string {
+	return diffFields(
+		"in", prev.In, next.In,
+		"broadcast", prev.Broadcast, next.Broadcast,
+		"route", prev.Route, next.Route,
+		"protocol", prev.Protocol, next.Protocol,
+		"codec", prev.Codec, next.Codec,
+		"resolution", prev.Resolution, next.Resolution,
+		"framerate", strconv.FormatFloat(prev.Framerate, 'f', -1, 64), strconv.FormatFloat(next.Framerate, 'f', -1, 64),
+		"bitrate", strconv.Itoa(prev.Bitrate), strconv.Itoa(next.Bitrate),
+		"latency", prev.Latency, next.Latency,
+	)
+}
+
+// diffFields takes (name, old, new) triplets, describing the ones whose values differ.
+func diffFields(triplets ...string) []string {
+	var res []string
+	for i := 0; i+2 < len(triplets); i += 3 {
+		if triplets[i+1] != triplets[i+2] {
+			res = append(res, fmt.Sprintf("%s: %q -> %q", triplets[i], triplets[i+1], triplets[i+2]))
+		}
+	}
+	return res
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package provision
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dantin/cubit/model"
+	memorystorage "github.com/dantin/cubit/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisioner_Import(t *testing.T) {
+	p, userRep := setupTest()
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "alice", Role: model.Usr})
+
+	site := testSite()
+
+	// unknown user
+	changes, err := p.Import(context.Background(), site, false)
+	require.NotNil(t, err)
+	require.Len(t, changes, 0)
+
+	exported, err := p.Export(context.Background())
+	require.Nil(t, err)
+	require.Len(t, exported.Rooms, 0)
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "bob", Role: model.Usr})
+
+	// dry run
+	changes, err = p.Import(context.Background(), site, true)
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		"+ room room01",
+		"+ stream room01/camera",
+		"+ stream room01/device",
+		"+ device SN-0001",
+		"+ binding room01/alice",
+		"+ binding room01/bob",
+		"+ room qc01",
+	}, changeStrings(changes))
+
+	exported, err = p.Export(context.Background())
+	require.Nil(t, err)
+	require.Len(t, exported.Rooms, 0)
+
+	// apply
+	changes, err = p.Import(context.Background(), site, false)
+	require.Nil(t, err)
+	require.Len(t, changes, 7)
+
+	exported, err = p.Export(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, site, exported)
+
+	// idempotent
+	changes, err = p.Import(context.Background(), site, false)
+	require.Nil(t, err)
+	require.Len(t, changes, 0)
+
+	// diff
+	site.Rooms[0].Name = "Room 1"
+	site.Rooms[0].Streams[0].Bitrate = 2500
+	site.Rooms[1].Devices = []Device{{Serial: "SN-0001", Type: "box"}}
+	site.Rooms[0].Devices = nil
+
+	changes, err = p.Import(context.Background(), site, true)
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		`~ room room01 (name: "Room 01" -> "Room 1")`,
+		`~ stream room01/camera (bitrate: "4000" -> "2500")`,
+		`~ device SN-0001 (room: "room01" -> "qc01")`,
+	}, changeStrings(changes))
+
+	_, err = p.Import(context.Background(), site, false)
+	require.Nil(t, err)
+
+	exported, err = p.Export(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, site, exported)
+}
+
+func setupTest() (*Provisioner, *memorystorage.User) {
+	userRep := memorystorage.NewUser()
+	return New(memorystorage.NewRoom(), userRep, memorystorage.NewDevice()), userRep
+}
+
+func changeStrings(changes []Change) []string {
+	var res []string
+	for _, change := range changes {
+		res = append(res, change.String())
+	}
+	return res
+}
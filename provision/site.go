@@ -0,0 +1,181 @@
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+)
+
+// Site represents a whole site configuration: rooms along with their streams, devices and bound users.
+type Site struct {
+	Rooms []Room `yaml:"rooms"`
+}
+
+// Room represents a provisioned room, identified by its JID username.
+type Room struct {
+	Username string   `yaml:"username"`
+	Name     string   `yaml:"name"`
+	Type     string   `yaml:"type"`
+	Streams  []Stream `yaml:"streams,omitempty"`
+	Devices  []Device `yaml:"devices,omitempty"`
+	Users    []string `yaml:"users,omitempty"`
+}
+
+// Stream represents a provisioned room video stream, identified by its type within the room.
+type Stream struct {
+	Type       string  `yaml:"type"`
+	In         string  `yaml:"in,omitempty"`
+	Broadcast  string  `yaml:"broadcast,omitempty"`
+	Route      string  `yaml:"route"`
+	Protocol   string  `yaml:"protocol,omitempty"`
+	Codec      string  `yaml:"codec,omitempty"`
+	Resolution string  `yaml:"resolution,omitempty"`
+	Framerate  float64 `yaml:"framerate,omitempty"`
+	Bitrate    int     `yaml:"bitrate,omitempty"`
+	Latency    string  `yaml:"latency,omitempty"`
+}
+
+// Device represents a provisioned streaming device, identified by its serial number.
+type Device struct {
+	Serial string `yaml:"serial"`
+	Type   string `yaml:"type"`
+}
+
+// Validate checks site configuration consistency.
+func (s *Site) Validate() error {
+	rooms := make(map[string]struct{})
+	serials := make(map[string]struct{})
+	for i := range s.Rooms {
+		room := &s.Rooms[i]
+		if len(room.Username) == 0 {
+			return fmt.Errorf("provision: room %d: username is required", i+1)
+		}
+		if _, ok := rooms[room.Username]; ok {
+			return fmt.Errorf("provision: room %s: duplicated room", room.Username)
+		}
+		rooms[room.Username] = struct{}{}
+
+		if _, err := room.model(); err != nil {
+			return err
+		}
+		streamTypes := make(map[string]struct{})
+		for j := range room.Streams {
+			vs, err := room.Streams[j].model()
+			if err != nil {
+				return fmt.Errorf("provision: room %s: %v", room.Username, err)
+			}
+			if _, ok := streamTypes[vs.Type.String()]; ok {
+				return fmt.Errorf("provision: room %s: duplicated %s stream", room.Username, vs.Type)
+			}
+			streamTypes[vs.Type.String()] = struct{}{}
+		}
+		for j := range room.Devices {
+			device, err := room.Devices[j].model()
+			if err != nil {
+				return fmt.Errorf("provision: room %s: %v", room.Username, err)
+			}
+			if _, ok := serials[device.Serial]; ok {
+				return fmt.Errorf("provision: room %s: duplicated device %s", room.Username, device.Serial)
+			}
+			serials[device.Serial] = struct{}{}
+		}
+		for _, username := range room.Users {
+			if len(username) == 0 {
+				return fmt.Errorf("provision: room %s: empty bound username", room.Username)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Room) model() (*roomsmodel.Room, error) {
+	tp := roomsmodel.ParseRoomTypeString(r.Type)
+	if tp == 0 {
+		return nil, fmt.Errorf("provision: room %s: invalid room type: %s", r.Username, r.Type)
+	}
+	return &roomsmodel.Room{Username: r.Username, Name: r.Name, Type: tp}, nil
+}
+
+func (s *Stream) model() (*roomsmodel.VideoStream, error) {
+	vs := &roomsmodel.VideoStream{In: s.In, Broadcast: s.Broadcast, Route: s.Route}
+	if vs.Type = roomsmodel.ParseVideoTypeString(s.Type); vs.Type == roomsmodel.Unknown {
+		return nil, fmt.Errorf("invalid stream type: %s", s.Type)
+	}
+	if _, err := roomsmodel.ParseRoutes(s.Route); err != nil {
+		return nil, fmt.Errorf("invalid %s stream route: %v", s.Type, err)
+	}
+	md := &vs.Metadata
+	if len(s.Protocol) > 0 {
+		if md.Protocol = roomsmodel.ParseProtocolString(s.Protocol); md.Protocol == roomsmodel.UnknownProtocol {
+			return nil, fmt.Errorf("invalid %s stream protocol: %s", s.Type, s.Protocol)
+		}
+	}
+	md.Codec = s.Codec
+	if len(s.Resolution) > 0 {
+		width, height, err := roomsmodel.ParseResolution(s.Resolution)
+		if err != nil {
+			return nil, err
+		}
+		md.Width, md.Height = width, height
+	}
+	if s.Framerate < 0 || s.Bitrate < 0 {
+		return nil, fmt.Errorf("invalid %s stream framerate or bitrate", s.Type)
+	}
+	md.Framerate, md.Bitrate = s.Framerate, s.Bitrate
+	if len(s.Latency) > 0 {
+		if md.Latency = roomsmodel.ParseLatencyClassString(s.Latency); md.Latency == roomsmodel.UnknownLatency {
+			return nil, fmt.Errorf("invalid %s stream latency: %s", s.Type, s.Latency)
+		}
+	}
+	return vs, nil
+}
+
+func (d *Device) model() (*devicesmodel.Device, error) {
+	if !devicesmodel.IsValidSerial(d.Serial) {
+		return nil, fmt.Errorf("invalid device serial: %s", d.Serial)
+	}
+	tp := roomsmodel.ParseVideoTypeString(d.Type)
+	if tp == roomsmodel.Unknown {
+		return nil, fmt.Errorf("invalid device %s type: %s", d.Serial, d.Type)
+	}
+	return &devicesmodel.Device{Serial: d.Serial, Type: tp}, nil
+}
+
+func newRoom(room *roomsmodel.Room, devices []devicesmodel.Device, users []string) Room {
+	res := Room{Username: room.Username, Name: room.Name, Type: room.Type.String()}
+	for i := range room.Streams {
+		res.Streams = append(res.Streams, newStream(&room.Streams[i]))
+	}
+	sort.Slice(res.Streams, func(i, j int) bool { return res.Streams[i].Type < res.Streams[j].Type })
+
+	for _, device := range devices {
+		res.Devices = append(res.Devices, Device{Serial: device.Serial, Type: device.Type.String()})
+	}
+	sort.Slice(res.Devices, func(i, j int) bool { return res.Devices[i].Serial < res.Devices[j].Serial })
+
+	res.Users = append(res.Users, users...)
+	sort.Strings(res.Users)
+	return res
+}
+
+func newStream(vs *roomsmodel.VideoStream) Stream {
+	md := &vs.Metadata
+	res := Stream{
+		Type:      vs.Type.String(),
+		In:        vs.In,
+		Broadcast: vs.Broadcast,
+		Route:     vs.Route,
+		Protocol:  md.Protocol.String(),
+		Codec:     md.Codec,
+		Framerate: md.Framerate,
+		Bitrate:   md.Bitrate,
+		Latency:   md.Latency.String(),
+	}
+	if md.Width > 0 && md.Height > 0 {
+		res.Resolution = strconv.Itoa(md.Width) + "x" + strconv.Itoa(md.Height)
+	}
+	return res
+}
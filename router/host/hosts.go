@@ -3,6 +3,7 @@ package host
 import (
 	"crypto/tls"
 	"sort"
+	"strings"
 
 	utiltls "github.com/dantin/cubit/util/tls"
 )
@@ -67,3 +68,13 @@ func (h *Hosts) Certificates() []tls.Certificate {
 	}
 	return certs
 }
+
+// GetCertificate returns the certificate of the host requested through SNI,
+// falling back to the default host certificate if none matches.
+func (h *Hosts) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cer, ok := h.hosts[strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))]; ok {
+		return &cer, nil
+	}
+	cer := h.hosts[h.defaultHostname]
+	return &cer, nil
+}
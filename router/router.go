@@ -2,6 +2,7 @@ package router
 
 import (
 	"context"
+	"sync"
 
 	"github.com/dantin/cubit/router/host"
 	"github.com/dantin/cubit/stream"
@@ -32,6 +33,33 @@ type Router interface {
 
 	// LocalStreams returns all steams associated to a given username.
 	LocalStreams(username string) []stream.C2S
+
+	// RegisterUnbindHandler registers a new c2s stream unbind handler.
+	RegisterUnbindHandler(h UnbindHandler)
+
+	// RegisterComponent registers a local service handling every stanza addressed to a given domain.
+	RegisterComponent(domain string, c Component)
+
+	// RegisterInterceptor registers a new stanza interceptor.
+	RegisterInterceptor(i Interceptor)
+}
+
+// UnbindHandler is notified every time a c2s stream gets unbound.
+type UnbindHandler interface {
+	Unbound(ctx context.Context, j *jid.JID)
+}
+
+// Component represents a local service reachable through its own domain.
+type Component interface {
+	// ProcessStanza processes a stanza addressed to component domain.
+	ProcessStanza(ctx context.Context, stanza xmpp.Stanza)
+}
+
+// Interceptor inspects every stanza before being routed.
+type Interceptor interface {
+	// InterceptStanza returns false whenever stanza shouldn't be routed any further,
+	// in which case interceptor is in charge of replying back to the sender.
+	InterceptStanza(ctx context.Context, stanza xmpp.Stanza) bool
 }
 
 // C2SRouter represents a router between client and server.
@@ -64,14 +92,20 @@ type router struct {
 	hosts *host.Hosts
 	c2s   C2SRouter
 	s2s   S2SRouter
+
+	mu             sync.RWMutex
+	unbindHandlers []UnbindHandler
+	interceptors   []Interceptor
+	components     map[string]Component
 }
 
 // New creates a new router.
 func New(hosts *host.Hosts, c2sRouter C2SRouter, s2sRouter S2SRouter) (Router, error) {
 	r := &router{
-		hosts: hosts,
-		c2s:   c2sRouter,
-		s2s:   s2sRouter,
+		hosts:      hosts,
+		c2s:        c2sRouter,
+		s2s:        s2sRouter,
+		components: make(map[string]Component),
 	}
 	return r, nil
 }
@@ -94,6 +128,31 @@ func (r *router) Bind(ctx context.Context, stm stream.C2S) {
 
 func (r *router) Unbind(ctx context.Context, j *jid.JID) {
 	r.c2s.Unbind(j.Node(), j.Resource())
+
+	r.mu.RLock()
+	handlers := r.unbindHandlers
+	r.mu.RUnlock()
+	for _, h := range handlers {
+		h.Unbound(ctx, j)
+	}
+}
+
+func (r *router) RegisterUnbindHandler(h UnbindHandler) {
+	r.mu.Lock()
+	r.unbindHandlers = append(r.unbindHandlers, h)
+	r.mu.Unlock()
+}
+
+func (r *router) RegisterComponent(domain string, c Component) {
+	r.mu.Lock()
+	r.components[domain] = c
+	r.mu.Unlock()
+}
+
+func (r *router) RegisterInterceptor(i Interceptor) {
+	r.mu.Lock()
+	r.interceptors = append(r.interceptors, i)
+	r.mu.Unlock()
 }
 
 func (r *router) LocalStream(username, resource string) stream.C2S {
@@ -106,6 +165,20 @@ func (r *router) LocalStreams(username string) []stream.C2S {
 
 func (r *router) route(ctx context.Context, stanza xmpp.Stanza, validateStanza bool) error {
 	toJID := stanza.ToJID()
+
+	r.mu.RLock()
+	interceptors := r.interceptors
+	comp := r.components[toJID.Domain()]
+	r.mu.RUnlock()
+	for _, i := range interceptors {
+		if !i.InterceptStanza(ctx, stanza) {
+			return nil
+		}
+	}
+	if comp != nil {
+		comp.ProcessStanza(ctx, stanza)
+		return nil
+	}
 	if !r.hosts.IsLocalHost(toJID.Domain()) {
 		if r.s2s == nil {
 			return ErrFailedRemoteConnect
@@ -3,9 +3,12 @@ package s2s
 import (
 	"crypto/tls"
 	"errors"
+	"fmt"
+	"net"
 	"time"
 
 	"github.com/dantin/cubit/stream"
+	"github.com/dantin/cubit/transport/proxyproto"
 )
 
 const (
@@ -21,11 +24,23 @@ const (
 type TransportConfig struct {
 	BindAddress string
 	Port        int
+
+	// DirectTLS defines whether or not incoming connections start with a TLS handshake (XEP-0368).
+	DirectTLS bool
+
+	// ProxyProtocol defines whether or not connections from trusted proxies start with a PROXY protocol header.
+	ProxyProtocol bool
+
+	// TrustedProxies defines the networks allowed to send a PROXY protocol header.
+	TrustedProxies []*net.IPNet
 }
 
 type transportConfigProxy struct {
-	BindAddress string `yaml:"bind_addr"`
-	Port        int    `yaml:"port"`
+	BindAddress    string   `yaml:"bind_addr"`
+	Port           int      `yaml:"port"`
+	DirectTLS      bool     `yaml:"direct_tls"`
+	ProxyProtocol  bool     `yaml:"proxy_protocol"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -35,7 +50,17 @@ func (c *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 		return err
 	}
 	c.BindAddress = p.BindAddress
+	if p.ProxyProtocol && len(p.TrustedProxies) == 0 {
+		return errors.New("s2s.TransportConfig: proxy_protocol option requires at least one trusted proxy")
+	}
+	trustedProxies, err := proxyproto.ParseTrustedProxies(p.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("s2s.TransportConfig: %v", err)
+	}
 	c.Port = p.Port
+	c.DirectTLS = p.DirectTLS
+	c.ProxyProtocol = p.ProxyProtocol
+	c.TrustedProxies = trustedProxies
 	if c.Port == 0 {
 		c.Port = defaultTransportPort
 	}
@@ -113,6 +138,7 @@ type inConfig struct {
 	timeout        time.Duration
 	keepAlive      time.Duration
 	tls            *tls.Config
+	directTLS      bool
 	maxStanzaSize  int
 	onDisconnect   func(s stream.S2SIn)
 }
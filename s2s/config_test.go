@@ -28,6 +28,30 @@ port: 5999
 	require.Nil(t, err)
 	require.Equal(t, "127.0.0.1", trCfg.BindAddress)
 	require.Equal(t, 5999, trCfg.Port)
+	require.False(t, trCfg.DirectTLS)
+
+	rawCfg = `
+port: 5270
+direct_tls: true
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.Nil(t, err)
+	require.True(t, trCfg.DirectTLS)
+
+	rawCfg = `
+proxy_protocol: true
+trusted_proxies: [10.0.0.0/8]
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.Nil(t, err)
+	require.True(t, trCfg.ProxyProtocol)
+	require.Equal(t, "10.0.0.0/8", trCfg.TrustedProxies[0].String())
+
+	rawCfg = `
+proxy_protocol: true
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.NotNil(t, err)
 }
 
 func TestS2SConfig_Config(t *testing.T) {
@@ -55,6 +55,9 @@ func newInStream(config *inConfig, tr transport.Transport, mods *module.Modules,
 		mods:     mods,
 		runQueue: runqueue.New(id),
 	}
+	if config.directTLS {
+		s.secured = 1 // TLS handshake is performed by the listener
+	}
 	// start s2s in session
 	s.restartSession()
 
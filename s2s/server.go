@@ -2,6 +2,7 @@ package s2s
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"strconv"
 	"sync"
@@ -13,6 +14,7 @@ import (
 	"github.com/dantin/cubit/router"
 	"github.com/dantin/cubit/stream"
 	"github.com/dantin/cubit/transport"
+	"github.com/dantin/cubit/transport/proxyproto"
 )
 
 var listenerProvider = net.Listen
@@ -71,13 +73,30 @@ func (s *server) listenConn(address string) error {
 	if err != nil {
 		return err
 	}
+	if s.cfg.Transport.ProxyProtocol {
+		ln = proxyproto.NewListener(ln, &proxyproto.Config{
+			TrustedProxies: s.cfg.Transport.TrustedProxies,
+			HeaderTimeout:  s.cfg.ConnectTimeout,
+		})
+	}
+	if s.cfg.Transport.DirectTLS {
+		ln = tls.NewListener(ln, &tls.Config{
+			GetCertificate: s.router.Hosts().GetCertificate,
+			ClientAuth:     tls.VerifyClientCertIfGiven,
+			NextProtos:     []string{"xmpps-server"},
+		})
+	}
 	s.ln = ln
 
 	atomic.StoreUint32(&s.listening, 1)
 	for atomic.LoadUint32(&s.listening) == 1 {
 		conn, err := ln.Accept()
 		if err == nil {
-			go s.startInStream(transport.NewSocketTransport(conn))
+			go func(conn net.Conn) {
+				// reading remote address might block until PROXY protocol header arrives
+				log.Infof("s2s_in: accepted connection from %v", conn.RemoteAddr())
+				s.startInStream(transport.NewSocketTransport(conn))
+			}(conn)
 			continue
 		}
 	}
@@ -91,6 +110,7 @@ func (s *server) startInStream(tr transport.Transport) {
 			connectTimeout: s.cfg.ConnectTimeout,
 			keepAlive:      s.cfg.KeepAlive,
 			timeout:        s.cfg.Timeout,
+			directTLS:      s.cfg.Transport.DirectTLS,
 			maxStanzaSize:  s.cfg.MaxStanzaSize,
 			onDisconnect:   s.unregisterInStream,
 		},
@@ -119,17 +139,21 @@ func (s *server) unregisterInStream(stm stream.S2SIn) {
 }
 
 func (s *server) closeConnections(ctx context.Context) (count int, err error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// streams unregister themselves on disconnection
+	s.mu.RLock()
+	stms := make([]stream.S2SIn, 0, len(s.inConnections))
 	for _, stm := range s.inConnections {
+		stms = append(stms, stm)
+	}
+	s.mu.RUnlock()
+
+	for _, stm := range stms {
 		select {
 		case <-closeConn(ctx, stm):
 			count++
 		case <-ctx.Done():
-			err = ctx.Err()
-			return 0, err
+			return 0, ctx.Err()
 		}
-
 	}
 	return count, nil
 }
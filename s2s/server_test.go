@@ -2,11 +2,16 @@ package s2s
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/dantin/cubit/router"
+	"github.com/dantin/cubit/router/host"
+	utiltls "github.com/dantin/cubit/util/tls"
+	"github.com/dantin/cubit/xmpp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -53,3 +58,47 @@ func TestS2S_SocketServer(t *testing.T) {
 	err := <-errCh
 	require.Nil(t, err)
 }
+
+func TestS2S_DirectTLSServer(t *testing.T) {
+	defer func() { _ = os.RemoveAll("./.cert") }()
+
+	cer, err := utiltls.LoadCertificate("", "", "localhost")
+	require.Nil(t, err)
+	h, _ := host.New([]host.Config{{Name: "localhost", Certificate: cer}})
+	r, _ := router.New(h, nil, nil)
+
+	cfg := Config{
+		ConnectTimeout: time.Second * time.Duration(5),
+		KeepAlive:      time.Duration(600) * time.Second,
+		MaxStanzaSize:  8192,
+		Transport: TransportConfig{
+			Port:      12779,
+			DirectTLS: true,
+		},
+	}
+	srv := newServer(&cfg, nil, nil, r)
+	go srv.start()
+	time.Sleep(time.Millisecond * 150)
+
+	conn, err := tls.Dial("tcp", "127.0.0.1:12779", &tls.Config{ServerName: "localhost", InsecureSkipVerify: true, NextProtos: []string{"xmpps-server"}})
+	require.Nil(t, err)
+	defer func() { _ = conn.Close() }()
+	require.Equal(t, "xmpps-server", conn.ConnectionState().NegotiatedProtocol)
+
+	_, err = conn.Write([]byte(`<stream:stream xmlns="jabber:server" xmlns:stream="http://etherx.jabber.org/streams" xmlns:db="jabber:server:dialback" from="jabber.org" to="localhost" version="1.0">`))
+	require.Nil(t, err)
+
+	// stream is already secured
+	pr := xmpp.NewParser(conn, xmpp.SocketStream, 0)
+	var elem xmpp.XElement
+	for elem == nil || elem.Name() != "stream:features" {
+		elem, err = pr.ParseElement()
+		require.Nil(t, err)
+	}
+	require.Nil(t, elem.Elements().Child("starttls"))
+	require.NotNil(t, elem.Elements().ChildNamespace("dialback", dialbackNamespace))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*5))
+	defer cancel()
+	require.Nil(t, srv.shutdown(ctx))
+}
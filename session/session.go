@@ -4,6 +4,7 @@ import (
 	"context"
 	stdxml "encoding/xml"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"strings"
@@ -23,6 +24,7 @@ const (
 	jabberClientNamespace = "jabber:client"
 	jabberServerNamespace = "jabber:server"
 	framedStreamNamespace = "urn:ietf:params:xml:ns:xmpp-framing"
+	boshNamespace         = "http://jabber.org/protocol/httpbind"
 	streamNamespace       = "http://etherx.jabber.org/streams"
 	diabackNamespace      = "jabber:server:dialback"
 )
@@ -81,6 +83,8 @@ func New(id string, config *Config, tr transport.Transport, hosts *host.Hosts) *
 	switch tr.Type() {
 	case transport.Socket:
 		parsingMode = xmpp.SocketStream
+	case transport.WebSocket, transport.BOSH:
+		parsingMode = xmpp.DefaultMode
 	}
 	s := &Session{
 		id:           id,
@@ -138,6 +142,23 @@ func (s *Session) Open(ctx context.Context, featuresElem xmpp.XElement) error {
 		}
 		buf.WriteString(`<?xml version="1.0"?>`)
 
+	case transport.WebSocket:
+		ops = xmpp.NewElementName("open")
+		ops.SetAttribute("xmlns", framedStreamNamespace)
+		includeClosing = true
+
+	case transport.BOSH:
+		// session attributes are sent by the connection manager
+		if featuresElem == nil {
+			return nil
+		}
+		s.setWriteDeadline(ctx)
+
+		if err := featuresElem.ToXML(buf, true); err != nil {
+			return err
+		}
+		return s.write(buf.String())
+
 	default:
 		return nil
 	}
@@ -156,22 +177,21 @@ func (s *Session) Open(ctx context.Context, featuresElem xmpp.XElement) error {
 	if err := ops.ToXML(buf, includeClosing); err != nil {
 		return err
 	}
+	s.setWriteDeadline(ctx)
 
 	if featuresElem != nil {
+		// framed streams carry every element in its own message
+		if s.tr.Type() == transport.WebSocket {
+			if err := s.write(buf.String()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
 		if err := featuresElem.ToXML(buf, true); err != nil {
 			return err
 		}
 	}
-	openStr := buf.String()
-	log.Debugf("SEND(%s): %s", s.id, openStr)
-
-	s.setWriteDeadline(ctx)
-
-	_, err := io.Copy(s.tr, strings.NewReader(openStr))
-	if err != nil {
-		return err
-	}
-	return s.tr.Flush()
+	return s.write(buf.String())
 }
 
 // Close closes session sending the proper XMPP payload.
@@ -185,6 +205,8 @@ func (s *Session) Close(ctx context.Context) error {
 	switch s.tr.Type() {
 	case transport.Socket:
 		_, err = io.WriteString(s.tr, "</stream:stream>")
+	case transport.WebSocket:
+		_, err = io.WriteString(s.tr, fmt.Sprintf(`<close xmlns="%s" />`, framedStreamNamespace))
 	}
 	if err != nil {
 		return err
@@ -198,6 +220,12 @@ func (s *Session) Send(ctx context.Context, elem xmpp.XElement) error {
 	if e, ok := elem.(namespaceSettable); elem.IsStanza() && ok {
 		e.SetNamespace("")
 	}
+	// framed streams require stream namespace to be declared on every element
+	if s.tr.Type() == transport.WebSocket && strings.HasPrefix(elem.Name(), "stream:") && len(elem.Attributes().Get("xmlns:stream")) == 0 {
+		e := xmpp.NewElementFromElement(elem)
+		e.SetAttribute("xmlns:stream", streamNamespace)
+		elem = e
+	}
 	log.Debugf("SEND(%s): %v", s.id, elem)
 
 	s.setWriteDeadline(ctx)
@@ -226,6 +254,8 @@ func (s *Session) Receive() (xmpp.XElement, *Error) {
 				s.mu.Unlock()
 			}
 			atomic.StoreUint32(&s.started, 1)
+		} else if s.isClosingElement(elem) {
+			return nil, s.mapErrorToSessionError(xmpp.ErrStreamClosedByPeer)
 		} else if elem.IsStanza() {
 			stanza, err := s.buildStanza(elem)
 			if err != nil {
@@ -237,6 +267,25 @@ func (s *Session) Receive() (xmpp.XElement, *Error) {
 	return elem, nil
 }
 
+func (s *Session) write(str string) error {
+	log.Debugf("SEND(%s): %s", s.id, str)
+
+	if _, err := io.Copy(s.tr, strings.NewReader(str)); err != nil {
+		return err
+	}
+	return s.tr.Flush()
+}
+
+func (s *Session) isClosingElement(elem xmpp.XElement) bool {
+	switch s.tr.Type() {
+	case transport.WebSocket:
+		return elem.Name() == "close" && elem.Namespace() == framedStreamNamespace
+	case transport.BOSH:
+		return elem.Name() == "body" && elem.Namespace() == boshNamespace && elem.Type() == "terminate"
+	}
+	return false
+}
+
 func (s *Session) setWriteDeadline(ctx context.Context) {
 	d, ok := ctx.Deadline()
 	if !ok {
@@ -341,6 +390,20 @@ func (s *Session) validateStreamElement(elem xmpp.XElement) *Error {
 		if elem.Namespace() != s.namespace() || elem.Attributes().Get("xmlns:stream") != streamNamespace {
 			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
 		}
+	case transport.WebSocket:
+		if elem.Name() != "open" {
+			return &Error{UnderlyingErr: streamerror.ErrUnsupportedStanzaType}
+		}
+		if elem.Namespace() != framedStreamNamespace {
+			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
+		}
+	case transport.BOSH:
+		if elem.Name() != "body" {
+			return &Error{UnderlyingErr: streamerror.ErrUnsupportedStanzaType}
+		}
+		if elem.Namespace() != boshNamespace {
+			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
+		}
 	}
 	to := elem.To()
 	if len(to) > 0 && !s.hosts.IsLocalHost(to) {
@@ -169,6 +169,107 @@ func TestSession_Receive(t *testing.T) {
 	require.Equal(t, xmpp.ErrBadRequest, err.UnderlyingErr)
 }
 
+func TestSession_WebSocket(t *testing.T) {
+	hosts := setupTest("example.org")
+	j, _ := jid.NewWithString("example.org", true)
+	tr := newFakeTransport(transport.WebSocket)
+	sess := New(uuid.New().String(), &Config{JID: j}, tr, hosts)
+
+	features := xmpp.NewElementName("stream:features")
+	features.SetAttribute("xmlns:stream", streamNamespace)
+	_ = sess.Open(context.Background(), features)
+
+	pr := xmpp.NewParser(tr.wrBuf, xmpp.DefaultMode, 0)
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "open", elem.Name())
+	require.Equal(t, framedStreamNamespace, elem.Namespace())
+	require.Equal(t, "example.org", elem.From())
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "stream:features", elem.Name())
+
+	// stream elements declare stream namespace
+	tr.wrBuf.Reset()
+	_ = sess.Send(context.Background(), streamerror.ErrConnectionTimeout.Element())
+	elem, _ = xmpp.NewParser(tr.wrBuf, xmpp.DefaultMode, 0).ParseElement()
+	require.Equal(t, "stream:error", elem.Name())
+	require.Equal(t, streamNamespace, elem.Attributes().Get("xmlns:stream"))
+
+	// socket stream header is not allowed
+	open := xmpp.NewElementNamespace("stream:stream", jabberClientNamespace)
+	open.SetVersion("1.0")
+	_ = open.ToXML(tr.rdBuf, true)
+	_, sErr := sess.Receive()
+	require.Equal(t, &Error{UnderlyingErr: streamerror.ErrUnsupportedStanzaType}, sErr)
+
+	tr = newFakeTransport(transport.WebSocket)
+	sess = New(uuid.New().String(), &Config{JID: j}, tr, hosts)
+	_ = sess.Open(context.Background(), nil)
+
+	open = xmpp.NewElementNamespace("open", framedStreamNamespace)
+	open.SetAttribute("to", "example.org")
+	open.SetVersion("1.0")
+	_ = open.ToXML(tr.rdBuf, true)
+	_ = xmpp.NewElementNamespace("close", framedStreamNamespace).ToXML(tr.rdBuf, true)
+
+	elem, sErr = sess.Receive()
+	require.Nil(t, sErr)
+	require.Equal(t, "open", elem.Name())
+
+	// closed by peer
+	tr.wrBuf.Reset()
+	_, sErr = sess.Receive()
+	require.Equal(t, &Error{}, sErr)
+	require.Equal(t, `<close xmlns="urn:ietf:params:xml:ns:xmpp-framing" />`, tr.wrBuf.String())
+}
+
+func TestSession_BOSH(t *testing.T) {
+	hosts := setupTest("example.org")
+	j, _ := jid.NewWithString("example.org", true)
+	tr := newFakeTransport(transport.BOSH)
+	sess := New(uuid.New().String(), &Config{JID: j}, tr, hosts)
+
+	// only features are sent, session attributes belong to connection manager
+	_ = sess.Open(context.Background(), nil)
+	require.Equal(t, 0, tr.wrBuf.Len())
+
+	tr = newFakeTransport(transport.BOSH)
+	sess = New(uuid.New().String(), &Config{JID: j}, tr, hosts)
+	_ = sess.Open(context.Background(), xmpp.NewElementName("stream:features"))
+	require.Equal(t, "<stream:features/>", tr.wrBuf.String())
+
+	// framed stream header is not allowed
+	open := xmpp.NewElementNamespace("open", framedStreamNamespace)
+	open.SetVersion("1.0")
+	_ = open.ToXML(tr.rdBuf, true)
+	_, sErr := sess.Receive()
+	require.Equal(t, &Error{UnderlyingErr: streamerror.ErrUnsupportedStanzaType}, sErr)
+
+	tr = newFakeTransport(transport.BOSH)
+	sess = New(uuid.New().String(), &Config{JID: j}, tr, hosts)
+	_ = sess.Open(context.Background(), nil)
+
+	body := xmpp.NewElementNamespace("body", boshNamespace)
+	body.SetAttribute("to", "example.org")
+	body.SetVersion("1.0")
+	_ = body.ToXML(tr.rdBuf, true)
+
+	terminate := xmpp.NewElementNamespace("body", boshNamespace)
+	terminate.SetType("terminate")
+	_ = terminate.ToXML(tr.rdBuf, true)
+
+	elem, sErr := sess.Receive()
+	require.Nil(t, sErr)
+	require.Equal(t, "body", elem.Name())
+
+	// terminated by peer
+	tr.wrBuf.Reset()
+	_, sErr = sess.Receive()
+	require.Equal(t, &Error{}, sErr)
+	require.Equal(t, 0, tr.wrBuf.Len())
+}
+
 func TestSession_IsValidNamespace(t *testing.T) {
 	hosts := setupTest("example.org")
 
@@ -0,0 +1,59 @@
+package memorystorage
+
+import (
+	"context"
+
+	auditmodel "github.com/dantin/cubit/model/audit"
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Audit represents an in-memory audit log storage.
+type Audit struct {
+	*memoryStorage
+}
+
+// NewAudit returns an instance of Audit in-memory storage.
+func NewAudit() *Audit {
+	return &Audit{memoryStorage: newStorage()}
+}
+
+// AppendAuditEvent chains an event to the last stored one and appends it to the log.
+func (a *Audit) AppendAuditEvent(_ context.Context, event *auditmodel.Event) error {
+	return a.updateInWriteLock(auditEventsKey(), func(b []byte) ([]byte, error) {
+		var events []auditmodel.Event
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &events); err != nil {
+				return nil, err
+			}
+		}
+		var prev *auditmodel.Event
+		if len(events) > 0 {
+			prev = &events[len(events)-1]
+		}
+		event.Chain(prev)
+		events = append(events, *event)
+		return serializer.SerializeSlice(&events)
+	})
+}
+
+// FetchAuditEvents retrieves from storage all events matching a given filter, sorted by sequence number.
+func (a *Audit) FetchAuditEvents(_ context.Context, filter *auditmodel.Filter) ([]auditmodel.Event, error) {
+	var events []auditmodel.Event
+	if _, err := a.getEntities(auditEventsKey(), &events); err != nil {
+		return nil, err
+	}
+	var res []auditmodel.Event
+	for i := range events {
+		if filter.Max > 0 && len(res) == filter.Max {
+			break
+		}
+		if filter.Matches(&events[i]) {
+			res = append(res, events[i])
+		}
+	}
+	return res, nil
+}
+
+func auditEventsKey() string {
+	return "auditEvents"
+}
@@ -0,0 +1,58 @@
+package memorystorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	auditmodel "github.com/dantin/cubit/model/audit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_AppendAuditEvent(t *testing.T) {
+	s := NewAudit()
+	now := time.Now()
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.AppendAuditEvent(context.Background(), &auditmodel.Event{Actor: "admin", Timestamp: now}))
+	DisableMockedError()
+
+	for i, actor := range []string{"admin", "room01", "admin"} {
+		e := auditmodel.Event{Actor: actor, Action: auditmodel.StreamView, RoomID: i + 1, Timestamp: now.Add(time.Duration(i) * time.Minute)}
+		require.Nil(t, s.AppendAuditEvent(context.Background(), &e))
+		require.Equal(t, int64(i+1), e.Seq)
+	}
+	events, err := s.FetchAuditEvents(context.Background(), &auditmodel.Filter{})
+	require.Nil(t, err)
+	require.Len(t, events, 3)
+
+	_, ok := auditmodel.VerifyChain(events)
+	require.True(t, ok)
+}
+
+func TestMemoryStorage_FetchAuditEvents(t *testing.T) {
+	s := NewAudit()
+	now := time.Now().Truncate(time.Second)
+	for i, actor := range []string{"admin", "room01", "admin", "admin"} {
+		_ = s.AppendAuditEvent(context.Background(), &auditmodel.Event{Actor: actor, Action: auditmodel.StreamView, RoomID: 1, Timestamp: now.Add(time.Duration(i) * time.Minute)})
+	}
+
+	EnableMockedError()
+	_, err := s.FetchAuditEvents(context.Background(), &auditmodel.Filter{})
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	events, _ := s.FetchAuditEvents(context.Background(), &auditmodel.Filter{Actor: "admin"})
+	require.Len(t, events, 3)
+
+	events, _ = s.FetchAuditEvents(context.Background(), &auditmodel.Filter{Actor: "admin", Max: 2})
+	require.Len(t, events, 2)
+	require.Equal(t, int64(3), events[1].Seq)
+
+	events, _ = s.FetchAuditEvents(context.Background(), &auditmodel.Filter{From: now.Add(time.Minute), To: now.Add(3 * time.Minute)})
+	require.Len(t, events, 2)
+	require.Equal(t, int64(2), events[0].Seq)
+
+	events, _ = s.FetchAuditEvents(context.Background(), &auditmodel.Filter{RoomID: 2})
+	require.Len(t, events, 0)
+}
@@ -0,0 +1,107 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Device represents an in-memory device storage.
+type Device struct {
+	*memoryStorage
+}
+
+// NewDevice returns an instance of Device in-memory storage.
+func NewDevice() *Device {
+	return &Device{memoryStorage: newStorage()}
+}
+
+// UpsertDevice inserts a new device entity into storage, or updates it if previously inserted.
+func (d *Device) UpsertDevice(_ context.Context, device *devicesmodel.Device) error {
+	return d.updateDevices(func(devices []devicesmodel.Device) []devicesmodel.Device {
+		for i, dev := range devices {
+			if dev.Serial == device.Serial {
+				devices[i] = *device
+				return devices
+			}
+		}
+		return append(devices, *device)
+	})
+}
+
+// DeleteDevice deletes a device entity from storage.
+func (d *Device) DeleteDevice(_ context.Context, serial string) error {
+	return d.updateDevices(func(devices []devicesmodel.Device) []devicesmodel.Device {
+		for i, dev := range devices {
+			if dev.Serial == serial {
+				return append(devices[:i], devices[i+1:]...)
+			}
+		}
+		return devices
+	})
+}
+
+// FetchDevice retrieves a device entity from storage.
+func (d *Device) FetchDevice(_ context.Context, serial string) (*devicesmodel.Device, error) {
+	return d.fetchDevice(func(dev *devicesmodel.Device) bool { return dev.Serial == serial })
+}
+
+// FetchDeviceByUsername retrieves from storage the device entity an XMPP account was issued to.
+func (d *Device) FetchDeviceByUsername(_ context.Context, username string) (*devicesmodel.Device, error) {
+	return d.fetchDevice(func(dev *devicesmodel.Device) bool { return len(username) > 0 && dev.Username == username })
+}
+
+// FetchDevices retrieves all device entities from storage.
+func (d *Device) FetchDevices(_ context.Context) ([]devicesmodel.Device, error) {
+	return d.fetchDevices(func(_ *devicesmodel.Device) bool { return true })
+}
+
+// FetchRoomDevices retrieves from storage all device entities bound to a given room.
+func (d *Device) FetchRoomDevices(_ context.Context, roomID int) ([]devicesmodel.Device, error) {
+	return d.fetchDevices(func(dev *devicesmodel.Device) bool { return dev.RoomID == roomID })
+}
+
+func (d *Device) fetchDevice(match func(dev *devicesmodel.Device) bool) (*devicesmodel.Device, error) {
+	devices, err := d.fetchDevices(match)
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, nil
+	}
+	return &devices[0], nil
+}
+
+func (d *Device) fetchDevices(match func(dev *devicesmodel.Device) bool) ([]devicesmodel.Device, error) {
+	var devices []devicesmodel.Device
+	if _, err := d.getEntities(devicesKey(), &devices); err != nil {
+		return nil, err
+	}
+	var res []devicesmodel.Device
+	for i := range devices {
+		if match(&devices[i]) {
+			res = append(res, devices[i])
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Serial < res[j].Serial })
+	return res, nil
+}
+
+func (d *Device) updateDevices(f func(devices []devicesmodel.Device) []devicesmodel.Device) error {
+	return d.updateInWriteLock(devicesKey(), func(b []byte) ([]byte, error) {
+		var devices []devicesmodel.Device
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &devices); err != nil {
+				return nil, err
+			}
+		}
+		devices = f(devices)
+		return serializer.SerializeSlice(&devices)
+	})
+}
+
+func devicesKey() string {
+	return "devices"
+}
@@ -0,0 +1,75 @@
+package memorystorage
+
+import (
+	"context"
+	"testing"
+
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_UpsertDevice(t *testing.T) {
+	s := NewDevice()
+	d := devicesmodel.Device{Serial: "SN-0001", Type: roomsmodel.Camera}
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertDevice(context.Background(), &d))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertDevice(context.Background(), &d))
+
+	d.RoomID = 2
+	d.Username = "device-sn-0001"
+	require.Nil(t, s.UpsertDevice(context.Background(), &d))
+
+	d2, err := s.FetchDevice(context.Background(), "SN-0001")
+	require.Nil(t, err)
+	require.Equal(t, &d, d2)
+
+	d2, err = s.FetchDeviceByUsername(context.Background(), "device-sn-0001")
+	require.Nil(t, err)
+	require.Equal(t, &d, d2)
+
+	d2, err = s.FetchDevice(context.Background(), "SN-0002")
+	require.Nil(t, err)
+	require.Nil(t, d2)
+
+	EnableMockedError()
+	_, err = s.FetchDevice(context.Background(), "SN-0001")
+	require.Equal(t, ErrMocked, err)
+	require.Equal(t, ErrMocked, s.DeleteDevice(context.Background(), "SN-0001"))
+	DisableMockedError()
+
+	require.Nil(t, s.DeleteDevice(context.Background(), "SN-0001"))
+	d2, err = s.FetchDevice(context.Background(), "SN-0001")
+	require.Nil(t, err)
+	require.Nil(t, d2)
+}
+
+func TestMemoryStorage_FetchDevices(t *testing.T) {
+	s := NewDevice()
+	require.Nil(t, s.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0003", Type: roomsmodel.Box, RoomID: 1}))
+	require.Nil(t, s.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0001", Type: roomsmodel.Camera, RoomID: 1}))
+	require.Nil(t, s.UpsertDevice(context.Background(), &devicesmodel.Device{Serial: "SN-0002", Type: roomsmodel.Device}))
+
+	EnableMockedError()
+	_, err := s.FetchDevices(context.Background())
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	devices, err := s.FetchDevices(context.Background())
+	require.Nil(t, err)
+	require.Len(t, devices, 3)
+	require.Equal(t, "SN-0001", devices[0].Serial)
+
+	devices, err = s.FetchRoomDevices(context.Background(), 1)
+	require.Nil(t, err)
+	require.Len(t, devices, 2)
+	require.Equal(t, "SN-0001", devices[0].Serial)
+	require.Equal(t, "SN-0003", devices[1].Serial)
+
+	devices, err = s.FetchRoomDevices(context.Background(), 2)
+	require.Nil(t, err)
+	require.Len(t, devices, 0)
+}
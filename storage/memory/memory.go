@@ -16,6 +16,12 @@ type memoryContainer struct {
 	pubSub    *PubSub
 	offline   *Offline
 	room      *Room
+	device    *Device
+	qc        *QC
+	rsv       *Reservation
+	muc       *MUC
+	role      *Role
+	audit     *Audit
 }
 
 // New initializes in-memory storage and returns associated container.
@@ -31,19 +37,31 @@ func New() (repository.Container, error) {
 	c.pubSub = NewPubSub()
 	c.offline = NewOffline()
 	c.room = NewRoom()
+	c.device = NewDevice()
+	c.qc = NewQC()
+	c.rsv = NewReservation()
+	c.muc = NewMUC()
+	c.role = NewRole()
+	c.audit = NewAudit()
 
 	return &c, nil
 }
 
-func (c *memoryContainer) User() repository.User           { return c.user }
-func (c *memoryContainer) Roster() repository.Roster       { return c.roster }
-func (c *memoryContainer) Presences() repository.Presences { return c.presences }
-func (c *memoryContainer) VCard() repository.VCard         { return c.vCard }
-func (c *memoryContainer) Private() repository.Private     { return c.priv }
-func (c *memoryContainer) BlockList() repository.BlockList { return c.blockList }
-func (c *memoryContainer) PubSub() repository.PubSub       { return c.pubSub }
-func (c *memoryContainer) Offline() repository.Offline     { return c.offline }
-func (c *memoryContainer) Room() repository.Room           { return c.room }
+func (c *memoryContainer) User() repository.User               { return c.user }
+func (c *memoryContainer) Roster() repository.Roster           { return c.roster }
+func (c *memoryContainer) Presences() repository.Presences     { return c.presences }
+func (c *memoryContainer) VCard() repository.VCard             { return c.vCard }
+func (c *memoryContainer) Private() repository.Private         { return c.priv }
+func (c *memoryContainer) BlockList() repository.BlockList     { return c.blockList }
+func (c *memoryContainer) PubSub() repository.PubSub           { return c.pubSub }
+func (c *memoryContainer) Offline() repository.Offline         { return c.offline }
+func (c *memoryContainer) Room() repository.Room               { return c.room }
+func (c *memoryContainer) Device() repository.Device           { return c.device }
+func (c *memoryContainer) QC() repository.QC                   { return c.qc }
+func (c *memoryContainer) Reservation() repository.Reservation { return c.rsv }
+func (c *memoryContainer) MUC() repository.MUC                 { return c.muc }
+func (c *memoryContainer) Role() repository.Role               { return c.role }
+func (c *memoryContainer) Audit() repository.Audit             { return c.audit }
 
 func (c *memoryContainer) Close(_ context.Context) error { return nil }
 
@@ -0,0 +1,175 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	mucmodel "github.com/dantin/cubit/model/muc"
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// MUC represents an in-memory multi-user chat storage.
+type MUC struct {
+	*memoryStorage
+}
+
+// NewMUC returns an instance of MUC in-memory storage.
+func NewMUC() *MUC {
+	return &MUC{memoryStorage: newStorage()}
+}
+
+// UpsertMUCRoom inserts a new chat room entity into storage, or updates it if previously inserted.
+func (m *MUC) UpsertMUCRoom(_ context.Context, room *mucmodel.Room) error {
+	return m.updateInWriteLock(mucRoomsKey(), func(b []byte) ([]byte, error) {
+		var rooms []mucmodel.Room
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &rooms); err != nil {
+				return nil, err
+			}
+		}
+		for i, r := range rooms {
+			if r.Name == room.Name {
+				rooms[i] = *room
+				return serializer.SerializeSlice(&rooms)
+			}
+		}
+		rooms = append(rooms, *room)
+		return serializer.SerializeSlice(&rooms)
+	})
+}
+
+// DeleteMUCRoom deletes a chat room entity, along with its affiliations and history, from storage.
+func (m *MUC) DeleteMUCRoom(_ context.Context, name string) error {
+	return m.inWriteLock(func() error {
+		b := m.b[mucRoomsKey()]
+		if len(b) > 0 {
+			var rooms []mucmodel.Room
+			if err := serializer.DeserializeSlice(b, &rooms); err != nil {
+				return err
+			}
+			for i, r := range rooms {
+				if r.Name == name {
+					rooms = append(rooms[:i], rooms[i+1:]...)
+					break
+				}
+			}
+			b, err := serializer.SerializeSlice(&rooms)
+			if err != nil {
+				return err
+			}
+			m.b[mucRoomsKey()] = b
+		}
+		delete(m.b, mucAffiliationsKey(name))
+		delete(m.b, mucHistoryKey(name))
+		return nil
+	})
+}
+
+// FetchMUCRoom retrieves a chat room entity from storage.
+func (m *MUC) FetchMUCRoom(_ context.Context, name string) (*mucmodel.Room, error) {
+	var rooms []mucmodel.Room
+	if _, err := m.getEntities(mucRoomsKey(), &rooms); err != nil {
+		return nil, err
+	}
+	for _, r := range rooms {
+		if r.Name == name {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+
+// FetchMUCRooms retrieves from storage all chat room entities, sorted by name.
+func (m *MUC) FetchMUCRooms(_ context.Context) ([]mucmodel.Room, error) {
+	var rooms []mucmodel.Room
+	if _, err := m.getEntities(mucRoomsKey(), &rooms); err != nil {
+		return nil, err
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	return rooms, nil
+}
+
+// UpsertMUCAffiliation inserts a new chat room affiliation into storage, or updates it if previously inserted.
+func (m *MUC) UpsertMUCAffiliation(_ context.Context, affiliation *mucmodel.Affiliation) error {
+	return m.updateAffiliations(affiliation.RoomName, func(affiliations []mucmodel.Affiliation) []mucmodel.Affiliation {
+		for i, aff := range affiliations {
+			if aff.JID == affiliation.JID {
+				affiliations[i] = *affiliation
+				return affiliations
+			}
+		}
+		return append(affiliations, *affiliation)
+	})
+}
+
+// DeleteMUCAffiliation deletes a user affiliation from a chat room.
+func (m *MUC) DeleteMUCAffiliation(_ context.Context, roomName, jid string) error {
+	return m.updateAffiliations(roomName, func(affiliations []mucmodel.Affiliation) []mucmodel.Affiliation {
+		for i, aff := range affiliations {
+			if aff.JID == jid {
+				return append(affiliations[:i], affiliations[i+1:]...)
+			}
+		}
+		return affiliations
+	})
+}
+
+// FetchMUCAffiliations retrieves from storage all affiliations of a given chat room.
+func (m *MUC) FetchMUCAffiliations(_ context.Context, roomName string) ([]mucmodel.Affiliation, error) {
+	var affiliations []mucmodel.Affiliation
+	if _, err := m.getEntities(mucAffiliationsKey(roomName), &affiliations); err != nil {
+		return nil, err
+	}
+	return affiliations, nil
+}
+
+// InsertMUCMessage appends a message to chat room history.
+func (m *MUC) InsertMUCMessage(_ context.Context, message *mucmodel.Message) error {
+	return m.updateInWriteLock(mucHistoryKey(message.RoomName), func(b []byte) ([]byte, error) {
+		var messages []mucmodel.Message
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &messages); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, *message)
+		return serializer.SerializeSlice(&messages)
+	})
+}
+
+// FetchMUCHistory retrieves from storage up to limit latest chat room history messages, oldest first.
+func (m *MUC) FetchMUCHistory(_ context.Context, roomName string, limit int) ([]mucmodel.Message, error) {
+	var messages []mucmodel.Message
+	if _, err := m.getEntities(mucHistoryKey(roomName), &messages); err != nil {
+		return nil, err
+	}
+	if limit >= 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
+}
+
+func (m *MUC) updateAffiliations(roomName string, f func(affiliations []mucmodel.Affiliation) []mucmodel.Affiliation) error {
+	return m.updateInWriteLock(mucAffiliationsKey(roomName), func(b []byte) ([]byte, error) {
+		var affiliations []mucmodel.Affiliation
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &affiliations); err != nil {
+				return nil, err
+			}
+		}
+		affiliations = f(affiliations)
+		return serializer.SerializeSlice(&affiliations)
+	})
+}
+
+func mucRoomsKey() string {
+	return "mucRooms"
+}
+
+func mucAffiliationsKey(roomName string) string {
+	return "mucAffiliations:" + roomName
+}
+
+func mucHistoryKey(roomName string) string {
+	return "mucHistory:" + roomName
+}
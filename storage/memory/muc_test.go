@@ -0,0 +1,101 @@
+package memorystorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mucmodel "github.com/dantin/cubit/model/muc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_MUCRooms(t *testing.T) {
+	s := NewMUC()
+	r1 := mucmodel.Room{Name: "room02", Title: "Room 02", MembersOnly: true, RoomID: 2}
+	r2 := mucmodel.Room{Name: "room01", Title: "Room 01"}
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertMUCRoom(context.Background(), &r1))
+	_, err := s.FetchMUCRoom(context.Background(), "room02")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertMUCRoom(context.Background(), &r1))
+	require.Nil(t, s.UpsertMUCRoom(context.Background(), &r2))
+
+	r1.Subject = "exam"
+	require.Nil(t, s.UpsertMUCRoom(context.Background(), &r1))
+
+	room, err := s.FetchMUCRoom(context.Background(), "room02")
+	require.Nil(t, err)
+	require.Equal(t, &r1, room)
+
+	rooms, err := s.FetchMUCRooms(context.Background())
+	require.Nil(t, err)
+	require.Len(t, rooms, 2)
+	require.Equal(t, "room01", rooms[0].Name)
+	require.Equal(t, "room02", rooms[1].Name)
+
+	require.Nil(t, s.UpsertMUCAffiliation(context.Background(), &mucmodel.Affiliation{RoomName: "room02", JID: "alice@jackal.im", Affiliation: mucmodel.Member}))
+	require.Nil(t, s.InsertMUCMessage(context.Background(), &mucmodel.Message{RoomName: "room02", Nick: "alice", Body: "hi"}))
+
+	require.Nil(t, s.DeleteMUCRoom(context.Background(), "room02"))
+
+	room, err = s.FetchMUCRoom(context.Background(), "room02")
+	require.Nil(t, err)
+	require.Nil(t, room)
+
+	affiliations, err := s.FetchMUCAffiliations(context.Background(), "room02")
+	require.Nil(t, err)
+	require.Len(t, affiliations, 0)
+
+	messages, err := s.FetchMUCHistory(context.Background(), "room02", 10)
+	require.Nil(t, err)
+	require.Len(t, messages, 0)
+}
+
+func TestMemoryStorage_MUCAffiliations(t *testing.T) {
+	s := NewMUC()
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertMUCAffiliation(context.Background(), &mucmodel.Affiliation{RoomName: "room01", JID: "alice@jackal.im"}))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertMUCAffiliation(context.Background(), &mucmodel.Affiliation{RoomName: "room01", JID: "alice@jackal.im", Affiliation: mucmodel.Member}))
+	require.Nil(t, s.UpsertMUCAffiliation(context.Background(), &mucmodel.Affiliation{RoomName: "room01", JID: "bob@jackal.im", Affiliation: mucmodel.Owner}))
+	require.Nil(t, s.UpsertMUCAffiliation(context.Background(), &mucmodel.Affiliation{RoomName: "room01", JID: "alice@jackal.im", Affiliation: mucmodel.Admin}))
+
+	affiliations, err := s.FetchMUCAffiliations(context.Background(), "room01")
+	require.Nil(t, err)
+	require.Len(t, affiliations, 2)
+	require.Equal(t, mucmodel.Admin, affiliations[0].Affiliation)
+
+	require.Nil(t, s.DeleteMUCAffiliation(context.Background(), "room01", "alice@jackal.im"))
+
+	affiliations, err = s.FetchMUCAffiliations(context.Background(), "room01")
+	require.Nil(t, err)
+	require.Len(t, affiliations, 1)
+	require.Equal(t, "bob@jackal.im", affiliations[0].JID)
+}
+
+func TestMemoryStorage_MUCHistory(t *testing.T) {
+	s := NewMUC()
+	now := time.Now()
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.InsertMUCMessage(context.Background(), &mucmodel.Message{RoomName: "room01"}))
+	DisableMockedError()
+
+	for _, body := range []string{"m1", "m2", "m3"} {
+		require.Nil(t, s.InsertMUCMessage(context.Background(), &mucmodel.Message{RoomName: "room01", Nick: "alice", Body: body, Stamp: now}))
+	}
+	messages, err := s.FetchMUCHistory(context.Background(), "room01", 2)
+	require.Nil(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "m2", messages[0].Body)
+	require.Equal(t, "m3", messages[1].Body)
+
+	messages, err = s.FetchMUCHistory(context.Background(), "room01", 0)
+	require.Nil(t, err)
+	require.Len(t, messages, 0)
+}
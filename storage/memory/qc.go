@@ -0,0 +1,99 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	qcmodel "github.com/dantin/cubit/model/qc"
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// QC represents an in-memory QC review session storage.
+type QC struct {
+	*memoryStorage
+}
+
+// NewQC returns an instance of QC in-memory storage.
+func NewQC() *QC {
+	return &QC{memoryStorage: newStorage()}
+}
+
+// ClaimQCSession inserts a new in progress session into storage,
+// unless its stream is already claimed by another in progress session, in which case false is returned.
+func (q *QC) ClaimQCSession(_ context.Context, session *qcmodel.Session) (bool, error) {
+	var claimed bool
+	err := q.updateSessions(func(sessions []qcmodel.Session) []qcmodel.Session {
+		for _, s := range sessions {
+			if s.IsOpen() && s.RoomID == session.RoomID && s.StreamType == session.StreamType {
+				return sessions
+			}
+		}
+		claimed = true
+		return append(sessions, *session)
+	})
+	if err != nil {
+		return false, err
+	}
+	return claimed, nil
+}
+
+// CloseQCSession stores session verdict and notes, releasing its stream claim.
+func (q *QC) CloseQCSession(_ context.Context, session *qcmodel.Session) error {
+	return q.updateSessions(func(sessions []qcmodel.Session) []qcmodel.Session {
+		for i, s := range sessions {
+			if s.ID == session.ID && s.IsOpen() {
+				sessions[i].Verdict = session.Verdict
+				sessions[i].Notes = session.Notes
+				sessions[i].EndedAt = session.EndedAt
+				break
+			}
+		}
+		return sessions
+	})
+}
+
+// FetchQCSession retrieves a session entity from storage.
+func (q *QC) FetchQCSession(_ context.Context, id string) (*qcmodel.Session, error) {
+	sessions, err := q.fetchSessions(func(s *qcmodel.Session) bool { return s.ID == id })
+	if err != nil || len(sessions) == 0 {
+		return nil, err
+	}
+	return &sessions[0], nil
+}
+
+// FetchQCSessions retrieves from storage all session entities of a given room, sorted by start time.
+func (q *QC) FetchQCSessions(_ context.Context, roomID int) ([]qcmodel.Session, error) {
+	return q.fetchSessions(func(s *qcmodel.Session) bool { return s.RoomID == roomID })
+}
+
+func (q *QC) fetchSessions(match func(s *qcmodel.Session) bool) ([]qcmodel.Session, error) {
+	var sessions []qcmodel.Session
+	if _, err := q.getEntities(qcSessionsKey(), &sessions); err != nil {
+		return nil, err
+	}
+	var res []qcmodel.Session
+	for i := range sessions {
+		if match(&sessions[i]) {
+			res = append(res, sessions[i])
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].StartedAt.Before(res[j].StartedAt) })
+	return res, nil
+}
+
+func (q *QC) updateSessions(f func(sessions []qcmodel.Session) []qcmodel.Session) error {
+	return q.updateInWriteLock(qcSessionsKey(), func(b []byte) ([]byte, error) {
+		var sessions []qcmodel.Session
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &sessions); err != nil {
+				return nil, err
+			}
+		}
+		sessions = f(sessions)
+		return serializer.SerializeSlice(&sessions)
+	})
+}
+
+func qcSessionsKey() string {
+	return "qcSessions"
+}
@@ -0,0 +1,78 @@
+package memorystorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qcmodel "github.com/dantin/cubit/model/qc"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_ClaimQCSession(t *testing.T) {
+	s := NewQC()
+	now := time.Now()
+	s1 := qcmodel.Session{ID: "s1", RoomID: 1, StreamType: roomsmodel.Camera, Reviewer: "qc01", StartedAt: now}
+
+	EnableMockedError()
+	_, err := s.ClaimQCSession(context.Background(), &s1)
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	ok, err := s.ClaimQCSession(context.Background(), &s1)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// stream already claimed
+	s2 := qcmodel.Session{ID: "s2", RoomID: 1, StreamType: roomsmodel.Camera, Reviewer: "qc02", StartedAt: now.Add(time.Second)}
+	ok, err = s.ClaimQCSession(context.Background(), &s2)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// another stream of the same room
+	s3 := qcmodel.Session{ID: "s3", RoomID: 1, StreamType: roomsmodel.Box, Reviewer: "qc02", StartedAt: now.Add(time.Second)}
+	ok, err = s.ClaimQCSession(context.Background(), &s3)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	s1.Verdict = qcmodel.Pass
+	s1.Notes = "looks good"
+	s1.EndedAt = now.Add(time.Minute)
+	require.Nil(t, s.CloseQCSession(context.Background(), &s1))
+
+	// claim released
+	ok, err = s.ClaimQCSession(context.Background(), &s2)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	session, err := s.FetchQCSession(context.Background(), "s1")
+	require.Nil(t, err)
+	require.NotNil(t, session)
+	require.False(t, session.IsOpen())
+	require.Equal(t, qcmodel.Pass, session.Verdict)
+	require.Equal(t, "looks good", session.Notes)
+
+	session, err = s.FetchQCSession(context.Background(), "s4")
+	require.Nil(t, err)
+	require.Nil(t, session)
+}
+
+func TestMemoryStorage_FetchQCSessions(t *testing.T) {
+	s := NewQC()
+	now := time.Now()
+	_, _ = s.ClaimQCSession(context.Background(), &qcmodel.Session{ID: "s2", RoomID: 1, StreamType: roomsmodel.Box, StartedAt: now.Add(time.Second)})
+	_, _ = s.ClaimQCSession(context.Background(), &qcmodel.Session{ID: "s1", RoomID: 1, StreamType: roomsmodel.Camera, StartedAt: now})
+	_, _ = s.ClaimQCSession(context.Background(), &qcmodel.Session{ID: "s3", RoomID: 2, StreamType: roomsmodel.Camera, StartedAt: now})
+
+	EnableMockedError()
+	_, err := s.FetchQCSessions(context.Background(), 1)
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	sessions, err := s.FetchQCSessions(context.Background(), 1)
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, "s1", sessions[0].ID)
+	require.Equal(t, "s2", sessions[1].ID)
+}
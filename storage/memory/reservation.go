@@ -0,0 +1,115 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Reservation represents an in-memory room reservation storage.
+type Reservation struct {
+	*memoryStorage
+}
+
+// NewReservation returns an instance of Reservation in-memory storage.
+func NewReservation() *Reservation {
+	return &Reservation{memoryStorage: newStorage()}
+}
+
+// InsertReservation inserts a new reservation into storage,
+// unless it overlaps another reservation of the same room, in which case false is returned.
+func (r *Reservation) InsertReservation(_ context.Context, reservation *roomsmodel.Reservation) (bool, error) {
+	var inserted bool
+	err := r.updateReservations(func(reservations []roomsmodel.Reservation) []roomsmodel.Reservation {
+		for _, rsv := range reservations {
+			if rsv.RoomID == reservation.RoomID && rsv.Overlaps(reservation.Start, reservation.End) {
+				return reservations
+			}
+		}
+		inserted = true
+		return append(reservations, *reservation)
+	})
+	if err != nil {
+		return false, err
+	}
+	return inserted, nil
+}
+
+// DeleteReservation deletes a reservation entity from storage.
+func (r *Reservation) DeleteReservation(_ context.Context, id string) error {
+	return r.updateReservations(func(reservations []roomsmodel.Reservation) []roomsmodel.Reservation {
+		for i, rsv := range reservations {
+			if rsv.ID == id {
+				return append(reservations[:i], reservations[i+1:]...)
+			}
+		}
+		return reservations
+	})
+}
+
+// FetchReservation retrieves a reservation entity from storage.
+func (r *Reservation) FetchReservation(_ context.Context, id string) (*roomsmodel.Reservation, error) {
+	reservations, err := r.fetchReservations(func(rsv *roomsmodel.Reservation) bool { return rsv.ID == id })
+	if err != nil || len(reservations) == 0 {
+		return nil, err
+	}
+	return &reservations[0], nil
+}
+
+// FetchReservations retrieves from storage the reservations of a given room ending after from
+// and starting no later than to, sorted by start time. A zero from or to leaves range unbounded.
+func (r *Reservation) FetchReservations(_ context.Context, roomID int, from, to time.Time) ([]roomsmodel.Reservation, error) {
+	return r.fetchReservations(func(rsv *roomsmodel.Reservation) bool {
+		return rsv.RoomID == roomID && inRange(rsv, from, to)
+	})
+}
+
+// FetchUserReservations retrieves from storage the reservations held by a given user ending after from
+// and starting no later than to, sorted by start time. A zero from or to leaves range unbounded.
+func (r *Reservation) FetchUserReservations(_ context.Context, username string, from, to time.Time) ([]roomsmodel.Reservation, error) {
+	return r.fetchReservations(func(rsv *roomsmodel.Reservation) bool {
+		return rsv.Username == username && inRange(rsv, from, to)
+	})
+}
+
+func (r *Reservation) fetchReservations(match func(rsv *roomsmodel.Reservation) bool) ([]roomsmodel.Reservation, error) {
+	var reservations []roomsmodel.Reservation
+	if _, err := r.getEntities(reservationsKey(), &reservations); err != nil {
+		return nil, err
+	}
+	var res []roomsmodel.Reservation
+	for i := range reservations {
+		if match(&reservations[i]) {
+			res = append(res, reservations[i])
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Start.Before(res[j].Start) })
+	return res, nil
+}
+
+func (r *Reservation) updateReservations(f func(reservations []roomsmodel.Reservation) []roomsmodel.Reservation) error {
+	return r.updateInWriteLock(reservationsKey(), func(b []byte) ([]byte, error) {
+		var reservations []roomsmodel.Reservation
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &reservations); err != nil {
+				return nil, err
+			}
+		}
+		reservations = f(reservations)
+		return serializer.SerializeSlice(&reservations)
+	})
+}
+
+func inRange(rsv *roomsmodel.Reservation, from, to time.Time) bool {
+	if !from.IsZero() && !rsv.End.After(from) {
+		return false
+	}
+	return to.IsZero() || !rsv.Start.After(to)
+}
+
+func reservationsKey() string {
+	return "reservations"
+}
@@ -0,0 +1,70 @@
+package memorystorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_Reservations(t *testing.T) {
+	s := NewReservation()
+	now := time.Now()
+	r1 := roomsmodel.Reservation{ID: "r1", RoomID: 1, Username: "alice", Purpose: "exam", Start: now, End: now.Add(time.Hour)}
+
+	EnableMockedError()
+	_, err := s.InsertReservation(context.Background(), &r1)
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	ok, err := s.InsertReservation(context.Background(), &r1)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// overlapping
+	r2 := roomsmodel.Reservation{ID: "r2", RoomID: 1, Username: "bob", Start: now.Add(time.Minute * 30), End: now.Add(time.Hour * 2)}
+	ok, err = s.InsertReservation(context.Background(), &r2)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// back to back
+	r2.Start = now.Add(time.Hour)
+	ok, err = s.InsertReservation(context.Background(), &r2)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// another room
+	r3 := roomsmodel.Reservation{ID: "r3", RoomID: 2, Username: "bob", Start: now, End: now.Add(time.Hour)}
+	ok, err = s.InsertReservation(context.Background(), &r3)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	reservations, err := s.FetchReservations(context.Background(), 1, time.Time{}, time.Time{})
+	require.Nil(t, err)
+	require.Len(t, reservations, 2)
+	require.Equal(t, "r1", reservations[0].ID)
+	require.Equal(t, "r2", reservations[1].ID)
+
+	reservations, err = s.FetchReservations(context.Background(), 1, now.Add(time.Hour), now.Add(time.Hour))
+	require.Nil(t, err)
+	require.Len(t, reservations, 1)
+	require.Equal(t, "r2", reservations[0].ID)
+
+	reservations, err = s.FetchUserReservations(context.Background(), "bob", now, now)
+	require.Nil(t, err)
+	require.Len(t, reservations, 1)
+	require.Equal(t, "r3", reservations[0].ID)
+
+	rsv, err := s.FetchReservation(context.Background(), "r1")
+	require.Nil(t, err)
+	require.NotNil(t, rsv)
+	require.Equal(t, "exam", rsv.Purpose)
+
+	require.Nil(t, s.DeleteReservation(context.Background(), "r1"))
+
+	rsv, err = s.FetchReservation(context.Background(), "r1")
+	require.Nil(t, err)
+	require.Nil(t, rsv)
+}
@@ -0,0 +1,93 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	"github.com/dantin/cubit/model/serializer"
+)
+
+// Role represents an in-memory role storage.
+type Role struct {
+	*memoryStorage
+}
+
+// NewRole returns an instance of Role in-memory storage.
+func NewRole() *Role {
+	return &Role{memoryStorage: newStorage()}
+}
+
+// UpsertRole inserts a new role entity into storage, or updates it if previously inserted.
+func (r *Role) UpsertRole(_ context.Context, role *rolesmodel.Role) error {
+	return r.updateRoles(func(roles []rolesmodel.Role) []rolesmodel.Role {
+		for i, rl := range roles {
+			if rl.Name == role.Name {
+				roles[i] = *role
+				return roles
+			}
+		}
+		return append(roles, *role)
+	})
+}
+
+// DeleteRole deletes a role entity from storage.
+func (r *Role) DeleteRole(_ context.Context, name string) error {
+	return r.updateRoles(func(roles []rolesmodel.Role) []rolesmodel.Role {
+		for i, rl := range roles {
+			if rl.Name == name {
+				return append(roles[:i], roles[i+1:]...)
+			}
+		}
+		return roles
+	})
+}
+
+// FetchRole retrieves a role entity from storage.
+func (r *Role) FetchRole(_ context.Context, name string) (*rolesmodel.Role, error) {
+	roles, err := r.fetchRoles()
+	if err != nil {
+		return nil, err
+	}
+	for i := range roles {
+		if roles[i].Name == name {
+			return &roles[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// FetchRoles retrieves all role entities from storage, sorted by name.
+func (r *Role) FetchRoles(_ context.Context) ([]rolesmodel.Role, error) {
+	roles, err := r.fetchRoles()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *Role) fetchRoles() ([]rolesmodel.Role, error) {
+	var roles []rolesmodel.Role
+	if _, err := r.getEntities(rolesKey(), &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *Role) updateRoles(f func(roles []rolesmodel.Role) []rolesmodel.Role) error {
+	return r.updateInWriteLock(rolesKey(), func(b []byte) ([]byte, error) {
+		var roles []rolesmodel.Role
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &roles); err != nil {
+				return nil, err
+			}
+		}
+		roles = f(roles)
+		return serializer.SerializeSlice(&roles)
+	})
+}
+
+func rolesKey() string {
+	return "roles"
+}
@@ -0,0 +1,65 @@
+package memorystorage
+
+import (
+	"context"
+	"testing"
+
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_InsertRole(t *testing.T) {
+	r := rolesmodel.Role{Name: "operator", Permissions: []rolesmodel.Permission{rolesmodel.RoomsList}}
+	s := NewRole()
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertRole(context.Background(), &r))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertRole(context.Background(), &r))
+
+	r.Permissions = append(r.Permissions, rolesmodel.DeviceControl)
+	require.Nil(t, s.UpsertRole(context.Background(), &r))
+
+	role, err := s.FetchRole(context.Background(), "operator")
+	require.Nil(t, err)
+	require.Equal(t, &r, role)
+}
+
+func TestMemoryStorage_FetchRoles(t *testing.T) {
+	s := NewRole()
+	_ = s.UpsertRole(context.Background(), &rolesmodel.Role{Name: "viewer", Permissions: []rolesmodel.Permission{rolesmodel.RoomsList}})
+	_ = s.UpsertRole(context.Background(), &rolesmodel.Role{Name: "operator", Permissions: []rolesmodel.Permission{rolesmodel.DeviceControl}})
+
+	EnableMockedError()
+	_, err := s.FetchRoles(context.Background())
+	require.Equal(t, ErrMocked, err)
+	_, err = s.FetchRole(context.Background(), "viewer")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	roles, err := s.FetchRoles(context.Background())
+	require.Nil(t, err)
+	require.Len(t, roles, 2)
+	require.Equal(t, "operator", roles[0].Name)
+	require.Equal(t, "viewer", roles[1].Name)
+
+	role, err := s.FetchRole(context.Background(), "none")
+	require.Nil(t, err)
+	require.Nil(t, role)
+}
+
+func TestMemoryStorage_DeleteRole(t *testing.T) {
+	s := NewRole()
+	_ = s.UpsertRole(context.Background(), &rolesmodel.Role{Name: "viewer", Permissions: []rolesmodel.Permission{rolesmodel.RoomsList}})
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeleteRole(context.Background(), "viewer"))
+	DisableMockedError()
+
+	require.Nil(t, s.DeleteRole(context.Background(), "viewer"))
+
+	role, err := s.FetchRole(context.Background(), "viewer")
+	require.Nil(t, err)
+	require.Nil(t, role)
+}
@@ -2,8 +2,12 @@ package memorystorage
 
 import (
 	"context"
+	"errors"
+	"sort"
 
+	"github.com/dantin/cubit/model"
 	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // Room represents an in-memory room storage.
@@ -16,22 +20,352 @@ func NewRoom() *Room {
 	return &Room{memoryStorage: newStorage()}
 }
 
+// UpsertRoom inserts a new room entity into storage, or updates it if previously inserted.
+func (r *Room) UpsertRoom(_ context.Context, room *roomsmodel.Room) error {
+	return r.updateRooms(func(rooms []roomsmodel.Room) ([]roomsmodel.Room, error) {
+		var maxID int
+		for i, rm := range rooms {
+			if rm.ID == room.ID {
+				rooms[i].Name = room.Name
+				rooms[i].Username = room.Username
+				rooms[i].Type = room.Type
+				return rooms, nil
+			}
+			if rm.ID > maxID {
+				maxID = rm.ID
+			}
+		}
+		if room.ID == 0 {
+			room.ID = maxID + 1
+		}
+		return append(rooms, roomsmodel.Room{
+			ID:       room.ID,
+			Name:     room.Name,
+			Username: room.Username,
+			Type:     room.Type,
+		}), nil
+	})
+}
+
+// DeleteRoom deletes a room entity, and all its video streams, from storage.
+func (r *Room) DeleteRoom(_ context.Context, roomID int) error {
+	err := r.updateRooms(func(rooms []roomsmodel.Room) ([]roomsmodel.Room, error) {
+		for i, rm := range rooms {
+			if rm.ID == roomID {
+				return append(rooms[:i], rooms[i+1:]...), nil
+			}
+		}
+		return rooms, nil // not present
+	})
+	if err != nil {
+		return err
+	}
+	return r.updateBindings(func(bindings []roomsmodel.Binding) []roomsmodel.Binding {
+		var res []roomsmodel.Binding
+		for _, b := range bindings {
+			if b.RoomID != roomID {
+				res = append(res, b)
+			}
+		}
+		return res
+	})
+}
+
+// BindRoom binds a room to user.
+func (r *Room) BindRoom(_ context.Context, roomID int, username string) error {
+	return r.updateBindings(func(bindings []roomsmodel.Binding) []roomsmodel.Binding {
+		for _, b := range bindings {
+			if b.RoomID == roomID && b.Username == username {
+				return bindings // already bound
+			}
+		}
+		return append(bindings, roomsmodel.Binding{RoomID: roomID, Username: username})
+	})
+}
+
+// UnbindRoom unbinds a room from user.
+func (r *Room) UnbindRoom(_ context.Context, roomID int, username string) error {
+	return r.updateBindings(func(bindings []roomsmodel.Binding) []roomsmodel.Binding {
+		for i, b := range bindings {
+			if b.RoomID == roomID && b.Username == username {
+				return append(bindings[:i], bindings[i+1:]...)
+			}
+		}
+		return bindings // not bound
+	})
+}
+
+// FetchBoundRooms retrieves from storage all room entities bound to a given user.
+func (r *Room) FetchBoundRooms(_ context.Context, username string) ([]roomsmodel.Room, error) {
+	rooms, err := r.fetchBoundRooms(username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rooms {
+		streams, err := resolveVideoStreams(rooms[i].Streams, model.Usr.String())
+		if err != nil {
+			return nil, err
+		}
+		rooms[i].Streams = streams
+	}
+	return rooms, nil
+}
+
+// FetchBoundUsers retrieves from storage the usernames of all users bound to a given room.
+func (r *Room) FetchBoundUsers(_ context.Context, roomID int) ([]string, error) {
+	var bindings []roomsmodel.Binding
+	if _, err := r.getEntities(roomBindingsKey(), &bindings); err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, b := range bindings {
+		if b.RoomID == roomID {
+			res = append(res, b.Username)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// UpsertVideoStream inserts a new video stream into its room, or updates it if a stream of the same type was previously inserted.
+func (r *Room) UpsertVideoStream(_ context.Context, stream *roomsmodel.VideoStream) error {
+	return r.updateRooms(func(rooms []roomsmodel.Room) ([]roomsmodel.Room, error) {
+		for i, rm := range rooms {
+			if rm.ID != stream.RoomID {
+				continue
+			}
+			for j, vs := range rm.Streams {
+				if vs.Type == stream.Type {
+					rm.Streams[j] = *stream
+					return rooms, nil
+				}
+			}
+			rooms[i].Streams = append(rm.Streams, *stream)
+			return rooms, nil
+		}
+		return rooms, nil // room not present
+	})
+}
+
+// DeleteVideoStream deletes a room video stream from storage.
+func (r *Room) DeleteVideoStream(_ context.Context, roomID int, tp roomsmodel.VideoType) error {
+	return r.updateRooms(func(rooms []roomsmodel.Room) ([]roomsmodel.Room, error) {
+		for i, rm := range rooms {
+			if rm.ID != roomID {
+				continue
+			}
+			for j, vs := range rm.Streams {
+				if vs.Type == tp {
+					rooms[i].Streams = append(rm.Streams[:j], rm.Streams[j+1:]...)
+					return rooms, nil
+				}
+			}
+		}
+		return rooms, nil // not present
+	})
+}
+
 // FetchRoom retrieves a room entity from storage.
-func (r *Room) FetchRoom(ctx context.Context, username string) (*roomsmodel.Room, error) {
+func (r *Room) FetchRoom(_ context.Context, username string) (*roomsmodel.Room, error) {
+	rooms, err := r.fetchRooms()
+	if err != nil {
+		return nil, err
+	}
+	for _, rm := range rooms {
+		if rm.Username != username {
+			continue
+		}
+		streams, err := resolveVideoStreams(rm.Streams, model.Usr.String())
+		if err != nil {
+			return nil, err
+		}
+		rm.Streams = streams
+		return &rm, nil
+	}
 	return nil, nil
 }
 
-// FetchRooms retrieves room entites from storage.
-func (r *Room) FetchRooms(ctx context.Context, page int, pageSize int) ([]roomsmodel.Room, error) {
+// FetchRoomByID retrieves a room entity, along with its raw video stream definitions, from storage.
+func (r *Room) FetchRoomByID(_ context.Context, roomID int) (*roomsmodel.Room, error) {
+	rooms, err := r.fetchRooms()
+	if err != nil {
+		return nil, err
+	}
+	for _, rm := range rooms {
+		if rm.ID == roomID {
+			return &rm, nil
+		}
+	}
 	return nil, nil
 }
 
-// CountRooms  returns current size of rooms.
-func (r *Room) CountRooms(ctx context.Context) (int, error) {
-	return 0, nil
+// FetchRooms retrieves from storage a page of room entities matching filter, along with the position of its first room.
+func (r *Room) FetchRooms(_ context.Context, filter *roomsmodel.Filter, page *roomsmodel.Page) ([]roomsmodel.Room, int, error) {
+	res, err := r.filterRooms(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	from, to := 0, len(res)
+	switch {
+	case page.After > 0:
+		from = sort.Search(len(res), func(i int) bool { return res[i].ID > page.After })
+	case page.Before > 0:
+		to = sort.Search(len(res), func(i int) bool { return res[i].ID >= page.Before })
+	case !page.Last:
+		from = page.Index
+	}
+	if page.Max > 0 {
+		if page.Before > 0 || page.Last {
+			if to-page.Max > from {
+				from = to - page.Max
+			}
+		} else if from+page.Max < to {
+			to = from + page.Max
+		}
+	}
+	if from >= to {
+		return nil, from, nil
+	}
+	res = res[from:to]
+	for i := range res {
+		streams, err := resolveVideoStreams(res[i].Streams, model.Admin.String())
+		if err != nil {
+			return nil, 0, err
+		}
+		res[i].Streams = streams
+	}
+	return res, from, nil
+}
+
+// CountRooms returns the number of rooms matching filter.
+func (r *Room) CountRooms(_ context.Context, filter *roomsmodel.Filter) (int, error) {
+	rooms, err := r.filterRooms(filter)
+	if err != nil {
+		return 0, err
+	}
+	return len(rooms), nil
 }
 
-// FetchQCStream retrieves qc room stream entites from storage.
-func (r *Room) FetchQCStream(ctx context.Context, username string) (*roomsmodel.VideoStream, error) {
+// FetchQCStream retrieves from storage the stream routed to user within the qc room bound to it.
+func (r *Room) FetchQCStream(_ context.Context, username string) (*roomsmodel.VideoStream, error) {
+	rooms, err := r.fetchBoundRooms(username)
+	if err != nil {
+		return nil, err
+	}
+	for _, rm := range rooms {
+		if rm.Type != roomsmodel.QC {
+			continue
+		}
+		streams, err := resolveVideoStreams(rm.Streams, username)
+		if err != nil {
+			return nil, err
+		}
+		switch len(streams) {
+		case 0:
+			return nil, nil
+		case 1:
+			return &streams[0], nil
+		default:
+			return nil, errors.New("multiple qc found")
+		}
+	}
 	return nil, nil
 }
+
+func (r *Room) fetchRooms() ([]roomsmodel.Room, error) {
+	var rooms []roomsmodel.Room
+	if _, err := r.getEntities(roomsKey(), &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (r *Room) filterRooms(filter *roomsmodel.Filter) ([]roomsmodel.Room, error) {
+	rooms, err := r.fetchRooms()
+	if err != nil {
+		return nil, err
+	}
+	var res []roomsmodel.Room
+	for _, rm := range rooms {
+		if filter.Matches(&rm) {
+			res = append(res, rm)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+func (r *Room) fetchBoundRooms(username string) ([]roomsmodel.Room, error) {
+	var bindings []roomsmodel.Binding
+	if _, err := r.getEntities(roomBindingsKey(), &bindings); err != nil {
+		return nil, err
+	}
+	rooms, err := r.fetchRooms()
+	if err != nil {
+		return nil, err
+	}
+	var res []roomsmodel.Room
+	for _, rm := range rooms {
+		for _, b := range bindings {
+			if b.RoomID == rm.ID && b.Username == username {
+				res = append(res, rm)
+				break
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+func (r *Room) updateBindings(f func(bindings []roomsmodel.Binding) []roomsmodel.Binding) error {
+	return r.updateInWriteLock(roomBindingsKey(), func(b []byte) ([]byte, error) {
+		var bindings []roomsmodel.Binding
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &bindings); err != nil {
+				return nil, err
+			}
+		}
+		bindings = f(bindings)
+		return serializer.SerializeSlice(&bindings)
+	})
+}
+
+func (r *Room) updateRooms(f func(rooms []roomsmodel.Room) ([]roomsmodel.Room, error)) error {
+	return r.updateInWriteLock(roomsKey(), func(b []byte) ([]byte, error) {
+		var rooms []roomsmodel.Room
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &rooms); err != nil {
+				return nil, err
+			}
+		}
+		rooms, err := f(rooms)
+		if err != nil {
+			return nil, err
+		}
+		return serializer.SerializeSlice(&rooms)
+	})
+}
+
+func roomsKey() string {
+	return "rooms"
+}
+
+func roomBindingsKey() string {
+	return "roomBindings"
+}
+
+// resolveVideoStreams returns the streams routed to target, discarding those without a route to it.
+// Route maps are left out, so that other targets are never disclosed.
+func resolveVideoStreams(streams []roomsmodel.VideoStream, target string) ([]roomsmodel.VideoStream, error) {
+	var res []roomsmodel.VideoStream
+	for _, vs := range streams {
+		routes, err := vs.Routes()
+		if err != nil {
+			return nil, err
+		}
+		if streamURI, ok := routes.Lookup(target); ok {
+			res = append(res, roomsmodel.VideoStream{Type: vs.Type, Stream: streamURI, RoomID: vs.RoomID, Metadata: vs.Metadata})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,292 @@
+package memorystorage
+
+import (
+	"context"
+	"testing"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_InsertRoom(t *testing.T) {
+	s := NewRoom()
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal}))
+	DisableMockedError()
+
+	r1 := roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal}
+	r2 := roomsmodel.Room{Name: "Room 02", Username: "room02", Type: roomsmodel.Normal}
+	require.Nil(t, s.UpsertRoom(context.Background(), &r1))
+	require.Nil(t, s.UpsertRoom(context.Background(), &r2))
+	require.Equal(t, 1, r1.ID)
+	require.Equal(t, 2, r2.ID)
+
+	// update
+	r1.Name = "Room QC"
+	r1.Type = roomsmodel.QC
+	require.Nil(t, s.UpsertRoom(context.Background(), &r1))
+
+	room, err := s.FetchRoomByID(context.Background(), 1)
+	require.Nil(t, err)
+	require.Equal(t, &r1, room)
+
+	// explicit identifier
+	r3 := roomsmodel.Room{ID: 10, Name: "Room 10", Username: "room10", Type: roomsmodel.Normal}
+	require.Nil(t, s.UpsertRoom(context.Background(), &r3))
+
+	room, err = s.FetchRoomByID(context.Background(), 10)
+	require.Nil(t, err)
+	require.Equal(t, &r3, room)
+}
+
+func TestMemoryStorage_DeleteRoom(t *testing.T) {
+	s := NewRoom()
+	require.Nil(t, s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal}))
+	require.Nil(t, s.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{Type: roomsmodel.Camera, RoomID: 1}))
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeleteRoom(context.Background(), 1))
+	DisableMockedError()
+
+	require.Nil(t, s.DeleteRoom(context.Background(), 1))
+
+	room, err := s.FetchRoomByID(context.Background(), 1)
+	require.Nil(t, err)
+	require.Nil(t, room)
+
+	// not present
+	require.Nil(t, s.DeleteRoom(context.Background(), 1))
+}
+
+func TestMemoryStorage_UpsertVideoStream(t *testing.T) {
+	s := NewRoom()
+	require.Nil(t, s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal}))
+
+	vs := roomsmodel.VideoStream{
+		In:        "srt://127.0.0.1:9001",
+		Broadcast: "srt://127.0.0.1:9002",
+		Route:     `{"user": "srt://127.0.0.1:9003"}`,
+		Type:      roomsmodel.Camera,
+		RoomID:    1,
+	}
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertVideoStream(context.Background(), &vs))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertVideoStream(context.Background(), &vs))
+
+	vs.Broadcast = "srt://127.0.0.1:9004"
+	require.Nil(t, s.UpsertVideoStream(context.Background(), &vs))
+	require.Nil(t, s.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{Type: roomsmodel.Device, RoomID: 1}))
+
+	room, err := s.FetchRoomByID(context.Background(), 1)
+	require.Nil(t, err)
+	require.Len(t, room.Streams, 2)
+	require.Equal(t, vs, room.Streams[0])
+
+	require.Nil(t, s.DeleteVideoStream(context.Background(), 1, roomsmodel.Camera))
+
+	room, err = s.FetchRoomByID(context.Background(), 1)
+	require.Nil(t, err)
+	require.Len(t, room.Streams, 1)
+	require.Equal(t, roomsmodel.Device, room.Streams[0].Type)
+}
+
+func TestMemoryStorage_FetchRoom(t *testing.T) {
+	s := NewRoom()
+	require.Nil(t, s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal}))
+	require.Nil(t, s.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`,
+		Type:   roomsmodel.Camera,
+		RoomID: 1,
+	}))
+	require.Nil(t, s.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"admin": "srt://127.0.0.1:9004"}`,
+		Type:   roomsmodel.Device,
+		RoomID: 1,
+	}))
+
+	EnableMockedError()
+	_, err := s.FetchRoom(context.Background(), "room01")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	room, err := s.FetchRoom(context.Background(), "room01")
+	require.Nil(t, err)
+	require.NotNil(t, room)
+	require.Equal(t, "Room 01", room.Name)
+	require.Equal(t, []roomsmodel.VideoStream{
+		{Stream: "srt://127.0.0.1:9001", Type: roomsmodel.Camera, RoomID: 1},
+	}, room.Streams)
+
+	room, err = s.FetchRoom(context.Background(), "room02")
+	require.Nil(t, err)
+	require.Nil(t, room)
+}
+
+func TestMemoryStorage_FetchRooms(t *testing.T) {
+	s := NewRoom()
+	require.Nil(t, s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room QC", Username: "admin", Type: roomsmodel.QC}))
+	for _, username := range []string{"room01", "room02", "room03"} {
+		room := roomsmodel.Room{Name: username, Username: username, Type: roomsmodel.Normal}
+		require.Nil(t, s.UpsertRoom(context.Background(), &room))
+		require.Nil(t, s.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+			Route:  `{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`,
+			Type:   roomsmodel.Camera,
+			RoomID: room.ID,
+		}))
+	}
+
+	normal := &roomsmodel.Filter{Type: roomsmodel.Normal}
+
+	EnableMockedError()
+	_, _, err := s.FetchRooms(context.Background(), normal, &roomsmodel.Page{Max: 2})
+	require.Equal(t, ErrMocked, err)
+	_, err = s.CountRooms(context.Background(), normal)
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	count, err := s.CountRooms(context.Background(), normal)
+	require.Nil(t, err)
+	require.Equal(t, 3, count)
+
+	count, err = s.CountRooms(context.Background(), &roomsmodel.Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 4, count)
+
+	rooms, index, err := s.FetchRooms(context.Background(), normal, &roomsmodel.Page{Max: 2})
+	require.Nil(t, err)
+	require.Equal(t, 0, index)
+	require.Len(t, rooms, 2)
+	require.Equal(t, "room01", rooms[0].Username)
+	require.Equal(t, "room02", rooms[1].Username)
+	require.Equal(t, []roomsmodel.VideoStream{
+		{Stream: "srt://127.0.0.1:9002", Type: roomsmodel.Camera, RoomID: 2},
+	}, rooms[0].Streams)
+
+	rooms, index, err = s.FetchRooms(context.Background(), normal, &roomsmodel.Page{Max: 2, After: 3})
+	require.Nil(t, err)
+	require.Equal(t, 2, index)
+	require.Len(t, rooms, 1)
+	require.Equal(t, "room03", rooms[0].Username)
+
+	rooms, index, err = s.FetchRooms(context.Background(), normal, &roomsmodel.Page{Max: 2, Before: 4})
+	require.Nil(t, err)
+	require.Equal(t, 0, index)
+	require.Len(t, rooms, 2)
+	require.Equal(t, "room01", rooms[0].Username)
+
+	rooms, index, err = s.FetchRooms(context.Background(), normal, &roomsmodel.Page{Max: 2, Last: true})
+	require.Nil(t, err)
+	require.Equal(t, 1, index)
+	require.Len(t, rooms, 2)
+	require.Equal(t, "room02", rooms[0].Username)
+	require.Equal(t, "room03", rooms[1].Username)
+
+	rooms, _, err = s.FetchRooms(context.Background(), normal, &roomsmodel.Page{Max: 2, Index: 3})
+	require.Nil(t, err)
+	require.Len(t, rooms, 0)
+
+	rooms, _, err = s.FetchRooms(context.Background(), &roomsmodel.Filter{Name: "ROOM0", ExcludedIDs: []int{3}}, &roomsmodel.Page{})
+	require.Nil(t, err)
+	require.Len(t, rooms, 2)
+	require.Equal(t, "room01", rooms[0].Username)
+	require.Equal(t, "room03", rooms[1].Username)
+
+	rooms, _, err = s.FetchRooms(context.Background(), &roomsmodel.Filter{IDs: []int{}}, &roomsmodel.Page{})
+	require.Nil(t, err)
+	require.Len(t, rooms, 0)
+}
+
+func TestMemoryStorage_FetchQCStream(t *testing.T) {
+	s := NewRoom()
+
+	vs, err := s.FetchQCStream(context.Background(), "room01")
+	require.Nil(t, err)
+	require.Nil(t, vs)
+
+	require.Nil(t, s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room QC", Username: "admin", Type: roomsmodel.QC}))
+	require.Nil(t, s.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"room01": "srt://127.0.0.1:39991", "room02": "srt://127.0.0.1:39992"}`,
+		Type:   roomsmodel.Camera,
+		RoomID: 1,
+	}))
+
+	// not bound
+	vs, err = s.FetchQCStream(context.Background(), "room02")
+	require.Nil(t, err)
+	require.Nil(t, vs)
+
+	require.Nil(t, s.BindRoom(context.Background(), 1, "room02"))
+	require.Nil(t, s.BindRoom(context.Background(), 1, "room03"))
+
+	EnableMockedError()
+	_, err = s.FetchQCStream(context.Background(), "room01")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	vs, err = s.FetchQCStream(context.Background(), "room02")
+	require.Nil(t, err)
+	require.NotNil(t, vs)
+	require.Equal(t, "srt://127.0.0.1:39992", vs.Stream)
+
+	vs, err = s.FetchQCStream(context.Background(), "room03")
+	require.Nil(t, err)
+	require.Nil(t, vs)
+}
+
+func TestMemoryStorage_BindRoom(t *testing.T) {
+	s := NewRoom()
+	require.Nil(t, s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room QC", Username: "admin", Type: roomsmodel.QC}))
+	require.Nil(t, s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 01", Username: "room01", Type: roomsmodel.Normal}))
+	require.Nil(t, s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 02", Username: "room02", Type: roomsmodel.Normal}))
+	require.Nil(t, s.UpsertVideoStream(context.Background(), &roomsmodel.VideoStream{
+		Route:  `{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`,
+		Type:   roomsmodel.Camera,
+		RoomID: 3,
+	}))
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.BindRoom(context.Background(), 1, "alice"))
+	DisableMockedError()
+
+	require.Nil(t, s.BindRoom(context.Background(), 3, "alice"))
+	require.Nil(t, s.BindRoom(context.Background(), 1, "alice"))
+	require.Nil(t, s.BindRoom(context.Background(), 1, "alice"))
+	require.Nil(t, s.BindRoom(context.Background(), 2, "bob"))
+
+	EnableMockedError()
+	_, err := s.FetchBoundRooms(context.Background(), "alice")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	rooms, err := s.FetchBoundRooms(context.Background(), "alice")
+	require.Nil(t, err)
+	require.Len(t, rooms, 2)
+	require.Equal(t, 1, rooms[0].ID)
+	require.Equal(t, 3, rooms[1].ID)
+	require.Equal(t, []roomsmodel.VideoStream{
+		{Stream: "srt://127.0.0.1:9001", Type: roomsmodel.Camera, RoomID: 3},
+	}, rooms[1].Streams)
+
+	EnableMockedError()
+	_, err = s.FetchBoundUsers(context.Background(), 1)
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	usernames, err := s.FetchBoundUsers(context.Background(), 3)
+	require.Nil(t, err)
+	require.Equal(t, []string{"alice"}, usernames)
+
+	require.Nil(t, s.UnbindRoom(context.Background(), 1, "alice"))
+	rooms, err = s.FetchBoundRooms(context.Background(), "alice")
+	require.Nil(t, err)
+	require.Len(t, rooms, 1)
+
+	// room deletion removes its bindings
+	require.Nil(t, s.DeleteRoom(context.Background(), 2))
+	rooms, err = s.FetchBoundRooms(context.Background(), "bob")
+	require.Nil(t, err)
+	require.Len(t, rooms, 0)
+}
@@ -2,8 +2,10 @@ package memorystorage
 
 import (
 	"context"
+	"time"
 
 	"github.com/dantin/cubit/model"
+	"github.com/dantin/cubit/model/serializer"
 )
 
 // User represents an in-memory user storage.
@@ -18,6 +20,11 @@ func NewUser() *User {
 
 // UpsertUser inserts a new user entity into storage, or updates it if previously inserted.
 func (m *User) UpsertUser(_ context.Context, user *model.User) error {
+	if user.LastPresence != nil {
+		usr := *user
+		usr.LastPresenceAt = time.Now()
+		user = &usr
+	}
 	return m.saveEntity(userKey(user.Username), user)
 }
 
@@ -42,6 +49,21 @@ func (m *User) FetchUser(_ context.Context, username string) (*model.User, error
 
 }
 
+// UpdateUserRole assigns a role to an existing user.
+func (m *User) UpdateUserRole(_ context.Context, username string, role model.Role) error {
+	return m.updateInWriteLock(userKey(username), func(b []byte) ([]byte, error) {
+		if b == nil {
+			return nil, nil
+		}
+		var user model.User
+		if err := serializer.Deserialize(b, &user); err != nil {
+			return nil, err
+		}
+		user.Role = role
+		return serializer.Serialize(&user)
+	})
+}
+
 // UserExists tells whether or not a user exists within storage.
 func (m *User) UserExists(_ context.Context, username string) (bool, error) {
 	return m.keyExists(userKey(username))
@@ -67,3 +67,24 @@ func TestMemoryStorage_DeleteUser(t *testing.T) {
 	usr, _ := s.FetchUser(context.Background(), "user")
 	require.Nil(t, usr)
 }
+
+func TestMemoryStorage_UpdateUserRole(t *testing.T) {
+	u := model.User{Username: "user", Password: "password", Role: model.Usr}
+	s := NewUser()
+
+	_ = s.UpsertUser(context.Background(), &u)
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpdateUserRole(context.Background(), "user", model.Admin))
+	DisableMockedError()
+
+	require.Nil(t, s.UpdateUserRole(context.Background(), "user", model.Admin))
+	usr, _ := s.FetchUser(context.Background(), "user")
+	require.Equal(t, model.Admin, usr.Role)
+	require.Equal(t, "password", usr.Password)
+
+	// not existing user
+	require.Nil(t, s.UpdateUserRole(context.Background(), "none", model.Admin))
+	ok, _ := s.UserExists(context.Background(), "none")
+	require.False(t, ok)
+}
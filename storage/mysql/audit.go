@@ -0,0 +1,116 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// appendAuditEventRetries bounds how many times a concurrent chain fork gets retried.
+	appendAuditEventRetries = 3
+
+	// errDuplicateEntry is MySQL ER_DUP_ENTRY error number.
+	errDuplicateEntry = 1062
+)
+
+type mySQLAudit struct {
+	*mySQLStorage
+}
+
+func newAudit(db *sql.DB) *mySQLAudit {
+	return &mySQLAudit{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (a *mySQLAudit) AppendAuditEvent(ctx context.Context, event *auditmodel.Event) error {
+	var err error
+	for i := 0; i < appendAuditEventRetries; i++ {
+		// a duplicated sequence number means another event got appended first, so chain it again
+		if err = a.appendAuditEvent(ctx, event); !isDuplicateEntry(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (a *mySQLAudit) appendAuditEvent(ctx context.Context, event *auditmodel.Event) error {
+	return a.inTransaction(ctx, func(tx *sql.Tx) error {
+		// lock chain tail, sequence number primary key rejects any concurrent fork anyway
+		var prev auditmodel.Event
+		err := sq.Select("seq", "hash").
+			From("audit_events").
+			OrderBy("seq DESC").
+			Limit(1).
+			Suffix("FOR UPDATE").
+			RunWith(tx).QueryRowContext(ctx).Scan(&prev.Seq, &prev.Hash)
+		switch err {
+		case nil:
+			event.Chain(&prev)
+		case sql.ErrNoRows:
+			event.Chain(nil)
+		default:
+			return err
+		}
+		_, err = sq.Insert("audit_events").
+			Columns("seq", "actor", "action", "room_id", "target", "details", "created_at", "prev_hash", "hash").
+			Values(event.Seq, event.Actor, string(event.Action), event.RoomID, event.Target, event.Details, event.Timestamp, event.PrevHash, event.Hash).
+			RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
+func isDuplicateEntry(err error) bool {
+	var mySQLErr *mysql.MySQLError
+	return errors.As(err, &mySQLErr) && mySQLErr.Number == errDuplicateEntry
+}
+
+func (a *mySQLAudit) FetchAuditEvents(ctx context.Context, filter *auditmodel.Filter) ([]auditmodel.Event, error) {
+	var pred sq.And
+	if len(filter.Actor) > 0 {
+		pred = append(pred, sq.Eq{"actor": filter.Actor})
+	}
+	if filter.RoomID != 0 {
+		pred = append(pred, sq.Eq{"room_id": filter.RoomID})
+	}
+	if !filter.From.IsZero() {
+		pred = append(pred, sq.GtOrEq{"created_at": filter.From.UTC()})
+	}
+	if !filter.To.IsZero() {
+		pred = append(pred, sq.Lt{"created_at": filter.To.UTC()})
+	}
+	q := sq.Select("seq", "actor", "action", "room_id", "target", "details", "created_at", "prev_hash", "hash").
+		From("audit_events").
+		OrderBy("seq")
+	if len(pred) > 0 {
+		q = q.Where(pred)
+	}
+	if filter.Max > 0 {
+		q = q.Limit(uint64(filter.Max))
+	}
+	rows, err := q.RunWith(a.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []auditmodel.Event
+	for rows.Next() {
+		var (
+			event  auditmodel.Event
+			action string
+		)
+		if err := rows.Scan(&event.Seq, &event.Actor, &action, &event.RoomID, &event.Target, &event.Details, &event.Timestamp, &event.PrevHash, &event.Hash); err != nil {
+			return nil, err
+		}
+		event.Action = auditmodel.Action(action)
+		event.Timestamp = event.Timestamp.UTC()
+		res = append(res, event)
+	}
+	return res, nil
+}
@@ -0,0 +1,123 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	auditmodel "github.com/dantin/cubit/model/audit"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+var auditColumns = []string{"seq", "actor", "action", "room_id", "target", "details", "created_at", "prev_hash", "hash"}
+
+func newAuditMock() (*mySQLAudit, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLAudit{
+		mySQLStorage: s,
+	}, sqlMock
+}
+
+func TestMySQLStorage_AppendAuditEvent(t *testing.T) {
+	now := time.Now()
+
+	s, mock := newAuditMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT seq, hash FROM audit_events ORDER BY seq DESC LIMIT 1 FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}).AddRow(7, "abcd"))
+	mock.ExpectExec("INSERT INTO audit_events (.+)").
+		WithArgs(8, "admin", "room.delete", 2, "", "", sqlmock.AnyArg(), "abcd", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	e := auditmodel.Event{Actor: "admin", Action: auditmodel.RoomDelete, RoomID: 2, Timestamp: now}
+	err := s.AppendAuditEvent(context.Background(), &e)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, int64(8), e.Seq)
+	require.Equal(t, e.ComputeHash(), e.Hash)
+
+	// first event
+	s, mock = newAuditMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT seq, hash FROM audit_events (.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}))
+	mock.ExpectExec("INSERT INTO audit_events (.+)").
+		WithArgs(1, "admin", "room.delete", 2, "", "", sqlmock.AnyArg(), "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	e = auditmodel.Event{Actor: "admin", Action: auditmodel.RoomDelete, RoomID: 2, Timestamp: now}
+	err = s.AppendAuditEvent(context.Background(), &e)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, int64(1), e.Seq)
+
+	// concurrent chain fork
+	s, mock = newAuditMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT seq, hash FROM audit_events (.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}).AddRow(7, "abcd"))
+	mock.ExpectExec("INSERT INTO audit_events (.+)").
+		WithArgs(8, "admin", "room.delete", 2, "", "", sqlmock.AnyArg(), "abcd", sqlmock.AnyArg()).
+		WillReturnError(&mysql.MySQLError{Number: errDuplicateEntry, Message: "Duplicate entry '8' for key 'PRIMARY'"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT seq, hash FROM audit_events (.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}).AddRow(8, "ef01"))
+	mock.ExpectExec("INSERT INTO audit_events (.+)").
+		WithArgs(9, "admin", "room.delete", 2, "", "", sqlmock.AnyArg(), "ef01", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	e = auditmodel.Event{Actor: "admin", Action: auditmodel.RoomDelete, RoomID: 2, Timestamp: now}
+	err = s.AppendAuditEvent(context.Background(), &e)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, int64(9), e.Seq)
+
+	s, mock = newAuditMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT seq, hash FROM audit_events (.+)").WillReturnError(errMySQLStorage)
+	mock.ExpectRollback()
+
+	err = s.AppendAuditEvent(context.Background(), &e)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchAuditEvents(t *testing.T) {
+	now := time.Now()
+
+	s, mock := newAuditMock()
+	mock.ExpectQuery("SELECT (.+) FROM audit_events WHERE \\(actor = \\? AND room_id = \\? AND created_at >= \\? AND created_at < \\?\\) ORDER BY seq LIMIT 10").
+		WithArgs("admin", 2, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(auditColumns).
+			AddRow(1, "admin", "room.create", 2, "Room 02", "", now, "", "h1").
+			AddRow(2, "admin", "room.delete", 2, "", "", now, "h1", "h2"))
+
+	events, err := s.FetchAuditEvents(context.Background(), &auditmodel.Filter{Actor: "admin", RoomID: 2, From: now.Add(-time.Hour), To: now.Add(time.Hour), Max: 10})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, auditmodel.RoomCreate, events[0].Action)
+	require.Equal(t, "h1", events[1].PrevHash)
+
+	s, mock = newAuditMock()
+	mock.ExpectQuery("SELECT (.+) FROM audit_events ORDER BY seq").
+		WillReturnRows(sqlmock.NewRows(auditColumns))
+
+	events, err = s.FetchAuditEvents(context.Background(), &auditmodel.Filter{})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, events, 0)
+
+	s, mock = newAuditMock()
+	mock.ExpectQuery("SELECT (.+) FROM audit_events (.+)").WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchAuditEvents(context.Background(), &auditmodel.Filter{})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
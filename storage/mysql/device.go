@@ -0,0 +1,100 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+)
+
+type mySQLDevice struct {
+	*mySQLStorage
+}
+
+func newDevice(db *sql.DB) *mySQLDevice {
+	return &mySQLDevice{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (d *mySQLDevice) UpsertDevice(ctx context.Context, device *devicesmodel.Device) error {
+	_, err := sq.Insert("devices").
+		Columns("serial", "`type`", "room_id", "username", "created_at", "updated_at").
+		Values(device.Serial, device.Type.String(), device.RoomID, device.Username, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE `type` = ?, room_id = ?, username = ?, updated_at = NOW()", device.Type.String(), device.RoomID, device.Username).
+		RunWith(d.db).ExecContext(ctx)
+	return err
+}
+
+func (d *mySQLDevice) DeleteDevice(ctx context.Context, serial string) error {
+	_, err := sq.Delete("devices").Where(sq.Eq{"serial": serial}).RunWith(d.db).ExecContext(ctx)
+	return err
+}
+
+func (d *mySQLDevice) FetchDevice(ctx context.Context, serial string) (*devicesmodel.Device, error) {
+	return d.fetchDevice(ctx, sq.Eq{"serial": serial})
+}
+
+func (d *mySQLDevice) FetchDeviceByUsername(ctx context.Context, username string) (*devicesmodel.Device, error) {
+	return d.fetchDevice(ctx, sq.Eq{"username": username})
+}
+
+func (d *mySQLDevice) FetchDevices(ctx context.Context) ([]devicesmodel.Device, error) {
+	return d.fetchDevices(ctx, nil)
+}
+
+func (d *mySQLDevice) FetchRoomDevices(ctx context.Context, roomID int) ([]devicesmodel.Device, error) {
+	return d.fetchDevices(ctx, sq.Eq{"room_id": roomID})
+}
+
+func (d *mySQLDevice) fetchDevice(ctx context.Context, pred interface{}) (*devicesmodel.Device, error) {
+	var (
+		device devicesmodel.Device
+		tp     string
+	)
+	err := sq.Select("serial", "`type`", "room_id", "username").
+		From("devices").
+		Where(pred).
+		RunWith(d.db).
+		QueryRowContext(ctx).
+		Scan(&device.Serial, &tp, &device.RoomID, &device.Username)
+	switch err {
+	case nil:
+		device.Type = roomsmodel.ParseVideoTypeString(tp)
+		return &device, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (d *mySQLDevice) fetchDevices(ctx context.Context, pred interface{}) ([]devicesmodel.Device, error) {
+	q := sq.Select("serial", "`type`", "room_id", "username").
+		From("devices").
+		OrderBy("serial")
+	if pred != nil {
+		q = q.Where(pred)
+	}
+	rows, err := q.RunWith(d.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []devicesmodel.Device
+	for rows.Next() {
+		var (
+			device devicesmodel.Device
+			tp     string
+		)
+		if err := rows.Scan(&device.Serial, &tp, &device.RoomID, &device.Username); err != nil {
+			return nil, err
+		}
+		device.Type = roomsmodel.ParseVideoTypeString(tp)
+		res = append(res, device)
+	}
+	return res, nil
+}
@@ -0,0 +1,122 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	devicesmodel "github.com/dantin/cubit/model/devices"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/stretchr/testify/require"
+)
+
+var deviceColumns = []string{"serial", "type", "room_id", "username"}
+
+func newDeviceMock() (*mySQLDevice, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLDevice{
+		mySQLStorage: s,
+	}, sqlMock
+}
+
+func TestMySQLStorage_UpsertDevice(t *testing.T) {
+	d := devicesmodel.Device{Serial: "SN-0001", Type: roomsmodel.Camera, RoomID: 2, Username: "device-sn-0001"}
+
+	s, mock := newDeviceMock()
+	mock.ExpectExec("INSERT INTO devices (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("SN-0001", "camera", 2, "device-sn-0001", "camera", 2, "device-sn-0001").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertDevice(context.Background(), &d)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newDeviceMock()
+	mock.ExpectExec("INSERT INTO devices (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.UpsertDevice(context.Background(), &d)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_DeleteDevice(t *testing.T) {
+	s, mock := newDeviceMock()
+	mock.ExpectExec("DELETE FROM devices WHERE serial = ?").
+		WithArgs("SN-0001").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeleteDevice(context.Background(), "SN-0001")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newDeviceMock()
+	mock.ExpectExec("DELETE FROM devices (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.DeleteDevice(context.Background(), "SN-0001")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchDevice(t *testing.T) {
+	s, mock := newDeviceMock()
+	mock.ExpectQuery("SELECT (.+) FROM devices WHERE serial = ?").
+		WithArgs("SN-0001").
+		WillReturnRows(sqlmock.NewRows(deviceColumns).AddRow("SN-0001", "box", 2, ""))
+
+	d, err := s.FetchDevice(context.Background(), "SN-0001")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, &devicesmodel.Device{Serial: "SN-0001", Type: roomsmodel.Box, RoomID: 2}, d)
+
+	s, mock = newDeviceMock()
+	mock.ExpectQuery("SELECT (.+) FROM devices WHERE username = ?").
+		WithArgs("device-sn-0001").
+		WillReturnRows(sqlmock.NewRows(deviceColumns))
+
+	d, err = s.FetchDeviceByUsername(context.Background(), "device-sn-0001")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, d)
+
+	s, mock = newDeviceMock()
+	mock.ExpectQuery("SELECT (.+) FROM devices (.+)").
+		WithArgs("SN-0001").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchDevice(context.Background(), "SN-0001")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchDevices(t *testing.T) {
+	s, mock := newDeviceMock()
+	mock.ExpectQuery("SELECT (.+) FROM devices ORDER BY serial").
+		WillReturnRows(sqlmock.NewRows(deviceColumns).
+			AddRow("SN-0001", "box", 2, "").
+			AddRow("SN-0002", "camera", 0, "device-sn-0002"))
+
+	devices, err := s.FetchDevices(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, devices, 2)
+	require.Equal(t, roomsmodel.Camera, devices[1].Type)
+
+	s, mock = newDeviceMock()
+	mock.ExpectQuery("SELECT (.+) FROM devices WHERE room_id = \\? ORDER BY serial").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(deviceColumns).AddRow("SN-0001", "box", 2, ""))
+
+	devices, err = s.FetchRoomDevices(context.Background(), 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, devices, 1)
+
+	s, mock = newDeviceMock()
+	mock.ExpectQuery("SELECT (.+) FROM devices (.+)").
+		WithArgs(2).
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchRoomDevices(context.Background(), 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
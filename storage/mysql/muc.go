@@ -0,0 +1,160 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	mucmodel "github.com/dantin/cubit/model/muc"
+)
+
+var mucRoomColumns = []string{"name", "title", "subject", "members_only", "room_id"}
+
+type mySQLMUC struct {
+	*mySQLStorage
+}
+
+func newMUC(db *sql.DB) *mySQLMUC {
+	return &mySQLMUC{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (s *mySQLMUC) UpsertMUCRoom(ctx context.Context, room *mucmodel.Room) error {
+	_, err := sq.Insert("muc_rooms").
+		Columns("name", "title", "subject", "members_only", "room_id", "updated_at", "created_at").
+		Values(room.Name, room.Title, room.Subject, room.MembersOnly, room.RoomID, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE title = ?, subject = ?, members_only = ?, room_id = ?, updated_at = NOW()", room.Title, room.Subject, room.MembersOnly, room.RoomID).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLMUC) DeleteMUCRoom(ctx context.Context, name string) error {
+	return s.inTransaction(ctx, func(tx *sql.Tx) error {
+		if _, err := sq.Delete("muc_messages").Where(sq.Eq{"room_name": name}).RunWith(tx).ExecContext(ctx); err != nil {
+			return err
+		}
+		if _, err := sq.Delete("muc_affiliations").Where(sq.Eq{"room_name": name}).RunWith(tx).ExecContext(ctx); err != nil {
+			return err
+		}
+		_, err := sq.Delete("muc_rooms").Where(sq.Eq{"name": name}).RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
+func (s *mySQLMUC) FetchMUCRoom(ctx context.Context, name string) (*mucmodel.Room, error) {
+	var room mucmodel.Room
+	err := sq.Select(mucRoomColumns...).
+		From("muc_rooms").
+		Where(sq.Eq{"name": name}).
+		RunWith(s.db).QueryRowContext(ctx).
+		Scan(&room.Name, &room.Title, &room.Subject, &room.MembersOnly, &room.RoomID)
+	switch err {
+	case nil:
+		return &room, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (s *mySQLMUC) FetchMUCRooms(ctx context.Context) ([]mucmodel.Room, error) {
+	rows, err := sq.Select(mucRoomColumns...).
+		From("muc_rooms").
+		OrderBy("name").
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []mucmodel.Room
+	for rows.Next() {
+		var room mucmodel.Room
+		if err := rows.Scan(&room.Name, &room.Title, &room.Subject, &room.MembersOnly, &room.RoomID); err != nil {
+			return nil, err
+		}
+		res = append(res, room)
+	}
+	return res, nil
+}
+
+func (s *mySQLMUC) UpsertMUCAffiliation(ctx context.Context, affiliation *mucmodel.Affiliation) error {
+	aff := affiliation.Affiliation.String()
+	_, err := sq.Insert("muc_affiliations").
+		Columns("room_name", "jid", "affiliation", "updated_at", "created_at").
+		Values(affiliation.RoomName, affiliation.JID, aff, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE affiliation = ?, updated_at = NOW()", aff).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLMUC) DeleteMUCAffiliation(ctx context.Context, roomName, jid string) error {
+	_, err := sq.Delete("muc_affiliations").
+		Where(sq.And{sq.Eq{"room_name": roomName}, sq.Eq{"jid": jid}}).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLMUC) FetchMUCAffiliations(ctx context.Context, roomName string) ([]mucmodel.Affiliation, error) {
+	rows, err := sq.Select("room_name", "jid", "affiliation").
+		From("muc_affiliations").
+		Where(sq.Eq{"room_name": roomName}).
+		OrderBy("jid").
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []mucmodel.Affiliation
+	for rows.Next() {
+		var aff mucmodel.Affiliation
+		var affStr string
+		if err := rows.Scan(&aff.RoomName, &aff.JID, &affStr); err != nil {
+			return nil, err
+		}
+		aff.Affiliation, _ = mucmodel.ParseAffiliationString(affStr)
+		res = append(res, aff)
+	}
+	return res, nil
+}
+
+func (s *mySQLMUC) InsertMUCMessage(ctx context.Context, message *mucmodel.Message) error {
+	_, err := sq.Insert("muc_messages").
+		Columns("room_name", "nick", "jid", "body", "created_at").
+		Values(message.RoomName, message.Nick, message.JID, message.Body, message.Stamp).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLMUC) FetchMUCHistory(ctx context.Context, roomName string, limit int) ([]mucmodel.Message, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	rows, err := sq.Select("room_name", "nick", "jid", "body", "created_at").
+		From("muc_messages").
+		Where(sq.Eq{"room_name": roomName}).
+		OrderBy("id DESC").
+		Limit(uint64(limit)).
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []mucmodel.Message
+	for rows.Next() {
+		var msg mucmodel.Message
+		if err := rows.Scan(&msg.RoomName, &msg.Nick, &msg.JID, &msg.Body, &msg.Stamp); err != nil {
+			return nil, err
+		}
+		res = append(res, msg)
+	}
+	// oldest first
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res, nil
+}
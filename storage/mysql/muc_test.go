@@ -0,0 +1,179 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	mucmodel "github.com/dantin/cubit/model/muc"
+	"github.com/stretchr/testify/require"
+)
+
+func newMUCMock() (*mySQLMUC, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLMUC{
+		mySQLStorage: s,
+	}, sqlMock
+}
+
+func TestMySQLStorage_UpsertMUCRoom(t *testing.T) {
+	room := mucmodel.Room{Name: "room01", Title: "Room 01", Subject: "exam", MembersOnly: true, RoomID: 1}
+
+	s, mock := newMUCMock()
+	mock.ExpectExec("INSERT INTO muc_rooms (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("room01", "Room 01", "exam", true, 1, "Room 01", "exam", true, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.UpsertMUCRoom(context.Background(), &room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMUCMock()
+	mock.ExpectExec("INSERT INTO muc_rooms (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.UpsertMUCRoom(context.Background(), &room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_DeleteMUCRoom(t *testing.T) {
+	s, mock := newMUCMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM muc_messages WHERE room_name = \\?").WithArgs("room01").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM muc_affiliations WHERE room_name = \\?").WithArgs("room01").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM muc_rooms WHERE name = \\?").WithArgs("room01").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.DeleteMUCRoom(context.Background(), "room01")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMUCMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM muc_messages (.+)").WillReturnError(errMySQLStorage)
+	mock.ExpectRollback()
+
+	err = s.DeleteMUCRoom(context.Background(), "room01")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchMUCRooms(t *testing.T) {
+	s, mock := newMUCMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms WHERE name = \\?").
+		WithArgs("room01").
+		WillReturnRows(sqlmock.NewRows(mucRoomColumns).AddRow("room01", "Room 01", "", true, 1))
+
+	room, err := s.FetchMUCRoom(context.Background(), "room01")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, room)
+	require.Equal(t, 1, room.RoomID)
+	require.True(t, room.MembersOnly)
+
+	s, mock = newMUCMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms WHERE name = \\?").
+		WithArgs("room02").
+		WillReturnRows(sqlmock.NewRows(mucRoomColumns))
+
+	room, err = s.FetchMUCRoom(context.Background(), "room02")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, room)
+
+	s, mock = newMUCMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms ORDER BY name").
+		WillReturnRows(sqlmock.NewRows(mucRoomColumns).
+			AddRow("room01", "Room 01", "", true, 1).
+			AddRow("lobby", "Lobby", "welcome", false, 0))
+
+	rooms, err := s.FetchMUCRooms(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, rooms, 2)
+	require.Equal(t, "welcome", rooms[1].Subject)
+
+	s, mock = newMUCMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchMUCRooms(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_MUCAffiliations(t *testing.T) {
+	s, mock := newMUCMock()
+	mock.ExpectExec("INSERT INTO muc_affiliations (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("room01", "alice@jackal.im", "admin", "admin").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.UpsertMUCAffiliation(context.Background(), &mucmodel.Affiliation{RoomName: "room01", JID: "alice@jackal.im", Affiliation: mucmodel.Admin})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMUCMock()
+	mock.ExpectExec("DELETE FROM muc_affiliations WHERE \\(room_name = \\? AND jid = \\?\\)").
+		WithArgs("room01", "alice@jackal.im").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = s.DeleteMUCAffiliation(context.Background(), "room01", "alice@jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMUCMock()
+	mock.ExpectQuery("SELECT room_name, jid, affiliation FROM muc_affiliations WHERE room_name = \\? ORDER BY jid").
+		WithArgs("room01").
+		WillReturnRows(sqlmock.NewRows([]string{"room_name", "jid", "affiliation"}).
+			AddRow("room01", "alice@jackal.im", "owner").
+			AddRow("room01", "bob@jackal.im", "member"))
+
+	affiliations, err := s.FetchMUCAffiliations(context.Background(), "room01")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, affiliations, 2)
+	require.Equal(t, mucmodel.Owner, affiliations[0].Affiliation)
+	require.Equal(t, mucmodel.Member, affiliations[1].Affiliation)
+
+	s, mock = newMUCMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_affiliations (.+)").WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchMUCAffiliations(context.Background(), "room01")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_MUCHistory(t *testing.T) {
+	now := time.Now()
+	msg := mucmodel.Message{RoomName: "room01", Nick: "alice", JID: "alice@jackal.im", Body: "hi", Stamp: now}
+
+	s, mock := newMUCMock()
+	mock.ExpectExec("INSERT INTO muc_messages \\(room_name,nick,jid,body,created_at\\) VALUES \\(\\?,\\?,\\?,\\?,\\?\\)").
+		WithArgs("room01", "alice", "alice@jackal.im", "hi", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertMUCMessage(context.Background(), &msg)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMUCMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_messages WHERE room_name = \\? ORDER BY id DESC LIMIT 2").
+		WithArgs("room01").
+		WillReturnRows(sqlmock.NewRows([]string{"room_name", "nick", "jid", "body", "created_at"}).
+			AddRow("room01", "bob", "bob@jackal.im", "m3", now).
+			AddRow("room01", "alice", "alice@jackal.im", "m2", now))
+
+	messages, err := s.FetchMUCHistory(context.Background(), "room01", 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "m2", messages[0].Body)
+	require.Equal(t, "m3", messages[1].Body)
+
+	s, mock = newMUCMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_messages (.+)").WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchMUCHistory(context.Background(), "room01", 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
@@ -21,6 +21,12 @@ type mySQLContainer struct {
 	pubSub    *mySQLPubSub
 	offline   *mySQLOffline
 	room      *mySQLRoom
+	device    *mySQLDevice
+	qc        *mySQLQC
+	rsv       *mySQLReservation
+	muc       *mySQLMUC
+	role      *mySQLRole
+	audit     *mySQLAudit
 
 	h      *sql.DB
 	doneCh chan chan bool
@@ -60,19 +66,31 @@ func New(cfg *Config) (repository.Container, error) {
 	c.pubSub = newPubSub(c.h)
 	c.offline = newOffline(c.h)
 	c.room = newRoom(c.h)
+	c.device = newDevice(c.h)
+	c.qc = newQC(c.h)
+	c.rsv = newReservation(c.h)
+	c.muc = newMUC(c.h)
+	c.role = newRole(c.h)
+	c.audit = newAudit(c.h)
 
 	return c, nil
 }
 
-func (c *mySQLContainer) User() repository.User           { return c.user }
-func (c *mySQLContainer) Roster() repository.Roster       { return c.roster }
-func (c *mySQLContainer) Presences() repository.Presences { return c.presences }
-func (c *mySQLContainer) VCard() repository.VCard         { return c.vCard }
-func (c *mySQLContainer) Private() repository.Private     { return c.priv }
-func (c *mySQLContainer) BlockList() repository.BlockList { return c.blockList }
-func (c *mySQLContainer) PubSub() repository.PubSub       { return c.pubSub }
-func (c *mySQLContainer) Offline() repository.Offline     { return c.offline }
-func (c *mySQLContainer) Room() repository.Room           { return c.room }
+func (c *mySQLContainer) User() repository.User               { return c.user }
+func (c *mySQLContainer) Roster() repository.Roster           { return c.roster }
+func (c *mySQLContainer) Presences() repository.Presences     { return c.presences }
+func (c *mySQLContainer) VCard() repository.VCard             { return c.vCard }
+func (c *mySQLContainer) Private() repository.Private         { return c.priv }
+func (c *mySQLContainer) BlockList() repository.BlockList     { return c.blockList }
+func (c *mySQLContainer) PubSub() repository.PubSub           { return c.pubSub }
+func (c *mySQLContainer) Offline() repository.Offline         { return c.offline }
+func (c *mySQLContainer) Room() repository.Room               { return c.room }
+func (c *mySQLContainer) Device() repository.Device           { return c.device }
+func (c *mySQLContainer) QC() repository.QC                   { return c.qc }
+func (c *mySQLContainer) Reservation() repository.Reservation { return c.rsv }
+func (c *mySQLContainer) MUC() repository.MUC                 { return c.muc }
+func (c *mySQLContainer) Role() repository.Role               { return c.role }
+func (c *mySQLContainer) Audit() repository.Audit             { return c.audit }
 
 func (c *mySQLContainer) IsClusterCompatible() bool {
 	return true
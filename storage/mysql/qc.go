@@ -0,0 +1,100 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	qcmodel "github.com/dantin/cubit/model/qc"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+)
+
+type mySQLQC struct {
+	*mySQLStorage
+}
+
+func newQC(db *sql.DB) *mySQLQC {
+	return &mySQLQC{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (s *mySQLQC) ClaimQCSession(ctx context.Context, session *qcmodel.Session) (bool, error) {
+	// unique claim key makes insertion a no-op while stream is claimed by another session
+	res, err := sq.Insert("qc_sessions").
+		Options("IGNORE").
+		Columns("id", "room_id", "stream_type", "reviewer", "verdict", "notes", "claim", "started_at").
+		Values(session.ID, session.RoomID, session.StreamType.String(), session.Reviewer, "", "", qcClaim(session), session.StartedAt).
+		RunWith(s.db).ExecContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *mySQLQC) CloseQCSession(ctx context.Context, session *qcmodel.Session) error {
+	_, err := sq.Update("qc_sessions").
+		Set("verdict", session.Verdict.String()).
+		Set("notes", session.Notes).
+		Set("claim", nil).
+		Set("ended_at", session.EndedAt).
+		Where(sq.And{sq.Eq{"id": session.ID}, sq.Eq{"ended_at": nil}}).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLQC) FetchQCSession(ctx context.Context, id string) (*qcmodel.Session, error) {
+	sessions, err := s.fetchQCSessions(ctx, sq.Eq{"id": id})
+	switch {
+	case err != nil:
+		return nil, err
+	case len(sessions) == 0:
+		return nil, nil
+	default:
+		return &sessions[0], nil
+	}
+}
+
+func (s *mySQLQC) FetchQCSessions(ctx context.Context, roomID int) ([]qcmodel.Session, error) {
+	return s.fetchQCSessions(ctx, sq.Eq{"room_id": roomID})
+}
+
+func (s *mySQLQC) fetchQCSessions(ctx context.Context, pred interface{}) ([]qcmodel.Session, error) {
+	rows, err := sq.Select("id", "room_id", "stream_type", "reviewer", "verdict", "notes", "started_at", "ended_at").
+		From("qc_sessions").
+		Where(pred).
+		OrderBy("started_at", "id").
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []qcmodel.Session
+	for rows.Next() {
+		var (
+			session     qcmodel.Session
+			tp, verdict string
+			endedAt     sql.NullTime
+		)
+		if err := rows.Scan(&session.ID, &session.RoomID, &tp, &session.Reviewer, &verdict, &session.Notes, &session.StartedAt, &endedAt); err != nil {
+			return nil, err
+		}
+		session.StreamType = roomsmodel.ParseVideoTypeString(tp)
+		session.Verdict = qcmodel.ParseVerdictString(verdict)
+		if endedAt.Valid {
+			session.EndedAt = endedAt.Time
+		}
+		res = append(res, session)
+	}
+	return res, nil
+}
+
+func qcClaim(session *qcmodel.Session) string {
+	return fmt.Sprintf("%d:%s", session.RoomID, session.StreamType.String())
+}
@@ -0,0 +1,111 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	qcmodel "github.com/dantin/cubit/model/qc"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/stretchr/testify/require"
+)
+
+var qcSessionColumns = []string{"id", "room_id", "stream_type", "reviewer", "verdict", "notes", "started_at", "ended_at"}
+
+func newQCMock() (*mySQLQC, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLQC{
+		mySQLStorage: s,
+	}, sqlMock
+}
+
+func TestMySQLStorage_ClaimQCSession(t *testing.T) {
+	now := time.Now()
+	session := qcmodel.Session{ID: "s1", RoomID: 1, StreamType: roomsmodel.Camera, Reviewer: "qc01", StartedAt: now}
+
+	s, mock := newQCMock()
+	mock.ExpectExec("INSERT IGNORE INTO qc_sessions (.+)").
+		WithArgs("s1", 1, "camera", "qc01", "", "", "1:camera", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ok, err := s.ClaimQCSession(context.Background(), &session)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// already claimed
+	s, mock = newQCMock()
+	mock.ExpectExec("INSERT IGNORE INTO qc_sessions (.+)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ok, err = s.ClaimQCSession(context.Background(), &session)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	s, mock = newQCMock()
+	mock.ExpectExec("INSERT IGNORE INTO qc_sessions (.+)").WillReturnError(errMySQLStorage)
+
+	_, err = s.ClaimQCSession(context.Background(), &session)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_CloseQCSession(t *testing.T) {
+	now := time.Now()
+	session := qcmodel.Session{ID: "s1", Verdict: qcmodel.Fail, Notes: "no signal", EndedAt: now}
+
+	s, mock := newQCMock()
+	mock.ExpectExec("UPDATE qc_sessions SET (.+) WHERE \\(id = \\? AND ended_at IS NULL\\)").
+		WithArgs("fail", "no signal", nil, now, "s1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.CloseQCSession(context.Background(), &session)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newQCMock()
+	mock.ExpectExec("UPDATE qc_sessions (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.CloseQCSession(context.Background(), &session)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchQCSessions(t *testing.T) {
+	now := time.Now()
+
+	s, mock := newQCMock()
+	mock.ExpectQuery("SELECT (.+) FROM qc_sessions WHERE room_id = \\? ORDER BY started_at, id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(qcSessionColumns).
+			AddRow("s1", 1, "camera", "qc01", "pass", "", now, now.Add(time.Minute)).
+			AddRow("s2", 1, "camera", "qc02", "", "", now.Add(time.Hour), nil))
+
+	sessions, err := s.FetchQCSessions(context.Background(), 1)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, roomsmodel.Camera, sessions[0].StreamType)
+	require.Equal(t, qcmodel.Pass, sessions[0].Verdict)
+	require.False(t, sessions[0].IsOpen())
+	require.True(t, sessions[1].IsOpen())
+
+	s, mock = newQCMock()
+	mock.ExpectQuery("SELECT (.+) FROM qc_sessions WHERE id = \\?").
+		WithArgs("s3").
+		WillReturnRows(sqlmock.NewRows(qcSessionColumns))
+
+	session, err := s.FetchQCSession(context.Background(), "s3")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, session)
+
+	s, mock = newQCMock()
+	mock.ExpectQuery("SELECT (.+) FROM qc_sessions (.+)").WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchQCSession(context.Background(), "s1")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
@@ -0,0 +1,116 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+)
+
+var reservationColumns = []string{"id", "room_id", "username", "purpose", "start_at", "end_at"}
+
+type mySQLReservation struct {
+	*mySQLStorage
+}
+
+func newReservation(db *sql.DB) *mySQLReservation {
+	return &mySQLReservation{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (s *mySQLReservation) InsertReservation(ctx context.Context, reservation *roomsmodel.Reservation) (bool, error) {
+	var inserted bool
+	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
+		// lock overlapping room reservations until insertion is committed
+		var count int
+		err := sq.Select("COUNT(*)").
+			From("reservations").
+			Where(sq.And{
+				sq.Eq{"room_id": reservation.RoomID},
+				sq.Lt{"start_at": reservation.End},
+				sq.Gt{"end_at": reservation.Start},
+			}).
+			Suffix("FOR UPDATE").
+			RunWith(tx).QueryRowContext(ctx).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		_, err = sq.Insert("reservations").
+			Columns("id", "room_id", "username", "purpose", "start_at", "end_at", "created_at").
+			Values(reservation.ID, reservation.RoomID, reservation.Username, reservation.Purpose, reservation.Start, reservation.End, nowExpr).
+			RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		inserted = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return inserted, nil
+}
+
+func (s *mySQLReservation) DeleteReservation(ctx context.Context, id string) error {
+	_, err := sq.Delete("reservations").Where(sq.Eq{"id": id}).RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLReservation) FetchReservation(ctx context.Context, id string) (*roomsmodel.Reservation, error) {
+	reservations, err := s.fetchReservations(ctx, sq.Eq{"id": id})
+	switch {
+	case err != nil:
+		return nil, err
+	case len(reservations) == 0:
+		return nil, nil
+	default:
+		return &reservations[0], nil
+	}
+}
+
+func (s *mySQLReservation) FetchReservations(ctx context.Context, roomID int, from, to time.Time) ([]roomsmodel.Reservation, error) {
+	return s.fetchReservations(ctx, rangePredicate(sq.Eq{"room_id": roomID}, from, to))
+}
+
+func (s *mySQLReservation) FetchUserReservations(ctx context.Context, username string, from, to time.Time) ([]roomsmodel.Reservation, error) {
+	return s.fetchReservations(ctx, rangePredicate(sq.Eq{"username": username}, from, to))
+}
+
+func rangePredicate(pred sq.Sqlizer, from, to time.Time) sq.And {
+	res := sq.And{pred}
+	if !from.IsZero() {
+		res = append(res, sq.Gt{"end_at": from})
+	}
+	if !to.IsZero() {
+		res = append(res, sq.LtOrEq{"start_at": to})
+	}
+	return res
+}
+
+func (s *mySQLReservation) fetchReservations(ctx context.Context, pred interface{}) ([]roomsmodel.Reservation, error) {
+	rows, err := sq.Select(reservationColumns...).
+		From("reservations").
+		Where(pred).
+		OrderBy("start_at", "id").
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []roomsmodel.Reservation
+	for rows.Next() {
+		var rsv roomsmodel.Reservation
+		if err := rows.Scan(&rsv.ID, &rsv.RoomID, &rsv.Username, &rsv.Purpose, &rsv.Start, &rsv.End); err != nil {
+			return nil, err
+		}
+		res = append(res, rsv)
+	}
+	return res, nil
+}
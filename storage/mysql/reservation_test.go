@@ -0,0 +1,137 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/stretchr/testify/require"
+)
+
+func newReservationMock() (*mySQLReservation, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLReservation{
+		mySQLStorage: s,
+	}, sqlMock
+}
+
+func TestMySQLStorage_InsertReservation(t *testing.T) {
+	now := time.Now()
+	rsv := roomsmodel.Reservation{ID: "r1", RoomID: 1, Username: "alice", Purpose: "exam", Start: now, End: now.Add(time.Hour)}
+
+	s, mock := newReservationMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM reservations WHERE \\(room_id = \\? AND start_at < \\? AND end_at > \\?\\) FOR UPDATE").
+		WithArgs(1, rsv.End, rsv.Start).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO reservations (.+)").
+		WithArgs("r1", 1, "alice", "exam", rsv.Start, rsv.End).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ok, err := s.InsertReservation(context.Background(), &rsv)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// overlapping reservation
+	s, mock = newReservationMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM reservations (.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectCommit()
+
+	ok, err = s.InsertReservation(context.Background(), &rsv)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	s, mock = newReservationMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM reservations (.+)").WillReturnError(errMySQLStorage)
+	mock.ExpectRollback()
+
+	_, err = s.InsertReservation(context.Background(), &rsv)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_DeleteReservation(t *testing.T) {
+	s, mock := newReservationMock()
+	mock.ExpectExec("DELETE FROM reservations WHERE id = \\?").
+		WithArgs("r1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeleteReservation(context.Background(), "r1")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newReservationMock()
+	mock.ExpectExec("DELETE FROM reservations (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.DeleteReservation(context.Background(), "r1")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchReservations(t *testing.T) {
+	now := time.Now()
+
+	s, mock := newReservationMock()
+	mock.ExpectQuery("SELECT (.+) FROM reservations WHERE \\(room_id = \\? AND end_at > \\? AND start_at <= \\?\\) ORDER BY start_at, id").
+		WithArgs(1, now, now.Add(time.Hour)).
+		WillReturnRows(sqlmock.NewRows(reservationColumns).
+			AddRow("r1", 1, "alice", "exam", now, now.Add(time.Minute)).
+			AddRow("r2", 1, "bob", "", now.Add(time.Minute), now.Add(time.Hour)))
+
+	reservations, err := s.FetchReservations(context.Background(), 1, now, now.Add(time.Hour))
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, reservations, 2)
+	require.Equal(t, "alice", reservations[0].Username)
+	require.Equal(t, "exam", reservations[0].Purpose)
+	require.Equal(t, "bob", reservations[1].Username)
+
+	// unbounded
+	s, mock = newReservationMock()
+	mock.ExpectQuery("SELECT (.+) FROM reservations WHERE \\(room_id = \\?\\) ORDER BY start_at, id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(reservationColumns))
+
+	reservations, err = s.FetchReservations(context.Background(), 1, time.Time{}, time.Time{})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, reservations, 0)
+
+	// held by user
+	s, mock = newReservationMock()
+	mock.ExpectQuery("SELECT (.+) FROM reservations WHERE \\(username = \\? AND end_at > \\? AND start_at <= \\?\\) ORDER BY start_at, id").
+		WithArgs("alice", now, now).
+		WillReturnRows(sqlmock.NewRows(reservationColumns).
+			AddRow("r1", 1, "alice", "exam", now, now.Add(time.Minute)))
+
+	reservations, err = s.FetchUserReservations(context.Background(), "alice", now, now)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, reservations, 1)
+	require.Equal(t, 1, reservations[0].RoomID)
+
+	s, mock = newReservationMock()
+	mock.ExpectQuery("SELECT (.+) FROM reservations WHERE id = \\?").
+		WithArgs("r3").
+		WillReturnRows(sqlmock.NewRows(reservationColumns))
+
+	rsv, err := s.FetchReservation(context.Background(), "r3")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, rsv)
+
+	s, mock = newReservationMock()
+	mock.ExpectQuery("SELECT (.+) FROM reservations (.+)").WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchReservation(context.Background(), "r1")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
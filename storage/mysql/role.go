@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+)
+
+type mySQLRole struct {
+	*mySQLStorage
+}
+
+func newRole(db *sql.DB) *mySQLRole {
+	return &mySQLRole{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (r *mySQLRole) UpsertRole(ctx context.Context, role *rolesmodel.Role) error {
+	perms := joinPermissions(role.Permissions)
+	_, err := sq.Insert("roles").
+		Columns("name", "permissions").
+		Values(role.Name, perms).
+		Suffix("ON DUPLICATE KEY UPDATE permissions = ?", perms).
+		RunWith(r.db).ExecContext(ctx)
+	return err
+}
+
+func (r *mySQLRole) DeleteRole(ctx context.Context, name string) error {
+	return r.inTransaction(ctx, func(tx *sql.Tx) error {
+		_, err := sq.Delete("user_role").
+			Where(sq.Expr("role_id = (SELECT id FROM roles WHERE name = ?)", name)).
+			RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = sq.Delete("roles").Where(sq.Eq{"name": name}).RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
+func (r *mySQLRole) FetchRole(ctx context.Context, name string) (*rolesmodel.Role, error) {
+	var (
+		role  rolesmodel.Role
+		perms string
+	)
+	err := sq.Select("name", "permissions").
+		From("roles").
+		Where(sq.Eq{"name": name}).
+		RunWith(r.db).
+		QueryRowContext(ctx).
+		Scan(&role.Name, &perms)
+	switch err {
+	case nil:
+		role.Permissions = splitPermissions(perms)
+		return &role, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (r *mySQLRole) FetchRoles(ctx context.Context) ([]rolesmodel.Role, error) {
+	rows, err := sq.Select("name", "permissions").
+		From("roles").
+		OrderBy("name").
+		RunWith(r.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []rolesmodel.Role
+	for rows.Next() {
+		var (
+			role  rolesmodel.Role
+			perms string
+		)
+		if err := rows.Scan(&role.Name, &perms); err != nil {
+			return nil, err
+		}
+		role.Permissions = splitPermissions(perms)
+		res = append(res, role)
+	}
+	return res, nil
+}
+
+func joinPermissions(perms []rolesmodel.Permission) string {
+	ss := make([]string, len(perms))
+	for i, p := range perms {
+		ss[i] = string(p)
+	}
+	return strings.Join(ss, ",")
+}
+
+func splitPermissions(s string) []rolesmodel.Permission {
+	if len(s) == 0 {
+		return nil
+	}
+	var perms []rolesmodel.Permission
+	for _, p := range strings.Split(s, ",") {
+		perms = append(perms, rolesmodel.Permission(p))
+	}
+	return perms
+}
@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	rolesmodel "github.com/dantin/cubit/model/roles"
+	"github.com/stretchr/testify/require"
+)
+
+var roleColumns = []string{"name", "permissions"}
+
+func newRoleMock() (*mySQLRole, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLRole{
+		mySQLStorage: s,
+	}, sqlMock
+}
+
+func TestMySQLStorage_UpsertRole(t *testing.T) {
+	r := rolesmodel.Role{Name: "operator", Permissions: []rolesmodel.Permission{rolesmodel.RoomsList, rolesmodel.DeviceControl}}
+
+	s, mock := newRoleMock()
+	mock.ExpectExec("INSERT INTO roles (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("operator", "rooms.list,device.control", "rooms.list,device.control").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertRole(context.Background(), &r)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newRoleMock()
+	mock.ExpectExec("INSERT INTO roles (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.UpsertRole(context.Background(), &r)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_DeleteRole(t *testing.T) {
+	s, mock := newRoleMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_role (.+)").
+		WithArgs("operator").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM roles (.+)").
+		WithArgs("operator").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.DeleteRole(context.Background(), "operator")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newRoleMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_role (.+)").WillReturnError(errMySQLStorage)
+	mock.ExpectRollback()
+
+	err = s.DeleteRole(context.Background(), "operator")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchRole(t *testing.T) {
+	s, mock := newRoleMock()
+	mock.ExpectQuery("SELECT (.+) FROM roles (.+)").
+		WithArgs("operator").
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow("operator", "rooms.list,device.control"))
+
+	role, err := s.FetchRole(context.Background(), "operator")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, []rolesmodel.Permission{rolesmodel.RoomsList, rolesmodel.DeviceControl}, role.Permissions)
+
+	s, mock = newRoleMock()
+	mock.ExpectQuery("SELECT (.+) FROM roles (.+)").
+		WithArgs("operator").
+		WillReturnRows(sqlmock.NewRows(roleColumns))
+
+	role, err = s.FetchRole(context.Background(), "operator")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, role)
+
+	s, mock = newRoleMock()
+	mock.ExpectQuery("SELECT (.+) FROM roles (.+)").WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchRole(context.Background(), "operator")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchRoles(t *testing.T) {
+	s, mock := newRoleMock()
+	mock.ExpectQuery("SELECT (.+) FROM roles ORDER BY name").
+		WillReturnRows(sqlmock.NewRows(roleColumns).
+			AddRow("admin", "rooms.list,rooms.edit").
+			AddRow("root", ""))
+
+	roles, err := s.FetchRoles(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, roles, 2)
+	require.Equal(t, []rolesmodel.Permission{rolesmodel.RoomsList, rolesmodel.RoomsEdit}, roles[0].Permissions)
+	require.Nil(t, roles[1].Permissions)
+
+	s, mock = newRoleMock()
+	mock.ExpectQuery("SELECT (.+) FROM roles ORDER BY name").WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchRoles(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
@@ -3,7 +3,6 @@ package mysql
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"sort"
 	"strings"
@@ -27,18 +26,158 @@ func newRoom(db *sql.DB) *mySQLRoom {
 	}
 }
 
+func (r *mySQLRoom) UpsertRoom(ctx context.Context, room *roomsmodel.Room) error {
+	if room.ID == 0 {
+		res, err := sq.Insert("rooms").
+			Columns("username", "name", "`type`", "created_at", "updated_at").
+			Values(room.Username, room.Name, room.Type.String(), nowExpr, nowExpr).
+			RunWith(r.db).ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		room.ID = int(id)
+		return nil
+	}
+	_, err := sq.Insert("rooms").
+		Columns("id", "username", "name", "`type`", "created_at", "updated_at").
+		Values(room.ID, room.Username, room.Name, room.Type.String(), nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE username = ?, name = ?, `type` = ?, updated_at = NOW()", room.Username, room.Name, room.Type.String()).
+		RunWith(r.db).ExecContext(ctx)
+	return err
+}
+
+func (r *mySQLRoom) DeleteRoom(ctx context.Context, roomID int) error {
+	return r.inTransaction(ctx, func(tx *sql.Tx) error {
+		_, err := sq.Delete("user_rooms").Where(sq.Eq{"room_id": roomID}).RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = sq.Delete("room_video_streams").Where(sq.Eq{"room_id": roomID}).RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = sq.Delete("rooms").Where(sq.Eq{"id": roomID}).RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
+func (r *mySQLRoom) BindRoom(ctx context.Context, roomID int, username string) error {
+	_, err := sq.Insert("user_rooms").
+		Options("IGNORE").
+		Columns("username", "room_id", "created_at").
+		Values(username, roomID, nowExpr).
+		RunWith(r.db).ExecContext(ctx)
+	return err
+}
+
+func (r *mySQLRoom) UnbindRoom(ctx context.Context, roomID int, username string) error {
+	_, err := sq.Delete("user_rooms").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"room_id": roomID}}).
+		RunWith(r.db).ExecContext(ctx)
+	return err
+}
+
+func (r *mySQLRoom) FetchBoundRooms(ctx context.Context, username string) ([]roomsmodel.Room, error) {
+	q := sq.Select("id", "name", "username", "`type`").
+		From("rooms").
+		Where(sq.Expr("id IN (SELECT room_id FROM user_rooms WHERE username = ?)", username)).
+		OrderBy("`id` ASC")
+
+	rows, err := q.RunWith(r.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []roomsmodel.Room
+	var ids []int
+	for rows.Next() {
+		var (
+			room roomsmodel.Room
+			tp   string
+		)
+		if err := rows.Scan(&room.ID, &room.Name, &room.Username, &tp); err != nil {
+			return nil, err
+		}
+		room.Type = roomsmodel.ParseRoomTypeString(tp)
+		ids = append(ids, room.ID)
+		res = append(res, room)
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	vs, err := r.scanVideoStreams(ctx, model.Usr.String(), ids...)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vs {
+		for i := range res {
+			if res[i].ID == v.RoomID {
+				res[i].Streams = append(res[i].Streams, v)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (r *mySQLRoom) FetchBoundUsers(ctx context.Context, roomID int) ([]string, error) {
+	q := sq.Select("username").
+		From("user_rooms").
+		Where(sq.Eq{"room_id": roomID}).
+		OrderBy("username")
+
+	rows, err := q.RunWith(r.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		res = append(res, username)
+	}
+	return res, nil
+}
+
+func (r *mySQLRoom) UpsertVideoStream(ctx context.Context, stream *roomsmodel.VideoStream) error {
+	md := &stream.Metadata
+	_, err := sq.Insert("room_video_streams").
+		Columns("input", "broadcast", "routes", "`type`", "room_id", "protocol", "codec", "width", "height", "framerate", "bitrate", "latency").
+		Values(stream.In, stream.Broadcast, stream.Route, stream.Type.String(), stream.RoomID, md.Protocol.String(), md.Codec, md.Width, md.Height, md.Framerate, md.Bitrate, md.Latency.String()).
+		Suffix("ON DUPLICATE KEY UPDATE input = ?, broadcast = ?, routes = ?, protocol = ?, codec = ?, width = ?, height = ?, framerate = ?, bitrate = ?, latency = ?",
+			stream.In, stream.Broadcast, stream.Route, md.Protocol.String(), md.Codec, md.Width, md.Height, md.Framerate, md.Bitrate, md.Latency.String()).
+		RunWith(r.db).ExecContext(ctx)
+	return err
+}
+
+func (r *mySQLRoom) DeleteVideoStream(ctx context.Context, roomID int, tp roomsmodel.VideoType) error {
+	_, err := sq.Delete("room_video_streams").
+		Where(sq.And{sq.Eq{"room_id": roomID}, sq.Eq{"`type`": tp.String()}}).
+		RunWith(r.db).ExecContext(ctx)
+	return err
+}
+
 func (r *mySQLRoom) FetchRoom(ctx context.Context, username string) (*roomsmodel.Room, error) {
 	var (
 		res  roomsmodel.Room
 		name string
 		user string
+		tp   string
 		id   int
 	)
-	err := sq.Select("id", "name", "username").
+	err := sq.Select("id", "name", "username", "`type`").
 		From("rooms").
 		Where(sq.Eq{"username": username}).
 		Limit(1).
-		RunWith(r.db).QueryRowContext(ctx).Scan(&id, &name, &user)
+		RunWith(r.db).QueryRowContext(ctx).Scan(&id, &name, &user, &tp)
 	switch err {
 	case nil:
 		streams, err := r.scanVideoStreams(ctx, model.Usr.String(), id)
@@ -48,6 +187,7 @@ func (r *mySQLRoom) FetchRoom(ctx context.Context, username string) (*roomsmodel
 		res.ID = id
 		res.Name = name
 		res.Username = user
+		res.Type = roomsmodel.ParseRoomTypeString(tp)
 		res.Streams = streams
 		return &res, nil
 	case sql.ErrNoRows:
@@ -57,53 +197,112 @@ func (r *mySQLRoom) FetchRoom(ctx context.Context, username string) (*roomsmodel
 	}
 }
 
-func (r *mySQLRoom) FetchRooms(ctx context.Context, page int, pageSize int) ([]roomsmodel.Room, error) {
-	log.Debugf("fetch rooms on page %d size %d", page, pageSize)
-
-	q := sq.Select("id", "name", "username").
+func (r *mySQLRoom) FetchRoomByID(ctx context.Context, roomID int) (*roomsmodel.Room, error) {
+	var (
+		res roomsmodel.Room
+		tp  string
+	)
+	err := sq.Select("id", "name", "username", "`type`").
 		From("rooms").
-		Where(sq.Eq{"`type`": roomsmodel.Normal.String()}).
-		OrderBy("`id` ASC").
-		Limit(uint64(pageSize)).
-		Offset(uint64(page * pageSize))
+		Where(sq.Eq{"id": roomID}).
+		RunWith(r.db).QueryRowContext(ctx).Scan(&res.ID, &res.Name, &res.Username, &tp)
+	switch err {
+	case nil:
+		streams, err := r.fetchVideoStreams(ctx, roomID)
+		if err != nil {
+			return nil, err
+		}
+		res.Type = roomsmodel.ParseRoomTypeString(tp)
+		res.Streams = streams
+		return &res, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (r *mySQLRoom) FetchRooms(ctx context.Context, filter *roomsmodel.Filter, page *roomsmodel.Page) ([]roomsmodel.Room, int, error) {
+	log.Debugf("fetch rooms page %+v", *page)
+
+	q := sq.Select("id", "name", "username", "`type`").From("rooms")
+
+	var index int
+	var reverse bool
+	pred := roomsPredicate(filter)
+	switch {
+	case page.After > 0:
+		pred = append(pred, sq.Gt{"id": page.After})
+		q = q.OrderBy("`id` ASC")
+	case page.Before > 0:
+		pred = append(pred, sq.Lt{"id": page.Before})
+		q = q.OrderBy("`id` DESC")
+		reverse = true
+	case page.Last:
+		q = q.OrderBy("`id` DESC")
+		reverse = true
+	default:
+		q = q.OrderBy("`id` ASC")
+		if page.Index > 0 {
+			q = q.Offset(uint64(page.Index))
+		}
+		index = page.Index
+	}
+	if len(pred) > 0 {
+		q = q.Where(pred)
+	}
+	if page.Max > 0 {
+		q = q.Limit(uint64(page.Max))
+	}
 
 	rows, err := q.RunWith(r.db).QueryContext(ctx)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer func() { _ = rows.Close() }()
 
-	// fetch video streams and build.
-	var ids []int
-	rooms := make(map[int]*roomsmodel.Room)
+	var res []roomsmodel.Room
 	for rows.Next() {
 		var (
-			id             int
-			name, username string
+			room roomsmodel.Room
+			tp   string
 		)
-		if err := rows.Scan(&id, &name, &username); err != nil {
-			return nil, err
+		if err := rows.Scan(&room.ID, &room.Name, &room.Username, &tp); err != nil {
+			return nil, 0, err
+		}
+		room.Type = roomsmodel.ParseRoomTypeString(tp)
+		res = append(res, room)
+	}
+	if len(res) == 0 {
+		return nil, index, nil
+	}
+	if reverse {
+		sort.Sort(byRoomID(res))
+	}
+	if page.After > 0 || reverse {
+		index, err = r.countRooms(ctx, append(roomsPredicate(filter), sq.Lt{"id": res[0].ID}))
+		if err != nil {
+			return nil, 0, err
 		}
-		ids = append(ids, id)
-		rooms[id] = &roomsmodel.Room{ID: id, Name: name, Username: username, Streams: nil}
 	}
 
+	// fetch video streams
+	ids := make([]int, len(res))
+	for i := range res {
+		ids[i] = res[i].ID
+	}
 	vs, err := r.scanVideoStreams(ctx, model.Admin.String(), ids...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-
 	for _, v := range vs {
-		room := rooms[v.RoomID]
-		room.Streams = append(room.Streams, v)
-	}
-
-	var res []roomsmodel.Room
-	for _, r := range rooms {
-		res = append(res, *r)
+		for i := range res {
+			if res[i].ID == v.RoomID {
+				res[i].Streams = append(res[i].Streams, v)
+			}
+		}
 	}
-	sort.Sort(byRoomID(res))
-	return res, nil
+	return res, index, nil
 }
 
 type byRoomID []roomsmodel.Room
@@ -120,12 +319,16 @@ func (s byRoomID) Less(i, j int) bool {
 	return s[i].ID < s[j].ID
 }
 
-// CountRooms  returns current size of rooms.
-func (r *mySQLRoom) CountRooms(ctx context.Context) (int, error) {
-	q := sq.Select("COUNT(*)").
-		From("rooms").
-		Where(sq.Eq{"`type`": roomsmodel.Normal.String()})
+// CountRooms returns the number of rooms matching filter.
+func (r *mySQLRoom) CountRooms(ctx context.Context, filter *roomsmodel.Filter) (int, error) {
+	return r.countRooms(ctx, roomsPredicate(filter))
+}
 
+func (r *mySQLRoom) countRooms(ctx context.Context, pred sq.And) (int, error) {
+	q := sq.Select("COUNT(*)").From("rooms")
+	if len(pred) > 0 {
+		q = q.Where(pred)
+	}
 	var count int
 	err := q.RunWith(r.db).QueryRowContext(ctx).Scan(&count)
 	switch err {
@@ -136,12 +339,17 @@ func (r *mySQLRoom) CountRooms(ctx context.Context) (int, error) {
 	}
 }
 
-// FetchQCStream retrieves qc room stream entites from storage.
+// FetchQCStream retrieves from storage the stream routed to user within the qc room bound to it.
 func (r *mySQLRoom) FetchQCStream(ctx context.Context, username string) (*roomsmodel.VideoStream, error) {
 	var id int
 	err := sq.Select("id").
 		From("rooms").
-		Where(sq.Eq{"`type`": roomsmodel.QC.String()}).
+		Where(sq.And{
+			sq.Eq{"`type`": roomsmodel.QC.String()},
+			sq.Expr("id IN (SELECT room_id FROM user_rooms WHERE username = ?)", username),
+		}).
+		OrderBy("`id` ASC").
+		Limit(1).
 		RunWith(r.db).QueryRowContext(ctx).Scan(&id)
 	switch err {
 	case nil:
@@ -149,10 +357,14 @@ func (r *mySQLRoom) FetchQCStream(ctx context.Context, username string) (*roomsm
 		if err != nil {
 			return nil, err
 		}
-		if len(res) != 1 {
+		switch len(res) {
+		case 0:
+			return nil, nil
+		case 1:
+			return &res[0], nil
+		default:
 			return nil, errors.New("multiple qc found")
 		}
-		return &res[0], nil
 	case sql.ErrNoRows:
 		return nil, nil
 	default:
@@ -161,7 +373,7 @@ func (r *mySQLRoom) FetchQCStream(ctx context.Context, username string) (*roomsm
 }
 
 func (r *mySQLRoom) scanVideoStreams(ctx context.Context, target string, ids ...int) ([]roomsmodel.VideoStream, error) {
-	q := sq.Select("routes", "`type`", "room_id").
+	q := sq.Select(append([]string{"routes", "`type`", "room_id"}, metadataColumns...)...).
 		From("room_video_streams").
 		Where(sq.Eq{"room_id": ids})
 
@@ -176,23 +388,82 @@ func (r *mySQLRoom) scanVideoStreams(ctx context.Context, target string, ids ...
 		var (
 			roomID         int
 			t, streamsJSON string
+			md             roomsmodel.Metadata
+			protocol       string
+			latency        string
 		)
-		if err := rows.Scan(&streamsJSON, &t, &roomID); err != nil {
+		if err := rows.Scan(&streamsJSON, &t, &roomID, &protocol, &md.Codec, &md.Width, &md.Height, &md.Framerate, &md.Bitrate, &latency); err != nil {
 			return nil, err
 		}
+		md.Protocol = roomsmodel.ParseProtocolString(protocol)
+		md.Latency = roomsmodel.ParseLatencyClassString(latency)
 		vt := roomsmodel.ParseVideoTypeString(t)
 		if vt == roomsmodel.Unknown {
 			continue
 		}
-		if len(streamsJSON) > 0 {
-			var routes map[string]string
-			if err := json.NewDecoder(strings.NewReader(streamsJSON)).Decode(&routes); err != nil {
-				return nil, err
-			}
-			if streamURI, ok := routes[target]; ok {
-				res = append(res, roomsmodel.VideoStream{Type: vt, Stream: streamURI, RoomID: roomID})
-			}
+		vs := roomsmodel.VideoStream{Route: streamsJSON}
+		routes, err := vs.Routes()
+		if err != nil {
+			return nil, err
+		}
+		if streamURI, ok := routes.Lookup(target); ok {
+			res = append(res, roomsmodel.VideoStream{Type: vt, Stream: streamURI, RoomID: roomID, Metadata: md})
+		}
+	}
+	return res, nil
+}
+
+func (r *mySQLRoom) fetchVideoStreams(ctx context.Context, roomID int) ([]roomsmodel.VideoStream, error) {
+	q := sq.Select(append([]string{"input", "broadcast", "routes", "`type`", "room_id"}, metadataColumns...)...).
+		From("room_video_streams").
+		Where(sq.Eq{"room_id": roomID}).
+		OrderBy("`id` ASC")
+
+	rows, err := q.RunWith(r.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []roomsmodel.VideoStream
+	for rows.Next() {
+		var (
+			vs                   roomsmodel.VideoStream
+			t, protocol, latency string
+		)
+		md := &vs.Metadata
+		if err := rows.Scan(&vs.In, &vs.Broadcast, &vs.Route, &t, &vs.RoomID, &protocol, &md.Codec, &md.Width, &md.Height, &md.Framerate, &md.Bitrate, &latency); err != nil {
+			return nil, err
+		}
+		md.Protocol = roomsmodel.ParseProtocolString(protocol)
+		md.Latency = roomsmodel.ParseLatencyClassString(latency)
+		vs.Type = roomsmodel.ParseVideoTypeString(t)
+		if vs.Type == roomsmodel.Unknown {
+			continue
 		}
+		res = append(res, vs)
 	}
 	return res, nil
 }
+
+// metadataColumns lists video stream metadata columns, in scanning order.
+var metadataColumns = []string{"protocol", "codec", "width", "height", "framerate", "bitrate", "latency"}
+
+func roomsPredicate(filter *roomsmodel.Filter) sq.And {
+	var pred sq.And
+	if len(filter.Name) > 0 {
+		pred = append(pred, sq.Like{"name": "%" + likeEscaper.Replace(filter.Name) + "%"})
+	}
+	if filter.Type != 0 {
+		pred = append(pred, sq.Eq{"`type`": filter.Type.String()})
+	}
+	if filter.IDs != nil {
+		pred = append(pred, sq.Eq{"id": filter.IDs})
+	}
+	if len(filter.ExcludedIDs) > 0 {
+		pred = append(pred, sq.NotEq{"id": filter.ExcludedIDs})
+	}
+	return pred
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
@@ -0,0 +1,459 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+	"github.com/dantin/cubit/util/pool"
+	"github.com/stretchr/testify/require"
+)
+
+func newRoomMock() (*mySQLRoom, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLRoom{
+		mySQLStorage: s,
+		pool:         pool.NewBufferPool(),
+	}, sqlMock
+}
+
+func TestMySQLStorage_InsertRoom(t *testing.T) {
+	room := roomsmodel.Room{Name: "Room 06", Username: "room06", Type: roomsmodel.Normal}
+
+	s, mock := newRoomMock()
+	mock.ExpectExec("INSERT INTO rooms (.+)").
+		WithArgs("room06", "Room 06", "normal").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	err := s.UpsertRoom(context.Background(), &room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 7, room.ID)
+
+	// update
+	s, mock = newRoomMock()
+	mock.ExpectExec("INSERT INTO rooms (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs(7, "room06", "Room 06", "normal", "room06", "Room 06", "normal").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = s.UpsertRoom(context.Background(), &room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newRoomMock()
+	mock.ExpectExec("INSERT INTO rooms (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.UpsertRoom(context.Background(), &roomsmodel.Room{Name: "Room 07", Username: "room07", Type: roomsmodel.QC})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_DeleteRoom(t *testing.T) {
+	s, mock := newRoomMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_rooms (.+)").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM room_video_streams (.+)").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM rooms (.+)").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.DeleteRoom(context.Background(), 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newRoomMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_rooms (.+)").
+		WithArgs(2).
+		WillReturnError(errMySQLStorage)
+	mock.ExpectRollback()
+
+	err = s.DeleteRoom(context.Background(), 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_BindRoom(t *testing.T) {
+	s, mock := newRoomMock()
+	mock.ExpectExec("INSERT IGNORE INTO user_rooms (.+)").
+		WithArgs("alice", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.BindRoom(context.Background(), 2, "alice")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newRoomMock()
+	mock.ExpectExec("INSERT IGNORE INTO user_rooms (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.BindRoom(context.Background(), 2, "alice")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_UnbindRoom(t *testing.T) {
+	s, mock := newRoomMock()
+	mock.ExpectExec("DELETE FROM user_rooms (.+)").
+		WithArgs("alice", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.UnbindRoom(context.Background(), 2, "alice")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newRoomMock()
+	mock.ExpectExec("DELETE FROM user_rooms (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.UnbindRoom(context.Background(), 2, "alice")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchBoundRooms(t *testing.T) {
+	var roomColumns = []string{"id", "name", "username", "type"}
+	var streamColumns = []string{"routes", "type", "room_id", "protocol", "codec", "width", "height", "framerate", "bitrate", "latency"}
+
+	s, mock := newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms WHERE id IN \\(SELECT room_id FROM user_rooms (.+)\\)(.+)").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows(roomColumns).
+			AddRow(1, "Room QC", "admin", "qc").
+			AddRow(2, "Room 01", "room01", "normal"))
+	mock.ExpectQuery("SELECT (.+) FROM room_video_streams (.+)").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows(streamColumns).
+			AddRow(`{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`, "camera", 2, "", "", 0, 0, 0.0, 0, ""))
+
+	rooms, err := s.FetchBoundRooms(context.Background(), "alice")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, rooms, 2)
+	require.Equal(t, roomsmodel.QC, rooms[0].Type)
+	require.Len(t, rooms[0].Streams, 0)
+	require.Len(t, rooms[1].Streams, 1)
+	require.Equal(t, "srt://127.0.0.1:9001", rooms[1].Streams[0].Stream)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms (.+)").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows(roomColumns))
+
+	rooms, err = s.FetchBoundRooms(context.Background(), "alice")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, rooms, 0)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms (.+)").
+		WithArgs("alice").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchBoundRooms(context.Background(), "alice")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchBoundUsers(t *testing.T) {
+	s, mock := newRoomMock()
+	mock.ExpectQuery("SELECT username FROM user_rooms WHERE room_id = \\? ORDER BY username").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice").AddRow("bob"))
+
+	usernames, err := s.FetchBoundUsers(context.Background(), 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, []string{"alice", "bob"}, usernames)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT username FROM user_rooms (.+)").
+		WithArgs(2).
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchBoundUsers(context.Background(), 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_UpsertVideoStream(t *testing.T) {
+	vs := roomsmodel.VideoStream{
+		In:        "srt://127.0.0.1:9001",
+		Broadcast: "srt://127.0.0.1:9002",
+		Route:     `{"user": "srt://127.0.0.1:9003"}`,
+		Type:      roomsmodel.Camera,
+		RoomID:    2,
+		Metadata: roomsmodel.Metadata{
+			Protocol:  roomsmodel.HLS,
+			Codec:     "h264",
+			Width:     1920,
+			Height:    1080,
+			Framerate: 30,
+			Bitrate:   4000,
+			Latency:   roomsmodel.NormalLatency,
+		},
+	}
+	s, mock := newRoomMock()
+	mock.ExpectExec("INSERT INTO room_video_streams (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs(vs.In, vs.Broadcast, vs.Route, "camera", 2, "hls", "h264", 1920, 1080, 30.0, 4000, "normal", vs.In, vs.Broadcast, vs.Route, "hls", "h264", 1920, 1080, 30.0, 4000, "normal").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertVideoStream(context.Background(), &vs)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newRoomMock()
+	mock.ExpectExec("INSERT INTO room_video_streams (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.UpsertVideoStream(context.Background(), &vs)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_DeleteVideoStream(t *testing.T) {
+	s, mock := newRoomMock()
+	mock.ExpectExec("DELETE FROM room_video_streams (.+)").
+		WithArgs(2, "device").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeleteVideoStream(context.Background(), 2, roomsmodel.Device)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newRoomMock()
+	mock.ExpectExec("DELETE FROM room_video_streams (.+)").WillReturnError(errMySQLStorage)
+
+	err = s.DeleteVideoStream(context.Background(), 2, roomsmodel.Device)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchRoom(t *testing.T) {
+	var roomColumns = []string{"id", "name", "username", "type"}
+	var streamColumns = []string{"routes", "type", "room_id", "protocol", "codec", "width", "height", "framerate", "bitrate", "latency"}
+
+	s, mock := newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms (.+)").
+		WithArgs("room01").
+		WillReturnRows(sqlmock.NewRows(roomColumns).AddRow(2, "Room 01", "room01", "normal"))
+	mock.ExpectQuery("SELECT (.+) FROM room_video_streams (.+)").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(streamColumns).
+			AddRow(`{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`, "camera", 2, "", "", 0, 0, 0.0, 0, "").
+			AddRow(`{"admin": "srt://127.0.0.1:9004"}`, "device", 2, "", "", 0, 0, 0.0, 0, ""))
+
+	room, err := s.FetchRoom(context.Background(), "room01")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, room)
+	require.Equal(t, roomsmodel.Normal, room.Type)
+	require.Len(t, room.Streams, 1)
+	require.Equal(t, "srt://127.0.0.1:9001", room.Streams[0].Stream)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms (.+)").
+		WithArgs("room01").
+		WillReturnRows(sqlmock.NewRows(roomColumns))
+
+	room, err = s.FetchRoom(context.Background(), "room01")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, room)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms (.+)").
+		WithArgs("room01").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchRoom(context.Background(), "room01")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchRoomByID(t *testing.T) {
+	var roomColumns = []string{"id", "name", "username", "type"}
+	var streamColumns = []string{"input", "broadcast", "routes", "type", "room_id", "protocol", "codec", "width", "height", "framerate", "bitrate", "latency"}
+
+	s, mock := newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms (.+)").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(roomColumns).AddRow(2, "Room 01", "room01", "normal"))
+	mock.ExpectQuery("SELECT (.+) FROM room_video_streams (.+)").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(streamColumns).
+			AddRow("srt://in", "srt://broadcast", `{"user": "srt://127.0.0.1:9001"}`, "camera", 2, "webrtc", "vp8", 1280, 720, 25.0, 1500, "ultra_low"))
+
+	room, err := s.FetchRoomByID(context.Background(), 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, room)
+	require.Equal(t, "room01", room.Username)
+	require.Len(t, room.Streams, 1)
+	require.Equal(t, roomsmodel.VideoStream{
+		In:        "srt://in",
+		Broadcast: "srt://broadcast",
+		Route:     `{"user": "srt://127.0.0.1:9001"}`,
+		Type:      roomsmodel.Camera,
+		RoomID:    2,
+		Metadata: roomsmodel.Metadata{
+			Protocol:  roomsmodel.WebRTC,
+			Codec:     "vp8",
+			Width:     1280,
+			Height:    720,
+			Framerate: 25,
+			Bitrate:   1500,
+			Latency:   roomsmodel.UltraLowLatency,
+		},
+	}, room.Streams[0])
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms (.+)").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(roomColumns))
+
+	room, err = s.FetchRoomByID(context.Background(), 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, room)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms (.+)").
+		WithArgs(2).
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchRoomByID(context.Background(), 2)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_CountRooms(t *testing.T) {
+	s, mock := newRoomMock()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM rooms (.+)").
+		WithArgs("%room\\_0%", "normal", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+
+	n, err := s.CountRooms(context.Background(), &roomsmodel.Filter{Name: "room_0", Type: roomsmodel.Normal, ExcludedIDs: []int{4}})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 5, n)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM rooms (.+)").
+		WithArgs("normal").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.CountRooms(context.Background(), &roomsmodel.Filter{Type: roomsmodel.Normal})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchRooms(t *testing.T) {
+	var roomColumns = []string{"id", "name", "username", "type"}
+	var streamColumns = []string{"routes", "type", "room_id", "protocol", "codec", "width", "height", "framerate", "bitrate", "latency"}
+
+	s, mock := newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms WHERE (.+) ORDER BY `id` ASC LIMIT 2").
+		WithArgs("normal").
+		WillReturnRows(sqlmock.NewRows(roomColumns).
+			AddRow(2, "Room 01", "room01", "normal").
+			AddRow(3, "Room 02", "room02", "normal"))
+	mock.ExpectQuery("SELECT (.+) FROM room_video_streams (.+)").
+		WithArgs(2, 3).
+		WillReturnRows(sqlmock.NewRows(streamColumns).
+			AddRow(`{"user": "srt://127.0.0.1:9001", "admin": "srt://127.0.0.1:9002"}`, "camera", 2, "", "", 0, 0, 0.0, 0, "").
+			AddRow(`{"user": "srt://127.0.0.1:9003", "admin": "srt://127.0.0.1:9004"}`, "camera", 3, "", "", 0, 0, 0.0, 0, ""))
+
+	rooms, index, err := s.FetchRooms(context.Background(), &roomsmodel.Filter{Type: roomsmodel.Normal}, &roomsmodel.Page{Max: 2})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 0, index)
+	require.Len(t, rooms, 2)
+	require.Equal(t, 2, rooms[0].ID)
+	require.Equal(t, roomsmodel.Normal, rooms[0].Type)
+	require.Equal(t, "srt://127.0.0.1:9002", rooms[0].Streams[0].Stream)
+	require.Equal(t, "srt://127.0.0.1:9004", rooms[1].Streams[0].Stream)
+
+	// last page
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms WHERE (.+) ORDER BY `id` DESC LIMIT 2").
+		WithArgs(2, 3, 4).
+		WillReturnRows(sqlmock.NewRows(roomColumns).
+			AddRow(4, "Room 03", "room03", "normal").
+			AddRow(3, "Room 02", "room02", "normal"))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM rooms (.+)").
+		WithArgs(2, 3, 4, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT (.+) FROM room_video_streams (.+)").
+		WithArgs(3, 4).
+		WillReturnRows(sqlmock.NewRows(streamColumns))
+
+	rooms, index, err = s.FetchRooms(context.Background(), &roomsmodel.Filter{IDs: []int{2, 3, 4}}, &roomsmodel.Page{Max: 2, Last: true})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1, index)
+	require.Len(t, rooms, 2)
+	require.Equal(t, 3, rooms[0].ID)
+	require.Equal(t, 4, rooms[1].ID)
+
+	// after a given room
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms WHERE (.+) ORDER BY `id` ASC LIMIT 2").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roomColumns))
+
+	rooms, _, err = s.FetchRooms(context.Background(), &roomsmodel.Filter{}, &roomsmodel.Page{Max: 2, After: 3})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, rooms, 0)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT (.+) FROM rooms (.+)").
+		WithArgs("normal").
+		WillReturnError(errMySQLStorage)
+
+	_, _, err = s.FetchRooms(context.Background(), &roomsmodel.Filter{Type: roomsmodel.Normal}, &roomsmodel.Page{Max: 2})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorage_FetchQCStream(t *testing.T) {
+	var streamColumns = []string{"routes", "type", "room_id", "protocol", "codec", "width", "height", "framerate", "bitrate", "latency"}
+
+	s, mock := newRoomMock()
+	mock.ExpectQuery("SELECT id FROM rooms (.+)").
+		WithArgs("qc", "room02").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT (.+) FROM room_video_streams (.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(streamColumns).
+			AddRow(`{"room01": "srt://127.0.0.1:39991", "room02": "srt://127.0.0.1:39992"}`, "camera", 1, "", "", 0, 0, 0.0, 0, ""))
+
+	vs, err := s.FetchQCStream(context.Background(), "room02")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, vs)
+	require.Equal(t, "srt://127.0.0.1:39992", vs.Stream)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT id FROM rooms (.+)").
+		WithArgs("qc", "room02").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	vs, err = s.FetchQCStream(context.Background(), "room02")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, vs)
+
+	s, mock = newRoomMock()
+	mock.ExpectQuery("SELECT id FROM rooms (.+)").
+		WithArgs("qc", "room02").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchQCStream(context.Background(), "room02")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
@@ -113,10 +113,14 @@ func (u *mySQLUser) FetchUser(ctx context.Context, username string) (*model.User
 			From("roles").
 			Where(sq.Expr("id = (SELECT role_id FROM user_role WHERE username = ?)", username)).
 			RunWith(u.db).QueryRowContext(ctx).Scan(&role)
-		if err != nil {
+		switch err {
+		case nil:
+			usr.Role = model.ParseRoleString(role)
+		case sql.ErrNoRows:
+			usr.Role = model.Unknown
+		default:
 			return nil, err
 		}
-		usr.Role = model.ParseRoleString(role)
 		return &usr, nil
 	case sql.ErrNoRows:
 		return nil, nil
@@ -160,6 +164,26 @@ func (u *mySQLUser) DeleteUser(ctx context.Context, username string) error {
 	})
 }
 
+func (u *mySQLUser) UpdateUserRole(ctx context.Context, username string, role model.Role) error {
+	return u.inTransaction(ctx, func(tx *sql.Tx) error {
+		var roleID int
+		err := sq.Select("id").
+			From("roles").
+			Where(sq.Eq{"name": role.String()}).
+			RunWith(tx).
+			QueryRowContext(ctx).Scan(&roleID)
+		if err != nil {
+			return err
+		}
+		_, err = sq.Insert("user_role").
+			Columns("username", "role_id").
+			Values(username, roleID).
+			Suffix("ON DUPLICATE KEY UPDATE role_id = ?", roleID).
+			RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
 func (u *mySQLUser) UserExists(ctx context.Context, username string) (bool, error) {
 	q := sq.Select("COUNT(*)").
 		From("users").
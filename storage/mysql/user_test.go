@@ -132,6 +132,23 @@ func TestMySQLStorage_FetchUser(t *testing.T) {
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.NotNil(t, usr)
+	require.Equal(t, model.Usr, usr.Role)
+
+	// no role assigned
+	s, mock = newUserMock()
+	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow("alice", "passwd", "", time.Now()))
+	mock.ExpectQuery("SELECT (.+) FROM user_role (.+)").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+	usr, err = s.FetchUser(context.Background(), "alice")
+
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, model.Unknown, usr.Role)
 
 	// empty
 	s, mock = newUserMock()
@@ -156,6 +173,36 @@ func TestMySQLStorage_FetchUser(t *testing.T) {
 	require.Equal(t, errMocked, err)
 }
 
+func TestMySQLStorage_UpdateUserRole(t *testing.T) {
+	s, mock := newUserMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM roles WHERE (.+)").
+		WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO user_role (.+) VALUES (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("alice", 1, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := s.UpdateUserRole(context.Background(), "alice", model.Admin)
+
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	// err case
+	s, mock = newUserMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM roles WHERE (.+)").
+		WithArgs("admin").
+		WillReturnError(errMySQLStorage)
+	mock.ExpectRollback()
+
+	err = s.UpdateUserRole(context.Background(), "alice", model.Admin)
+
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
 func TestMySQLStorage_UserExists(t *testing.T) {
 	cols := []string{"count"}
 
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	auditmodel "github.com/dantin/cubit/model/audit"
+)
+
+// Audit defines audit log repository operations
+type Audit interface {
+	// AppendAuditEvent chains an event to the last stored one and appends it to the log.
+	AppendAuditEvent(ctx context.Context, event *auditmodel.Event) error
+
+	// FetchAuditEvents retrieves from storage all events matching a given filter, sorted by sequence number.
+	FetchAuditEvents(ctx context.Context, filter *auditmodel.Filter) ([]auditmodel.Event, error)
+}
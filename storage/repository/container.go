@@ -31,6 +31,24 @@ type Container interface {
 	// Room method returns repository.Room concrete implementation.
 	Room() Room
 
+	// Device method returns repository.Device concrete implementation.
+	Device() Device
+
+	// QC method returns repository.QC concrete implementation.
+	QC() QC
+
+	// Reservation method returns repository.Reservation concrete implementation.
+	Reservation() Reservation
+
+	// MUC method returns repository.MUC concrete implementation.
+	MUC() MUC
+
+	// Role method returns repository.Role concrete implementation.
+	Role() Role
+
+	// Audit method returns repository.Audit concrete implementation.
+	Audit() Audit
+
 	// Close closes underlying storage resources, commonly shared across repositories.
 	Close(ctx context.Context) error
 
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+
+	devicesmodel "github.com/dantin/cubit/model/devices"
+)
+
+// Device defines device repository operations
+type Device interface {
+	// UpsertDevice inserts a new device entity into storage, or updates it if previously inserted.
+	UpsertDevice(ctx context.Context, device *devicesmodel.Device) error
+
+	// DeleteDevice deletes a device entity from storage.
+	DeleteDevice(ctx context.Context, serial string) error
+
+	// FetchDevice retrieves a device entity from storage.
+	FetchDevice(ctx context.Context, serial string) (*devicesmodel.Device, error)
+
+	// FetchDeviceByUsername retrieves from storage the device entity an XMPP account was issued to.
+	FetchDeviceByUsername(ctx context.Context, username string) (*devicesmodel.Device, error)
+
+	// FetchDevices retrieves all device entities from storage.
+	FetchDevices(ctx context.Context) ([]devicesmodel.Device, error)
+
+	// FetchRoomDevices retrieves from storage all device entities bound to a given room.
+	FetchRoomDevices(ctx context.Context, roomID int) ([]devicesmodel.Device, error)
+}
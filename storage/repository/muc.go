@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+
+	mucmodel "github.com/dantin/cubit/model/muc"
+)
+
+// MUC defines multi-user chat repository operations
+type MUC interface {
+	// UpsertMUCRoom inserts a new chat room entity into storage, or updates it if previously inserted.
+	UpsertMUCRoom(ctx context.Context, room *mucmodel.Room) error
+
+	// DeleteMUCRoom deletes a chat room entity, along with its affiliations and history, from storage.
+	DeleteMUCRoom(ctx context.Context, name string) error
+
+	// FetchMUCRoom retrieves a chat room entity from storage.
+	FetchMUCRoom(ctx context.Context, name string) (*mucmodel.Room, error)
+
+	// FetchMUCRooms retrieves from storage all chat room entities, sorted by name.
+	FetchMUCRooms(ctx context.Context) ([]mucmodel.Room, error)
+
+	// UpsertMUCAffiliation inserts a new chat room affiliation into storage, or updates it if previously inserted.
+	UpsertMUCAffiliation(ctx context.Context, affiliation *mucmodel.Affiliation) error
+
+	// DeleteMUCAffiliation deletes a user affiliation from a chat room.
+	DeleteMUCAffiliation(ctx context.Context, roomName, jid string) error
+
+	// FetchMUCAffiliations retrieves from storage all affiliations of a given chat room.
+	FetchMUCAffiliations(ctx context.Context, roomName string) ([]mucmodel.Affiliation, error)
+
+	// InsertMUCMessage appends a message to chat room history.
+	InsertMUCMessage(ctx context.Context, message *mucmodel.Message) error
+
+	// FetchMUCHistory retrieves from storage up to limit latest chat room history messages, oldest first.
+	FetchMUCHistory(ctx context.Context, roomName string, limit int) ([]mucmodel.Message, error)
+}
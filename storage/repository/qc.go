@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	qcmodel "github.com/dantin/cubit/model/qc"
+)
+
+// QC defines QC review session repository operations
+type QC interface {
+	// ClaimQCSession inserts a new in progress session into storage,
+	// unless its stream is already claimed by another in progress session, in which case false is returned.
+	ClaimQCSession(ctx context.Context, session *qcmodel.Session) (bool, error)
+
+	// CloseQCSession stores session verdict and notes, releasing its stream claim.
+	CloseQCSession(ctx context.Context, session *qcmodel.Session) error
+
+	// FetchQCSession retrieves a session entity from storage.
+	FetchQCSession(ctx context.Context, id string) (*qcmodel.Session, error)
+
+	// FetchQCSessions retrieves from storage all session entities of a given room, sorted by start time.
+	FetchQCSessions(ctx context.Context, roomID int) ([]qcmodel.Session, error)
+}
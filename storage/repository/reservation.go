@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	roomsmodel "github.com/dantin/cubit/model/rooms"
+)
+
+// Reservation defines room reservation repository operations
+type Reservation interface {
+	// InsertReservation inserts a new reservation into storage,
+	// unless it overlaps another reservation of the same room, in which case false is returned.
+	InsertReservation(ctx context.Context, reservation *roomsmodel.Reservation) (bool, error)
+
+	// DeleteReservation deletes a reservation entity from storage.
+	DeleteReservation(ctx context.Context, id string) error
+
+	// FetchReservation retrieves a reservation entity from storage.
+	FetchReservation(ctx context.Context, id string) (*roomsmodel.Reservation, error)
+
+	// FetchReservations retrieves from storage the reservations of a given room ending after from
+	// and starting no later than to, sorted by start time. A zero from or to leaves range unbounded.
+	FetchReservations(ctx context.Context, roomID int, from, to time.Time) ([]roomsmodel.Reservation, error)
+
+	// FetchUserReservations retrieves from storage the reservations held by a given user ending after from
+	// and starting no later than to, sorted by start time. A zero from or to leaves range unbounded.
+	FetchUserReservations(ctx context.Context, username string, from, to time.Time) ([]roomsmodel.Reservation, error)
+}
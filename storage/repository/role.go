@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+
+	rolesmodel "github.com/dantin/cubit/model/roles"
+)
+
+// Role defines role repository operations
+type Role interface {
+	// UpsertRole inserts a new role entity into storage, or updates it if previously inserted.
+	UpsertRole(ctx context.Context, role *rolesmodel.Role) error
+
+	// DeleteRole deletes a role entity from storage, along with its user assignments.
+	DeleteRole(ctx context.Context, name string) error
+
+	// FetchRole retrieves a role entity from storage.
+	FetchRole(ctx context.Context, name string) (*rolesmodel.Role, error)
+
+	// FetchRoles retrieves all role entities from storage, sorted by name.
+	FetchRoles(ctx context.Context) ([]rolesmodel.Role, error)
+}
@@ -9,32 +9,43 @@ import (
 // Room defines room repository operations
 type Room interface {
 	// UpsertRoom inserts a new room entity into storage, or updates it if previously inserted.
-	//UpsertRoom(ctx context.Context, room *roomsmodel.Room) error
+	// On insertion room identifier will be populated with the generated one.
+	UpsertRoom(ctx context.Context, room *roomsmodel.Room) error
 
-	// DeleteRoom deletes a room entity from storage.
-	//DeleteRoom(ctx context.Context, name string) error
+	// DeleteRoom deletes a room entity, and all its video streams, from storage.
+	DeleteRoom(ctx context.Context, roomID int) error
 
 	// BindRoom binds a room to user.
-	//BindRoom(ctx context.Context, room *roomsmodel.Room, username string) error
+	BindRoom(ctx context.Context, roomID int, username string) error
 
 	// UnbindRoom unbinds a room from user.
-	//UnbindRoom(ctx context.Context, room *roomsmodel.Room, username string) error
+	UnbindRoom(ctx context.Context, roomID int, username string) error
 
-	// UpsertVideoStream insert a new video stream to room whose name is 'name' into storage, or updates it if previously inserted.
-	//UpsertVideoStream(ctx context.Context, room *roomsmodel.Room, to roomsmodel.VideoType, stream *roomsmodel.VideoStream) error
+	// FetchBoundRooms retrieves from storage all room entities bound to a given user.
+	FetchBoundRooms(ctx context.Context, username string) ([]roomsmodel.Room, error)
 
-	// DeleteVideoStream deletes a video stream from storage.
-	//DeleteVideoStream(ctx context.Context, room *roomsmodel.Room, to roomsmodel.VideoType) error
+	// FetchBoundUsers retrieves from storage the usernames of all users bound to a given room.
+	FetchBoundUsers(ctx context.Context, roomID int) ([]string, error)
+
+	// UpsertVideoStream inserts a new video stream into its room, or updates it if a stream of the same type was previously inserted.
+	UpsertVideoStream(ctx context.Context, stream *roomsmodel.VideoStream) error
+
+	// DeleteVideoStream deletes a room video stream from storage.
+	DeleteVideoStream(ctx context.Context, roomID int, tp roomsmodel.VideoType) error
 
 	// FetchRoom retrieves a room entity from storage.
 	FetchRoom(ctx context.Context, username string) (*roomsmodel.Room, error)
 
-	// FetchRooms retrieves room entites from storage.
-	FetchRooms(ctx context.Context, page int, pageSize int) ([]roomsmodel.Room, error)
+	// FetchRoomByID retrieves a room entity, along with its raw video stream definitions, from storage.
+	FetchRoomByID(ctx context.Context, roomID int) (*roomsmodel.Room, error)
+
+	// FetchRooms retrieves from storage a page of room entities matching filter, with their video streams routed to admin target,
+	// along with the position of its first room within the whole matching set.
+	FetchRooms(ctx context.Context, filter *roomsmodel.Filter, page *roomsmodel.Page) ([]roomsmodel.Room, int, error)
 
-	// CountRooms  returns current size of rooms.
-	CountRooms(ctx context.Context) (int, error)
+	// CountRooms returns the number of rooms matching filter.
+	CountRooms(ctx context.Context, filter *roomsmodel.Filter) (int, error)
 
-	// FetchQCStream retrieves qc room stream entites from storage.
+	// FetchQCStream retrieves from storage the stream routed to user within the qc room bound to it.
 	FetchQCStream(ctx context.Context, username string) (*roomsmodel.VideoStream, error)
 }
@@ -17,6 +17,9 @@ type User interface {
 	// FetchUser retrieves a user entity from storage.
 	FetchUser(ctx context.Context, username string) (*model.User, error)
 
+	// UpdateUserRole assigns a role to an existing user.
+	UpdateUserRole(ctx context.Context, username string, role model.Role) error
+
 	// UserExists tells whether or not a user exists within storage.
 	UserExists(ctx context.Context, username string) (bool, error)
 }
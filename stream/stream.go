@@ -2,6 +2,7 @@ package stream
 
 import (
 	"context"
+	"net"
 
 	"github.com/dantin/cubit/xmpp"
 	"github.com/dantin/cubit/xmpp/jid"
@@ -34,6 +35,8 @@ type C2S interface {
 
 	JID() *jid.JID
 
+	RemoteAddr() net.Addr
+
 	IsSecured() bool
 	IsAuthenticated() bool
 
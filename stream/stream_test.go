@@ -2,6 +2,7 @@ package stream
 
 import (
 	"context"
+	"net"
 	"testing"
 
 	"github.com/dantin/cubit/xmpp"
@@ -49,4 +50,8 @@ func TestMockC2Stream(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 	require.True(t, stm.IsAuthenticated())
+
+	addr := &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 56324}
+	stm.SetRemoteAddr(addr)
+	require.Equal(t, addr, stm.RemoteAddr())
 }
@@ -0,0 +1,529 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dantin/cubit/transport/compress"
+	"github.com/dantin/cubit/xmpp"
+	"github.com/dantin/cubit/xmpp/jid"
+	"github.com/google/uuid"
+)
+
+const (
+	boshNamespace       = "http://jabber.org/protocol/httpbind"
+	xboshNamespace      = "urn:xmpp:xbosh"
+	boshStreamNamespace = "http://etherx.jabber.org/streams"
+	boshVersion         = "1.11"
+)
+
+// BOSH terminal binding conditions
+const (
+	boshBadRequest      = "bad-request"
+	boshItemNotFound    = "item-not-found"
+	boshPolicyViolation = "policy-violation"
+)
+
+// BOSHConfig represents a BOSH connection manager configuration.
+type BOSHConfig struct {
+	// Wait defines the longest time a request can be held.
+	Wait time.Duration
+
+	// Hold defines the maximum number of requests that can be simultaneously held.
+	Hold int
+
+	// Inactivity defines the longest allowed time without any held request.
+	Inactivity time.Duration
+
+	// MaxPause defines the longest time a session can be paused.
+	MaxPause time.Duration
+
+	// Polling defines the shortest allowable polling interval.
+	Polling time.Duration
+
+	// MaxRequestSize defines the maximum size of an incoming request body, zero means unlimited.
+	MaxRequestSize int
+}
+
+// BOSHHandler represents a BOSH (XEP-0124, XEP-0206) connection manager,
+// binding incoming HTTP requests to their BOSH session transport.
+type BOSHHandler struct {
+	cfg       *BOSHConfig
+	onSession func(tr Transport, r *http.Request)
+
+	mu       sync.RWMutex
+	sessions map[string]*boshTransport
+}
+
+// NewBOSHHandler returns a BOSH connection manager notifying every newly created session transport,
+// along with its session creation request.
+func NewBOSHHandler(cfg *BOSHConfig, onSession func(tr Transport, r *http.Request)) *BOSHHandler {
+	return &BOSHHandler{
+		cfg:       cfg,
+		onSession: onSession,
+		sessions:  make(map[string]*boshTransport),
+	}
+}
+
+// ServeHTTP satisfies http.Handler interface.
+func (h *BOSHHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+
+	switch r.Method {
+	case http.MethodOptions:
+		return
+	case http.MethodPost:
+		break
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var rd io.Reader = r.Body
+	if h.cfg.MaxRequestSize > 0 {
+		rd = io.LimitReader(r.Body, int64(h.cfg.MaxRequestSize)+1)
+	}
+	body, err := xmpp.NewParser(rd, xmpp.DefaultMode, h.cfg.MaxRequestSize).ParseElement()
+	if err != nil || body == nil || body.Name() != "body" || body.Namespace() != boshNamespace {
+		writeBOSHResponse(w, terminateBody(boshBadRequest, ""))
+		return
+	}
+	rid, err := strconv.ParseUint(body.Attributes().Get("rid"), 10, 64)
+	if err != nil {
+		writeBOSHResponse(w, terminateBody(boshBadRequest, ""))
+		return
+	}
+	var tr *boshTransport
+
+	sid := body.Attributes().Get("sid")
+	if len(sid) == 0 {
+		tr, err = h.createSession(body, rid)
+		if err != nil {
+			writeBOSHResponse(w, terminateBody(boshBadRequest, ""))
+			return
+		}
+	} else {
+		h.mu.RLock()
+		tr = h.sessions[sid]
+		h.mu.RUnlock()
+		if tr == nil {
+			writeBOSHResponse(w, terminateBody(boshItemNotFound, ""))
+			return
+		}
+	}
+	respCh := tr.handleRequest(rid, body)
+	if sid == "" && h.onSession != nil {
+		h.onSession(tr, r)
+	}
+	select {
+	case resp := <-respCh:
+		writeBOSHResponse(w, resp)
+	case <-r.Context().Done():
+		break // response will be sent again on retransmission
+	}
+}
+
+// Close terminates every active session.
+func (h *BOSHHandler) Close() {
+	h.mu.RLock()
+	trs := make([]*boshTransport, 0, len(h.sessions))
+	for _, tr := range h.sessions {
+		trs = append(trs, tr)
+	}
+	h.mu.RUnlock()
+
+	for _, tr := range trs {
+		_ = tr.Close()
+	}
+}
+
+func (h *BOSHHandler) createSession(body xmpp.XElement, rid uint64) (*boshTransport, error) {
+	attrs := body.Attributes()
+	if _, err := jid.New("", attrs.Get("to"), "", false); err != nil {
+		return nil, fmt.Errorf("bosh: invalid 'to' attribute: %v", err)
+	}
+	wait := h.cfg.Wait
+	if v := attrs.Get("wait"); len(v) > 0 {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			return nil, fmt.Errorf("bosh: invalid 'wait' attribute: %s", v)
+		}
+		if d := time.Duration(secs) * time.Second; d < wait {
+			wait = d
+		}
+	}
+	hold := 1
+	if v := attrs.Get("hold"); len(v) > 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("bosh: invalid 'hold' attribute: %s", v)
+		}
+		hold = n
+	}
+	if hold > h.cfg.Hold {
+		hold = h.cfg.Hold
+	}
+	tr := newBOSHTransport(uuid.New().String(), h, attrs.Get("to"), wait, hold, rid)
+
+	h.mu.Lock()
+	h.sessions[tr.sid] = tr
+	h.mu.Unlock()
+	return tr, nil
+}
+
+func (h *BOSHHandler) unregister(sid string) {
+	h.mu.Lock()
+	delete(h.sessions, sid)
+	h.mu.Unlock()
+}
+
+type boshRequest struct {
+	rid    uint64
+	body   xmpp.XElement
+	respCh chan []byte
+	waitTm *time.Timer
+}
+
+type boshTransport struct {
+	h    *BOSHHandler
+	sid  string
+	to   string
+	wait time.Duration
+	hold int
+
+	mu           sync.Mutex
+	cond         *sync.Cond
+	nextRID      uint64
+	queued       map[uint64]*boshRequest // out of order requests
+	held         []*boshRequest
+	responses    map[uint64][]byte // latest responses, by request identifier
+	in           [][]byte
+	wBuf         bytes.Buffer
+	out          bytes.Buffer
+	started      bool // stream open element was handed to the reader
+	created      bool // session creation response was sent
+	closed       bool
+	inactivity   time.Duration
+	inactivityTm *time.Timer
+}
+
+func newBOSHTransport(sid string, h *BOSHHandler, to string, wait time.Duration, hold int, rid uint64) *boshTransport {
+	tr := &boshTransport{
+		h:          h,
+		sid:        sid,
+		to:         to,
+		wait:       wait,
+		hold:       hold,
+		nextRID:    rid,
+		queued:     make(map[uint64]*boshRequest),
+		responses:  make(map[uint64][]byte),
+		inactivity: h.cfg.Inactivity,
+	}
+	tr.cond = sync.NewCond(&tr.mu)
+	return tr
+}
+
+// Read returns incoming payloads in request order.
+// Stream restarts and terminations are handed to the reader as a self-closed body element.
+func (tr *boshTransport) Read(p []byte) (n int, err error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	for len(tr.in) == 0 {
+		if tr.closed {
+			return 0, io.EOF
+		}
+		tr.cond.Wait()
+	}
+	n = copy(p, tr.in[0])
+	if n < len(tr.in[0]) {
+		tr.in[0] = tr.in[0][n:]
+	} else {
+		tr.in = tr.in[1:]
+	}
+	return n, nil
+}
+
+func (tr *boshTransport) Write(p []byte) (n int, err error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.wBuf.Write(p)
+}
+
+// Close terminates BOSH session, delivering any pending payload.
+func (tr *boshTransport) Close() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.terminate("")
+	return nil
+}
+
+func (tr *boshTransport) Type() Type {
+	return BOSH
+}
+
+func (tr *boshTransport) WriteString(s string) (int, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.wBuf.WriteString(s)
+}
+
+// Flush makes every written byte available to the oldest held request.
+func (tr *boshTransport) Flush() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.closed {
+		return io.ErrClosedPipe
+	}
+	_, _ = tr.wBuf.WriteTo(&tr.out)
+	if len(tr.held) > 0 && tr.out.Len() > 0 {
+		tr.respond(tr.held[0])
+	}
+	return nil
+}
+
+// SetWriteDeadline is a no-op, since writes never block.
+func (tr *boshTransport) SetWriteDeadline(_ time.Time) error { return nil }
+
+// StartTLS is a no-op, since BOSH connections get secured at HTTP level.
+func (tr *boshTransport) StartTLS(_ *tls.Config, _ bool) {}
+
+// EnableCompression is a no-op, since BOSH payloads get compressed at HTTP level.
+func (tr *boshTransport) EnableCompression(_ compress.Level) {}
+
+// ChannelBindingBytes returns nil, since BOSH sessions span across several connections.
+func (tr *boshTransport) ChannelBindingBytes(_ ChannelBindingMechanism) []byte { return nil }
+
+// PeerCertificates returns nil, since BOSH sessions span across several connections.
+func (tr *boshTransport) PeerCertificates() []*x509.Certificate { return nil }
+
+// handleRequest processes an incoming request, returning the channel its response is to be sent on.
+func (tr *boshTransport) handleRequest(rid uint64, body xmpp.XElement) <-chan []byte {
+	req := &boshRequest{rid: rid, body: body, respCh: make(chan []byte, 1)}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.closed {
+		req.respCh <- terminateBody(boshItemNotFound, "")
+		return req.respCh
+	}
+	window := uint64(tr.hold) + 1
+	switch {
+	case rid < tr.nextRID:
+		// retransmission
+		if resp, ok := tr.responses[rid]; ok {
+			req.respCh <- resp
+			return req.respCh
+		}
+		for i, held := range tr.held {
+			if held.rid == rid {
+				tr.held[i] = req
+				req.waitTm = held.waitTm
+				held.respCh <- emptyBody()
+				return req.respCh
+			}
+		}
+		tr.terminate(boshItemNotFound)
+		req.respCh <- terminateBody(boshItemNotFound, "")
+		return req.respCh
+
+	case rid >= tr.nextRID+window:
+		tr.terminate(boshItemNotFound)
+		req.respCh <- terminateBody(boshItemNotFound, "")
+		return req.respCh
+	}
+	tr.queued[rid] = req
+
+	// process requests in order
+	for {
+		next, ok := tr.queued[tr.nextRID]
+		if !ok {
+			break
+		}
+		delete(tr.queued, tr.nextRID)
+		tr.nextRID++
+		tr.process(next)
+	}
+	return req.respCh
+}
+
+func (tr *boshTransport) process(req *boshRequest) {
+	if tr.closed {
+		req.respCh <- terminateBody(boshItemNotFound, "")
+		return
+	}
+	if tr.inactivityTm != nil {
+		tr.inactivityTm.Stop()
+		tr.inactivityTm = nil
+	}
+	attrs := req.body.Attributes()
+
+	buf := &strings.Builder{}
+	for _, elem := range req.body.Elements().All() {
+		_ = elem.ToXML(buf, true)
+	}
+	switch {
+	case !tr.started || attrs.Get("xmpp:restart") == "true":
+		tr.started = true
+		buf.WriteString(fmt.Sprintf(`<body xmlns="%s" to="%s" version="1.0"/>`, boshNamespace, tr.to))
+	case attrs.Get("type") == "terminate":
+		buf.WriteString(fmt.Sprintf(`<body xmlns="%s" type="terminate"/>`, boshNamespace))
+	case buf.Len() == 0:
+		buf.WriteString(" ") // keep alive
+	}
+	tr.in = append(tr.in, []byte(buf.String()))
+	tr.cond.Broadcast()
+
+	tr.held = append(tr.held, req)
+	req.waitTm = time.AfterFunc(tr.wait, func() {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		tr.respond(req)
+	})
+	if v := attrs.Get("pause"); len(v) > 0 {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 || time.Duration(secs)*time.Second > tr.h.cfg.MaxPause {
+			tr.terminate(boshPolicyViolation)
+			return
+		}
+		// release every held request
+		tr.inactivity = time.Duration(secs) * time.Second
+		for len(tr.held) > 0 {
+			tr.respond(tr.held[0])
+		}
+		return
+	}
+	tr.inactivity = tr.h.cfg.Inactivity
+
+	if tr.out.Len() > 0 {
+		tr.respond(tr.held[0])
+	}
+	for len(tr.held) > tr.hold {
+		tr.respond(tr.held[0])
+	}
+}
+
+// respond sends pending payload to a held request. Caller must hold the lock.
+func (tr *boshTransport) respond(req *boshRequest) {
+	idx := -1
+	for i, held := range tr.held {
+		if held == req {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return // already responded
+	}
+	tr.held = append(tr.held[:idx], tr.held[idx+1:]...)
+	req.waitTm.Stop()
+
+	resp := tr.responseBody("", tr.out.String())
+	tr.out.Reset()
+
+	tr.responses[req.rid] = resp
+	delete(tr.responses, req.rid-uint64(tr.hold)-1)
+	req.respCh <- resp
+
+	if len(tr.held) == 0 && !tr.closed {
+		tr.inactivityTm = time.AfterFunc(tr.inactivity, func() {
+			_ = tr.Close()
+		})
+	}
+}
+
+// terminate closes the session responding to every pending request. Caller must hold the lock.
+func (tr *boshTransport) terminate(condition string) {
+	if tr.closed {
+		return
+	}
+	tr.closed = true
+	tr.cond.Broadcast()
+
+	if tr.inactivityTm != nil {
+		tr.inactivityTm.Stop()
+	}
+	_, _ = tr.wBuf.WriteTo(&tr.out)
+	for _, req := range tr.held {
+		req.waitTm.Stop()
+		req.respCh <- tr.responseBody(condition, tr.out.String())
+		tr.out.Reset()
+	}
+	tr.held = nil
+	for _, req := range tr.queued {
+		req.respCh <- terminateBody(condition, "")
+	}
+	tr.queued = nil
+
+	tr.h.unregister(tr.sid)
+}
+
+func (tr *boshTransport) responseBody(condition, payload string) []byte {
+	body := xmpp.NewElementNamespace("body", boshNamespace)
+	body.SetAttribute("xmlns:stream", boshStreamNamespace)
+	if !tr.created {
+		cfg := tr.h.cfg
+		body.SetAttribute("xmlns:xmpp", xboshNamespace)
+		body.SetAttribute("sid", tr.sid)
+		body.SetAttribute("wait", strconv.Itoa(int(tr.wait/time.Second)))
+		body.SetAttribute("hold", strconv.Itoa(tr.hold))
+		body.SetAttribute("requests", strconv.Itoa(tr.hold+1))
+		body.SetAttribute("inactivity", strconv.Itoa(int(cfg.Inactivity/time.Second)))
+		body.SetAttribute("maxpause", strconv.Itoa(int(cfg.MaxPause/time.Second)))
+		body.SetAttribute("polling", strconv.Itoa(int(cfg.Polling/time.Second)))
+		body.SetAttribute("ver", boshVersion)
+		body.SetAttribute("from", tr.to)
+		body.SetAttribute("xmpp:version", "1.0")
+		body.SetAttribute("xmpp:restartlogic", "true")
+		tr.created = true
+	}
+	if tr.closed {
+		body.SetAttribute("type", "terminate")
+		if len(condition) > 0 {
+			body.SetAttribute("condition", condition)
+		}
+	}
+	return wrapBody(body, payload)
+}
+
+func emptyBody() []byte {
+	return wrapBody(xmpp.NewElementNamespace("body", boshNamespace), "")
+}
+
+func terminateBody(condition, payload string) []byte {
+	body := xmpp.NewElementNamespace("body", boshNamespace)
+	body.SetAttribute("type", "terminate")
+	if len(condition) > 0 {
+		body.SetAttribute("condition", condition)
+	}
+	return wrapBody(body, payload)
+}
+
+func wrapBody(body *xmpp.Element, payload string) []byte {
+	buf := &bytes.Buffer{}
+	if len(payload) == 0 {
+		_ = body.ToXML(buf, true)
+		return buf.Bytes()
+	}
+	_ = body.ToXML(buf, false)
+	buf.WriteString(payload)
+	buf.WriteString("</body>")
+	return buf.Bytes()
+}
+
+func writeBOSHResponse(w http.ResponseWriter, resp []byte) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	_, _ = w.Write(resp)
+}
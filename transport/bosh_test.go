@@ -0,0 +1,205 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dantin/cubit/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBOSH_SessionCreation(t *testing.T) {
+	srv, trCh := setupBOSHTest(&BOSHConfig{Wait: time.Second * 60, Hold: 1, Inactivity: time.Second * 30, MaxPause: time.Second * 120, Polling: time.Second * 2})
+	defer srv.Close()
+
+	// invalid requests
+	resp, err := http.Get(srv.URL)
+	require.Nil(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	body := postBOSH(t, srv, `<iq/>`)
+	require.Equal(t, "terminate", body.Type())
+	require.Equal(t, boshBadRequest, body.Attributes().Get("condition"))
+
+	body = postBOSH(t, srv, boshBody(`sid="unknown" rid="1"`, ""))
+	require.Equal(t, boshItemNotFound, body.Attributes().Get("condition"))
+
+	body = postBOSH(t, srv, boshBody(`to="" rid="1"`, ""))
+	require.Equal(t, boshBadRequest, body.Attributes().Get("condition"))
+
+	// session creation
+	bodyCh := postBOSHAsync(t, srv, boshBody(`to="example.org" rid="100" wait="30" hold="2"`, ""))
+
+	tr := <-trCh
+	require.Equal(t, BOSH, tr.Type())
+	require.Equal(t, `<body xmlns="http://jabber.org/protocol/httpbind" to="example.org" version="1.0"/>`, readBOSH(t, tr))
+
+	_, _ = tr.WriteString(`<stream:features/>`)
+	require.Nil(t, tr.Flush())
+
+	body = <-bodyCh
+	attrs := body.Attributes()
+	require.Equal(t, "", body.Type())
+	require.NotEqual(t, "", attrs.Get("sid"))
+	require.Equal(t, "30", attrs.Get("wait"))
+	require.Equal(t, "1", attrs.Get("hold")) // clamped to configured value
+	require.Equal(t, "2", attrs.Get("requests"))
+	require.Equal(t, "30", attrs.Get("inactivity"))
+	require.Equal(t, "120", attrs.Get("maxpause"))
+	require.Equal(t, "2", attrs.Get("polling"))
+	require.Equal(t, boshVersion, attrs.Get("ver"))
+	require.Equal(t, "example.org", attrs.Get("from"))
+	require.Equal(t, "true", attrs.Get("xmpp:restartlogic"))
+	require.NotNil(t, body.Elements().Child("stream:features"))
+
+	// creation attributes are sent only once
+	bodyCh = postBOSHAsync(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="101" xmpp:restart="true" xmlns:xmpp="urn:xmpp:xbosh"`, attrs.Get("sid")), ""))
+	require.Equal(t, `<body xmlns="http://jabber.org/protocol/httpbind" to="example.org" version="1.0"/>`, readBOSH(t, tr))
+
+	_, _ = tr.WriteString(`<stream:features/>`)
+	require.Nil(t, tr.Flush())
+
+	body = <-bodyCh
+	require.Equal(t, "", body.Attributes().Get("sid"))
+	require.NotNil(t, body.Elements().Child("stream:features"))
+}
+
+func TestBOSH_RequestOrdering(t *testing.T) {
+	srv, trCh := setupBOSHTest(&BOSHConfig{Wait: time.Second, Hold: 1, Inactivity: time.Second * 30, MaxPause: time.Second * 120})
+	defer srv.Close()
+
+	sid, tr := createBOSHSession(t, srv, trCh)
+
+	// out of order request is queued
+	bodyCh102 := postBOSHAsync(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="102"`, sid), ""))
+	time.Sleep(time.Millisecond * 100)
+
+	bodyCh101 := postBOSHAsync(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="101"`, sid), "<message/>"))
+	require.Equal(t, "<message/>", readBOSH(t, tr))
+	require.Equal(t, " ", readBOSH(t, tr))
+
+	// oldest request is released when exceeding hold
+	body := <-bodyCh101
+	require.Equal(t, "", body.Type())
+	require.Len(t, body.Elements().All(), 0)
+
+	_, _ = tr.WriteString(`<presence/>`)
+	require.Nil(t, tr.Flush())
+
+	body = <-bodyCh102
+	require.NotNil(t, body.Elements().Child("presence"))
+
+	// retransmitted request is answered from cache
+	body = postBOSH(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="102"`, sid), ""))
+	require.NotNil(t, body.Elements().Child("presence"))
+
+	// held request is answered after wait
+	start := time.Now()
+	body = postBOSH(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="103"`, sid), ""))
+	require.True(t, time.Since(start) >= time.Second)
+	require.Equal(t, "", body.Type())
+	require.Equal(t, " ", readBOSH(t, tr))
+
+	// request out of window
+	body = postBOSH(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="110"`, sid), ""))
+	require.Equal(t, "terminate", body.Type())
+	require.Equal(t, boshItemNotFound, body.Attributes().Get("condition"))
+
+	_, err := tr.Read(make([]byte, 16))
+	require.Equal(t, io.EOF, err)
+}
+
+func TestBOSH_Pause(t *testing.T) {
+	srv, trCh := setupBOSHTest(&BOSHConfig{Wait: time.Second * 60, Hold: 1, Inactivity: time.Second * 30, MaxPause: time.Second * 120})
+	defer srv.Close()
+
+	sid, tr := createBOSHSession(t, srv, trCh)
+
+	// held requests are released on pause
+	bodyCh := postBOSHAsync(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="101"`, sid), ""))
+	require.Equal(t, " ", readBOSH(t, tr))
+
+	body := postBOSH(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="102" pause="60"`, sid), ""))
+	require.Equal(t, "", body.Type())
+	body = <-bodyCh
+	require.Equal(t, "", body.Type())
+
+	// too long pause
+	body = postBOSH(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="103" pause="180"`, sid), ""))
+	require.Equal(t, "terminate", body.Type())
+	require.Equal(t, boshPolicyViolation, body.Attributes().Get("condition"))
+}
+
+func TestBOSH_Terminate(t *testing.T) {
+	srv, trCh := setupBOSHTest(&BOSHConfig{Wait: time.Second * 60, Hold: 1, Inactivity: time.Second * 30, MaxPause: time.Second * 120})
+	defer srv.Close()
+
+	sid, tr := createBOSHSession(t, srv, trCh)
+
+	bodyCh := postBOSHAsync(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="101" type="terminate"`, sid), "<presence type='unavailable'/>"))
+	require.Equal(t, `<presence type="unavailable"/><body xmlns="http://jabber.org/protocol/httpbind" type="terminate"/>`, readBOSH(t, tr))
+
+	_, _ = tr.WriteString(`<presence/>`)
+	require.Nil(t, tr.Close())
+
+	body := <-bodyCh
+	require.Equal(t, "terminate", body.Type())
+	require.Equal(t, "", body.Attributes().Get("condition"))
+	require.NotNil(t, body.Elements().Child("presence"))
+
+	// session no longer exists
+	body = postBOSH(t, srv, boshBody(fmt.Sprintf(`sid="%s" rid="102"`, sid), ""))
+	require.Equal(t, boshItemNotFound, body.Attributes().Get("condition"))
+}
+
+func setupBOSHTest(cfg *BOSHConfig) (*httptest.Server, <-chan Transport) {
+	trCh := make(chan Transport, 1)
+	h := NewBOSHHandler(cfg, func(tr Transport, _ *http.Request) { trCh <- tr })
+	return httptest.NewServer(h), trCh
+}
+
+func createBOSHSession(t *testing.T, srv *httptest.Server, trCh <-chan Transport) (string, Transport) {
+	bodyCh := postBOSHAsync(t, srv, boshBody(`to="example.org" rid="100"`, ""))
+	tr := <-trCh
+	_ = readBOSH(t, tr)
+	_, _ = tr.WriteString(`<stream:features/>`)
+	require.Nil(t, tr.Flush())
+
+	body := <-bodyCh
+	return body.Attributes().Get("sid"), tr
+}
+
+func boshBody(attrs, payload string) string {
+	return fmt.Sprintf(`<body xmlns="http://jabber.org/protocol/httpbind" xmlns:stream="http://etherx.jabber.org/streams" %s>%s</body>`, attrs, payload)
+}
+
+func postBOSH(t *testing.T, srv *httptest.Server, body string) xmpp.XElement {
+	resp, err := http.Post(srv.URL, "text/xml; charset=utf-8", strings.NewReader(body))
+	require.Nil(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	elem, err := xmpp.NewParser(resp.Body, xmpp.DefaultMode, 0).ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "body", elem.Name())
+	return elem
+}
+
+func postBOSHAsync(t *testing.T, srv *httptest.Server, body string) <-chan xmpp.XElement {
+	ch := make(chan xmpp.XElement, 1)
+	go func() { ch <- postBOSH(t, srv, body) }()
+	return ch
+}
+
+func readBOSH(t *testing.T, tr Transport) string {
+	buf := make([]byte, 1024)
+	n, err := tr.Read(buf)
+	require.Nil(t, err)
+	return string(buf[:n])
+}
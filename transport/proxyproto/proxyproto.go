@@ -0,0 +1,280 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	v1Prefix        = "PROXY "
+	v1MaxHeaderSize = 107
+	v2HeaderSize    = 16
+
+	v2CmdLocal = 0x0
+	v2CmdProxy = 0x1
+
+	v2FamilyInet  = 0x1
+	v2FamilyInet6 = 0x2
+
+	readBufferSize = 4096
+)
+
+var v2Signature = []byte{0x0d, 0x0a, 0x0d, 0x0a, 0x00, 0x0d, 0x0a, 0x51, 0x55, 0x49, 0x54, 0x0a}
+
+// ErrMissingHeader will be returned by a trusted connection not starting with a PROXY protocol header.
+var ErrMissingHeader = errors.New("proxyproto: missing PROXY protocol header")
+
+// ErrInvalidHeader will be returned by a trusted connection carrying a malformed PROXY protocol header.
+var ErrInvalidHeader = errors.New("proxyproto: invalid PROXY protocol header")
+
+// Config represents a PROXY protocol listener configuration.
+type Config struct {
+	// TrustedProxies defines the networks whose connections are required to start with a PROXY protocol header.
+	TrustedProxies []*net.IPNet
+
+	// HeaderTimeout defines the longest time to wait for a PROXY protocol header, zero means no limit.
+	HeaderTimeout time.Duration
+}
+
+// ParseTrustedProxies parses a list of CIDR notation networks or single IP addresses.
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var ret []*net.IPNet
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("proxyproto: invalid trusted proxy address: %s", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			ret = append(ret, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("proxyproto: invalid trusted proxy network: %s", p)
+		}
+		ret = append(ret, ipNet)
+	}
+	return ret, nil
+}
+
+type listener struct {
+	net.Listener
+	cfg *Config
+}
+
+// NewListener returns a listener parsing PROXY protocol (v1 and v2) headers sent by trusted proxies.
+// Connections from any other source are returned untouched.
+func NewListener(ln net.Listener, cfg *Config) net.Listener {
+	return &listener{Listener: ln, cfg: cfg}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &Conn{
+		Conn:    conn,
+		br:      bufio.NewReaderSize(conn, readBufferSize),
+		timeout: l.cfg.HeaderTimeout,
+	}, nil
+}
+
+func (l *listener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, ipNet := range l.cfg.TrustedProxies {
+		if ipNet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn represents a connection accepted from a trusted proxy.
+// PROXY protocol header is read on first access, so that accepting connections never blocks.
+type Conn struct {
+	net.Conn
+	br      *bufio.Reader
+	timeout time.Duration
+
+	once       sync.Once
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	err        error
+}
+
+// Read reads data from the connection, once the PROXY protocol header has been consumed.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.br.Read(p)
+}
+
+// RemoteAddr returns the original client address, falling back to the proxy address
+// if the header carried none.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the original destination address, falling back to the proxy connection local address
+// if the header carried none.
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *Conn) readHeader() {
+	if c.timeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+	}
+	c.remoteAddr, c.localAddr, c.err = readHeader(c.br)
+	if c.err != nil {
+		_ = c.Conn.Close()
+	}
+}
+
+func readHeader(br *bufio.Reader) (src, dst net.Addr, err error) {
+	b, err := br.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, nil, err
+	}
+	if string(b) == v1Prefix {
+		return readV1Header(br)
+	}
+	b, err = br.Peek(len(v2Signature))
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.Equal(b, v2Signature) {
+		return readV2Header(br)
+	}
+	return nil, nil, ErrMissingHeader
+}
+
+// readV1Header reads a human readable header. (e.g. 'PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n')
+func readV1Header(br *bufio.Reader) (src, dst net.Addr, err error) {
+	var line []byte
+	for len(line) < v1MaxHeaderSize {
+		c, err := br.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, ErrInvalidHeader
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 {
+		return nil, nil, ErrInvalidHeader
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil, nil // connection addresses are to be used
+	case "TCP4", "TCP6":
+		if len(fields) != 6 {
+			return nil, nil, ErrInvalidHeader
+		}
+	default:
+		return nil, nil, ErrInvalidHeader
+	}
+	isV4 := fields[1] == "TCP4"
+	srcAddr, err := parseV1Address(fields[2], fields[4], isV4)
+	if err != nil {
+		return nil, nil, err
+	}
+	dstAddr, err := parseV1Address(fields[3], fields[5], isV4)
+	if err != nil {
+		return nil, nil, err
+	}
+	return srcAddr, dstAddr, nil
+}
+
+func parseV1Address(ipStr, portStr string, isV4 bool) (*net.TCPAddr, error) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil || (ip.To4() != nil) != isV4 {
+		return nil, ErrInvalidHeader
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2Header reads a binary header.
+func readV2Header(br *bufio.Reader) (src, dst net.Addr, err error) {
+	var hdr [v2HeaderSize]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, nil, err
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, nil, ErrInvalidHeader
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, nil, err
+	}
+	switch hdr[12] & 0x0f {
+	case v2CmdLocal:
+		return nil, nil, nil // health check issued by the proxy itself
+	case v2CmdProxy:
+		break
+	default:
+		return nil, nil, ErrInvalidHeader
+	}
+	var ipLen int
+	switch hdr[13] >> 4 {
+	case v2FamilyInet:
+		ipLen = net.IPv4len
+	case v2FamilyInet6:
+		ipLen = net.IPv6len
+	default:
+		return nil, nil, nil // unsupported family, connection addresses are to be used
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, nil, ErrInvalidHeader
+	}
+	// any trailing TLV vector is ignored
+	srcAddr := &net.TCPAddr{
+		IP:   net.IP(payload[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}
+	dstAddr := &net.TCPAddr{
+		IP:   net.IP(payload[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+	}
+	return srcAddr, dstAddr, nil
+}
@@ -0,0 +1,152 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyProto_ParseTrustedProxies(t *testing.T) {
+	nets, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.10", "::1"})
+	require.Nil(t, err)
+	require.Len(t, nets, 3)
+	require.Equal(t, "10.0.0.0/8", nets[0].String())
+	require.Equal(t, "192.168.1.10/32", nets[1].String())
+	require.Equal(t, "::1/128", nets[2].String())
+
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	require.NotNil(t, err)
+
+	_, err = ParseTrustedProxies([]string{"localhost"})
+	require.NotNil(t, err)
+}
+
+func TestProxyProto_V1(t *testing.T) {
+	ln := setupTest(t, "127.0.0.0/8")
+	defer func() { _ = ln.Close() }()
+
+	conn := acceptWithHeader(t, ln, []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 5222\r\nhello"))
+	require.Equal(t, "192.168.0.1:56324", conn.RemoteAddr().String())
+	require.Equal(t, "192.168.0.11:5222", conn.LocalAddr().String())
+	requireRead(t, conn, "hello")
+
+	conn = acceptWithHeader(t, ln, []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 5222\r\nhello"))
+	require.Equal(t, "[2001:db8::1]:56324", conn.RemoteAddr().String())
+	requireRead(t, conn, "hello")
+
+	// connection addresses are used for unknown protocols
+	conn = acceptWithHeader(t, ln, []byte("PROXY UNKNOWN\r\nhello"))
+	require.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+	requireRead(t, conn, "hello")
+
+	// address family mismatch
+	conn = acceptWithHeader(t, ln, []byte("PROXY TCP4 2001:db8::1 192.168.0.11 56324 5222\r\n"))
+	_, err := conn.Read(make([]byte, 16))
+	require.Equal(t, ErrInvalidHeader, err)
+
+	// unterminated header
+	conn = acceptWithHeader(t, ln, []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 5222\n"))
+	_, err = conn.Read(make([]byte, 16))
+	require.Equal(t, ErrInvalidHeader, err)
+}
+
+func TestProxyProto_V2(t *testing.T) {
+	ln := setupTest(t, "127.0.0.1")
+	defer func() { _ = ln.Close() }()
+
+	// IPv4 header including a TLV vector
+	payload := []byte{192, 168, 0, 1, 192, 168, 0, 11, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(payload[8:], 56324)
+	binary.BigEndian.PutUint16(payload[10:], 5222)
+	payload = append(payload, 0x04, 0x00, 0x01, 0xff) // PP2_TYPE_NOOP
+
+	conn := acceptWithHeader(t, ln, append(v2Header(v2CmdProxy, v2FamilyInet, payload), []byte("hello")...))
+	require.Equal(t, "192.168.0.1:56324", conn.RemoteAddr().String())
+	require.Equal(t, "192.168.0.11:5222", conn.LocalAddr().String())
+	requireRead(t, conn, "hello")
+
+	// IPv6 header
+	payload = make([]byte, 36)
+	copy(payload, net.ParseIP("2001:db8::1"))
+	copy(payload[16:], net.ParseIP("2001:db8::2"))
+	binary.BigEndian.PutUint16(payload[32:], 56324)
+	binary.BigEndian.PutUint16(payload[34:], 5222)
+
+	conn = acceptWithHeader(t, ln, append(v2Header(v2CmdProxy, v2FamilyInet6, payload), []byte("hello")...))
+	require.Equal(t, "[2001:db8::1]:56324", conn.RemoteAddr().String())
+	requireRead(t, conn, "hello")
+
+	// health check
+	conn = acceptWithHeader(t, ln, append(v2Header(v2CmdLocal, 0, nil), []byte("hello")...))
+	require.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+	requireRead(t, conn, "hello")
+
+	// truncated addresses
+	conn = acceptWithHeader(t, ln, v2Header(v2CmdProxy, v2FamilyInet, []byte{192, 168, 0, 1}))
+	_, err := conn.Read(make([]byte, 16))
+	require.Equal(t, ErrInvalidHeader, err)
+}
+
+func TestProxyProto_Trust(t *testing.T) {
+	// untrusted connections are not parsed
+	ln := setupTest(t, "10.0.0.0/8")
+
+	conn := acceptWithHeader(t, ln, []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 5222\r\n"))
+	_, ok := conn.(*Conn)
+	require.False(t, ok)
+	requireRead(t, conn, "PROXY TCP4 192.168.0.1 192.168.0.11 56324 5222\r\n")
+	_ = ln.Close()
+
+	// trusted connections require header
+	ln = setupTest(t, "127.0.0.1")
+	defer func() { _ = ln.Close() }()
+
+	conn = acceptWithHeader(t, ln, []byte("<?xml version='1.0'?>"))
+	_, err := conn.Read(make([]byte, 16))
+	require.Equal(t, ErrMissingHeader, err)
+
+	// header timeout
+	conn = acceptWithHeader(t, ln, []byte("PROXY TCP4"))
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 16))
+	require.NotNil(t, err)
+	require.True(t, time.Since(start) < time.Second*5)
+}
+
+func setupTest(t *testing.T, trusted string) net.Listener {
+	nets, err := ParseTrustedProxies([]string{trusted})
+	require.Nil(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	return NewListener(ln, &Config{TrustedProxies: nets, HeaderTimeout: time.Millisecond * 250})
+}
+
+func acceptWithHeader(t *testing.T, ln net.Listener, b []byte) net.Conn {
+	cli, err := net.Dial("tcp", ln.Addr().String())
+	require.Nil(t, err)
+	_, err = cli.Write(b)
+	require.Nil(t, err)
+
+	conn, err := ln.Accept()
+	require.Nil(t, err)
+	return conn
+}
+
+func requireRead(t *testing.T, conn net.Conn, expected string) {
+	b := make([]byte, len(expected))
+	_, err := io.ReadFull(conn, b)
+	require.Nil(t, err)
+	require.Equal(t, expected, string(b))
+}
+
+func v2Header(cmd, family byte, payload []byte) []byte {
+	hdr := append([]byte{}, v2Signature...)
+	hdr = append(hdr, 0x20|cmd, family<<4|0x1, 0, 0)
+	binary.BigEndian.PutUint16(hdr[14:], uint16(len(payload)))
+	return append(hdr, payload...)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/dantin/cubit/transport/compress"
+	"github.com/dantin/cubit/transport/proxyproto"
 )
 
 const socketBuffSize = 4096
@@ -63,7 +64,8 @@ func (s *socketTransport) SetWriteDeadline(d time.Time) error {
 }
 
 func (s *socketTransport) StartTLS(cfg *tls.Config, asClient bool) {
-	if _, ok := s.conn.(*net.TCPConn); ok {
+	switch s.conn.(type) {
+	case *net.TCPConn, *proxyproto.Conn:
 		if asClient {
 			s.conn = tls.Client(s.conn, cfg)
 		} else {
@@ -9,18 +9,28 @@ import (
 	"github.com/dantin/cubit/transport/compress"
 )
 
-// Type represents a stream transport type (socket).
+// Type represents a stream transport type (socket, websocket, bosh).
 type Type int
 
 const (
 	// Socket represents a socket transport type.
 	Socket Type = iota + 1
+
+	// WebSocket represents a websocket transport type.
+	WebSocket
+
+	// BOSH represents a bosh transport type.
+	BOSH
 )
 
 func (tt Type) String() string {
 	switch tt {
 	case Socket:
 		return "socket"
+	case WebSocket:
+		return "websocket"
+	case BOSH:
+		return "bosh"
 	}
 	return ""
 }
@@ -0,0 +1,559 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dantin/cubit/transport/compress"
+)
+
+const (
+	webSocketGUID        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	webSocketVersion     = "13"
+	webSocketSubprotocol = "xmpp"
+	webSocketBuffSize    = 4096
+
+	// hard limit on incoming message size, regardless of configuration
+	webSocketMaxMessageSize = 16 << 20
+
+	perMessageDeflateExtension = "permessage-deflate"
+)
+
+// websocket frame opcodes
+const (
+	continuationFrame = 0x0
+	textFrame         = 0x1
+	binaryFrame       = 0x2
+	closeFrame        = 0x8
+	pingFrame         = 0x9
+	pongFrame         = 0xa
+)
+
+// websocket close status codes
+const (
+	closeNormal           = 1000
+	closeProtocolError    = 1002
+	closeUnsupportedData  = 1003
+	closeMessageTooBig    = 1009
+	maxControlPayloadSize = 125
+)
+
+// deflate sync flush marker stripped off every compressed message (RFC 7692)
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// keep-alive marker handed to the reader every time a ping or pong frame is received
+var keepAliveMarker = []byte(" ")
+
+// WebSocketConfig represents a WebSocket transport configuration.
+type WebSocketConfig struct {
+	// KeepAlive defines the interval at which ping frames are sent, zero disables them.
+	KeepAlive time.Duration
+
+	// MaxMessageSize defines the maximum size of an incoming message.
+	// Zero, or any value above 16 MiB, means 16 MiB.
+	MaxMessageSize int
+
+	// CompressionLevel defines per-message compression level (RFC 7692).
+	// Compression is only negotiated if a level other than compress.NoCompression is specified.
+	CompressionLevel compress.Level
+}
+
+type webSocketTransport struct {
+	cfg  *WebSocketConfig
+	conn net.Conn
+	br   *bufio.Reader
+
+	rd io.Reader // pending incoming message
+
+	wMu       sync.Mutex
+	bw        *bufio.Writer
+	wBuf      bytes.Buffer // outgoing message, sent on flush
+	closeSent bool
+
+	compressed bool
+	fw         *flate.Writer
+	fwBuf      bytes.Buffer
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewWebSocketTransport upgrades an HTTP request to a WebSocket stream transport (RFC 7395).
+// On failure an error response is written back and the reason is returned.
+func NewWebSocketTransport(w http.ResponseWriter, r *http.Request, cfg *WebSocketConfig) (Transport, error) {
+	if err := validateWebSocketRequest(r); err != nil {
+		w.Header().Set("Sec-WebSocket-Version", webSocketVersion)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not implement http.Hijacker")
+	}
+	compressed := cfg.CompressionLevel != compress.NoCompression && acceptsPerMessageDeflate(r.Header)
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	resp := &strings.Builder{}
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	resp.WriteString("Upgrade: websocket\r\n")
+	resp.WriteString("Connection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n")
+	resp.WriteString("Sec-WebSocket-Protocol: " + webSocketSubprotocol + "\r\n")
+	if compressed {
+		resp.WriteString("Sec-WebSocket-Extensions: " + perMessageDeflateExtension + "; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	resp.WriteString("\r\n")
+
+	if _, err := conn.Write([]byte(resp.String())); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return newWebSocketTransport(conn, rw.Reader, cfg, compressed), nil
+}
+
+func newWebSocketTransport(conn net.Conn, br *bufio.Reader, cfg *WebSocketConfig, compressed bool) *webSocketTransport {
+	wst := &webSocketTransport{
+		cfg:        cfg,
+		conn:       conn,
+		br:         br,
+		bw:         bufio.NewWriterSize(conn, webSocketBuffSize),
+		compressed: compressed,
+		closeCh:    make(chan struct{}),
+	}
+	if cfg.KeepAlive > 0 {
+		go wst.ping()
+	}
+	return wst
+}
+
+func (wst *webSocketTransport) Read(p []byte) (n int, err error) {
+	for {
+		if wst.rd != nil {
+			n, err = wst.rd.Read(p)
+			if err == io.EOF {
+				wst.rd = nil
+				if n == 0 {
+					continue
+				}
+				err = nil
+			}
+			return n, err
+		}
+		msg, err := wst.readMessage()
+		if err != nil {
+			return 0, err
+		}
+		wst.rd = bytes.NewReader(msg)
+	}
+}
+
+func (wst *webSocketTransport) Write(p []byte) (n int, err error) {
+	wst.wMu.Lock()
+	defer wst.wMu.Unlock()
+	return wst.wBuf.Write(p)
+}
+
+func (wst *webSocketTransport) Close() error {
+	var err error
+	wst.closeOnce.Do(func() {
+		close(wst.closeCh)
+		_ = wst.writeClose(closeNormal)
+		err = wst.conn.Close()
+	})
+	return err
+}
+
+func (wst *webSocketTransport) Type() Type {
+	return WebSocket
+}
+
+func (wst *webSocketTransport) WriteString(s string) (int, error) {
+	wst.wMu.Lock()
+	defer wst.wMu.Unlock()
+	return wst.wBuf.WriteString(s)
+}
+
+// Flush sends every pending written byte as a single text message.
+func (wst *webSocketTransport) Flush() error {
+	wst.wMu.Lock()
+	defer wst.wMu.Unlock()
+
+	if wst.wBuf.Len() == 0 {
+		return nil
+	}
+	defer wst.wBuf.Reset()
+
+	if !wst.compressed {
+		return wst.writeFrame(textFrame, false, wst.wBuf.Bytes())
+	}
+	payload, err := wst.deflate(wst.wBuf.Bytes())
+	if err != nil {
+		return err
+	}
+	return wst.writeFrame(textFrame, true, payload)
+}
+
+func (wst *webSocketTransport) SetWriteDeadline(d time.Time) error {
+	return wst.conn.SetWriteDeadline(d)
+}
+
+// StartTLS is a no-op, since WebSocket connections get secured at HTTP level.
+func (wst *webSocketTransport) StartTLS(_ *tls.Config, _ bool) {}
+
+// EnableCompression is a no-op, since WebSocket connections negotiate per-message compression on upgrade.
+func (wst *webSocketTransport) EnableCompression(_ compress.Level) {}
+
+func (wst *webSocketTransport) ChannelBindingBytes(mechanism ChannelBindingMechanism) []byte {
+	if conn, ok := wst.conn.(tlsStateQueryable); ok {
+		switch mechanism {
+		case TLSUnique:
+			st := conn.ConnectionState()
+			return st.TLSUnique
+		default:
+			break
+		}
+	}
+	return nil
+}
+
+func (wst *webSocketTransport) PeerCertificates() []*x509.Certificate {
+	if conn, ok := wst.conn.(tlsStateQueryable); ok {
+		st := conn.ConnectionState()
+		return st.PeerCertificates
+	}
+	return nil
+}
+
+// readMessage reads next incoming data message, replying to control frames on the way.
+// Received ping and pong frames are handed to the reader as a whitespace keep-alive.
+func (wst *webSocketTransport) readMessage() ([]byte, error) {
+	var msg []byte
+	var started, compressed bool
+	for {
+		fin, rsv1, opcode, payload, err := wst.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case pingFrame:
+			wst.wMu.Lock()
+			err = wst.writeFrame(pongFrame, false, payload)
+			wst.wMu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			if !started {
+				return keepAliveMarker, nil
+			}
+			continue
+
+		case pongFrame:
+			if !started {
+				return keepAliveMarker, nil
+			}
+			continue
+
+		case closeFrame:
+			code := closeNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			_ = wst.writeClose(code)
+			return nil, io.EOF
+
+		case textFrame:
+			if started {
+				return nil, wst.fail(closeProtocolError, "websocket: unexpected text frame")
+			}
+			started, compressed = true, rsv1
+
+		case continuationFrame:
+			if !started {
+				return nil, wst.fail(closeProtocolError, "websocket: unexpected continuation frame")
+			}
+
+		case binaryFrame:
+			return nil, wst.fail(closeUnsupportedData, "websocket: binary messages are not supported")
+
+		default:
+			return nil, wst.fail(closeProtocolError, fmt.Sprintf("websocket: unknown opcode: %d", opcode))
+		}
+		msg = append(msg, payload...)
+		if len(msg) > wst.maxMessageSize() {
+			return nil, wst.fail(closeMessageTooBig, "websocket: message too big")
+		}
+		if !fin {
+			continue
+		}
+		if compressed {
+			return wst.inflate(msg)
+		}
+		return msg, nil
+	}
+}
+
+func (wst *webSocketTransport) readFrame() (fin, rsv1 bool, opcode byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(wst.br, hdr[:]); err != nil {
+		return
+	}
+	fin = hdr[0]&0x80 != 0
+	rsv1 = hdr[0]&0x40 != 0
+	opcode = hdr[0] & 0x0f
+
+	if hdr[0]&0x30 != 0 || (rsv1 && (!wst.compressed || opcode != textFrame)) {
+		err = wst.fail(closeProtocolError, "websocket: unexpected reserved bits")
+		return
+	}
+	if hdr[1]&0x80 == 0 {
+		err = wst.fail(closeProtocolError, "websocket: unmasked client frame")
+		return
+	}
+	length := uint64(hdr[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(wst.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(wst.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+		if length&(1<<63) != 0 {
+			err = wst.fail(closeProtocolError, "websocket: invalid payload length")
+			return
+		}
+	}
+	if opcode >= closeFrame && (!fin || length > maxControlPayloadSize) {
+		err = wst.fail(closeProtocolError, "websocket: invalid control frame")
+		return
+	}
+	if length > uint64(wst.maxMessageSize()) {
+		err = wst.fail(closeMessageTooBig, "websocket: message too big")
+		return
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(wst.br, mask[:]); err != nil {
+		return
+	}
+	// payload buffer grows as data arrives, rather than trusting declared length
+	buf := new(bytes.Buffer)
+	if _, err = io.CopyN(buf, wst.br, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	payload = buf.Bytes()
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// writeFrame writes a single final frame. Caller must hold write lock.
+func (wst *webSocketTransport) writeFrame(opcode byte, compressed bool, payload []byte) error {
+	b0 := 0x80 | opcode
+	if compressed {
+		b0 |= 0x40
+	}
+	hdr := []byte{b0, 0}
+	switch l := len(payload); {
+	case l < 126:
+		hdr[1] = byte(l)
+	case l <= 0xffff:
+		hdr[1] = 126
+		hdr = append(hdr, 0, 0)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(l))
+	default:
+		hdr[1] = 127
+		hdr = append(hdr, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(hdr[2:], uint64(l))
+	}
+	if _, err := wst.bw.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := wst.bw.Write(payload); err != nil {
+		return err
+	}
+	return wst.bw.Flush()
+}
+
+func (wst *webSocketTransport) writeClose(code int) error {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+
+	wst.wMu.Lock()
+	defer wst.wMu.Unlock()
+	if wst.closeSent {
+		return nil
+	}
+	wst.closeSent = true
+	return wst.writeFrame(closeFrame, false, payload)
+}
+
+// fail closes the connection with a given status code returning associated error.
+func (wst *webSocketTransport) fail(code int, reason string) error {
+	_ = wst.writeClose(code)
+	return errors.New(reason)
+}
+
+func (wst *webSocketTransport) ping() {
+	tc := time.NewTicker(wst.cfg.KeepAlive)
+	defer tc.Stop()
+	for {
+		select {
+		case <-tc.C:
+			wst.wMu.Lock()
+			err := wst.writeFrame(pingFrame, false, nil)
+			wst.wMu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-wst.closeCh:
+			return
+		}
+	}
+}
+
+// deflate compresses an outgoing message. Caller must hold write lock.
+func (wst *webSocketTransport) deflate(p []byte) ([]byte, error) {
+	wst.fwBuf.Reset()
+	if wst.fw == nil {
+		fw, err := flate.NewWriter(&wst.fwBuf, flateLevel(wst.cfg.CompressionLevel))
+		if err != nil {
+			return nil, err
+		}
+		wst.fw = fw
+	} else {
+		wst.fw.Reset(&wst.fwBuf) // no context takeover
+	}
+	if _, err := wst.fw.Write(p); err != nil {
+		return nil, err
+	}
+	if err := wst.fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(wst.fwBuf.Bytes(), deflateTail), nil
+}
+
+func (wst *webSocketTransport) inflate(p []byte) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(p), bytes.NewReader(deflateTail)))
+	defer func() { _ = fr.Close() }()
+
+	msg, err := ioutil.ReadAll(io.LimitReader(fr, int64(wst.maxMessageSize())+1))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, wst.fail(closeProtocolError, "websocket: invalid compressed message")
+	}
+	if len(msg) > wst.maxMessageSize() {
+		return nil, wst.fail(closeMessageTooBig, "websocket: message too big")
+	}
+	return msg, nil
+}
+
+// maxMessageSize returns the largest incoming message allowed, which is never unlimited.
+func (wst *webSocketTransport) maxMessageSize() int {
+	if wst.cfg.MaxMessageSize > 0 && wst.cfg.MaxMessageSize < webSocketMaxMessageSize {
+		return wst.cfg.MaxMessageSize
+	}
+	return webSocketMaxMessageSize
+}
+
+func flateLevel(level compress.Level) int {
+	switch level {
+	case compress.BestCompression:
+		return flate.BestCompression
+	case compress.SpeedCompression:
+		return flate.BestSpeed
+	}
+	return flate.DefaultCompression
+}
+
+func validateWebSocketRequest(r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errors.New("websocket: method not allowed")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return errors.New("websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != webSocketVersion {
+		return errors.New("websocket: unsupported version")
+	}
+	if len(r.Header.Get("Sec-WebSocket-Key")) == 0 {
+		return errors.New("websocket: missing key")
+	}
+	if !headerContainsToken(r.Header, "Sec-WebSocket-Protocol", webSocketSubprotocol) {
+		return errors.New("websocket: xmpp subprotocol required")
+	}
+	return nil
+}
+
+// acceptsPerMessageDeflate tells whether or not a client offered a permessage-deflate
+// configuration the server is able to honor.
+func acceptsPerMessageDeflate(h http.Header) bool {
+	for _, ext := range h["Sec-Websocket-Extensions"] {
+		for _, offer := range strings.Split(ext, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != perMessageDeflateExtension {
+				continue
+			}
+			accepted := true
+			for _, param := range params[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				switch kv[0] {
+				case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+					break
+				case "server_max_window_bits":
+					// outgoing messages always use a 32K window
+					accepted = accepted && len(kv) == 2 && strings.Trim(kv[1], `"`) == "15"
+				default:
+					accepted = false
+				}
+			}
+			if accepted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func webSocketAccept(key string) string {
+	h := sha1.New()
+	_, _ = h.Write([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
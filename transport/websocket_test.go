@@ -0,0 +1,272 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dantin/cubit/transport/compress"
+	"github.com/stretchr/testify/require"
+)
+
+const testWebSocketKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+func TestWebSocket_Handshake(t *testing.T) {
+	srv, _ := setupWebSocketTest(&WebSocketConfig{})
+	defer srv.Close()
+
+	// missing xmpp subprotocol
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	conn, _, hdr := dialWebSocket(t, srv, "")
+	defer func() { _ = conn.Close() }()
+
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", hdr.Get("Sec-WebSocket-Accept"))
+	require.Equal(t, "xmpp", hdr.Get("Sec-WebSocket-Protocol"))
+	require.Equal(t, "", hdr.Get("Sec-WebSocket-Extensions"))
+}
+
+func TestWebSocket_Framing(t *testing.T) {
+	srv, trCh := setupWebSocketTest(&WebSocketConfig{MaxMessageSize: 64})
+	defer srv.Close()
+
+	conn, br, _ := dialWebSocket(t, srv, "")
+	defer func() { _ = conn.Close() }()
+
+	tr := <-trCh
+	require.Equal(t, WebSocket, tr.Type())
+
+	// every flush results in a single text message
+	_, _ = tr.WriteString(`<open xmlns="urn:ietf:params:xml:ns:xmpp-framing"`)
+	_, _ = tr.Write([]byte(` version="1.0"/>`))
+	require.Nil(t, tr.Flush())
+
+	opcode, compressed, payload := readServerFrame(t, br)
+	require.Equal(t, byte(textFrame), opcode)
+	require.False(t, compressed)
+	require.Equal(t, `<open xmlns="urn:ietf:params:xml:ns:xmpp-framing" version="1.0"/>`, string(payload))
+
+	// fragmented message with an interleaved ping
+	writeClientFrame(t, conn, textFrame, false, false, []byte("<iq "))
+	writeClientFrame(t, conn, pingFrame, true, false, []byte("hb"))
+	writeClientFrame(t, conn, continuationFrame, true, false, []byte("type='get'/>"))
+
+	buf := make([]byte, 128)
+	n, err := tr.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, "<iq type='get'/>", string(buf[:n]))
+
+	opcode, _, payload = readServerFrame(t, br)
+	require.Equal(t, byte(pongFrame), opcode)
+	require.Equal(t, "hb", string(payload))
+
+	// keep alive
+	writeClientFrame(t, conn, pongFrame, true, false, nil)
+	n, err = tr.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, " ", string(buf[:n]))
+
+	// too large message
+	writeClientFrame(t, conn, textFrame, true, false, bytes.Repeat([]byte("a"), 65))
+	_, err = tr.Read(buf)
+	require.NotNil(t, err)
+
+	opcode, _, payload = readServerFrame(t, br)
+	require.Equal(t, byte(closeFrame), opcode)
+	require.Equal(t, uint16(closeMessageTooBig), binary.BigEndian.Uint16(payload))
+}
+
+func TestWebSocket_FrameLength(t *testing.T) {
+	srv, trCh := setupWebSocketTest(&WebSocketConfig{})
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		length uint64
+		code   uint16
+	}{
+		{length: 1 << 40, code: closeMessageTooBig}, // bounded even when no max size is configured
+		{length: 1 << 63, code: closeProtocolError}, // most significant bit must be zero
+	} {
+		conn, br, _ := dialWebSocket(t, srv, "")
+		tr := <-trCh
+
+		frame := []byte{0x80 | textFrame, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(frame[2:], tc.length)
+		_, err := conn.Write(append(frame, 0x1, 0x2, 0x3, 0x4))
+		require.Nil(t, err)
+
+		_, err = tr.Read(make([]byte, 16))
+		require.NotNil(t, err)
+
+		opcode, _, payload := readServerFrame(t, br)
+		require.Equal(t, byte(closeFrame), opcode)
+		require.Equal(t, tc.code, binary.BigEndian.Uint16(payload))
+		_ = conn.Close()
+	}
+}
+
+func TestWebSocket_Close(t *testing.T) {
+	srv, trCh := setupWebSocketTest(&WebSocketConfig{})
+	defer srv.Close()
+
+	conn, br, _ := dialWebSocket(t, srv, "")
+	defer func() { _ = conn.Close() }()
+
+	tr := <-trCh
+
+	// closed by peer
+	writeClientFrame(t, conn, closeFrame, true, false, []byte{0x03, 0xe8})
+	_, err := tr.Read(make([]byte, 16))
+	require.Equal(t, io.EOF, err)
+
+	opcode, _, payload := readServerFrame(t, br)
+	require.Equal(t, byte(closeFrame), opcode)
+	require.Equal(t, uint16(closeNormal), binary.BigEndian.Uint16(payload))
+
+	// close frame is sent only once
+	require.Nil(t, tr.Close())
+	_, err = br.ReadByte()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestWebSocket_Compression(t *testing.T) {
+	srv, trCh := setupWebSocketTest(&WebSocketConfig{CompressionLevel: compress.BestCompression})
+	defer srv.Close()
+
+	// unsupported window size
+	conn, _, hdr := dialWebSocket(t, srv, "permessage-deflate; server_max_window_bits=10")
+	require.Equal(t, "", hdr.Get("Sec-WebSocket-Extensions"))
+	_ = conn.Close()
+	<-trCh
+
+	conn, br, hdr := dialWebSocket(t, srv, "permessage-deflate; client_max_window_bits")
+	defer func() { _ = conn.Close() }()
+	require.True(t, strings.HasPrefix(hdr.Get("Sec-WebSocket-Extensions"), "permessage-deflate"))
+
+	tr := <-trCh
+
+	msg := `<message to="bob@example.org"><body>hi there</body></message>`
+	_, _ = tr.WriteString(msg)
+	require.Nil(t, tr.Flush())
+
+	opcode, compressed, payload := readServerFrame(t, br)
+	require.Equal(t, byte(textFrame), opcode)
+	require.True(t, compressed)
+	require.Equal(t, msg, string(inflateTest(t, payload)))
+
+	writeClientFrame(t, conn, textFrame, true, true, deflateTest(t, []byte(msg)))
+
+	buf := make([]byte, 128)
+	n, err := tr.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, msg, string(buf[:n]))
+}
+
+func setupWebSocketTest(cfg *WebSocketConfig) (*httptest.Server, <-chan Transport) {
+	trCh := make(chan Transport, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tr, err := NewWebSocketTransport(w, r, cfg)
+		if err != nil {
+			return
+		}
+		trCh <- tr
+	}))
+	return srv, trCh
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, extensions string) (net.Conn, *bufio.Reader, http.Header) {
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.Nil(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/xmpp/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+	req.Header.Set("Sec-WebSocket-Protocol", "xmpp")
+	if len(extensions) > 0 {
+		req.Header.Set("Sec-WebSocket-Extensions", extensions)
+	}
+	require.Nil(t, req.Write(conn))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	return conn, br, resp.Header
+}
+
+func writeClientFrame(t *testing.T, w io.Writer, opcode byte, fin, compressed bool, payload []byte) {
+	var b0 byte
+	if fin {
+		b0 |= 0x80
+	}
+	if compressed {
+		b0 |= 0x40
+	}
+	frame := []byte{b0 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	}
+	mask := []byte{0x1, 0x2, 0x3, 0x4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	require.Nil(t, err)
+}
+
+func readServerFrame(t *testing.T, br *bufio.Reader) (opcode byte, compressed bool, payload []byte) {
+	var hdr [2]byte
+	_, err := io.ReadFull(br, hdr[:])
+	require.Nil(t, err)
+	require.True(t, hdr[0]&0x80 != 0) // final
+	require.True(t, hdr[1]&0x80 == 0) // unmasked
+
+	length := int(hdr[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(br, payload)
+	require.Nil(t, err)
+	return hdr[0] & 0x0f, hdr[0]&0x40 != 0, payload
+}
+
+func deflateTest(t *testing.T, p []byte) []byte {
+	buf := new(bytes.Buffer)
+	fw, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	_, _ = fw.Write(p)
+	require.Nil(t, fw.Flush())
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail)
+}
+
+func inflateTest(t *testing.T, p []byte) []byte {
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(p), bytes.NewReader(deflateTail)))
+	b, err := ioutil.ReadAll(fr)
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	return b
+}
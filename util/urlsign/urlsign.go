@@ -0,0 +1,127 @@
+package urlsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	subjectParam   = "sub"
+	expiresParam   = "exp"
+	keyIDParam     = "kid"
+	signatureParam = "sig"
+)
+
+var (
+	// ErrNoKeys will be returned by Sign when signer has no keys.
+	ErrNoKeys = errors.New("urlsign: no signing keys")
+
+	// ErrNotSigned will be returned by Verify when URL carries no signature.
+	ErrNotSigned = errors.New("urlsign: url not signed")
+
+	// ErrUnknownKey will be returned by Verify when URL was signed using an unknown key.
+	ErrUnknownKey = errors.New("urlsign: unknown signing key")
+
+	// ErrInvalidSignature will be returned by Verify when URL signature does not match.
+	ErrInvalidSignature = errors.New("urlsign: invalid signature")
+
+	// ErrExpired will be returned by Verify when URL signature has expired.
+	ErrExpired = errors.New("urlsign: signature expired")
+)
+
+// Key represents a named signing key.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Signer signs and verifies time-limited URLs.
+// First key is used for signing, while any of them is accepted on verification,
+// so that keys can be rotated by prepending new ones and dropping old ones
+// once every URL signed with them has expired.
+type Signer struct {
+	keys []Key
+}
+
+// NewSigner returns a URL signer using the given key set.
+func NewSigner(keys ...Key) *Signer {
+	return &Signer{keys: keys}
+}
+
+// Sign returns rawURL signed for subject until expiresAt.
+func (s *Signer) Sign(rawURL, subject string, expiresAt time.Time) (string, error) {
+	if len(s.keys) == 0 {
+		return "", ErrNoKeys
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	key := s.keys[0]
+
+	q := u.Query()
+	q.Set(subjectParam, subject)
+	q.Set(expiresParam, strconv.FormatInt(expiresAt.Unix(), 10))
+	q.Set(keyIDParam, key.ID)
+	u.RawQuery = q.Encode()
+
+	q.Set(signatureParam, signature(key.Secret, u))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Verify checks rawURL signature at a given time, returning the subject it was signed for.
+func (s *Signer) Verify(rawURL string, now time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	sig := q.Get(signatureParam)
+	if len(sig) == 0 {
+		return "", ErrNotSigned
+	}
+	key, ok := s.key(q.Get(keyIDParam))
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	q.Del(signatureParam)
+	u.RawQuery = q.Encode()
+
+	if !hmac.Equal([]byte(sig), []byte(signature(key.Secret, u))) {
+		return "", ErrInvalidSignature
+	}
+	exp, err := strconv.ParseInt(q.Get(expiresParam), 10, 64)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	if now.Unix() >= exp {
+		return "", ErrExpired
+	}
+	return q.Get(subjectParam), nil
+}
+
+func (s *Signer) key(id string) (Key, bool) {
+	for _, k := range s.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// signature computes the HMAC of u stream path, that is its host, path and
+// sorted query (subject, expiry and key id included).
+func signature(secret []byte, u *url.URL) string {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(u.Host))
+	m.Write([]byte(u.EscapedPath()))
+	m.Write([]byte("?"))
+	m.Write([]byte(u.RawQuery))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,86 @@
+package urlsign
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_SignVerify(t *testing.T) {
+	now := time.Unix(1590000000, 0)
+	s := NewSigner(Key{ID: "k1", Secret: []byte("secret-1")})
+
+	signed, err := s.Sign("srt://127.0.0.1:9001?streamid=camera", "alice@example.org", now.Add(time.Minute))
+	require.Nil(t, err)
+
+	u, _ := url.Parse(signed)
+	require.Equal(t, "127.0.0.1:9001", u.Host)
+	require.Equal(t, "camera", u.Query().Get("streamid"))
+	require.Equal(t, "k1", u.Query().Get("kid"))
+
+	sub, err := s.Verify(signed, now)
+	require.Nil(t, err)
+	require.Equal(t, "alice@example.org", sub)
+
+	_, err = s.Verify(signed, now.Add(time.Minute))
+	require.Equal(t, ErrExpired, err)
+
+	_, err = s.Verify("srt://127.0.0.1:9001", now)
+	require.Equal(t, ErrNotSigned, err)
+
+	_, err = NewSigner().Sign("srt://127.0.0.1:9001", "alice@example.org", now)
+	require.Equal(t, ErrNoKeys, err)
+}
+
+func TestSigner_Tampering(t *testing.T) {
+	now := time.Unix(1590000000, 0)
+	s := NewSigner(Key{ID: "k1", Secret: []byte("secret-1")})
+
+	signed, _ := s.Sign("srt://127.0.0.1:9001?streamid=camera", "alice@example.org", now.Add(time.Minute))
+
+	for _, tamper := range []func(u *url.URL, q url.Values){
+		func(u *url.URL, q url.Values) { u.Host = "127.0.0.1:9002" },
+		func(u *url.URL, q url.Values) { q.Set("streamid", "device") },
+		func(u *url.URL, q url.Values) { q.Set("sub", "bob@example.org") },
+		func(u *url.URL, q url.Values) { q.Set("exp", "1690000000") },
+	} {
+		u, _ := url.Parse(signed)
+		q := u.Query()
+		tamper(u, q)
+		u.RawQuery = q.Encode()
+
+		_, err := s.Verify(u.String(), now)
+		require.Equal(t, ErrInvalidSignature, err)
+	}
+
+	u, _ := url.Parse(signed)
+	q := u.Query()
+	q.Set("kid", "k0")
+	u.RawQuery = q.Encode()
+	_, err := s.Verify(u.String(), now)
+	require.Equal(t, ErrUnknownKey, err)
+}
+
+func TestSigner_KeyRotation(t *testing.T) {
+	now := time.Unix(1590000000, 0)
+	k1 := Key{ID: "k1", Secret: []byte("secret-1")}
+	k2 := Key{ID: "k2", Secret: []byte("secret-2")}
+
+	signed, _ := NewSigner(k1).Sign("srt://127.0.0.1:9001", "alice@example.org", now.Add(time.Minute))
+
+	rotated := NewSigner(k2, k1)
+	_, err := rotated.Verify(signed, now)
+	require.Nil(t, err)
+
+	signed2, _ := rotated.Sign("srt://127.0.0.1:9001", "alice@example.org", now.Add(time.Minute))
+	u, _ := url.Parse(signed2)
+	require.Equal(t, "k2", u.Query().Get("kid"))
+
+	_, err = NewSigner(k2).Verify(signed, now)
+	require.Equal(t, ErrUnknownKey, err)
+
+	_, err = NewSigner(Key{ID: "k1", Secret: []byte("other")}).Verify(signed, now)
+	require.Equal(t, ErrInvalidSignature, err)
+}